@@ -0,0 +1 @@
+ALTER TABLE "transcode_queue" ADD COLUMN "worker_class" text;
\ No newline at end of file
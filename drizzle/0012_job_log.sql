@@ -0,0 +1 @@
+ALTER TABLE "transcode_queue" ADD COLUMN "log" text;
\ No newline at end of file
@@ -0,0 +1,2 @@
+ALTER TABLE "transcode_queue" ADD COLUMN "in_point" real;--> statement-breakpoint
+ALTER TABLE "transcode_queue" ADD COLUMN "out_point" real;
\ No newline at end of file
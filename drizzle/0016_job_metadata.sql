@@ -0,0 +1,2 @@
+ALTER TABLE "transcode_queue" ADD COLUMN "title" text;--> statement-breakpoint
+ALTER TABLE "transcode_queue" ADD COLUMN "language" text;
\ No newline at end of file
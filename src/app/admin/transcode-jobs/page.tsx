@@ -16,6 +16,7 @@ import {
   DialogHeader,
   DialogTitle,
 } from "~/components/ui/dialog";
+import { Progress } from "~/components/ui/progress";
 import {
   Select,
   SelectContent,
@@ -26,8 +27,21 @@ import {
 import { Spinner } from "~/components/ui/spinner";
 import { api } from "~/trpc/react";
 
-type StatusFilter = "all" | "queued" | "running" | "done" | "failed";
-type QueueStatus = "queued" | "running" | "done" | "failed";
+type StatusFilter =
+  | "all"
+  | "queued"
+  | "running"
+  | "done"
+  | "failed"
+  | "dead"
+  | "cancelled";
+type QueueStatus =
+  | "queued"
+  | "running"
+  | "done"
+  | "failed"
+  | "dead"
+  | "cancelled";
 
 export default function TranscodeJobsAdminPage() {
   const [statusFilter, setStatusFilter] = useQueryState<StatusFilter>(
@@ -38,16 +52,27 @@ export default function TranscodeJobsAdminPage() {
       "running",
       "done",
       "failed",
+      "dead",
+      "cancelled",
     ]).withDefault("all"),
   );
   const [changeStatusDialogOpen, setChangeStatusDialogOpen] = useState(false);
   const [selectedJobId, setSelectedJobId] = useState<string | null>(null);
   const [newStatus, setNewStatus] = useState<QueueStatus>("queued");
 
-  const { data: jobs, isLoading } = api.transcodeQueue.list.useQuery({
-    status: statusFilter === "all" ? undefined : statusFilter,
-    limit: 100,
-  });
+  const { data: jobs, isLoading } = api.transcodeQueue.list.useQuery(
+    {
+      status: statusFilter === "all" ? undefined : statusFilter,
+      limit: 100,
+    },
+    // Poll while jobs are running so their progress bars advance
+    {
+      refetchInterval: (query) =>
+        query.state.data?.some((job) => job.status === "running")
+          ? 5000
+          : false,
+    },
+  );
 
   const { data: stats } = api.transcodeQueue.stats.useQuery();
 
@@ -77,6 +102,17 @@ export default function TranscodeJobsAdminPage() {
     },
   });
 
+  const cancelJob = api.transcodeQueue.cancel.useMutation({
+    onSuccess: () => {
+      toast.success("Job cancellation requested");
+      void utils.transcodeQueue.list.invalidate();
+      void utils.transcodeQueue.stats.invalidate();
+    },
+    onError: (error) => {
+      toast.error(error.message);
+    },
+  });
+
   const deleteJob = api.transcodeQueue.delete.useMutation({
     onSuccess: () => {
       toast.success("Job deleted successfully");
@@ -109,6 +145,12 @@ export default function TranscodeJobsAdminPage() {
     retryJob.mutate({ id: jobId });
   };
 
+  const handleCancel = (jobId: string) => {
+    if (confirm("Are you sure you want to cancel this transcode job?")) {
+      cancelJob.mutate({ id: jobId });
+    }
+  };
+
   const handleDelete = (jobId: string) => {
     if (confirm("Are you sure you want to delete this transcode job?")) {
       deleteJob.mutate({ id: jobId });
@@ -127,6 +169,8 @@ export default function TranscodeJobsAdminPage() {
       running: { variant: "default", label: "Running" },
       done: { variant: "secondary", label: "Done" },
       failed: { variant: "destructive", label: "Failed" },
+      dead: { variant: "destructive", label: "Dead" },
+      cancelled: { variant: "outline", label: "Cancelled" },
     };
 
     const statusInfo = variants[status] ?? {
@@ -254,6 +298,12 @@ export default function TranscodeJobsAdminPage() {
           >
             Failed ({stats?.failed ?? 0})
           </Button>
+          <Button
+            onClick={() => setStatusFilter("dead")}
+            variant={statusFilter === "dead" ? "default" : "outline"}
+          >
+            Dead ({stats?.dead ?? 0})
+          </Button>
         </div>
 
         {isLoading ? (
@@ -316,6 +366,17 @@ export default function TranscodeJobsAdminPage() {
                         </td>
                         <td className="px-4 py-3">
                           {getStatusBadge(job.status)}
+                          {job.status === "running" && (
+                            <div className="mt-2 flex items-center gap-2">
+                              <Progress
+                                className="w-24"
+                                value={job.progressPercent}
+                              />
+                              <span className="text-muted-foreground text-xs">
+                                {job.progressPercent}%
+                              </span>
+                            </div>
+                          )}
                           {job.error && (
                             <div className="mt-1 max-w-xs truncate text-destructive text-xs">
                               {job.error}
@@ -374,7 +435,8 @@ export default function TranscodeJobsAdminPage() {
                             >
                               Change Status
                             </Button>
-                            {job.status === "failed" && (
+                            {(job.status === "failed" ||
+                              job.status === "dead") && (
                               <Button
                                 disabled={retryJob.isPending}
                                 onClick={() => handleRetry(job.id)}
@@ -384,6 +446,18 @@ export default function TranscodeJobsAdminPage() {
                                 Retry
                               </Button>
                             )}
+                            {(job.status === "queued" ||
+                              job.status === "running") &&
+                              !job.cancelRequested && (
+                                <Button
+                                  disabled={cancelJob.isPending}
+                                  onClick={() => handleCancel(job.id)}
+                                  size="sm"
+                                  variant="outline"
+                                >
+                                  Cancel
+                                </Button>
+                              )}
                             <Button
                               disabled={deleteJob.isPending}
                               onClick={() => handleDelete(job.id)}
@@ -428,6 +502,8 @@ export default function TranscodeJobsAdminPage() {
                 <SelectItem value="running">Running</SelectItem>
                 <SelectItem value="done">Done</SelectItem>
                 <SelectItem value="failed">Failed</SelectItem>
+                <SelectItem value="dead">Dead</SelectItem>
+                <SelectItem value="cancelled">Cancelled</SelectItem>
               </SelectContent>
             </Select>
           </div>
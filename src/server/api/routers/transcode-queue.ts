@@ -1,5 +1,5 @@
 import { z } from "zod";
-import { desc, eq } from "~/server/db";
+import { and, desc, eq, inArray } from "~/server/db";
 import * as schema from "~/server/db/schema";
 import { adminProcedure, createTRPCRouter } from "../trpc";
 
@@ -14,7 +14,9 @@ export const transcodeQueueRouter = createTRPCRouter({
     .input(
       z
         .object({
-          status: z.enum(["queued", "running", "done", "failed"]).optional(),
+          status: z
+            .enum(["queued", "running", "done", "failed", "dead", "cancelled"])
+            .optional(),
           limit: z.number().min(1).max(100).default(50),
         })
         .optional(),
@@ -61,7 +63,14 @@ export const transcodeQueueRouter = createTRPCRouter({
     .input(
       z.object({
         id: z.string(),
-        status: z.enum(["queued", "running", "done", "failed"]),
+        status: z.enum([
+          "queued",
+          "running",
+          "done",
+          "failed",
+          "dead",
+          "cancelled",
+        ]),
       }),
     )
     .mutation(async ({ ctx, input }) => {
@@ -81,8 +90,13 @@ export const transcodeQueueRouter = createTRPCRouter({
         updates.startedAt = now;
       }
 
-      // Set finishedAt when status changes to done or failed
-      if (input.status === "done" || input.status === "failed") {
+      // Set finishedAt when status changes to a terminal status
+      if (
+        input.status === "done" ||
+        input.status === "failed" ||
+        input.status === "dead" ||
+        input.status === "cancelled"
+      ) {
         updates.finishedAt = now;
       }
 
@@ -142,7 +156,42 @@ export const transcodeQueueRouter = createTRPCRouter({
     }),
 
   /**
-   * Retry a failed job by resetting it to queued
+   * Cancel a job. Queued jobs are cancelled immediately; running jobs are
+   * flagged and stopped by the transcoder, which cleans up partial output.
+   */
+  cancel: adminProcedure
+    .input(
+      z.object({
+        id: z.string(),
+      }),
+    )
+    .mutation(async ({ ctx, input }) => {
+      const now = new Date();
+      await ctx.db
+        .update(schema.transcodeQueue)
+        .set({ cancelRequested: true, updatedAt: now })
+        .where(
+          and(
+            eq(schema.transcodeQueue.id, input.id),
+            inArray(schema.transcodeQueue.status, ["queued", "running"]),
+          ),
+        );
+      // Nothing is working on a queued job, so it can be cancelled right away
+      await ctx.db
+        .update(schema.transcodeQueue)
+        .set({ status: "cancelled", finishedAt: now, updatedAt: now })
+        .where(
+          and(
+            eq(schema.transcodeQueue.id, input.id),
+            eq(schema.transcodeQueue.status, "queued"),
+          ),
+        );
+
+      return { success: true };
+    }),
+
+  /**
+   * Retry a failed or dead job by resetting it to queued with fresh attempts
    */
   retry: adminProcedure
     .input(
@@ -155,6 +204,8 @@ export const transcodeQueueRouter = createTRPCRouter({
         .update(schema.transcodeQueue)
         .set({
           status: "queued",
+          attempts: 0,
+          nextAttemptAt: new Date(),
           error: null,
           startedAt: null,
           finishedAt: null,
@@ -169,7 +220,7 @@ export const transcodeQueueRouter = createTRPCRouter({
    * Get statistics about the transcode queue
    */
   stats: adminProcedure.query(async ({ ctx }) => {
-    const [queued, running, done, failed] = await Promise.all([
+    const [queued, running, done, failed, dead] = await Promise.all([
       ctx.db
         .select({ count: schema.transcodeQueue.id })
         .from(schema.transcodeQueue)
@@ -186,6 +237,10 @@ export const transcodeQueueRouter = createTRPCRouter({
         .select({ count: schema.transcodeQueue.id })
         .from(schema.transcodeQueue)
         .where(eq(schema.transcodeQueue.status, "failed")),
+      ctx.db
+        .select({ count: schema.transcodeQueue.id })
+        .from(schema.transcodeQueue)
+        .where(eq(schema.transcodeQueue.status, "dead")),
     ]);
 
     return {
@@ -193,7 +248,13 @@ export const transcodeQueueRouter = createTRPCRouter({
       running: running.length,
       done: done.length,
       failed: failed.length,
-      total: queued.length + running.length + done.length + failed.length,
+      dead: dead.length,
+      total:
+        queued.length +
+        running.length +
+        done.length +
+        failed.length +
+        dead.length,
     };
   }),
 });
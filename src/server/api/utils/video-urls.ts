@@ -15,7 +15,8 @@ export interface VideoWithUrls {
 }
 
 /**
- * Builds URLs for video assets (HLS, previews, thumbnails) from transcode data
+ * Builds URLs for video assets (HLS, previews, thumbnails). Keys recorded on the
+ * video by the transcoder win; otherwise they are derived from the transcode job.
  */
 export function buildVideoUrls(
   video: Video,
@@ -26,25 +27,33 @@ export function buildVideoUrls(
   const baseUrl = env.S3_FORCE_PATH_STYLE
     ? `${basePath}/${bucket}`
     : `${basePath}`;
-  const hlsSource = transcode
-    ? `${baseUrl}/${transcode.outputPrefix}/master.m3u8`
-    : null;
+  const hlsSource = video.hlsMasterKey
+    ? `${baseUrl}/${video.hlsMasterKey}`
+    : transcode
+      ? `${baseUrl}/${transcode.outputPrefix}/master.m3u8`
+      : null;
 
   const hoverPreviewMp4 = transcode
     ? `${baseUrl}/${transcode.outputPrefix}/hover.mp4`
     : null;
 
-  const hoverPreviewWebm = transcode
-    ? `${baseUrl}/${transcode.outputPrefix}/hover.webm`
-    : null;
+  const hoverPreviewWebm = video.hoverPreviewKey
+    ? `${baseUrl}/${video.hoverPreviewKey}`
+    : transcode
+      ? `${baseUrl}/${transcode.outputPrefix}/hover.webm`
+      : null;
 
-  const thumbnailsVtt = transcode
-    ? `${baseUrl}/${transcode.outputPrefix}/thumbnails.vtt`
-    : null;
+  const thumbnailsVtt = video.thumbnailsVttKey
+    ? `${baseUrl}/${video.thumbnailsVttKey}`
+    : transcode
+      ? `${baseUrl}/${transcode.outputPrefix}/thumbnails.vtt`
+      : null;
 
-  const thumbnail25pct = transcode
-    ? `${baseUrl}/${transcode.outputPrefix}/thumb_25pct.jpg`
-    : null;
+  const thumbnail25pct = video.posterKey
+    ? `${baseUrl}/${video.posterKey}`
+    : transcode
+      ? `${baseUrl}/${transcode.outputPrefix}/thumb_25pct.jpg`
+      : null;
 
   const thumbnailUrl = video.originalThumbnailKey
     ? `${baseUrl}/${video.originalThumbnailKey}`
@@ -1,9 +1,11 @@
 import { relations } from "drizzle-orm";
 import {
+  boolean,
   index,
   integer,
   pgEnum,
   pgTable,
+  real,
   text,
   timestamp,
 } from "drizzle-orm/pg-core";
@@ -14,6 +16,9 @@ export const queueStatusEnum = pgEnum("queue_status", [
   "running",
   "done",
   "failed",
+  // Failed on every allowed attempt; the transcoder no longer retries it
+  "dead",
+  "cancelled",
 ]);
 
 export const processingStatusEnum = pgEnum("processing_status", [
@@ -37,7 +42,24 @@ export const transcodeQueue = pgTable(
     outputPrefix: text("output_prefix").notNull(),
     status: queueStatusEnum("status").notNull().default("queued"),
     attempts: integer("attempts").notNull().default(0),
+    // Higher priority jobs are claimed first; equal priorities stay FIFO
+    priority: integer("priority").notNull().default(0),
+    // Optional trim in seconds into the source; outputs cover only [inPoint, outPoint).
+    // Unset inPoint starts at the beginning, unset outPoint runs to the end.
+    inPoint: real("in_point"),
+    outPoint: real("out_point"),
+    // Parts of a multi-part source (e.g. a recording uploaded in chunks), in play order.
+    // The worker joins them into one video before transcoding; inputKey is unused then.
+    inputKeys: text("input_keys").array(),
+    // Optional title and ISO 639 language (e.g. "eng") written into the outputs' metadata;
+    // unset falls back to the source's own tags
+    title: text("title"),
+    language: text("language"),
+    // Failed jobs are re-queued with backoff and not claimed again before this time
+    nextAttemptAt: timestamp("next_attempt_at").defaultNow().notNull(),
     error: text("error"),
+    // Tail of the worker's log for the last failed attempt (JOB_LOG_CAPTURE)
+    log: text("log"),
     createdAt: timestamp("created_at").defaultNow().notNull(),
     updatedAt: timestamp("updated_at")
       .defaultNow()
@@ -45,6 +67,10 @@ export const transcodeQueue = pgTable(
       .notNull(),
     startedAt: timestamp("started_at"),
     finishedAt: timestamp("finished_at"),
+    // Refreshed by the worker while running; stale heartbeats get the job re-queued
+    heartbeatAt: timestamp("heartbeat_at"),
+    // Set to stop a job; the worker polls it and ends the job as "cancelled"
+    cancelRequested: boolean("cancel_requested").notNull().default(false),
 
     hlsStatus: processingStatusEnum("hls_status").notNull().default("pending"),
     posterStatus: processingStatusEnum("poster_status")
@@ -56,11 +82,21 @@ export const transcodeQueue = pgTable(
     hoverPreviewStatus: processingStatusEnum("hover_preview_status")
       .notNull()
       .default("pending"),
+    // Fingerprint of the source the task statuses above were produced from; a retry
+    // against a changed source resets them and reprocesses everything
+    sourceFingerprint: text("source_fingerprint"),
+    // Overall progress across all tasks (0-100); only reaches 100 once done
+    progressPercent: integer("progress_percent").notNull().default(0),
+
+    // Optional routing tag (e.g. "gpu"); only workers of this class claim the job.
+    // Untagged jobs can be claimed by any worker.
+    workerClass: text("worker_class"),
   },
   (t) => [
     index("transcode_queue_video_idx").on(t.videoId),
     index("transcode_queue_status_idx").on(t.status),
     index("transcode_queue_created_idx").on(t.createdAt),
+    index("transcode_queue_claim_idx").on(t.status, t.priority, t.createdAt),
   ],
 );
 
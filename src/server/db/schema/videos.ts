@@ -4,6 +4,7 @@ import {
   boolean,
   check,
   integer,
+  jsonb,
   pgEnum,
   pgTable,
   text,
@@ -48,6 +49,21 @@ export const video = pgTable("video", {
   durationSeconds: integer("duration_seconds"), // set after probe/transcode
   sizeBytes: bigint("size_bytes", { mode: "number" }),
 
+  // Storage keys of the transcoded artifacts, set by the transcoder on completion
+  hlsMasterKey: text("hls_master_key"), // e.g. hls/<videoId>/master.m3u8
+  posterKey: text("poster_key"),
+  hoverPreviewKey: text("hover_preview_key"),
+  thumbnailsVttKey: text("thumbnails_vtt_key"),
+  // HLS renditions actually encoded (ladder filtered to the source height)
+  renditions: jsonb("renditions").$type<
+    {
+      height: number;
+      videoBitrateKbps: number;
+      audioBitrateKbps: number;
+      codec?: string; // ffmpeg encoder; absent is libx264
+    }[]
+  >(),
+
   // Denormalized view count for performance (can be used to archive videoView table)
   viewCount: integer("view_count").default(0),
 
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+	"transcoder/pkg/queue"
+
+	"github.com/charmbracelet/log"
+)
+
+// runFailed implements `transcoder failed [limit]`: it lists the most recently failed
+// jobs with their last error, for triage before `transcoder requeue`.
+func runFailed(ctx context.Context, sqlDB *sql.DB, args []string) error {
+	limit := 20
+	if len(args) > 1 {
+		return errors.New("usage: transcoder failed [limit]")
+	}
+	if len(args) == 1 {
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n <= 0 {
+			return fmt.Errorf("invalid limit %q", args[0])
+		}
+		limit = n
+	}
+
+	jobs, err := queue.ListFailed(ctx, sqlDB, limit)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tVIDEO\tSTATUS\tATTEMPTS\tFINISHED\tERROR")
+	for _, j := range jobs {
+		finished := "-"
+		if j.FinishedAt != nil {
+			finished = j.FinishedAt.Local().Format(time.DateTime)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\t%s\n", j.ID, j.VideoID, j.Status, j.Attempts, finished, errorSummary(j.Error))
+	}
+	return w.Flush()
+}
+
+// runRequeue implements `transcoder requeue [-reset-attempts] <job id>...`: it puts
+// failed or dead jobs back in the queue. Jobs out of attempts are skipped unless
+// -reset-attempts is given.
+func runRequeue(ctx context.Context, sqlDB *sql.DB, args []string) error {
+	fs := flag.NewFlagSet("requeue", flag.ContinueOnError)
+	reset := fs.Bool("reset-attempts", false, "start the jobs' attempt count over")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return errors.New("usage: transcoder requeue [-reset-attempts] <job id>...")
+	}
+
+	var failed int
+	for _, id := range fs.Args() {
+		err := queue.RequeueFailed(ctx, sqlDB, id, *reset)
+		switch {
+		case err == nil:
+			log.Info("job requeued", "id", id, "reset_attempts", *reset)
+			continue
+		case errors.Is(err, sql.ErrNoRows):
+			log.Error("job not found", "id", id)
+		case errors.Is(err, queue.ErrNoAttemptsLeft):
+			log.Error("job not requeued, pass -reset-attempts to retry it anyway", "id", id, "error", err)
+		default:
+			log.Error("job not requeued", "id", id, "error", err)
+		}
+		failed++
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d jobs not requeued", failed, fs.NArg())
+	}
+	return nil
+}
+
+// errorSummary shortens a job error to its first line for the failed listing.
+func errorSummary(msg string) string {
+	msg, _, _ = strings.Cut(msg, "\n")
+	if len(msg) > 120 {
+		msg = msg[:117] + "..."
+	}
+	return msg
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sync"
+	"time"
+	"transcoder/pkg/config"
+	"transcoder/pkg/storage"
+
+	"github.com/charmbracelet/log"
+	"golang.org/x/sys/unix"
+)
+
+// errInsufficientDisk is returned by processJob when the scratch disk can't take the
+// job's estimated needs on top of what running jobs have reserved. The job is handed
+// back to the queue without counting the attempt, for a worker with room.
+var errInsufficientDisk = errors.New("insufficient scratch disk space")
+
+// diskClaimBackoff is how long a worker stops claiming after handing a job back for lack
+// of scratch space, so it doesn't claim the same job straight back.
+const diskClaimBackoff = 30 * time.Second
+
+const bytesPerGB = 1 << 30
+
+// estimateScratchBytes conservatively estimates the scratch space a job needs: a local
+// copy of the source (none with remote input), a stitched copy of a multi-part source,
+// and factor times the source size per rendition. Renditions are capped below the
+// source's bitrate (see capRenditionBitrates), so a factor of 1 overestimates them.
+func estimateScratchBytes(sourceBytes int64, parts int, remote bool, renditions int, factor float64) int64 {
+	copies := 0.0
+	if !remote {
+		copies++
+	}
+	if parts > 1 {
+		copies++
+	}
+	return int64(float64(sourceBytes) * (copies + factor*float64(renditions)))
+}
+
+// estimateJobScratch sizes the job's source parts in storage and estimates its scratch
+// needs against the full configured ladder, before the probe narrows it down. 0 means
+// no estimate (DISK_ESTIMATE_FACTOR=0), leaving only the TEMP_DIR_MIN_FREE_GB floor.
+func estimateJobScratch(ctx context.Context, s storage.Backend, cfg *config.Config, inputKeys []string, logger *log.Logger) (int64, error) {
+	if cfg.DiskEstimateFactor <= 0 {
+		return 0, nil
+	}
+	var sourceBytes int64
+	for _, key := range inputKeys {
+		n, err := s.ObjectSize(ctx, cfg.Bucket(), key)
+		if err != nil {
+			return 0, fmt.Errorf("size input: %w", err)
+		}
+		sourceBytes += n
+	}
+	renditions := len(addCodecTiers(qualityLadder, cfg.ExtraVideoCodecs))
+	need := estimateScratchBytes(sourceBytes, len(inputKeys), cfg.RemoteInput, renditions, cfg.DiskEstimateFactor)
+	logger.Info("estimated scratch space",
+		"source_gb", fmt.Sprintf("%.2f", float64(sourceBytes)/bytesPerGB),
+		"renditions", renditions,
+		"factor", cfg.DiskEstimateFactor,
+		"estimate_gb", fmt.Sprintf("%.2f", float64(need)/bytesPerGB),
+	)
+	return need, nil
+}
+
+// diskReservations tracks the scratch space running jobs are expected to still write,
+// so a new job is only taken on when the disk fits it on top of theirs; free space
+// alone doesn't show what a job that just started is about to write.
+type diskReservations struct {
+	mu          sync.Mutex
+	jobs        map[string]diskReservation
+	pausedUntil time.Time
+}
+
+type diskReservation struct {
+	dir   string // the job's work dir; what it holds counts against the reservation
+	bytes int64
+}
+
+// unwritten returns how much of the reservation the job's work dir doesn't hold yet.
+func (r diskReservation) unwritten() int64 {
+	if n := r.bytes - dirSize(r.dir); n > 0 {
+		return n
+	}
+	return 0
+}
+
+func newDiskReservations() *diskReservations {
+	return &diskReservations{jobs: make(map[string]diskReservation)}
+}
+
+// CheckFree is checkDiskSpace for claiming: dir must have at least minGB free beyond
+// what running jobs have reserved but not written yet. It also fails during the
+// backoff after a job was handed back (see Pause).
+func (d *diskReservations) CheckFree(dir string, minGB int) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if wait := time.Until(d.pausedUntil); wait > 0 {
+		return fmt.Errorf("claims paused for %s after handing a job back for lack of disk space", wait.Round(time.Second))
+	}
+	avail, _, err := diskSpace(dir)
+	if err != nil {
+		return err
+	}
+	reserved := d.outstanding("")
+	if avail-reserved < int64(minGB)*bytesPerGB {
+		return fmt.Errorf("insufficient disk space: %.2f GB available, %.2f GB of it reserved by running jobs, %d GB required",
+			float64(avail)/bytesPerGB, float64(reserved)/bytesPerGB, minGB)
+	}
+	return nil
+}
+
+// Reserve takes need bytes of dir's disk for jobID until Release, if the disk has them
+// plus the minGB floor beyond the other jobs' outstanding reservations. A job that
+// can't fit right now gets an errInsufficientDisk error; one bigger than the whole
+// disk gets a plain error, as no retry on this kind of worker would fit it either.
+func (d *diskReservations) Reserve(jobID, dir string, need int64, minGB int) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	avail, total, err := diskSpace(dir)
+	if err != nil {
+		return err
+	}
+	floor := int64(minGB) * bytesPerGB
+	if need+floor > total {
+		return fmt.Errorf("job needs an estimated %.2f GB of scratch space plus the %d GB floor, more than the %.2f GB disk holds",
+			float64(need)/bytesPerGB, minGB, float64(total)/bytesPerGB)
+	}
+	reserved := d.outstanding(jobID)
+	if avail-reserved-floor < need {
+		return fmt.Errorf("%w: job needs an estimated %.2f GB, %.2f GB available with %.2f GB reserved by running jobs and a %d GB floor",
+			errInsufficientDisk, float64(need)/bytesPerGB, float64(avail)/bytesPerGB, float64(reserved)/bytesPerGB, minGB)
+	}
+	d.jobs[jobID] = diskReservation{dir: dir, bytes: need}
+	return nil
+}
+
+// Check re-verifies jobID's reservation before a task: the disk must still have room
+// for what the job has yet to write, beyond the other jobs' reservations and the minGB
+// floor. Other jobs may have outgrown their estimates since the job started.
+func (d *diskReservations) Check(jobID string, minGB int) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	r, ok := d.jobs[jobID]
+	if !ok {
+		return nil
+	}
+	avail, _, err := diskSpace(r.dir)
+	if err != nil {
+		return err
+	}
+	remaining := r.unwritten()
+	reserved := d.outstanding(jobID)
+	if avail-reserved-int64(minGB)*bytesPerGB < remaining {
+		return fmt.Errorf("insufficient disk space: job has an estimated %.2f GB left to write, %.2f GB available with %.2f GB reserved by running jobs and a %d GB floor",
+			float64(remaining)/bytesPerGB, float64(avail)/bytesPerGB, float64(reserved)/bytesPerGB, minGB)
+	}
+	return nil
+}
+
+// Release drops jobID's reservation.
+func (d *diskReservations) Release(jobID string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.jobs, jobID)
+}
+
+// Pause makes CheckFree fail for the next wait.
+func (d *diskReservations) Pause(wait time.Duration) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.pausedUntil = time.Now().Add(wait)
+}
+
+// outstanding returns how much the reservations other than except's have yet to
+// write: each one's estimate less what its work dir already holds. Callers hold mu.
+func (d *diskReservations) outstanding(except string) int64 {
+	var total int64
+	for id, r := range d.jobs {
+		if id != except {
+			total += r.unwritten()
+		}
+	}
+	return total
+}
+
+// diskSpace returns the bytes available to unprivileged users and the total size of
+// the filesystem holding path.
+func diskSpace(path string) (avail, total int64, err error) {
+	var stat unix.Statfs_t
+	if err := unix.Statfs(path, &stat); err != nil {
+		return 0, 0, fmt.Errorf("failed to check disk space: %w", err)
+	}
+	return int64(stat.Bavail) * int64(stat.Bsize), int64(stat.Blocks) * int64(stat.Bsize), nil
+}
+
+// dirSize returns the total size of the regular files under dir; unreadable entries
+// count as empty.
+func dirSize(dir string) int64 {
+	var size int64
+	_ = filepath.WalkDir(dir, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.Type().IsRegular() {
+			if info, err := d.Info(); err == nil {
+				size += info.Size()
+			}
+		}
+		return nil
+	})
+	return size
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEstimateScratchBytes(t *testing.T) {
+	const gb = bytesPerGB
+	tests := []struct {
+		name       string
+		source     int64
+		parts      int
+		remote     bool
+		renditions int
+		factor     float64
+		want       int64
+	}{
+		{"local single part", 10 * gb, 1, false, 4, 1, 50 * gb},
+		{"remote single part", 10 * gb, 1, true, 4, 1, 40 * gb},
+		{"local multi-part", 10 * gb, 3, false, 4, 1, 60 * gb},
+		{"remote multi-part", 10 * gb, 3, true, 4, 1, 50 * gb},
+		{"half factor", 10 * gb, 1, false, 4, 0.5, 30 * gb},
+		{"zero factor", 10 * gb, 2, false, 4, 0, 20 * gb},
+		{"no renditions", 10 * gb, 1, true, 0, 1, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := estimateScratchBytes(tt.source, tt.parts, tt.remote, tt.renditions, tt.factor)
+			if got != tt.want {
+				t.Errorf("got %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+// writeFile writes n bytes to a file in dir.
+func writeFile(t *testing.T, dir string, n int) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, "out.bin"), make([]byte, n), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDiskReservations(t *testing.T) {
+	dir := t.TempDir()
+	avail, total, err := diskSpace(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := newDiskReservations()
+
+	if err := d.Reserve("big", dir, total+1, 0); err == nil || errors.Is(err, errInsufficientDisk) {
+		t.Fatalf("reserving more than the disk: got %v, want a plain error", err)
+	}
+
+	// Most of what's available, leaving too little for a second job of the same size
+	need := avail / 2
+	jobDir := filepath.Join(dir, "a")
+	if err := os.Mkdir(jobDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Reserve("a", jobDir, need+need/2, 0); err != nil {
+		t.Fatal(err)
+	}
+	if got := d.outstanding(""); got != need+need/2 {
+		t.Errorf("outstanding = %d, want %d", got, need+need/2)
+	}
+	if got := d.outstanding("a"); got != 0 {
+		t.Errorf("outstanding excluding a = %d, want 0", got)
+	}
+	if err := d.Reserve("b", dir, need, 0); !errors.Is(err, errInsufficientDisk) {
+		t.Fatalf("second reservation: got %v, want errInsufficientDisk", err)
+	}
+	if err := d.Check("a", 0); err != nil {
+		t.Errorf("Check on the only job: %v", err)
+	}
+	if err := d.Check("unknown", 0); err != nil {
+		t.Errorf("Check on a job without a reservation: %v", err)
+	}
+
+	// What the job has written no longer counts as outstanding
+	writeFile(t, jobDir, 4096)
+	if got, want := d.outstanding(""), need+need/2-4096; got != want {
+		t.Errorf("outstanding after writing = %d, want %d", got, want)
+	}
+
+	d.Release("a")
+	if got := d.outstanding(""); got != 0 {
+		t.Errorf("outstanding after Release = %d, want 0", got)
+	}
+	if err := d.Reserve("b", dir, need, 0); err != nil {
+		t.Errorf("reserving after Release: %v", err)
+	}
+}
+
+func TestDiskReservations_Unwritten(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, 1000)
+	if got := (diskReservation{dir: dir, bytes: 1500}).unwritten(); got != 500 {
+		t.Errorf("unwritten = %d, want 500", got)
+	}
+	// A job that outgrew its estimate has nothing left to write
+	if got := (diskReservation{dir: dir, bytes: 500}).unwritten(); got != 0 {
+		t.Errorf("unwritten past the estimate = %d, want 0", got)
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+
+	"github.com/charmbracelet/log"
+)
+
+// drainState is the worker's drain mode, for maintenance: while it is on the main loop
+// claims no new jobs, so the worker finishes what it is running and then idles until it
+// is removed or resumed. It is switched by signals only (SIGUSR1 drains, SIGUSR2
+// resumes); the health server just reports it, so the unauthenticated port can't stop
+// a worker.
+type drainState struct {
+	on atomic.Bool
+}
+
+// Draining reports whether drain mode is on.
+func (d *drainState) Draining() bool {
+	return d.on.Load()
+}
+
+// watchSignals switches drain mode on SIGUSR1 and off on SIGUSR2 until ctx is done.
+func (d *drainState) watchSignals(ctx context.Context) {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGUSR1, syscall.SIGUSR2)
+	defer signal.Stop(ch)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case sig := <-ch:
+			switch sig {
+			case syscall.SIGUSR1:
+				if !d.on.Swap(true) {
+					log.Info("SIGUSR1 received, draining: running jobs finish, no new jobs are claimed")
+				}
+			case syscall.SIGUSR2:
+				if d.on.Swap(false) {
+					log.Info("SIGUSR2 received, drain cancelled, resuming job claims")
+				}
+			}
+		}
+	}
+}
+
+// drainStatus is the GET /drain response. Idle means drained: nothing left running,
+// so the worker can be removed.
+type drainStatus struct {
+	Draining   bool `json:"draining"`
+	ActiveJobs int  `json:"active_jobs"`
+	Idle       bool `json:"idle"`
+}
+
+// newDrainHandler serves GET /drain with the worker's drain state.
+func newDrainHandler(drain *drainState, tracker *JobTracker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		active := len(tracker.GetAll())
+		st := drainStatus{Draining: drain.Draining(), ActiveJobs: active}
+		st.Idle = st.Draining && active == 0
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		if err := json.NewEncoder(w).Encode(st); err != nil {
+			log.Warn("write drain status failed", "error", err)
+		}
+	}
+}
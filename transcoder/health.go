@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// newHealthHandler serves the liveness and readiness probes. /healthz reports the
+// process is up; /readyz additionally requires the worker not to be draining, the
+// database to answer a ping and the scratch directory to have at least minFreeGB free,
+// so a worker that cannot take jobs is pulled from rotation. /queue reports the queue's
+// depth for autoscaling and /drain the worker's drain state.
+func newHealthHandler(sqlDB *sql.DB, scratchDir string, minFreeGB int, drain *drainState, tracker *JobTracker) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/queue", newQueueStatsHandler(sqlDB))
+	mux.Handle("GET /drain", newDrainHandler(drain, tracker))
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "ok")
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		if drain.Draining() {
+			http.Error(w, "draining", http.StatusServiceUnavailable)
+			return
+		}
+		if err := checkReady(r.Context(), sqlDB, scratchDir, minFreeGB); err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprintln(w, "ok")
+	})
+	return mux
+}
+
+// checkReady returns why the worker cannot take jobs, or nil when it can.
+func checkReady(ctx context.Context, sqlDB *sql.DB, scratchDir string, minFreeGB int) error {
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	if err := sqlDB.PingContext(ctx); err != nil {
+		return fmt.Errorf("database unreachable: %w", err)
+	}
+	if err := checkDiskSpace(scratchDir, minFreeGB); err != nil {
+		return err
+	}
+	return nil
+}
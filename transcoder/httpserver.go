@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+// serveHTTP runs an HTTP server on addr until ctx is cancelled, then shuts it down,
+// giving in-flight requests a few seconds to finish. name identifies it in logs.
+// A server that fails to start is logged, not fatal: it must never take the worker down.
+func serveHTTP(ctx context.Context, name, addr string, handler http.Handler) {
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			log.Warn("http server shutdown error", "server", name, "error", err)
+		}
+	}()
+
+	log.Info("http server listening", "server", name, "addr", addr)
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Error("http server failed", "server", name, "addr", addr, "error", err)
+		return
+	}
+	log.Info("http server stopped", "server", name)
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"transcoder/pkg/config"
+	"transcoder/pkg/db"
+	"transcoder/pkg/transcoder"
+
+	"github.com/charmbracelet/log"
+)
+
+// inspectReport is what the inspect subcommand prints: the probed source and the
+// renditions a job for it would encode, with their estimated output sizes.
+type inspectReport struct {
+	Input      string              `json:"input"`
+	Source     SourceSummary       `json:"source"`
+	Renditions []renditionEstimate `json:"renditions"`
+	// Sum of the renditions' estimated sizes: the job's HLS output footprint
+	EstimatedBytes int64 `json:"estimated_bytes"`
+}
+
+// renditionEstimate is a rendition a source would be encoded to and its estimated
+// output size.
+type renditionEstimate struct {
+	db.Rendition
+	EstimatedBytes int64 `json:"estimatedBytes"`
+}
+
+// estimateRenditions dry-runs ladder against a source: the renditions a job would
+// encode, selected the same way (see selectRenditionsFrom), each with its estimated
+// size, its bitrate (video, plus audio when the source has any) over the source's
+// duration. The bitrates are caps, so CRF encodes usually come in smaller; audio is
+// counted as muxed into every rendition.
+func estimateRenditions(info transcoder.VideoInfo, ladder []transcoder.Rendition, cfg *config.Config) []renditionEstimate {
+	renditions := selectRenditionsFrom(info, ladder, cfg)
+	out := make([]renditionEstimate, 0, len(renditions))
+	for i, r := range renditions {
+		kbps := r.VideoBitrateKbps
+		if info.HasAudio {
+			kbps += r.AudioBitrateKbps
+		}
+		out = append(out, renditionEstimate{
+			Rendition:      dbRenditions(renditions[i : i+1])[0],
+			EstimatedBytes: int64(float64(kbps) * 1000 / 8 * info.DurationSec),
+		})
+	}
+	return out
+}
+
+// runInspect implements `transcoder inspect <path>`: it probes a source without
+// enqueuing a job and prints the result, with the ladder it would get, as JSON. path is a local file or, if no such
+// file exists, an object key in the configured bucket, downloaded the same way jobs
+// download their input.
+func runInspect(ctx context.Context, cfg *config.Config, args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: transcoder inspect <local path or object key>")
+	}
+	input := args[0]
+
+	localPath := input
+	if _, err := os.Stat(input); errors.Is(err, os.ErrNotExist) {
+		s, err := newStorageBackend(ctx, cfg)
+		if err != nil {
+			return fmt.Errorf("create storage backend: %w", err)
+		}
+		workDir, err := os.MkdirTemp(cfg.ScratchDir(), "inspect-*")
+		if err != nil {
+			return fmt.Errorf("create temp dir: %w", err)
+		}
+		defer os.RemoveAll(workDir)
+
+		localPath = filepath.Join(workDir, "input"+filepath.Ext(input))
+		log.Info("downloading input file", "bucket", cfg.Bucket(), "key", input)
+		if err := s.DownloadFile(ctx, cfg.Bucket(), input, localPath); err != nil {
+			return fmt.Errorf("download input: %w", err)
+		}
+	} else if err != nil {
+		return err
+	}
+
+	t := transcoder.NewFFmpegTranscoder(cfg.FFmpegPath, cfg.FFprobePath)
+	info, err := t.ProbeVideo(ctx, localPath)
+	if err != nil {
+		return fmt.Errorf("probe video: %w", err)
+	}
+	fi, err := os.Stat(localPath)
+	if err != nil {
+		return err
+	}
+
+	report := inspectReport{
+		Input: input,
+		Source: SourceSummary{
+			Width:       info.Width,
+			Height:      info.Height,
+			DurationSec: info.DurationSec,
+			FrameRate:   info.AvgFrameRate,
+			HasAudio:    info.HasAudio,
+			SizeBytes:   fi.Size(),
+			BitrateKbps: info.BitrateBps / 1000,
+		},
+		Renditions: estimateRenditions(info, qualityLadder, cfg),
+	}
+	for _, r := range report.Renditions {
+		report.EstimatedBytes += r.EstimatedBytes
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(report)
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"sync"
+)
+
+// jobLogMaxBytes bounds the log kept per job. The end of a failed job's log is what
+// explains the failure, so older output is dropped first.
+const jobLogMaxBytes = 64 * 1024
+
+// jobLog is an io.Writer keeping the last maxBytes written to it. Job loggers write to
+// it alongside stderr so a failed job's log can be stored with the job.
+type jobLog struct {
+	mu        sync.Mutex
+	buf       []byte
+	maxBytes  int
+	truncated bool
+}
+
+func newJobLog(maxBytes int) *jobLog {
+	return &jobLog{maxBytes: maxBytes}
+}
+
+func (l *jobLog) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.buf = append(l.buf, p...)
+	// Trim in batches rather than on every write
+	if len(l.buf) > 2*l.maxBytes {
+		l.buf = append(l.buf[:0], l.buf[len(l.buf)-l.maxBytes:]...)
+		l.truncated = true
+	}
+	return len(p), nil
+}
+
+// String returns the captured log, starting at a line boundary when older output was
+// dropped.
+func (l *jobLog) String() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	b := l.buf
+	if len(b) > l.maxBytes {
+		b = b[len(b)-l.maxBytes:]
+		l.truncated = true
+	}
+	if !l.truncated {
+		return string(b)
+	}
+	for i, c := range b {
+		if c == '\n' {
+			b = b[i+1:]
+			break
+		}
+	}
+	return "[earlier output truncated]\n" + string(b)
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"io/fs"
+	"path"
+	"path/filepath"
+	"time"
+
+	"transcoder/pkg/transcoder"
+)
+
+// JobResult is a machine-readable summary of what a job produced. processJob returns
+// it even when the job fails, so a missing artifact can be told apart from the ones
+// that were written.
+type JobResult struct {
+	JobID        string                   `json:"job_id"`
+	VideoID      string                   `json:"video_id"`
+	OutputPrefix string                   `json:"output_prefix"`
+	Source       *SourceSummary           `json:"source,omitempty"`
+	Renditions   []RenditionSummary       `json:"renditions,omitempty"`
+	Loudness     *transcoder.LoudnessInfo `json:"loudness,omitempty"`
+	Subtitles    []string                 `json:"subtitles,omitempty"` // subtitle playlist keys
+	Tasks        []TaskSummary            `json:"tasks,omitempty"`
+	OutputKeys   []string                 `json:"output_keys,omitempty"` // every object under OutputPrefix
+	DurationMs   int64                    `json:"duration_ms"`
+
+	log *jobLog // captured job log; nil unless JOB_LOG_CAPTURE is set
+}
+
+// SourceSummary describes the probed input file.
+type SourceSummary struct {
+	Width       int     `json:"width"`
+	Height      int     `json:"height"`
+	DurationSec float64 `json:"duration_sec"`
+	FrameRate   float64 `json:"frame_rate"`
+	HasAudio    bool    `json:"has_audio"`
+	SizeBytes   int64   `json:"size_bytes"`
+	BitrateKbps int64   `json:"bitrate_kbps,omitempty"` // 0 when unknown
+
+	// Frames unevenly spaced; renditions were converted to a constant FrameRate
+	VariableFrameRate bool `json:"variable_frame_rate,omitempty"`
+}
+
+// RenditionSummary describes one HLS video rendition.
+type RenditionSummary struct {
+	Height           int    `json:"height"`
+	PlaylistKey      string `json:"playlist_key"`
+	Bandwidth        int    `json:"bandwidth"`
+	AverageBandwidth int    `json:"average_bandwidth,omitempty"`
+	Codecs           string `json:"codecs,omitempty"`
+}
+
+// TaskSummary records how one job task went.
+type TaskSummary struct {
+	Name       string `json:"name"`
+	DurationMs int64  `json:"duration_ms"`
+	Error      string `json:"error,omitempty"`
+	Skipped    bool   `json:"skipped,omitempty"` // done by an earlier attempt
+}
+
+func newJobResult(jobID, videoID, outputPrefix string) *JobResult {
+	return &JobResult{JobID: jobID, VideoID: videoID, OutputPrefix: outputPrefix}
+}
+
+// setHLS records the renditions and loudness of a finished HLS transcode.
+func (r *JobResult) setHLS(res transcoder.HLSResult) {
+	r.Loudness = res.Loudness
+	for _, v := range res.Variants {
+		r.Renditions = append(r.Renditions, RenditionSummary{
+			Height:           v.Height,
+			PlaylistKey:      path.Join(r.OutputPrefix, v.Playlist),
+			Bandwidth:        v.Bandwidth,
+			AverageBandwidth: v.AverageBandwidth,
+			Codecs:           v.Codecs,
+		})
+	}
+	for _, sub := range res.Subtitles {
+		r.Subtitles = append(r.Subtitles, path.Join(r.OutputPrefix, sub.Playlist))
+	}
+}
+
+func (r *JobResult) addTask(name string, err error, elapsed time.Duration) {
+	t := TaskSummary{Name: name, DurationMs: elapsed.Milliseconds()}
+	if err != nil {
+		t.Error = err.Error()
+	}
+	r.Tasks = append(r.Tasks, t)
+}
+
+// addSkippedTask records a task left out because an earlier attempt finished it.
+func (r *JobResult) addSkippedTask(name string) {
+	r.Tasks = append(r.Tasks, TaskSummary{Name: name, Skipped: true})
+}
+
+// collectOutputKeys lists the files in the local output directory as the object keys
+// they are synced to.
+func (r *JobResult) collectOutputKeys(outputPath string) {
+	r.OutputKeys = nil
+	_ = filepath.WalkDir(outputPath, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(outputPath, p)
+		if err != nil {
+			return nil
+		}
+		r.OutputKeys = append(r.OutputKeys, path.Join(r.OutputPrefix, filepath.ToSlash(rel)))
+		return nil
+	})
+}
@@ -1,19 +1,28 @@
 package main
 
 import (
+	"bufio"
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"path"
 	"path/filepath"
+	"strconv"
+	"strings"
 
 	"os"
 	"os/signal"
 	"runtime"
+	"slices"
 	"sync"
 	"syscall"
 	"time"
 	"transcoder/pkg/config"
 	"transcoder/pkg/db"
+	"transcoder/pkg/ffmpeg"
 	"transcoder/pkg/queue"
 	"transcoder/pkg/storage"
 	"transcoder/pkg/transcoder"
@@ -39,20 +48,74 @@ func checkDiskSpace(path string, minGB int) error {
 	return nil
 }
 
+// checkMemoryPressure returns an error when memory is past either limit: the worker's
+// own runtime memory above maxWorkerMB, or the host's available memory below
+// minFreeMB. The latter is what catches ffmpeg, which runs outside the Go heap. Zero
+// disables a check.
+func checkMemoryPressure(maxWorkerMB, minFreeMB int) error {
+	if maxWorkerMB > 0 {
+		var m runtime.MemStats
+		runtime.ReadMemStats(&m)
+		// Sys never shrinks; memory returned to the OS is counted in HeapReleased
+		usedMB := (m.Sys - m.HeapReleased) / 1024 / 1024
+		if usedMB > uint64(maxWorkerMB) {
+			return fmt.Errorf("worker memory %d MB above %d MB limit", usedMB, maxWorkerMB)
+		}
+	}
+	if minFreeMB > 0 {
+		availMB, err := availableMemoryMB()
+		if err != nil {
+			return fmt.Errorf("failed to check available memory: %w", err)
+		}
+		if availMB < minFreeMB {
+			return fmt.Errorf("insufficient memory: %d MB available, %d MB required", availMB, minFreeMB)
+		}
+	}
+	return nil
+}
+
+// availableMemoryMB reads MemAvailable from /proc/meminfo (Linux only).
+func availableMemoryMB() (int, error) {
+	f, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		// MemAvailable:   12345678 kB
+		fields := strings.Fields(sc.Text())
+		if len(fields) >= 2 && fields[0] == "MemAvailable:" {
+			kb, err := strconv.Atoi(fields[1])
+			if err != nil {
+				return 0, fmt.Errorf("parse MemAvailable: %w", err)
+			}
+			return kb / 1024, nil
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return 0, err
+	}
+	return 0, errors.New("MemAvailable not found in /proc/meminfo")
+}
+
 // JobStatus tracks the state of a job being processed
 type JobStatus struct {
-	ID                    string
-	VideoID               string
-	StartedAt             time.Time
-	HLSStatus             queue.ProcessingStatus
-	HLSStartedAt          *time.Time
-	PosterStatus          queue.ProcessingStatus
-	PosterStartedAt       *time.Time
-	ScrubberPreviewStatus queue.ProcessingStatus
-	ScrubberStartedAt     *time.Time
-	HoverPreviewStatus    queue.ProcessingStatus
-	HoverStartedAt        *time.Time
-	mu                    sync.Mutex
+	ID              string
+	VideoID         string
+	StartedAt       time.Time
+	Tasks           []TaskStatus // the job's tasks, in the order of the job's task list
+	ProgressPercent int          // overall job progress, see SetTaskProgress
+	mu              sync.Mutex
+}
+
+// TaskStatus tracks the state of one of a job's tasks
+type TaskStatus struct {
+	ID        string
+	Status    queue.ProcessingStatus
+	StartedAt *time.Time
+	weight    float64 // share of the job's progress, see Task.Weight
+	percent   float64 // progress, 0-100
 }
 
 // JobTracker tracks all jobs currently being processed by this transcoder instance
@@ -67,18 +130,22 @@ func NewJobTracker() *JobTracker {
 	}
 }
 
-func (jt *JobTracker) Add(jobID, videoID string) *JobStatus {
+func (jt *JobTracker) Add(jobID, videoID string, tasks []Task) *JobStatus {
 	jt.mu.Lock()
 	defer jt.mu.Unlock()
 	
 	status := &JobStatus{
-		ID:                    jobID,
-		VideoID:               videoID,
-		StartedAt:             time.Now(),
-		HLSStatus:             queue.ProcessingStatusPending,
-		PosterStatus:          queue.ProcessingStatusPending,
-		ScrubberPreviewStatus: queue.ProcessingStatusPending,
-		HoverPreviewStatus:    queue.ProcessingStatusPending,
+		ID:        jobID,
+		VideoID:   videoID,
+		StartedAt: time.Now(),
+		Tasks:     make([]TaskStatus, len(tasks)),
+	}
+	for i, task := range tasks {
+		status.Tasks[i] = TaskStatus{
+			ID:     task.ID(),
+			Status: queue.ProcessingStatusPending,
+			weight: task.Weight(),
+		}
 	}
 	jt.jobs[jobID] = status
 	return status
@@ -101,65 +168,55 @@ func (jt *JobTracker) GetAll() []*JobStatus {
 	return result
 }
 
-func (js *JobStatus) UpdateHLS(status queue.ProcessingStatus) {
+// SetTaskStatus records the status of the job's task at index task.
+func (js *JobStatus) SetTaskStatus(task int, status queue.ProcessingStatus) {
 	js.mu.Lock()
 	defer js.mu.Unlock()
-	js.HLSStatus = status
-	if status == queue.ProcessingStatusProcessing && js.HLSStartedAt == nil {
+	ts := &js.Tasks[task]
+	ts.Status = status
+	if status == queue.ProcessingStatusProcessing && ts.StartedAt == nil {
 		now := time.Now()
-		js.HLSStartedAt = &now
+		ts.StartedAt = &now
 	}
 }
 
-func (js *JobStatus) UpdatePoster(status queue.ProcessingStatus) {
+// SetTaskProgress records a task's progress (0-100) and recomputes the weighted
+// overall percentage. The overall value never decreases and is capped at 99, since
+// only a completed job is 100%. It reports whether the overall value changed.
+func (js *JobStatus) SetTaskProgress(task int, percent float64) (int, bool) {
 	js.mu.Lock()
 	defer js.mu.Unlock()
-	js.PosterStatus = status
-	if status == queue.ProcessingStatusProcessing && js.PosterStartedAt == nil {
-		now := time.Now()
-		js.PosterStartedAt = &now
-	}
-}
 
-func (js *JobStatus) UpdateScrubber(status queue.ProcessingStatus) {
-	js.mu.Lock()
-	defer js.mu.Unlock()
-	js.ScrubberPreviewStatus = status
-	if status == queue.ProcessingStatusProcessing && js.ScrubberStartedAt == nil {
-		now := time.Now()
-		js.ScrubberStartedAt = &now
+	if percent > js.Tasks[task].percent {
+		js.Tasks[task].percent = percent
 	}
-}
-
-func (js *JobStatus) UpdateHover(status queue.ProcessingStatus) {
-	js.mu.Lock()
-	defer js.mu.Unlock()
-	js.HoverPreviewStatus = status
-	if status == queue.ProcessingStatusProcessing && js.HoverStartedAt == nil {
-		now := time.Now()
-		js.HoverStartedAt = &now
+	// Weights are relative, so a job running only some of the tasks still reaches 99
+	overall, totalWeight := 0.0, 0.0
+	for _, ts := range js.Tasks {
+		overall += ts.weight * ts.percent
+		totalWeight += ts.weight
+	}
+	if totalWeight > 0 {
+		overall /= totalWeight
 	}
+	next := min(int(overall), 99)
+	if next <= js.ProgressPercent {
+		return js.ProgressPercent, false
+	}
+	js.ProgressPercent = next
+	return next, true
 }
 
 func (js *JobStatus) GetProgress() (completed, total int) {
 	js.mu.Lock()
 	defer js.mu.Unlock()
 	
-	total = 4
-	completed = 0
-	if js.HLSStatus == queue.ProcessingStatusDone {
-		completed++
-	}
-	if js.PosterStatus == queue.ProcessingStatusDone {
-		completed++
-	}
-	if js.ScrubberPreviewStatus == queue.ProcessingStatusDone {
-		completed++
-	}
-	if js.HoverPreviewStatus == queue.ProcessingStatusDone {
-		completed++
+	for _, ts := range js.Tasks {
+		if ts.Status == queue.ProcessingStatusDone {
+			completed++
+		}
 	}
-	return completed, total
+	return completed, len(js.Tasks)
 }
 
 // logMemoryStats logs current memory usage
@@ -207,17 +264,10 @@ func logJobStatus(tracker *JobTracker, maxParallelTasksPerJob int) {
 	totalWaiting := 0
 	for _, job := range jobs {
 		job.mu.Lock()
-		if job.HLSStatus == queue.ProcessingStatusPending {
-			totalWaiting++
-		}
-		if job.PosterStatus == queue.ProcessingStatusPending {
-			totalWaiting++
-		}
-		if job.ScrubberPreviewStatus == queue.ProcessingStatusPending {
-			totalWaiting++
-		}
-		if job.HoverPreviewStatus == queue.ProcessingStatusPending {
-			totalWaiting++
+		for _, ts := range job.Tasks {
+			if ts.Status == queue.ProcessingStatusPending {
+				totalWaiting++
+			}
 		}
 		job.mu.Unlock()
 	}
@@ -237,23 +287,20 @@ func logJobStatus(tracker *JobTracker, maxParallelTasksPerJob int) {
 		elapsed := time.Since(job.StartedAt).Truncate(time.Second)
 		completed, total := job.GetProgress()
 		
-		job.mu.Lock()
-		hlsStatus := formatTaskStatus(job.HLSStatus, job.HLSStartedAt)
-		posterStatus := formatTaskStatus(job.PosterStatus, job.PosterStartedAt)
-		scrubberStatus := formatTaskStatus(job.ScrubberPreviewStatus, job.ScrubberStartedAt)
-		hoverStatus := formatTaskStatus(job.HoverPreviewStatus, job.HoverStartedAt)
-		job.mu.Unlock()
-		
-		log.Info("active job",
+		keyvals := []any{
 			"job_id", job.ID,
 			"video_id", job.VideoID,
 			"elapsed", elapsed,
 			"progress", fmt.Sprintf("%d/%d", completed, total),
-			"hls", hlsStatus,
-			"poster", posterStatus,
-			"scrubber", scrubberStatus,
-			"hover", hoverStatus,
-		)
+		}
+		job.mu.Lock()
+		keyvals = append(keyvals, "percent", job.ProgressPercent)
+		for _, ts := range job.Tasks {
+			keyvals = append(keyvals, ts.ID, formatTaskStatus(ts.Status, ts.StartedAt))
+		}
+		job.mu.Unlock()
+		
+		log.Info("active job", keyvals...)
 	}
 }
 
@@ -262,6 +309,11 @@ func main() {
 	if err != nil {
 		log.Fatal(err)
 	}
+	logLevel, err := log.ParseLevel(cfg.LogLevel)
+	if err != nil {
+		log.Fatal("invalid LOG_LEVEL", "value", cfg.LogLevel, "error", err)
+	}
+	log.SetLevel(logLevel)
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -280,6 +332,13 @@ func main() {
 		os.Exit(1)
 	}()
 
+	if len(os.Args) > 1 && os.Args[1] == "inspect" {
+		if err := runInspect(ctx, cfg, os.Args[2:]); err != nil {
+			log.Fatal("inspect failed", "error", err)
+		}
+		return
+	}
+
 	sqlDB, err := db.Open(ctx, cfg.DatabaseURL)
 	if err != nil {
 		log.Fatal(err)
@@ -288,44 +347,241 @@ func main() {
 
 	log.Info("database connected", "max_conns", sqlDB.Stats().MaxOpenConnections)
 
-	// Instantiate Syncer and Transcoder
-	s3sync, err := storage.NewS3Syncer(ctx, storage.S3Options{
-		Region:          cfg.S3Region,
-		Endpoint:        cfg.S3Endpoint,
-		UsePathStyle:    cfg.S3ForcePathStyle,
-		AccessKeyID:     cfg.S3AccessKey,
-		SecretAccessKey: cfg.S3SecretKey,
-		// ACL and CacheControl can be configured later via env/config if needed
+	if cfg.RunMigrations {
+		n, err := db.Migrate(ctx, sqlDB)
+		if err != nil {
+			log.Fatal("database migration failed", "error", err)
+		}
+		log.Info("database schema up to date", "applied", n)
+	}
+
+	queue.SetRetryPolicy(queue.RetryPolicy{
+		MaxAttempts: cfg.DBRetryMaxAttempts,
+		BaseDelay:   cfg.DBRetryBaseDelay,
+	})
+	queue.SetAttemptPolicy(queue.AttemptPolicy{
+		MaxAttempts: cfg.JobMaxAttempts,
+		BaseBackoff: cfg.JobRetryBaseDelay,
+		MaxBackoff:  cfg.JobRetryMaxDelay,
 	})
+
+	// Dead-letter triage: list failed jobs or put them back in the queue, then exit
+	if len(os.Args) > 1 && (os.Args[1] == "failed" || os.Args[1] == "requeue") {
+		run := runFailed
+		if os.Args[1] == "requeue" {
+			run = runRequeue
+		}
+		if err := run(ctx, sqlDB, os.Args[2:]); err != nil {
+			log.Fatal("command failed", "command", os.Args[1], "error", err)
+		}
+		return
+	}
+
+	// Instantiate Syncer and Transcoder
+	syncer, err := newStorageBackend(ctx, cfg)
 	if err != nil {
-		log.Fatal("failed to create S3 syncer", "error", err)
+		log.Fatal("failed to create storage backend", "backend", cfg.StorageBackend, "error", err)
 	}
 	ff := transcoder.NewFFmpegTranscoder(cfg.FFmpegPath, cfg.FFprobePath)
 	ff.SetMaxParallelRenditions(cfg.MaxParallelRenditions)
+	ff.SetRenditionThreads(cfg.RenditionThreads)
+	ff.SetSinglePassHLS(cfg.HLSSinglePass)
+	ff.SetSharedAudio(cfg.HLSSharedAudio)
+	ff.SetAudioCopy(cfg.HLSAudioCopy)
+	ff.SetRenditionDirs(cfg.HLSRenditionDirs)
+	ff.SetMetadataPassthrough(cfg.MetadataPassthrough)
+	ff.SetLoudnessNorm(cfg.LoudnessNorm)
+	ff.SetThumbnailMode(transcoder.ThumbnailMode(cfg.ThumbnailMode))
+	ff.SetGenerateBIF(cfg.GenerateBIF)
+	if err := ff.SetThumbnailFormat(transcoder.ImageFormat(cfg.ThumbnailFormat)); err != nil {
+		log.Fatal("invalid THUMBNAIL_FORMAT", "error", err)
+	}
+	ff.SetThumbnailQuality(cfg.ThumbnailQuality)
+	if cfg.ThumbnailSprites {
+		ff.SetThumbnailSprites(cfg.ThumbnailSpriteCols, cfg.ThumbnailSpriteRows)
+	}
+	ff.SetThumbnailMosaic(cfg.ThumbnailMosaic)
+	if transcoder.ImageFormat(cfg.PosterFormat).Ext() == "" {
+		log.Fatal("invalid POSTER_FORMAT", "format", cfg.PosterFormat)
+	}
+	if err := validatePosterCandidates(cfg.PosterCandidates); err != nil {
+		log.Fatal("invalid POSTER_CANDIDATES", "error", err)
+	}
+	ff.SetToneMapMode(transcoder.ToneMapMode(cfg.ToneMapMode))
+	ff.SetPreserve10Bit(cfg.Preserve10Bit)
+	ff.SetProgressivePlaylists(cfg.ProgressiveUpload)
+	ff.SetFastStart(cfg.HLSFastStart)
+	ff.SetSubtitles(transcoder.SubtitleMode(cfg.SubtitleMode), cfg.SubtitleLanguage)
+	ff.SetFFmpegLogDir(cfg.FFmpegLogDir)
+	ff.SetWorkDir(cfg.ScratchDir())
+	ff.SetFFmpegExtraArgs(strings.Fields(cfg.FFmpegExtraArgs))
+	ff.SetProbeTimeout(cfg.ProbeTimeout)
+	ff.SetHLSSegmentSeconds(cfg.HLSSegmentSeconds)
+	ff.SetHLSStartOffset(cfg.HLSStartOffset)
+	if cfg.HLSAspect != "" {
+		aspect, err := transcoder.ParseAspect(cfg.HLSAspect)
+		if err != nil {
+			log.Fatal("invalid HLS_ASPECT", "error", err)
+		}
+		if err := ff.SetAspect(aspect, transcoder.AspectMode(cfg.HLSAspectMode)); err != nil {
+			log.Fatal("invalid HLS_ASPECT_MODE", "error", err)
+		}
+	}
+	if err := ff.SetX264Preset(cfg.X264Preset); err != nil {
+		log.Fatal("invalid X264_PRESET", "error", err)
+	}
+	if cfg.RemoteInput && cfg.StorageBackend != "s3" {
+		log.Fatal("REMOTE_INPUT needs the s3 storage backend", "storage_backend", cfg.StorageBackend)
+	}
+	if cfg.WorkDir != "" {
+		if err := os.MkdirAll(cfg.WorkDir, 0o755); err != nil {
+			log.Fatal("failed to create WORK_DIR", "path", cfg.WorkDir, "error", err)
+		}
+	}
+	jobTasks, err := selectTasks(cfg.JobTasks)
+	if err != nil {
+		log.Fatal("invalid JOB_TASKS", "error", err)
+	}
+	for _, c := range cfg.ExtraVideoCodecs {
+		if !transcoder.VideoCodec(c).Valid() {
+			log.Fatal("invalid EXTRA_VIDEO_CODECS entry", "codec", c)
+		}
+	}
+	if cfg.WatermarkImage != "" {
+		corner, mx, my, err := ffmpeg.ParseWatermarkPosition(cfg.WatermarkPosition)
+		if err != nil {
+			log.Fatal("invalid watermark position", "error", err)
+		}
+		if _, err := os.Stat(cfg.WatermarkImage); err != nil {
+			log.Fatal("watermark image not readable", "path", cfg.WatermarkImage, "error", err)
+		}
+		ff.SetWatermark(&ffmpeg.Watermark{
+			ImagePath: cfg.WatermarkImage,
+			Corner:    corner,
+			MarginX:   mx,
+			MarginY:   my,
+			Opacity:   cfg.WatermarkOpacity,
+			Scale:     cfg.WatermarkScale,
+		})
+	}
 	log.Info("syncer and ffmpeg transcoder initialized",
-		"s3_endpoint", cfg.S3Endpoint,
-		"s3_region", cfg.S3Region,
+		"storage_backend", cfg.StorageBackend,
+		"bucket", cfg.Bucket(),
 		"ffmpeg", cfg.FFmpegPath,
 		"ffprobe", cfg.FFprobePath,
+		"loudness_norm", cfg.LoudnessNorm,
+		"thumbnail_mode", cfg.ThumbnailMode,
+		"smart_poster", cfg.SmartPoster,
+		"poster_format", cfg.PosterFormat,
+		"poster_candidates", cfg.PosterCandidates,
+		"thumbnail_format", cfg.ThumbnailFormat,
+		"thumbnail_quality", cfg.ThumbnailQuality,
+		"thumbnail_sprites", cfg.ThumbnailSprites,
+		"thumbnail_mosaic", cfg.ThumbnailMosaic,
+		"watermark", cfg.WatermarkImage != "",
+		"subtitle_mode", cfg.SubtitleMode,
+		"tonemap_mode", cfg.ToneMapMode,
+		"job_timeout_minutes", cfg.JobTimeoutMinutes,
+		"preserve_10bit", cfg.Preserve10Bit,
+		"progressive_upload", cfg.ProgressiveUpload,
+		"hls_fast_start", cfg.HLSFastStart,
+		"remote_input", cfg.RemoteInput,
+		"hls_segment_seconds", cfg.HLSSegmentSeconds,
+		"hls_aspect", cfg.HLSAspect,
+		"hls_aspect_mode", cfg.HLSAspectMode,
+		"x264_preset", cfg.X264Preset,
+		"extra_video_codecs", cfg.ExtraVideoCodecs,
+		"job_tasks", taskIDs(jobTasks),
+		"ffmpeg_log_dir", cfg.FFmpegLogDir,
+		"ffmpeg_extra_args", cfg.FFmpegExtraArgs,
+		"log_level", cfg.LogLevel,
+		"job_log_capture", cfg.JobLogCapture,
 	)
 
 	// Concurrency limiter - configurable or auto-detect based on CPUs
-	workerLimit := cfg.WorkerConcurrency
-	if workerLimit <= 0 {
-		workerLimit = max(2, runtime.GOMAXPROCS(0))
-	}
-	sem := make(chan struct{}, workerLimit)
+	sem := newLimiter(workerLimit(cfg))
+
+	// SIGHUP reloads the settings that can change without a restart; jobs claimed from
+	// then on use them
+	live := &liveConfig{}
+	live.cfg.Store(cfg)
+	live.ff.Store(ff)
+	hupCh := make(chan os.Signal, 1)
+	signal.Notify(hupCh, syscall.SIGHUP)
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hupCh:
+				log.Info("SIGHUP received, reloading config")
+				if err := live.reload(sem); err != nil {
+					log.Error("config reload failed, keeping the current settings", "error", err)
+				}
+			}
+		}
+	}()
 
 	log.Info("queue worker started",
-		"concurrency", workerLimit,
+		"concurrency", workerLimit(cfg),
 		"max_parallel_tasks_per_job", cfg.MaxParallelTasksPerJob,
 		"max_parallel_renditions", cfg.MaxParallelRenditions,
+		"rendition_threads", cfg.RenditionThreads,
+		"hls_single_pass", cfg.HLSSinglePass,
+		"hls_shared_audio", cfg.HLSSharedAudio,
+		"hls_audio_copy", cfg.HLSAudioCopy,
+		"hls_lowest_source_audio", cfg.HLSLowestSourceAudio,
+		"hls_rendition_dirs", cfg.HLSRenditionDirs,
+		"metadata_passthrough", cfg.MetadataPassthrough,
 		"temp_dir_min_free_gb", cfg.TempDirMinFreeGB,
+		"disk_estimate_factor", cfg.DiskEstimateFactor,
+		"work_dir", cfg.ScratchDir(),
+		"max_worker_memory_mb", cfg.MaxWorkerMemoryMB,
+		"min_free_memory_mb", cfg.MinFreeMemoryMB,
+		"worker_class", cfg.WorkerClass,
+		"stale_job_timeout", cfg.StaleJobTimeout,
+		"metrics_addr", cfg.MetricsAddr,
+		"health_addr", cfg.HealthAddr,
 	)
 
 	// Create job tracker for internal state management
 	jobTracker := NewJobTracker()
 
+	// Scratch space reserved by running jobs, by their estimated needs
+	disk := newDiskReservations()
+
+	// SIGUSR1 drains the worker for maintenance, SIGUSR2 resumes it
+	drain := &drainState{}
+	go drain.watchSignals(ctx)
+
+	// Prometheus metrics; stops with ctx, independently of the job drain below
+	if cfg.MetricsAddr != "" {
+		go serveHTTP(ctx, "metrics", cfg.MetricsAddr, newMetricsHandler(jobTracker, drain))
+	}
+	// Liveness/readiness probes
+	if cfg.HealthAddr != "" {
+		go serveHTTP(ctx, "health", cfg.HealthAddr, newHealthHandler(sqlDB, cfg.ScratchDir(), cfg.TempDirMinFreeGB, drain, jobTracker))
+	}
+
+	// Re-queue jobs orphaned by crashed workers, now and periodically
+	reclaimStaleJobs(ctx, sqlDB, cfg.StaleJobTimeout)
+	reclaimInterval := cfg.StaleJobTimeout / 2
+	if reclaimInterval < time.Minute {
+		reclaimInterval = time.Minute
+	}
+	go func() {
+		ticker := time.NewTicker(reclaimInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				reclaimStaleJobs(ctx, sqlDB, cfg.StaleJobTimeout)
+			}
+		}
+	}()
+
 	// Start periodic memory stats logging
 	go func() {
 		ticker := time.NewTicker(60 * time.Second)
@@ -349,29 +605,32 @@ func main() {
 			case <-ctx.Done():
 				return
 			case <-ticker.C:
-				logJobStatus(jobTracker, cfg.MaxParallelTasksPerJob)
+				logJobStatus(jobTracker, live.cfg.Load().MaxParallelTasksPerJob)
 			}
 		}
 	}()
-	// Track active goroutines for graceful shutdown
-	activeJobs := make(chan struct{}, workerLimit)
+	memoryThrottled := false
+	drainedIdle := false
 	
 	for {
 		select {
 		case <-ctx.Done():
-			log.Info("context cancelled, waiting for active jobs to complete...", "active", len(activeJobs))
+			// Outside of a claim attempt, every held slot is an active job
+			active, changed := sem.Held()
+			log.Info("context cancelled, waiting for active jobs to complete...", "active", active)
 			
 			// Wait for all active jobs to complete
 			ticker := time.NewTicker(5 * time.Second)
 			defer ticker.Stop()
 			
-			for len(activeJobs) > 0 {
+			for active > 0 {
 				select {
 				case <-ticker.C:
-					log.Info("waiting for jobs to complete", "remaining", len(activeJobs))
-				case <-activeJobs:
+					log.Info("waiting for jobs to complete", "remaining", active)
+				case <-changed:
 					// Job completed
 				}
+				active, changed = sem.Held()
 			}
 			
 			log.Info("all jobs completed, exiting cleanly")
@@ -379,9 +638,23 @@ func main() {
 		default:
 		}
 
-		// Pre-flight check: verify disk space BEFORE claiming job
-		// Check temp directory location (os.TempDir returns the system temp directory)
-		if err := checkDiskSpace(os.TempDir(), cfg.TempDirMinFreeGB); err != nil {
+		// Draining: claim nothing and let running jobs finish
+		if drain.Draining() {
+			if active, _ := sem.Held(); active == 0 && !drainedIdle {
+				log.Info("drained, no jobs running, worker is idle")
+				drainedIdle = true
+			}
+			select {
+			case <-time.After(time.Second):
+			case <-ctx.Done():
+			}
+			continue
+		}
+		drainedIdle = false
+
+		// Pre-flight check: verify disk space BEFORE claiming job, on the scratch
+		// directory job work dirs are created in (WORK_DIR or the system temp dir)
+		if err := disk.CheckFree(cfg.ScratchDir(), cfg.TempDirMinFreeGB); err != nil {
 			log.Warn("insufficient disk space, waiting before retry", 
 				"error", err,
 				"min_required_gb", cfg.TempDirMinFreeGB,
@@ -392,17 +665,35 @@ func main() {
 
 		// Acquire semaphore BEFORE claiming job - this ensures we only mark jobs as
 		// "running" when we actually have compute capacity to process them
-		select {
-		case sem <- struct{}{}:
-			// Got semaphore, continue
-		case <-ctx.Done():
+		if !sem.Acquire(ctx) {
 			// Context cancelled while waiting for semaphore
 			continue
 		}
-		
-		job, err := queue.ClaimNext(ctx, sqlDB)
+
+		// Checked after the semaphore, so the reading is fresh even after a long wait
+		// for a slot. The slot is handed back while throttled; running jobs are
+		// unaffected and free memory as they finish.
+		if err := checkMemoryPressure(cfg.MaxWorkerMemoryMB, cfg.MinFreeMemoryMB); err != nil {
+			sem.Release()
+			if !memoryThrottled {
+				active, _ := sem.Held()
+				log.Warn("memory pressure, pausing job claims", "error", err, "active", active)
+				memoryThrottled = true
+			}
+			select {
+			case <-time.After(5 * time.Second):
+			case <-ctx.Done():
+			}
+			continue
+		}
+		if memoryThrottled {
+			log.Info("memory pressure cleared, resuming job claims")
+			memoryThrottled = false
+		}
+
+		job, err := queue.ClaimNext(ctx, sqlDB, cfg.WorkerClass)
 		if err != nil {
-			<-sem // Release semaphore if we didn't get a job
+			sem.Release() // Release semaphore if we didn't get a job
 			if err == sql.ErrNoRows {
 				time.Sleep(1 * time.Second)
 				continue
@@ -413,18 +704,68 @@ func main() {
 		}
 
 		// Job is now marked as running and we have compute capacity + disk space
-		activeJobs <- struct{}{} // Track active job
-		go func(j *queue.TranscodeJob) {
-			defer func() { 
-				<-sem 
-				<-activeJobs // Job completed
-			}()
-			result := processJob(ctx, sqlDB, j, ff, s3sync, cfg, jobTracker)
-			if result != nil {
-				log.Error("job error", "id", j.ID, "error", result)
-				queue.Fail(ctx, sqlDB, j.ID, result.Error())
+		jobsClaimed.Inc()
+		go func(j *queue.TranscodeJob, cfg *config.Config, ff transcoder.Transcoder) {
+			defer sem.Release() // Job completed
+			claimedAt := time.Now()
+			summary, result := processJob(ctx, sqlDB, j, ff, syncer, cfg, jobTasks, jobTracker, disk)
+			logJobResult(summary, result)
+			if result != nil && ctx.Err() != nil && cfg.RequeueOnShutdown {
+				observeJob(outcomeRequeued, time.Since(claimedAt))
+				requeueJob(sqlDB, syncer, cfg, j)
+				return
+			}
+			if errors.Is(result, errInsufficientDisk) {
+				observeJob(outcomeRequeued, time.Since(claimedAt))
+				disk.Pause(diskClaimBackoff)
+				handBackJob(sqlDB, j)
+				return
 			}
-		}(job)
+			switch {
+			case result == nil:
+				observeJob(outcomeCompleted, time.Since(claimedAt))
+			case errors.Is(result, queue.ErrNotOwner):
+				// Reclaimed as stale mid-run; the job's current state belongs to its new run
+				observeJob(outcomeSuperseded, time.Since(claimedAt))
+				log.Warn("job was reclaimed while running, discarding its result", "id", j.ID, "attempt", j.Attempts)
+				return
+			case errors.Is(result, errJobCancelled):
+				observeJob(outcomeCancelled, time.Since(claimedAt))
+			default:
+				observeJob(outcomeFailed, time.Since(claimedAt))
+			}
+			if errors.Is(result, errJobCancelled) {
+				finishCancelledJob(ctx, sqlDB, syncer, cfg, j)
+			} else if result != nil {
+				log.Error("job error", "id", j.ID, "attempt", j.Attempts, "error", result)
+				unreadable := errors.Is(result, transcoder.ErrUnprobeable)
+				var status queue.Status
+				var err error
+				if unreadable {
+					// A bad upload fails the same way on every attempt
+					status, err = queue.StatusDead, rejectSource(ctx, sqlDB, j, result)
+				} else {
+					status, err = queue.Fail(ctx, sqlDB, j.ID, j.Attempts, result.Error())
+				}
+				if errors.Is(err, queue.ErrNotOwner) {
+					log.Warn("job was reclaimed while running, not recording its failure", "id", j.ID, "attempt", j.Attempts)
+					return
+				}
+				switch {
+				case err != nil:
+					log.Error("failed to record job failure", "id", j.ID, "error", err)
+				case unreadable:
+					log.Error("source is not a readable video, job is dead and the video rejected", "id", j.ID, "video_id", j.VideoID)
+				case status == queue.StatusDead:
+					log.Error("job exhausted its attempts and is now dead", "id", j.ID, "attempts", j.Attempts)
+				}
+				if summary.log != nil {
+					if err := queue.AttachLog(ctx, sqlDB, j.ID, summary.log.String()); err != nil {
+						log.Error("failed to store job log", "id", j.ID, "error", err)
+					}
+				}
+			}
+		}(job, live.cfg.Load(), live.ff.Load())
 	}
 }
 
@@ -435,6 +776,8 @@ var qualityLadder = []transcoder.Rendition{
 		Height:           2160, // 4K
 		VideoBitrateKbps: 8000,
 		AudioBitrateKbps: 128,
+		AudioSampleRate:  48000,
+		AudioChannels:    2,
 		CRF:              23,
 		FPS:              30,
 	},
@@ -442,6 +785,8 @@ var qualityLadder = []transcoder.Rendition{
 		Height:           1440, // 2K
 		VideoBitrateKbps: 6000,
 		AudioBitrateKbps: 128,
+		AudioSampleRate:  48000,
+		AudioChannels:    2,
 		CRF:              23,
 		FPS:              30,
 	},
@@ -449,6 +794,8 @@ var qualityLadder = []transcoder.Rendition{
 		Height:           1080, // Full HD
 		VideoBitrateKbps: 4500,
 		AudioBitrateKbps: 128,
+		AudioSampleRate:  48000,
+		AudioChannels:    2,
 		CRF:              23,
 		FPS:              30,
 	},
@@ -456,6 +803,8 @@ var qualityLadder = []transcoder.Rendition{
 		Height:           720, // HD
 		VideoBitrateKbps: 2500,
 		AudioBitrateKbps: 128,
+		AudioSampleRate:  48000,
+		AudioChannels:    2,
 		CRF:              23,
 		FPS:              30,
 	},
@@ -463,6 +812,8 @@ var qualityLadder = []transcoder.Rendition{
 		Height:           480, // SD
 		VideoBitrateKbps: 1200,
 		AudioBitrateKbps: 96,
+		AudioSampleRate:  48000,
+		AudioChannels:    2,
 		CRF:              23,
 		FPS:              30,
 	},
@@ -470,15 +821,23 @@ var qualityLadder = []transcoder.Rendition{
 		Height:           360, // Low
 		VideoBitrateKbps: 800,
 		AudioBitrateKbps: 96,
+		AudioSampleRate:  48000,
+		AudioChannels:    2,
 		CRF:              23,
 		FPS:              30,
+		Profile:          "main", // older phones/TVs reject High
+		Level:            "3.0",
 	},
 	{
 		Height:           240, // Very Low
 		VideoBitrateKbps: 400,
 		AudioBitrateKbps: 64,
+		AudioSampleRate:  48000,
+		AudioChannels:    2,
 		CRF:              23,
 		FPS:              30,
+		Profile:          "baseline", // plays on legacy decoders
+		Level:            "3.0",
 	},
 }
 
@@ -505,63 +864,446 @@ func filterRenditionsBySourceHeight(sourceHeight int, ladder []transcoder.Rendit
 	return filtered
 }
 
-func processJob(
-	ctx context.Context,
-	sqlDB *sql.DB,
-	j *queue.TranscodeJob,
-	t transcoder.Transcoder,
-	s *storage.S3Syncer,
-	cfg *config.Config,
-	tracker *JobTracker,
-) error {
-	start := time.Now()
+// selectRenditions returns the ladder to encode for a source: filtered to prevent
+// upscaling, capped to the source bitrate, with the lowest rendition keeping the
+// source's audio format if configured, plus the configured extra codec tiers.
+func selectRenditions(info transcoder.VideoInfo, cfg *config.Config) []transcoder.Rendition {
+	return selectRenditionsFrom(info, qualityLadder, cfg)
+}
 
-	// Track this job internally
-	jobStatus := tracker.Add(j.ID, j.VideoID)
-	defer tracker.Remove(j.ID)
+// selectRenditionsFrom is selectRenditions for a given ladder.
+func selectRenditionsFrom(info transcoder.VideoInfo, ladder []transcoder.Rendition, cfg *config.Config) []transcoder.Rendition {
+	renditions := filterRenditionsBySourceHeight(info.Height, ladder)
+	renditions = capRenditionBitrates(info.BitrateBps, renditions)
+	if cfg.HLSLowestSourceAudio && len(renditions) > 0 {
+		// The ladder normalizes to 48 kHz stereo; 0 keeps a mono or 44.1 kHz source's
+		low := &renditions[len(renditions)-1]
+		low.AudioSampleRate, low.AudioChannels = 0, 0
+	}
+	return addCodecTiers(renditions, cfg.ExtraVideoCodecs)
+}
 
-	// Create contextual logger with job_id and video_id for traceability
+// dbRenditions converts renditions to the form recorded on the video row.
+func dbRenditions(renditions []transcoder.Rendition) []db.Rendition {
+	out := make([]db.Rendition, 0, len(renditions))
+	for _, r := range renditions {
+		out = append(out, db.Rendition{
+			Height:           r.Height,
+			VideoBitrateKbps: r.VideoBitrateKbps,
+			AudioBitrateKbps: r.AudioBitrateKbps,
+			Codec:            string(r.Codec),
+		})
+	}
+	return out
+}
+
+// sourceBitrateFraction caps every rendition's bitrate relative to the source's.
+// Re-encoding can't add detail, so spending more bits than the source only bloats the
+// output.
+const sourceBitrateFraction = 0.8
+
+// capRenditionBitrates is the bitrate counterpart of filterRenditionsBySourceHeight: it
+// drops renditions whose target bitrate exceeds the source's and caps the rest at
+// sourceBitrateFraction of it. An unknown source bitrate leaves the ladder unchanged.
+func capRenditionBitrates(sourceBps int64, renditions []transcoder.Rendition) []transcoder.Rendition {
+	if sourceBps <= 0 {
+		return renditions
+	}
+	sourceKbps := int(sourceBps / 1000)
+	capKbps := max(int(float64(sourceKbps)*sourceBitrateFraction), 1)
+
+	var filtered []transcoder.Rendition
+	for _, r := range renditions {
+		if r.VideoBitrateKbps > sourceKbps {
+			continue
+		}
+		r.VideoBitrateKbps = min(r.VideoBitrateKbps, capKbps)
+		filtered = append(filtered, r)
+	}
+
+	// Always keep at least one rendition (the lowest quality, capped)
+	if len(filtered) == 0 && len(renditions) > 0 {
+		r := renditions[len(renditions)-1]
+		r.VideoBitrateKbps = min(r.VideoBitrateKbps, capKbps)
+		filtered = []transcoder.Rendition{r}
+	}
+
+	return filtered
+}
+
+// addCodecTiers appends a copy of every rendition for each extra codec, so players
+// that support VP9/AV1 can pick the smaller streams from the same master playlist.
+func addCodecTiers(renditions []transcoder.Rendition, codecs []string) []transcoder.Rendition {
+	out := slices.Clone(renditions)
+	for _, c := range codecs {
+		for _, r := range renditions {
+			r.Codec = transcoder.VideoCodec(c)
+			r.Profile, r.Level = "", "" // H.264 only
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// reclaimStaleJobs re-queues running jobs whose worker stopped sending heartbeats.
+func reclaimStaleJobs(ctx context.Context, sqlDB *sql.DB, olderThan time.Duration) {
+	n, err := queue.ReclaimStale(ctx, sqlDB, olderThan)
+	if err != nil {
+		log.Warn("reclaim stale jobs failed", "error", err)
+		return
+	}
+	if n > 0 {
+		log.Warn("reclaimed stale jobs", "count", n, "older_than", olderThan)
+	}
+}
+
+// errJobCancelled is the cause of a job context cancelled by a cancel request.
+var errJobCancelled = errors.New("job cancelled")
+
+// progressiveUploadInterval is how often HLS output is published while it's encoded.
+const progressiveUploadInterval = 2 * time.Second
+
+// errJobTimeout is the cause of a job context that ran past JobTimeoutMinutes.
+var errJobTimeout = errors.New("job timed out")
+
+// watchForCancel polls the job's cancel flag every interval and cancels the job
+// context with errJobCancelled once it is set.
+func watchForCancel(ctx context.Context, sqlDB *sql.DB, jobID string, interval time.Duration, cancel context.CancelCauseFunc, logger *log.Logger) {
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			requested, err := queue.CancelRequested(ctx, sqlDB, jobID)
+			if err != nil {
+				if ctx.Err() == nil {
+					logger.Warn("cancel check failed", "error", err)
+				}
+				continue
+			}
+			if requested {
+				logger.Warn("cancel requested, stopping job")
+				cancel(errJobCancelled)
+				return
+			}
+		}
+	}
+}
+
+// newStorageBackend creates the object storage client selected by cfg.StorageBackend.
+func newStorageBackend(ctx context.Context, cfg *config.Config) (storage.Backend, error) {
+	switch cfg.StorageBackend {
+	case "gcs":
+		return storage.NewGCSSyncer(ctx, storage.GCSOptions{
+			CredentialsFile: cfg.GCSCredentialsFile,
+			Endpoint:        cfg.GCSEndpoint,
+			Attachments:     cfg.StorageAttachments,
+		})
+	case "fs":
+		return storage.NewFSSyncer(cfg.FSBasePath)
+	}
+	return storage.NewS3Syncer(ctx, storage.S3Options{
+		Region:            cfg.S3Region,
+		Endpoint:          cfg.S3Endpoint,
+		UsePathStyle:      cfg.S3ForcePathStyle,
+		AccessKeyID:       cfg.S3AccessKey,
+		SecretAccessKey:   cfg.S3SecretKey,
+		UploadPartSizeMB:  cfg.S3UploadPartSizeMB,
+		UploadConcurrency: cfg.S3UploadConcurrency,
+		// Per-file upload retries
+		UploadAttempts:       cfg.S3UploadAttempts,
+		UploadRetryBaseDelay: cfg.S3UploadRetryBaseDelay,
+		VerifyUploads:        cfg.S3VerifyUploads,
+		// Ranged parallel source downloads
+		DownloadPartSizeMB:  cfg.S3DownloadPartSizeMB,
+		DownloadConcurrency: cfg.S3DownloadConcurrency,
+		DownloadAttempts:    cfg.S3DownloadAttempts,
+		ACL:                 cfg.S3ACL,
+		DisableACL:          cfg.S3DisableACL,
+		Attachments:         cfg.StorageAttachments,
+		// CacheControl can be configured later via env/config if needed
+	})
+}
+
+// finishCancelledJob marks a cancelled job cancelled and removes any output already
+// uploaded for it. A job reclaimed by another worker meanwhile is left alone, output
+// included, as that output may be the new run's.
+func finishCancelledJob(ctx context.Context, sqlDB *sql.DB, s storage.Backend, cfg *config.Config, j *queue.TranscodeJob) {
 	jobLogger := log.With("job_id", j.ID, "video_id", j.VideoID)
-	jobLogger.Info("========================================")
-	jobLogger.Info("STARTING JOB", "input", j.InputKey, "attempt", j.Attempts)
-	jobLogger.Info("========================================")
+	if err := queue.MarkCancelled(ctx, sqlDB, j.ID, j.Attempts); err != nil {
+		if errors.Is(err, queue.ErrNotOwner) {
+			jobLogger.Warn("job was reclaimed while running, not cancelling it or removing its output", "attempt", j.Attempts)
+			return
+		}
+		jobLogger.Error("failed to mark job cancelled", "error", err)
+		return
+	}
+	n, err := s.DeletePrefix(ctx, cfg.Bucket(), j.OutputPrefix)
+	if err != nil {
+		jobLogger.Error("failed to clean up partial output", "prefix", j.OutputPrefix, "error", err)
+	} else {
+		jobLogger.Info("cleaned up partial output", "prefix", j.OutputPrefix, "objects", n)
+	}
+	jobLogger.Warn("JOB CANCELLED")
+}
 
-	inputPath := j.InputKey
+// rejectSource ends a job whose source can't be read as a video: the job is failed
+// without further attempts, with the reason as its error, and the video is rejected.
+func rejectSource(ctx context.Context, sqlDB *sql.DB, j *queue.TranscodeJob, reason error) error {
+	if err := queue.FailPermanently(ctx, sqlDB, j.ID, j.Attempts, reason.Error()); err != nil {
+		return err
+	}
+	if err := db.UpdateVideoStatus(ctx, sqlDB, j.VideoID, db.VideoStatusRejected); err != nil {
+		return fmt.Errorf("reject video: %w", err)
+	}
+	return nil
+}
+
+// logJobResult logs the job summary as JSON, at error level when the job failed.
+func logJobResult(res *JobResult, err error) {
+	out, mErr := json.Marshal(res)
+	if mErr != nil {
+		log.Warn("failed to encode job result", "job_id", res.JobID, "error", mErr)
+		return
+	}
+	if err != nil {
+		log.Error("job result", "job_id", res.JobID, "summary", string(out))
+		return
+	}
+	log.Info("job result", "job_id", res.JobID, "summary", string(out))
+}
+
+// requeueJob hands a job interrupted by worker shutdown back to the queue, after
+// removing any partial output so the next attempt starts clean. It runs on its own
+// context because the worker's is already cancelled.
+func requeueJob(sqlDB *sql.DB, s storage.Backend, cfg *config.Config, j *queue.TranscodeJob) {
+	jobLogger := log.With("job_id", j.ID, "video_id", j.VideoID)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	n, err := s.DeletePrefix(ctx, cfg.Bucket(), j.OutputPrefix)
+	if err != nil {
+		jobLogger.Error("failed to clean up partial output", "prefix", j.OutputPrefix, "error", err)
+	} else {
+		jobLogger.Info("cleaned up partial output", "prefix", j.OutputPrefix, "objects", n)
+	}
+	status, err := queue.Requeue(ctx, sqlDB, j.ID, j.Attempts)
+	if errors.Is(err, queue.ErrNotOwner) {
+		jobLogger.Warn("job was reclaimed while running, not requeueing it")
+		return
+	}
+	if err != nil {
+		jobLogger.Error("failed to requeue job on shutdown, it will be reclaimed once stale", "error", err)
+		return
+	}
+	jobLogger.Info("job requeued on shutdown", "status", status)
+}
+
+// handBackJob returns a job that didn't fit on this worker's scratch disk to the queue
+// without counting the attempt. Nothing was written for it, so unlike requeueJob there
+// is no output to clean up.
+func handBackJob(sqlDB *sql.DB, j *queue.TranscodeJob) {
+	jobLogger := log.With("job_id", j.ID, "video_id", j.VideoID)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	status, err := queue.Requeue(ctx, sqlDB, j.ID, j.Attempts)
+	if errors.Is(err, queue.ErrNotOwner) {
+		jobLogger.Warn("job was reclaimed while running, not handing it back")
+		return
+	}
+	if err != nil {
+		jobLogger.Error("failed to hand back job, it will be reclaimed once stale", "error", err)
+		return
+	}
+	jobLogger.Warn("job handed back for lack of scratch space, pausing claims", "status", status, "pause", diskClaimBackoff)
+}
+
+// runHeartbeat updates the job's heartbeat every interval until ctx is cancelled. Once
+// the job is no longer running under the claim identified by attempt, it cancels the
+// job with queue.ErrNotOwner: the job now belongs to another run.
+func runHeartbeat(ctx context.Context, sqlDB *sql.DB, jobID string, attempt int, interval time.Duration, cancel context.CancelCauseFunc, logger *log.Logger) {
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := queue.Heartbeat(ctx, sqlDB, jobID, attempt)
+			if errors.Is(err, queue.ErrNotOwner) {
+				logger.Warn("job was reclaimed while running, stopping it")
+				cancel(err)
+				return
+			}
+			if err != nil && ctx.Err() == nil {
+				logger.Warn("heartbeat failed", "error", err)
+			}
+		}
+	}
+}
 
-	// Wait for the input file to exist in S3 (upload might still be in progress)
-	jobLogger.Info("waiting for input file in S3", "bucket", cfg.S3Bucket, "key", inputPath)
+// waitForInput polls storage until key exists, for up to 10 minutes.
+func waitForInput(ctx context.Context, s storage.Backend, bucket, key string, logger *log.Logger) error {
+	logger.Info("waiting for input file in storage", "bucket", bucket, "key", key)
 	maxWait := 10 * time.Minute
 	waitStart := time.Now()
 	for {
-		exists, err := s.FileExists(ctx, cfg.S3Bucket, inputPath)
+		exists, err := s.FileExists(ctx, bucket, key)
 		if err != nil {
-			jobLogger.Error("error checking file existence", "error", err)
+			logger.Error("error checking file existence", "error", err)
 			return err
 		}
 		if exists {
-			jobLogger.Info("input file found in S3", "waited", time.Since(waitStart).Truncate(time.Millisecond))
-			break
+			logger.Info("input file found in storage", "key", key, "waited", time.Since(waitStart).Truncate(time.Millisecond))
+			return nil
 		}
 
 		if time.Since(waitStart) > maxWait {
-			jobLogger.Error("timeout waiting for input file", "max_wait", maxWait)
+			logger.Error("timeout waiting for input file", "key", key, "max_wait", maxWait)
 			return fmt.Errorf("timeout waiting for input file")
 		}
 
 		select {
 		case <-ctx.Done():
-			jobLogger.Warn("context cancelled while waiting for file")
+			logger.Warn("context cancelled while waiting for file")
 			return fmt.Errorf("context cancelled")
 		case <-time.After(1 * time.Second):
 			// Continue polling
 		}
 	}
+}
+
+// fetchInput downloads key to dest and returns dest or, with REMOTE_INPUT, returns a
+// URL valid for ttl that ffmpeg reads key from in place.
+func fetchInput(ctx context.Context, s storage.Backend, cfg *config.Config, key, dest string, ttl time.Duration, logger *log.Logger) (string, error) {
+	if !cfg.RemoteInput {
+		logger.Info("downloading input file", "from", key, "to", dest)
+		if err := s.DownloadFile(ctx, cfg.Bucket(), key, dest); err != nil {
+			logger.Error("download error", "error", err)
+			return "", fmt.Errorf("download input: %w", err)
+		}
+		return dest, nil
+	}
+	presigner, ok := s.(inputPresigner)
+	if !ok {
+		return "", fmt.Errorf("remote input: storage backend %q can't presign URLs", cfg.StorageBackend)
+	}
+	// The URL is never logged, it grants access
+	u, err := presigner.PresignGet(ctx, cfg.Bucket(), key, ttl)
+	if err != nil {
+		logger.Error("presign input error", "error", err)
+		return "", fmt.Errorf("presign input: %w", err)
+	}
+	logger.Info("reading input file from storage without downloading", "key", key, "expires", u.Expires)
+	return u.URL, nil
+}
+
+func processJob(
+	ctx context.Context,
+	sqlDB *sql.DB,
+	j *queue.TranscodeJob,
+	t transcoder.Transcoder,
+	s storage.Backend,
+	cfg *config.Config,
+	tasks []Task,
+	tracker *JobTracker,
+	disk *diskReservations,
+) (res *JobResult, jobErr error) {
+	start := time.Now()
+	res = newJobResult(j.ID, j.VideoID, j.OutputPrefix)
+	defer func() { res.DurationMs = time.Since(start).Milliseconds() }()
+
+	// Track this job internally
+	jobStatus := tracker.Add(j.ID, j.VideoID, tasks)
+	defer tracker.Remove(j.ID)
+
+	// Create contextual logger with job_id and video_id for traceability
+	jobLogger := log.With("job_id", j.ID, "video_id", j.VideoID)
+	if cfg.JobLogCapture {
+		res.log = newJobLog(jobLogMaxBytes)
+		jobLogger.SetOutput(io.MultiWriter(os.Stderr, res.log))
+	}
+	jobLogger.Info("========================================")
+	jobLogger.Info("STARTING JOB", "input", j.InputKey, "attempt", j.Attempts, "priority", j.Priority, "worker_class", j.WorkerClass)
+	jobLogger.Info("========================================")
+
+	// Stop the job (killing ffmpeg and skipping S3 syncs) if it gets cancelled
+	ctx, cancelJob := context.WithCancelCause(ctx)
+	defer cancelJob(nil)
+	go watchForCancel(ctx, sqlDB, j.ID, cfg.CancelPollInterval, cancelJob, jobLogger)
+
+	// Bound the whole job so a pathological source can't hold the worker slot for hours
+	jobTimeout := time.Duration(cfg.JobTimeoutMinutes) * time.Minute
+	if jobTimeout > 0 {
+		var cancelTimeout context.CancelFunc
+		ctx, cancelTimeout = context.WithTimeoutCause(ctx, jobTimeout, errJobTimeout)
+		defer cancelTimeout()
+	}
+	defer func() {
+		if jobErr == nil {
+			return
+		}
+		switch cause := context.Cause(ctx); {
+		case errors.Is(cause, errJobCancelled):
+			jobErr = errJobCancelled
+		case errors.Is(cause, queue.ErrNotOwner):
+			jobErr = cause
+		case errors.Is(cause, errJobTimeout):
+			jobLogger.Error("job exceeded its time limit", "timeout", jobTimeout)
+			jobErr = fmt.Errorf("%w after %s: %w", errJobTimeout, jobTimeout, jobErr)
+		}
+	}()
+
+	// Keep the heartbeat fresh so other workers don't reclaim this job
+	heartbeatCtx, stopHeartbeat := context.WithCancel(ctx)
+	defer stopHeartbeat()
+	go runHeartbeat(heartbeatCtx, sqlDB, j.ID, j.Attempts, cfg.HeartbeatInterval, cancelJob, jobLogger)
+
+	inputPath := j.InputKey
+	inputKeys := j.InputKeys
+	if len(inputKeys) == 0 {
+		inputKeys = []string{inputPath}
+	}
+
+	// Cut the source down to the job's in/out points; every task below sees the trimmed
+	// range as the whole video
+	if j.InPoint != 0 || j.OutPoint != 0 {
+		if j.InPoint < 0 || j.OutPoint < 0 || (j.OutPoint > 0 && j.OutPoint <= j.InPoint) {
+			jobLogger.Error("invalid trim", "in_point", j.InPoint, "out_point", j.OutPoint)
+			return res, fmt.Errorf("invalid trim: in point %.3fs, out point %.3fs", j.InPoint, j.OutPoint)
+		}
+		jobLogger.Info("trimming source", "in_point", j.InPoint, "out_point", j.OutPoint)
+	}
+	// The job's transcoder applies the trim and tags the outputs with the job's metadata
+	t = t.ForJob(transcoder.JobOptions{
+		Trim: transcoder.Trim{
+			In:  time.Duration(j.InPoint * float64(time.Second)),
+			Out: time.Duration(j.OutPoint * float64(time.Second)),
+		},
+		Metadata: transcoder.Metadata{Title: j.Title, Language: j.Language},
+	})
+
+	// Wait for the input to exist in S3 (upload might still be in progress); a
+	// multi-part source waits for every part
+	for _, key := range inputKeys {
+		if err := waitForInput(ctx, s, cfg.Bucket(), key, jobLogger); err != nil {
+			return res, err
+		}
+	}
 
 	// Create a temporary working directory for this job
-	workDir, err := os.MkdirTemp("", "transcode-*")
+	workDir, err := os.MkdirTemp(cfg.ScratchDir(), "transcode-*")
 	if err != nil {
 		jobLogger.Error("create temp dir error", "error", err)
-		return fmt.Errorf("create temp dir: %w", err)
+		return res, fmt.Errorf("create temp dir: %w", err)
 	}
 	defer func() {
 		if rmErr := os.RemoveAll(workDir); rmErr != nil {
@@ -569,45 +1311,99 @@ func processJob(
 		}
 	}()
 
-	// Final disk space verification (already checked before claiming, but verify again
-	// in case space was consumed between initial check and temp dir creation)
-	if err := checkDiskSpace(workDir, cfg.TempDirMinFreeGB); err != nil {
+	// Reserve the scratch space this job is estimated to need; the check before claiming
+	// only knew the TEMP_DIR_MIN_FREE_GB floor, not the job
+	need, err := estimateJobScratch(ctx, s, cfg, inputKeys, jobLogger)
+	if err != nil {
+		jobLogger.Warn("scratch space estimate failed, checking the free space floor only", "error", err)
+		need = 0
+	}
+	if err := disk.Reserve(j.ID, workDir, need, cfg.TempDirMinFreeGB); err != nil {
 		jobLogger.Error("disk space verification failed", "error", err)
-		return err
+		return res, err
+	}
+	defer disk.Release(j.ID)
+	jobLogger.Info("disk space verified", "reserved_gb", fmt.Sprintf("%.2f", float64(need)/bytesPerGB), "min_free_gb", cfg.TempDirMinFreeGB)
+
+	// Download the input file from S3, or let ffmpeg read it in place through a URL
+	// valid for as long as the job may run
+	ttl := remoteInputTTL
+	if jobTimeout > 0 {
+		ttl = jobTimeout + time.Minute
+	}
+	partPaths := make([]string, len(inputKeys))
+	for i, key := range inputKeys {
+		dest := filepath.Join(workDir, "input"+filepath.Ext(key))
+		if len(inputKeys) > 1 {
+			dest = filepath.Join(workDir, fmt.Sprintf("part%03d%s", i+1, filepath.Ext(key)))
+		}
+		partPaths[i], err = fetchInput(ctx, s, cfg, key, dest, ttl, jobLogger)
+		if err != nil {
+			return res, err
+		}
 	}
-	jobLogger.Info("disk space verified", "min_free_gb", cfg.TempDirMinFreeGB)
 
-	// Download the input file from S3
-	localInputPath := filepath.Join(workDir, "input"+filepath.Ext(inputPath))
-	jobLogger.Info("downloading input file", "from", inputPath, "to", localInputPath)
-	if err := s.DownloadFile(ctx, cfg.S3Bucket, inputPath, localInputPath); err != nil {
-		jobLogger.Error("download error", "error", err)
-		return fmt.Errorf("download input: %w", err)
+	// Join a multi-part source into one video; everything below treats it as the source
+	sourcePath := partPaths[0]
+	if len(partPaths) > 1 {
+		sourcePath = filepath.Join(workDir, "stitched.mkv")
+		jobLogger.Info("stitching source parts", "parts", len(partPaths))
+		if err := t.Stitch(ctx, partPaths, sourcePath); err != nil {
+			jobLogger.Error("stitch error", "error", err)
+			return res, fmt.Errorf("stitch parts: %w", err)
+		}
 	}
 
 	// Create output directory within work directory
 	outputPath := filepath.Join(workDir, "output")
 	if err := os.MkdirAll(outputPath, 0755); err != nil {
 		jobLogger.Error("create output dir error", "error", err)
-		return fmt.Errorf("create output dir: %w", err)
+		return res, fmt.Errorf("create output dir: %w", err)
+	}
+
+	// Report every file written, including those of a partially failed job; runs
+	// before the work dir is removed
+	defer res.collectOutputKeys(outputPath)
+
+	// A retry skips the tasks an earlier attempt finished, unless the source changed
+	skip, err := completedTasks(ctx, sqlDB, s, cfg, j, partPaths, tasks, jobLogger)
+	if err != nil {
+		jobLogger.Error("failed to check completed tasks", "error", err)
+		return res, err
 	}
 
 	// Probe source video to determine appropriate quality ladder
-	jobLogger.Info("probing source video", "path", localInputPath)
-	sourceInfo, err := t.ProbeVideo(ctx, localInputPath)
+	jobLogger.Info("probing source video", "key", inputPath, "parts", len(inputKeys))
+	sourceInfo, err := t.ProbeVideo(ctx, sourcePath)
+	if errors.Is(err, transcoder.ErrUnprobeable) {
+		jobLogger.Error("source is not a readable video, rejecting it", "error", err)
+		return res, fmt.Errorf("probe video: %w", err)
+	}
 	if err != nil {
 		jobLogger.Error("probe error", "error", err)
-		return fmt.Errorf("probe video: %w", err)
+		return res, fmt.Errorf("probe video: %w", err)
 	}
 	jobLogger.Info("source video info", "width", sourceInfo.Width, "height", sourceInfo.Height, "duration", sourceInfo.DurationSec)
 
 	// Get file size
 	var fileSizeBytes int64
-	if fileInfo, err := os.Stat(localInputPath); err != nil {
+	if _, size, closer, err := openSource(ctx, sourcePath); err != nil {
 		jobLogger.Warn("failed to get file size", "error", err)
 		fileSizeBytes = 0
 	} else {
-		fileSizeBytes = fileInfo.Size()
+		closer.Close()
+		fileSizeBytes = size
+	}
+
+	res.Source = &SourceSummary{
+		Width:             sourceInfo.Width,
+		Height:            sourceInfo.Height,
+		DurationSec:       sourceInfo.DurationSec,
+		FrameRate:         sourceInfo.AvgFrameRate,
+		VariableFrameRate: sourceInfo.VariableFrameRate,
+		HasAudio:          sourceInfo.HasAudio,
+		SizeBytes:         fileSizeBytes,
+		BitrateKbps:       sourceInfo.BitrateBps / 1000,
 	}
 
 	// Update video metadata (duration and size)
@@ -619,183 +1415,125 @@ func processJob(
 		jobLogger.Info("updated video metadata", "duration_secs", durationSecs, "size_bytes", fileSizeBytes)
 	}
 
-	// Filter renditions to prevent upscaling
-	renditions := filterRenditionsBySourceHeight(sourceInfo.Height, qualityLadder)
-	jobLogger.Info("selected renditions", "count", len(renditions), "heights", getRenditionHeights(renditions))
-
-	// Run transcoding tasks concurrently for faster processing
-	// Use configurable concurrency to control memory usage
-	type taskResult struct {
-		name string
-		err  error
-	}
-
-	const totalTasks = 4 // Total number of tasks: HLS, Hover, Scrubber, Poster
-	results := make(chan taskResult, totalTasks)
-	taskSem := make(chan struct{}, cfg.MaxParallelTasksPerJob) // Semaphore to limit concurrent tasks
-
-	// Task 1: HLS transcoding (usually the longest)
-	go func() {
-		taskSem <- struct{}{} // Acquire inside goroutine so all tasks can spawn
-		defer func() { <-taskSem }()
-		taskStart := time.Now()
-		jobLogger.Info("starting HLS transcode", "renditions", len(renditions))
-		jobStatus.UpdateHLS(queue.ProcessingStatusProcessing)
-		queue.UpdateHLSStatus(ctx, sqlDB, j.ID, queue.ProcessingStatusProcessing)
-
-		// Start a heartbeat goroutine for long-running transcode
-		heartbeatDone := make(chan struct{})
-		go func() {
-			ticker := time.NewTicker(30 * time.Second)
-			defer ticker.Stop()
-			for {
-				select {
-				case <-heartbeatDone:
-					return
-				case <-ticker.C:
-					elapsed := time.Since(taskStart).Truncate(time.Second)
-					jobLogger.Info("HLS transcode in progress", "elapsed", elapsed, "renditions", len(renditions))
-				}
-			}
-		}()
-
-		err := t.TranscodeHLS(ctx, localInputPath, outputPath, renditions)
-		close(heartbeatDone)
-
+	// Extract embedded chapter markers (cheap, so done inline before the heavy tasks)
+	if cfg.GenerateChapters {
+		err := t.GenerateChaptersVTT(ctx, sourcePath,
+			filepath.Join(outputPath, "chapters.vtt"),
+			filepath.Join(outputPath, "chapters.json"),
+		)
 		if err != nil {
-			jobLogger.Error("HLS transcode FAILED - job will fail", "error", err, "duration", time.Since(taskStart).Truncate(time.Millisecond))
-			jobStatus.UpdateHLS(queue.ProcessingStatusFailed)
-			queue.UpdateHLSStatus(ctx, sqlDB, j.ID, queue.ProcessingStatusFailed)
-			results <- taskResult{"HLS transcode", err}
-			return
+			jobLogger.Warn("failed to generate chapters", "error", err)
+			// Continue anyway, chapters are optional metadata
 		}
+	}
 
-		jobLogger.Info("HLS syncing directory")
-		s.SyncDirectory(ctx, outputPath, cfg.S3Bucket, j.OutputPrefix)
-		jobLogger.Info("HLS syncing directory complete")
-		
-		jobLogger.Info("HLS transcode complete", "duration", time.Since(taskStart).Truncate(time.Millisecond))
-		jobStatus.UpdateHLS(queue.ProcessingStatusDone)
-		queue.UpdateHLSStatus(ctx, sqlDB, j.ID, queue.ProcessingStatusDone)
-
-		results <- taskResult{"HLS transcode", nil}
-	}()
-
-	// Task 2: Hover preview generation
-	go func() {
-		taskSem <- struct{}{} // Acquire inside goroutine so all tasks can spawn
-		defer func() { <-taskSem }()
-		taskStart := time.Now()
-		jobLogger.Info("starting hover preview generation")
-		jobStatus.UpdateHover(queue.ProcessingStatusProcessing)
-		queue.UpdateHoverPreviewStatus(ctx, sqlDB, j.ID, queue.ProcessingStatusProcessing)
-		err := t.GenerateHoverPreview(
-			ctx, localInputPath,
-			filepath.Join(outputPath, "hover.webm"), filepath.Join(outputPath, "hover.mp4"),
-			5*time.Second,
-			720, 24,
+	// Downloadable audio track (cheap next to video encoding, so done inline too)
+	if cfg.GenerateAudio {
+		format := transcoder.AudioFormat(cfg.AudioFormat)
+		err := t.ExtractAudio(ctx, sourcePath,
+			filepath.Join(outputPath, "audio"+format.Ext()),
+			format, cfg.AudioBitrateKbps,
 		)
-
-		if err != nil {
-			jobLogger.Error("hover preview FAILED - job will fail", "error", err, "duration", time.Since(taskStart).Truncate(time.Millisecond))
-			jobStatus.UpdateHover(queue.ProcessingStatusFailed)
-			queue.UpdateHoverPreviewStatus(ctx, sqlDB, j.ID, queue.ProcessingStatusFailed)
-			results <- taskResult{"hover preview", err}
-			return
+		if errors.Is(err, transcoder.ErrNoAudio) {
+			jobLogger.Info("source has no audio, skipping audio extraction")
+		} else if err != nil {
+			jobLogger.Warn("failed to extract audio", "error", err)
+			// Continue anyway, the audio download is optional
 		}
+	}
 
-		jobLogger.Info("hover preview syncing directory")
-		s.SyncDirectory(ctx, outputPath, cfg.S3Bucket, j.OutputPrefix)
-		jobLogger.Info("hover preview syncing directory complete")
-		
-		jobLogger.Info("hover preview complete", "duration", time.Since(taskStart).Truncate(time.Millisecond))
-		jobStatus.UpdateHover(queue.ProcessingStatusDone)
-		queue.UpdateHoverPreviewStatus(ctx, sqlDB, j.ID, queue.ProcessingStatusDone)
-
-		results <- taskResult{"hover preview", nil}
-	}()
-
-	// Task 3: Thumbnail and VTT generation
-	go func() {
-		taskSem <- struct{}{} // Acquire inside goroutine so all tasks can spawn
-		defer func() { <-taskSem }()
-		taskStart := time.Now()
-		jobLogger.Info("starting thumbnail generation")
-		jobStatus.UpdateScrubber(queue.ProcessingStatusProcessing)
-		queue.UpdateScrubberPreviewStatus(ctx, sqlDB, j.ID, queue.ProcessingStatusProcessing)
-		thumbsDir := filepath.Join(outputPath, "thumbnails")
-		err := t.GenerateThumbnailsAndVTT(
-			ctx, localInputPath,
-			thumbsDir,
-			filepath.Join(outputPath, "thumbnails.vtt"),
-			100, // Thumbnail height in pixels
-			100, // Maximum number of thumbnails (will be less for shorter videos)
-		)
+	renditions := selectRenditions(sourceInfo, cfg)
+	jobLogger.Info("selected renditions",
+		"count", len(renditions),
+		"heights", getRenditionHeights(renditions),
+		"source_kbps", sourceInfo.BitrateBps/1000,
+	)
+	if err := db.UpdateVideoRenditions(ctx, sqlDB, j.VideoID, dbRenditions(renditions)); err != nil {
+		jobLogger.Error("failed to record selected renditions", "error", err)
+		// Continue anyway, don't fail the job for this
+	}
 
-		if err != nil {
-			jobLogger.Error("thumbnails and VTT FAILED - job will fail", "error", err, "duration", time.Since(taskStart).Truncate(time.Millisecond))
-			jobStatus.UpdateScrubber(queue.ProcessingStatusFailed)
-			queue.UpdateScrubberPreviewStatus(ctx, sqlDB, j.ID, queue.ProcessingStatusFailed)
-			results <- taskResult{"thumbnails and VTT", err}
-			return
+	// Feed per-task progress into the job's overall percentage
+	reportTaskProgress := func(task int, percent float64) {
+		if overall, changed := jobStatus.SetTaskProgress(task, percent); changed {
+			if err := queue.UpdateProgress(ctx, sqlDB, j.ID, j.Attempts, overall); err != nil && ctx.Err() == nil {
+				jobLogger.Warn("failed to update progress", "error", err)
+			}
 		}
+	}
 
-		jobLogger.Info("thumbnails and VTT syncing directory")
-		s.SyncDirectory(ctx, outputPath, cfg.S3Bucket, j.OutputPrefix)
-		jobLogger.Info("thumbnails and VTT syncing directory complete")
-		
-		jobLogger.Info("thumbnails and VTT complete", "duration", time.Since(taskStart).Truncate(time.Millisecond))
-		jobStatus.UpdateScrubber(queue.ProcessingStatusDone)
-		queue.UpdateScrubberPreviewStatus(ctx, sqlDB, j.ID, queue.ProcessingStatusDone)
-
-		results <- taskResult{"thumbnails and VTT", nil}
-	}()
+	// Run transcoding tasks concurrently for faster processing
+	// Use configurable concurrency to control memory usage
+	type taskResult struct {
+		name    string
+		err     error
+		elapsed time.Duration
+	}
+	env := &taskEnv{
+		job:        j,
+		cfg:        cfg,
+		t:          t,
+		s:          s,
+		inputPath:  sourcePath,
+		outputPath: outputPath,
+		renditions: renditions,
+		res:        res,
+		logger:     jobLogger,
+	}
+	results := make(chan taskResult, len(tasks))
+	taskSem := make(chan struct{}, cfg.MaxParallelTasksPerJob) // Semaphore to limit concurrent tasks
 
-	// Generate a thumbnail at 25% of the video's duration
-	go func() {
-		taskSem <- struct{}{} // Acquire inside goroutine so all tasks can spawn
-		defer func() { <-taskSem }()
-		taskStart := time.Now()
-		jobLogger.Info("starting 25pct thumbnail generation")
-		jobStatus.UpdatePoster(queue.ProcessingStatusProcessing)
-		queue.UpdatePosterStatus(ctx, sqlDB, j.ID, queue.ProcessingStatusProcessing)
-		// Probe video info to get duration
-		info, err := t.ProbeVideo(ctx, localInputPath)
-		if err != nil {
-			jobLogger.Error("failed to probe video for 25pct thumbnail - job will fail", "error", err, "duration", time.Since(taskStart).Truncate(time.Millisecond))
-			jobStatus.UpdatePoster(queue.ProcessingStatusFailed)
-			queue.UpdatePosterStatus(ctx, sqlDB, j.ID, queue.ProcessingStatusFailed)
-			results <- taskResult{"25pct thumbnail", err}
-			return
-		}
-		thumbTime := time.Duration(info.DurationSec * 0.25 * float64(time.Second)) // 25% point
-		thumbPath := filepath.Join(outputPath, "thumb_25pct.jpg")
-		err = t.GeneratePoster(ctx, localInputPath, thumbPath, thumbTime, 480)
-	
-		if err != nil {
-			jobLogger.Error("25pct thumbnail FAILED - job will fail", "error", err, "duration", time.Since(taskStart).Truncate(time.Millisecond))
-			jobStatus.UpdatePoster(queue.ProcessingStatusFailed)
-			queue.UpdatePosterStatus(ctx, sqlDB, j.ID, queue.ProcessingStatusFailed)
-			results <- taskResult{"25pct thumbnail", err}
-			return
+	running := len(tasks)
+	for i, task := range tasks {
+		// Tasks an earlier attempt finished are reported done without running; their
+		// output is already in storage
+		if skip[i] {
+			jobLogger.Info("skipping task completed by an earlier attempt", "task", task.Name())
+			jobStatus.SetTaskStatus(i, queue.ProcessingStatusDone)
+			reportTaskProgress(i, 100)
+			res.addSkippedTask(task.Name())
+			running--
+			continue
 		}
 
-		jobLogger.Info("25pct thumbnail syncing directory")
-		s.SyncDirectory(ctx, outputPath, cfg.S3Bucket, j.OutputPrefix)
-		jobLogger.Info("25pct thumbnail syncing directory complete")
-		
-		jobLogger.Info("25pct thumbnail complete", "path", thumbPath, "duration", time.Since(taskStart).Truncate(time.Millisecond))
-		jobStatus.UpdatePoster(queue.ProcessingStatusDone)
-		queue.UpdatePosterStatus(ctx, sqlDB, j.ID, queue.ProcessingStatusDone)
+		go func() {
+			taskSem <- struct{}{} // Acquire inside goroutine so all tasks can spawn
+			defer func() { <-taskSem }()
+			taskStart := time.Now()
+			setStatus := func(status queue.ProcessingStatus) {
+				jobStatus.SetTaskStatus(i, status)
+				if err := task.UpdateStatus(ctx, sqlDB, j.ID, j.Attempts, status); err != nil && ctx.Err() == nil {
+					jobLogger.Warn("failed to update task status", "task", task.Name(), "error", err)
+				}
+			}
 
-		results <- taskResult{"25pct thumbnail", nil}
-	}()
+			setStatus(queue.ProcessingStatusProcessing)
+			// Other jobs may have outgrown their estimates since this one started
+			err := disk.Check(j.ID, cfg.TempDirMinFreeGB)
+			if err == nil {
+				err = task.Run(ctx, env, func(percent float64) {
+					reportTaskProgress(i, percent)
+				})
+			}
+			if err != nil {
+				jobLogger.Error("task FAILED - job will fail", "task", task.Name(), "error", err, "duration", time.Since(taskStart).Truncate(time.Millisecond))
+				setStatus(queue.ProcessingStatusFailed)
+			} else {
+				jobLogger.Info("task complete", "task", task.Name(), "duration", time.Since(taskStart).Truncate(time.Millisecond))
+				setStatus(queue.ProcessingStatusDone)
+				reportTaskProgress(i, 100)
+			}
+			results <- taskResult{task.Name(), err, time.Since(taskStart)}
+		}()
+	}
 
 	// Wait for all tasks to complete and collect errors
 	var taskErrors []error
 	var failedTasks []string
-	for range totalTasks {
+	for range running {
 		result := <-results
+		observeTask(result.name, result.err, result.elapsed)
+		res.addTask(result.name, result.err, result.elapsed)
 		if result.err != nil {
 			taskErrors = append(taskErrors, fmt.Errorf("%s: %w", result.name, result.err))
 			failedTasks = append(failedTasks, result.name)
@@ -815,28 +1553,56 @@ func processJob(
 		for _, err := range taskErrors {
 			jobLogger.Error("task failure", "error", err)
 		}
-		return taskErrors[0]
+		return res, taskErrors[0]
 	}
 
 	jobLogger.Info("all transcoding tasks complete")
 
 	jobLogger.Info("syncing output directory")
-	err = s.SyncDirectory(ctx, outputPath, cfg.S3Bucket, j.OutputPrefix)
+	// Final pass mirrors the output directory: files regenerated with new content
+	// (e.g. master.m3u8 after a ladder change) overwrite and, with S3_SYNC_DELETE,
+	// stale objects are removed. Skipped tasks' output only exists remotely, so
+	// nothing is removed then.
+	anySkipped := running < len(tasks)
+	if anySkipped && cfg.S3SyncDelete {
+		jobLogger.Info("tasks were skipped, not removing stale objects in the final sync")
+	}
+	err = s.SyncDirectoryWithOptions(ctx, outputPath, cfg.Bucket(), j.OutputPrefix, storage.SyncOptions{
+		Delete:  cfg.S3SyncDelete && !anySkipped,
+		Compare: true,
+	})
 	if err != nil {
 		jobLogger.Error("sync error", "error", err)
-		return fmt.Errorf("sync: %w", err)
+		return res, fmt.Errorf("sync: %w", err)
 	}
 	jobLogger.Info("output directory synced")
 
-	if err := queue.Complete(ctx, sqlDB, j.ID); err != nil {
+	// Point the video at the uploaded artifacts; those of disabled tasks are cleared
+	artifactKey := func(task Task) string {
+		if !slices.Contains(tasks, task) {
+			return ""
+		}
+		return path.Join(j.OutputPrefix, task.Artifact(cfg))
+	}
+	if err := db.UpdateVideoArtifacts(ctx, sqlDB, j.VideoID,
+		artifactKey(hlsTask{}),
+		artifactKey(posterTask{}),
+		artifactKey(hoverTask{}),
+		artifactKey(thumbnailsTask{}),
+	); err != nil {
+		jobLogger.Error("failed to record video artifacts", "error", err)
+		// Continue anyway, the app falls back to paths derived from the output prefix
+	}
+
+	if err := queue.Complete(ctx, sqlDB, j.ID, j.Attempts); err != nil {
 		jobLogger.Error("complete error for job", "error", err)
-		return fmt.Errorf("complete: %w", err)
+		return res, fmt.Errorf("complete: %w", err)
 	}
 
 	jobLogger.Info("========================================")
 	jobLogger.Info("JOB COMPLETE", "status", "in_review", "duration", time.Since(start).Truncate(time.Millisecond))
 	jobLogger.Info("========================================")
-	return nil
+	return res, nil
 }
 
 func max(a, b int) int {
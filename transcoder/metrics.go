@@ -0,0 +1,89 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Job outcomes, used as the "outcome" label
+const (
+	outcomeCompleted = "completed"
+	outcomeFailed    = "failed"
+	outcomeCancelled = "cancelled"
+	outcomeRequeued  = "requeued"
+	// The job was reclaimed while running; its result was discarded
+	outcomeSuperseded = "superseded"
+)
+
+var (
+	jobsClaimed = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "transcoder_jobs_claimed_total",
+		Help: "Jobs claimed from the queue by this worker.",
+	})
+	jobsFinished = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "transcoder_jobs_finished_total",
+		Help: "Jobs finished by this worker, by outcome (completed, failed, cancelled, requeued, superseded).",
+	}, []string{"outcome"})
+	// Jobs range from seconds (short clips) to hours (long 4K sources)
+	jobDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "transcoder_job_duration_seconds",
+		Help:    "Wall-clock duration of jobs from claim to finish, by outcome.",
+		Buckets: prometheus.ExponentialBuckets(5, 2, 12), // 5s .. ~2.8h
+	}, []string{"outcome"})
+	taskDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "transcoder_task_duration_seconds",
+		Help:    "Duration of individual job tasks (HLS, previews, poster), by task and outcome.",
+		Buckets: prometheus.ExponentialBuckets(1, 2, 14), // 1s .. ~2.3h
+	}, []string{"task", "outcome"})
+)
+
+// newMetricsHandler registers the worker's metrics, plus an active jobs gauge read
+// from tracker and a draining gauge read from drain, on a fresh registry and returns
+// the /metrics handler for it.
+func newMetricsHandler(tracker *JobTracker, drain *drainState) http.Handler {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		jobsClaimed,
+		jobsFinished,
+		jobDuration,
+		taskDuration,
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "transcoder_active_jobs",
+			Help: "Jobs currently being processed by this worker.",
+		}, func() float64 { return float64(len(tracker.GetAll())) }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "transcoder_draining",
+			Help: "1 while the worker is draining (claiming no new jobs), else 0.",
+		}, func() float64 {
+			if drain.Draining() {
+				return 1
+			}
+			return 0
+		}),
+	)
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(reg, promhttp.HandlerOpts{}))
+	return mux
+}
+
+// observeJob records a finished job's outcome and duration.
+func observeJob(outcome string, elapsed time.Duration) {
+	jobsFinished.WithLabelValues(outcome).Inc()
+	jobDuration.WithLabelValues(outcome).Observe(elapsed.Seconds())
+}
+
+// observeTask records how long a job task took.
+func observeTask(task string, err error, elapsed time.Duration) {
+	outcome := outcomeCompleted
+	if err != nil {
+		outcome = outcomeFailed
+	}
+	taskDuration.WithLabelValues(task, outcome).Observe(elapsed.Seconds())
+}
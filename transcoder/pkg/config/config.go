@@ -1,37 +1,393 @@
 package config
 
 import (
+	"bufio"
 	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/sethvargo/go-envconfig"
 )
 
 type Config struct {
 	DatabaseURL string `env:"DATABASE_URL,required"`
+	// Apply the embedded schema migrations at startup (compatible with drizzle-kit migrate)
+	RunMigrations bool `env:"RUN_MIGRATIONS,default=false"`
 
 	FFmpegPath  string `env:"FFMPEG_PATH,required"`
 	FFprobePath string `env:"FFPROBE_PATH,required"`
 
-	S3Endpoint       string `env:"S3_ENDPOINT,required"`
-	S3AccessKey      string `env:"S3_ACCESS_KEY_ID,required"`
-	S3SecretKey      string `env:"S3_SECRET_ACCESS_KEY,required"`
-	S3Bucket         string `env:"S3_BUCKET,required"`
-	S3Region         string `env:"S3_REGION,required"`
+	// Object storage backend: "s3", "gcs" or "fs". The S3 settings are required for
+	// "s3", GCSBucket for "gcs"; "fs" stores objects under FSBasePath for local development.
+	StorageBackend string `env:"STORAGE_BACKEND,default=s3"`
+
+	S3Endpoint       string `env:"S3_ENDPOINT"`
+	S3AccessKey      string `env:"S3_ACCESS_KEY_ID"`
+	S3SecretKey      string `env:"S3_SECRET_ACCESS_KEY"`
+	S3Bucket         string `env:"S3_BUCKET"`
+	S3Region         string `env:"S3_REGION"`
 	S3SSL            bool   `env:"S3_SSL,default=false"`
 	S3ForcePathStyle bool   `env:"S3_FORCE_PATH_STYLE,default=false"`
+	// Delete objects under a job's output prefix that the final sync did not produce
+	// (e.g. renditions dropped on re-transcode). Opt-in, as it also deletes anything
+	// else stored under the prefix.
+	S3SyncDelete bool `env:"S3_SYNC_DELETE,default=false"`
+	// Canned ACL for uploaded objects (e.g. "public-read" on buckets that still use
+	// ACLs). S3_DISABLE_ACL sends none at all, as buckets with Object Ownership
+	// enforced require.
+	S3ACL        string `env:"S3_ACL"`
+	S3DisableACL bool   `env:"S3_DISABLE_ACL,default=false"`
+	// Multipart uploads for large files (parts below 5MB are raised to S3's minimum)
+	S3UploadPartSizeMB  int `env:"S3_UPLOAD_PART_SIZE_MB,default=16"`
+	S3UploadConcurrency int `env:"S3_UPLOAD_CONCURRENCY,default=4"`
+	// Per-file upload retries on throttling, 5xx and network errors
+	S3UploadAttempts       int           `env:"S3_UPLOAD_ATTEMPTS,default=3"`
+	S3UploadRetryBaseDelay time.Duration `env:"S3_UPLOAD_RETRY_BASE_DELAY,default=500ms"`
+	// HEAD every synced object and fail the sync if its size differs from the local file
+	S3VerifyUploads bool `env:"S3_VERIFY_UPLOADS,default=false"`
+	// Ranged parallel downloads of source files
+	S3DownloadPartSizeMB  int `env:"S3_DOWNLOAD_PART_SIZE_MB,default=16"`
+	S3DownloadConcurrency int `env:"S3_DOWNLOAD_CONCURRENCY,default=8"`
+	S3DownloadAttempts    int `env:"S3_DOWNLOAD_ATTEMPTS,default=3"`
+
+	GCSBucket          string `env:"GCS_BUCKET"`
+	GCSCredentialsFile string `env:"GCS_CREDENTIALS_FILE"` // empty uses Application Default Credentials
+	GCSEndpoint        string `env:"GCS_ENDPOINT"`
+
+	// Uploaded objects whose key ends in one of these are served with
+	// Content-Disposition: attachment, so download links save them instead of playing
+	// them in the browser (S3 and GCS; playlists and segments stay inline)
+	StorageAttachments []string `env:"STORAGE_ATTACHMENTS,default=audio.m4a,audio.mp3,audio.opus"`
+
+	FSBasePath string `env:"FS_BASE_PATH"` // objects are stored at <base>/<bucket>/<key>
+	FSBucket   string `env:"FS_BUCKET,default=media"`
 
 	// Resource Controls
 	WorkerConcurrency      int `env:"WORKER_CONCURRENCY,default=0"` // 0 = auto-detect based on CPUs
 	MaxParallelRenditions  int `env:"MAX_PARALLEL_RENDITIONS,default=2"`
+	RenditionThreads       int `env:"RENDITION_THREADS,default=0"` // encoder threads per rendition; 0 = CPUs / renditions encoded at once
 	MaxParallelTasksPerJob int `env:"MAX_PARALLEL_TASKS_PER_JOB,default=2"`
 	TempDirMinFreeGB       int `env:"TEMP_DIR_MIN_FREE_GB,default=10"`
+	// Scratch space reserved per job, per rendition of the configured ladder, as a
+	// multiple of the source size (on top of its local copy); 0 disables the estimate
+	// and leaves only TempDirMinFreeGB
+	DiskEstimateFactor float64 `env:"DISK_ESTIMATE_FACTOR,default=1"`
+	// Encode the whole HLS ladder from one ffmpeg process that decodes the source once,
+	// instead of one process per rendition (MAX_PARALLEL_RENDITIONS no longer applies)
+	HLSSinglePass bool `env:"HLS_SINGLE_PASS,default=false"`
+	// Encode a single-track source's audio once, as an alternate audio playlist every
+	// variant references, instead of muxing a separate encode into each rendition
+	HLSSharedAudio bool `env:"HLS_SHARED_AUDIO,default=true"`
+	// Stream copy source audio that is already stereo 48 kHz AAC-LC within the
+	// rendition's audio bitrate instead of re-encoding it; LOUDNESS_NORM still re-encodes
+	HLSAudioCopy bool `env:"HLS_AUDIO_COPY,default=false"`
+	// Keep the source's audio sample rate and channels (mono stays mono, 44.1 kHz stays
+	// 44.1 kHz) on the lowest rendition instead of 48 kHz stereo. With HLS_SHARED_AUDIO
+	// the one shared track is encoded that way for every variant
+	HLSLowestSourceAudio bool `env:"HLS_LOWEST_SOURCE_AUDIO,default=false"`
+	// Write each rendition's playlist and segments into its own subdirectory
+	// (e.g. 720/v720.m3u8, 720/seg_0001.ts) instead of flat under the output prefix
+	HLSRenditionDirs bool `env:"HLS_RENDITION_DIRS,default=false"`
+	// Copy the source's container title and language into the HLS outputs where the job
+	// doesn't set its own; audio tracks always keep their language tags
+	MetadataPassthrough bool `env:"METADATA_PASSTHROUGH,default=true"`
+	// Scratch directory job work dirs are created in, and whose free space
+	// TempDirMinFreeGB applies to; empty uses the system temp directory
+	WorkDir string `env:"WORK_DIR"`
+	// Stop claiming jobs while the worker's own memory is above MaxWorkerMemoryMB or the
+	// host's available memory (which includes ffmpeg) is below MinFreeMemoryMB; 0 = off.
+	MaxWorkerMemoryMB int `env:"MAX_WORKER_MEMORY_MB,default=0"`
+	MinFreeMemoryMB   int `env:"MIN_FREE_MEMORY_MB,default=0"`
+
+	// Job routing: when set, only claim jobs tagged with this worker class (or untagged jobs)
+	WorkerClass string `env:"WORKER_CLASS"`
+
+	// Job retries: failed jobs are re-queued with exponential backoff until they run out of attempts
+	JobMaxAttempts    int           `env:"JOB_MAX_ATTEMPTS,default=3"`
+	JobRetryBaseDelay time.Duration `env:"JOB_RETRY_BASE_DELAY,default=30s"`
+	JobRetryMaxDelay  time.Duration `env:"JOB_RETRY_MAX_DELAY,default=30m"`
+
+	// Liveness: running jobs heartbeat periodically; jobs silent for longer than
+	// StaleJobTimeout are assumed orphaned and re-queued. Keep the timeout generous
+	// so a slow but healthy worker is never double-processed.
+	HeartbeatInterval time.Duration `env:"HEARTBEAT_INTERVAL,default=30s"`
+	StaleJobTimeout   time.Duration `env:"STALE_JOB_TIMEOUT,default=10m"`
+
+	// Upper bound on a single job's processing; a job still running after it is stopped
+	// and recorded as a failed attempt. 0 disables the limit.
+	JobTimeoutMinutes int `env:"JOB_TIMEOUT_MINUTES,default=0"`
+	// Upper bound on a single ffprobe run; a probe that times out is retried with the
+	// job, while a source ffprobe can't read rejects the video
+	ProbeTimeout time.Duration `env:"PROBE_TIMEOUT,default=60s"`
+
+	// How often running jobs check whether they have been cancelled
+	CancelPollInterval time.Duration `env:"CANCEL_POLL_INTERVAL,default=5s"`
+
+	// Database retries on serialization failures / deadlocks
+	DBRetryMaxAttempts int           `env:"DB_RETRY_MAX_ATTEMPTS,default=3"`
+	DBRetryBaseDelay   time.Duration `env:"DB_RETRY_BASE_DELAY,default=50ms"`
+
+	// Audio
+	LoudnessNorm bool `env:"LOUDNESS_NORM,default=false"` // two-pass EBU R128 loudnorm on HLS audio
+
+	// Watermark burned into every HLS rendition; disabled when WATERMARK_IMAGE is empty.
+	// Position is "corner[:marginX:marginY]" (top-left, top-right, bottom-left, bottom-right);
+	// scale is the logo height as a fraction of the rendition height.
+	WatermarkImage    string  `env:"WATERMARK_IMAGE"`
+	WatermarkPosition string  `env:"WATERMARK_POSITION,default=bottom-right:10:10"`
+	WatermarkOpacity  float64 `env:"WATERMARK_OPACITY,default=0.8"`
+	WatermarkScale    float64 `env:"WATERMARK_SCALE,default=0.1"`
+
+	// HDR sources: "auto" tonemaps PQ/HLG video to SDR BT.709, "off" encodes it as-is
+	ToneMapMode string `env:"TONEMAP_MODE,default=auto"`
+
+	// Renditions are normalized to 8-bit yuv420p for browser playback. When set, 10-bit
+	// sources stay 10-bit in the VP9/AV1 tiers (EXTRA_VIDEO_CODECS); H.264 is always 8-bit.
+	Preserve10Bit bool `env:"PRESERVE_10BIT,default=false"`
+
+	// Downloadable audio track (audio.m4a / audio.mp3 / audio.opus) next to the HLS output
+	GenerateAudio    bool   `env:"GENERATE_AUDIO,default=false"`
+	AudioFormat      string `env:"AUDIO_FORMAT,default=aac"` // aac, mp3 or opus
+	AudioBitrateKbps int    `env:"AUDIO_BITRATE_KBPS,default=192"`
+
+	// Embedded subtitles: "off", "burn" (render one stream into the video) or
+	// "passthrough" (WebVTT renditions in the master playlist). SubtitleLanguage
+	// ("en"/"eng") picks the burned stream or filters passed-through ones.
+	SubtitleMode     string `env:"SUBTITLE_MODE,default=off"`
+	SubtitleLanguage string `env:"SUBTITLE_LANGUAGE"`
+
+	// Directory for full ffmpeg stderr logs of failed runs (kept outside the job work
+	// dir, which is removed after every job). Empty disables them.
+	FFmpegLogDir string `env:"FFMPEG_LOG_DIR"`
+	// Extra global options for every ffmpeg encode, split on whitespace (no quoting),
+	// e.g. "-threads 4" or "-init_hw_device vaapi=va:/dev/dri/renderD128"
+	FFmpegExtraArgs string `env:"FFMPEG_EXTRA_ARGS"`
+
+	// Minimum log level: debug, info, warn or error. Debug includes every ffmpeg command line.
+	LogLevel string `env:"LOG_LEVEL,default=info"`
+	// Keep the tail of each job's log and store it on the job record when it fails
+	JobLogCapture bool `env:"JOB_LOG_CAPTURE,default=false"`
+
+	// Address (e.g. ":9090") for the Prometheus /metrics endpoint. Empty disables it.
+	MetricsAddr string `env:"METRICS_ADDR"`
+
+	// Address (e.g. ":8081") for the /healthz and /readyz probes and the /queue depth
+	// (JSON, for autoscalers) and /drain state. Readiness fails while draining, when the
+	// database is unreachable or free temp disk drops below TempDirMinFreeGB. Empty
+	// disables them.
+	HealthAddr string `env:"HEALTH_ADDR"`
+
+	// On SIGTERM, stop active jobs, delete their partial output and hand them back to
+	// the queue (attempt not counted) instead of letting them die with the worker.
+	RequeueOnShutdown bool `env:"REQUEUE_ON_SHUTDOWN,default=false"`
+
+	// Read the source straight from storage through a presigned URL instead of
+	// downloading it first (S3 backend only). Saves the download and the disk space, but
+	// every seek is an HTTP request, so seek-heavy tasks (thumbnails) run slower.
+	RemoteInput bool `env:"REMOTE_INPUT,default=false"`
+
+	// Upload HLS segments and playlists while they are encoded, so long videos become
+	// playable before the encode finishes (playlists are EVENT until complete)
+	ProgressiveUpload bool `env:"PROGRESSIVE_UPLOAD,default=false"`
+	// Encode the lowest H.264 rendition first and publish a master listing only it as
+	// soon as it is done, adding the others as they finish
+	HLSFastStart bool `env:"HLS_FAST_START,default=false"`
+
+	// Video encoding: HLS segment length (keyframe interval is kept a divisor of it)
+	// and libx264 speed preset (ultrafast … placebo)
+	HLSSegmentSeconds int    `env:"HLS_SEGMENT_SECONDS,default=4"`
+	X264Preset        string `env:"X264_PRESET,default=veryfast"`
+	// Where players start playback, in seconds (EXT-X-START in the master playlist;
+	// negative counts back from the end). 0 starts at the beginning.
+	HLSStartOffset float64 `env:"HLS_START_OFFSET,default=0"`
+	// Display aspect every rendition is normalized to, as "16:9" or "2.39"; empty keeps
+	// each source's. HLSAspectMode "pad" adds black bars, "crop" cuts the excess
+	HLSAspect     string `env:"HLS_ASPECT"`
+	HLSAspectMode string `env:"HLS_ASPECT_MODE,default=pad"`
+	// Extra codec tiers encoded alongside the H.264 ladder, e.g. "libvpx-vp9,libsvtav1"
+	// (also "libaom-av1"). Each adds a copy of every selected rendition in that codec.
+	ExtraVideoCodecs []string `env:"EXTRA_VIDEO_CODECS"`
+
+	// Artifacts produced per job, run in this order as task slots free up: any of
+	// "hls", "hover", "thumbnails" and "poster"
+	JobTasks []string `env:"JOB_TASKS,default=hls,hover,thumbnails,poster"`
+
+	// Retries skip tasks an earlier attempt finished (reset when the source changed);
+	// optionally only after checking the task's main output is still in storage
+	SkipCompletedTasks bool `env:"SKIP_COMPLETED_TASKS,default=true"`
+	VerifySkippedTasks bool `env:"VERIFY_SKIPPED_TASKS,default=false"`
+
+	// Metadata
+	GenerateChapters bool `env:"GENERATE_CHAPTERS,default=true"` // chapters.vtt/chapters.json from embedded markers
+
+	// Scrubber thumbnails: "interval" (fixed spacing) or "scene" (at scene changes)
+	ThumbnailMode string `env:"THUMBNAIL_MODE,default=interval"`
+	GenerateBIF   bool   `env:"GENERATE_BIF,default=false"` // thumbnails.bif for Roku trick-play (JPEG thumbnails only)
+	// JPEG quality of the thumbnails as an ffmpeg -q:v value: 2 (best) to 31 (smallest)
+	ThumbnailQuality int `env:"THUMBNAIL_QUALITY,default=2"`
+	// Pack the thumbnails into sprite sheets of this many columns x rows instead of
+	// writing one file each, so players fetch a few sheets rather than every thumbnail
+	ThumbnailSprites    bool `env:"THUMBNAIL_SPRITES,default=false"`
+	ThumbnailSpriteCols int  `env:"THUMBNAIL_SPRITE_COLS,default=10"`
+	ThumbnailSpriteRows int  `env:"THUMBNAIL_SPRITE_ROWS,default=10"`
+	// Pack all thumbnails into one sprite sized to fit them (overrides THUMBNAIL_SPRITES)
+	ThumbnailMosaic bool `env:"THUMBNAIL_MOSAIC,default=false"`
+
+	// Pick the poster frame by content (skipping black/blank frames) instead of always
+	// taking the frame at 25% of the duration
+	SmartPoster bool `env:"SMART_POSTER,default=true"`
+	// Extra candidate posters for editorial selection, as fractions of the duration
+	// (e.g. "0.1,0.25,0.5,0.75"); each is written as poster_<percent>.<format>
+	PosterCandidates []float64 `env:"POSTER_CANDIDATES"`
+
+	// Image formats of the 25% poster and the scrubber thumbnails: "jpg", "webp" or "avif"
+	PosterFormat    string `env:"POSTER_FORMAT,default=jpg"`
+	ThumbnailFormat string `env:"THUMBNAIL_FORMAT,default=jpg"`
+
+	// Preview output. Zero or negative values fall back to the defaults below.
+	HoverDurationSec int `env:"HOVER_DURATION_SEC,default=5"` // length of the hover teaser (all formats)
+	HoverWidth       int `env:"HOVER_WIDTH,default=720"`      // WebM/MP4 teaser width in pixels
+	HoverFPS         int `env:"HOVER_FPS,default=24"`         // WebM/MP4 teaser frame rate
+	ThumbnailHeight  int `env:"THUMBNAIL_HEIGHT,default=100"` // scrubber thumbnail height in pixels
+	MaxThumbnails    int `env:"MAX_THUMBNAILS,default=100"`   // upper bound; shorter videos get fewer
 }
 
+// Preview defaults, applied when the configured value is not positive.
+const (
+	DefaultHoverDurationSec = 5
+	DefaultHoverWidth       = 720
+	DefaultHoverFPS         = 24
+	DefaultThumbnailHeight  = 100
+	DefaultMaxThumbnails    = 100
+)
+
+// Load reads the configuration from the environment. Settings in the env file named by
+// CONFIG_FILE, if any, take precedence; the file is read again on every Load, which
+// lets a running worker pick up changes on SIGHUP.
 func Load() (*Config, error) {
 	ctx := context.Background()
+	lookuper := envconfig.OsLookuper()
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		vars, err := readEnvFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("CONFIG_FILE: %w", err)
+		}
+		lookuper = envconfig.MultiLookuper(envconfig.MapLookuper(vars), lookuper)
+	}
 	var cfg Config
-	if err := envconfig.Process(ctx, &cfg); err != nil {
+	if err := envconfig.ProcessWith(ctx, &envconfig.Config{Target: &cfg, Lookuper: lookuper}); err != nil {
+		return nil, err
+	}
+	if err := cfg.validateStorage(); err != nil {
 		return nil, err
 	}
+	cfg.applyPreviewDefaults()
 	return &cfg, nil
 }
+
+// readEnvFile parses an env file: KEY=VALUE lines, optionally prefixed with "export"
+// and with the value in quotes. Blank lines and lines starting with # are skipped.
+func readEnvFile(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	vars := make(map[string]string)
+	sc := bufio.NewScanner(f)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(strings.TrimPrefix(line, "export "), "=")
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: missing '='", path, n)
+		}
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		vars[strings.TrimSpace(key)] = value
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return vars, nil
+}
+
+// applyPreviewDefaults replaces invalid (zero or negative) preview settings with
+// their defaults.
+func (c *Config) applyPreviewDefaults() {
+	for _, f := range []struct {
+		v   *int
+		def int
+	}{
+		{&c.HoverDurationSec, DefaultHoverDurationSec},
+		{&c.HoverWidth, DefaultHoverWidth},
+		{&c.HoverFPS, DefaultHoverFPS},
+		{&c.ThumbnailHeight, DefaultThumbnailHeight},
+		{&c.MaxThumbnails, DefaultMaxThumbnails},
+	} {
+		if *f.v <= 0 {
+			*f.v = f.def
+		}
+	}
+}
+
+// Bucket returns the bucket of the configured storage backend.
+func (c *Config) Bucket() string {
+	switch c.StorageBackend {
+	case "gcs":
+		return c.GCSBucket
+	case "fs":
+		return c.FSBucket
+	}
+	return c.S3Bucket
+}
+
+// ScratchDir returns the directory job work dirs are created in: WorkDir, or the
+// system temp directory when unset.
+func (c *Config) ScratchDir() string {
+	if c.WorkDir != "" {
+		return c.WorkDir
+	}
+	return os.TempDir()
+}
+
+func (c *Config) validateStorage() error {
+	var missing []string
+	switch c.StorageBackend {
+	case "s3":
+		for name, v := range map[string]string{
+			"S3_ENDPOINT":          c.S3Endpoint,
+			"S3_ACCESS_KEY_ID":     c.S3AccessKey,
+			"S3_SECRET_ACCESS_KEY": c.S3SecretKey,
+			"S3_BUCKET":            c.S3Bucket,
+			"S3_REGION":            c.S3Region,
+		} {
+			if v == "" {
+				missing = append(missing, name)
+			}
+		}
+	case "gcs":
+		if c.GCSBucket == "" {
+			missing = append(missing, "GCS_BUCKET")
+		}
+	case "fs":
+		if c.FSBasePath == "" {
+			missing = append(missing, "FS_BASE_PATH")
+		}
+	default:
+		return fmt.Errorf("STORAGE_BACKEND: unknown backend %q (want \"s3\", \"gcs\" or \"fs\")", c.StorageBackend)
+	}
+	if len(missing) > 0 {
+		slices.Sort(missing)
+		return fmt.Errorf("missing required storage settings for %s: %s", c.StorageBackend, strings.Join(missing, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package db
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"embed"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/log"
+)
+
+// The schema is owned by the app's drizzle migrations (/drizzle at the repo root);
+// migrations/ is a copy embedded into the binary. Refresh it after adding a migration:
+//
+//go:generate sh -c "rm -f migrations/*.sql && cp ../../../drizzle/*.sql migrations/ && cp ../../../drizzle/meta/_journal.json migrations/meta/"
+
+//go:embed migrations/*.sql migrations/meta/_journal.json
+var migrationFS embed.FS
+
+// migrationLockID is the pg_advisory_xact_lock key serializing concurrent Migrate calls.
+const migrationLockID = 7_461_927_302
+
+type migration struct {
+	tag     string
+	when    int64 // journal timestamp, drizzle's ordering key
+	hash    string
+	queries []string
+}
+
+// Migrate brings the database schema up to date with the embedded migrations and
+// returns how many were applied. It records progress in drizzle's own
+// drizzle.__drizzle_migrations table (same hash and created_at semantics as
+// drizzle-kit migrate), so it is interchangeable with running the app's migrations:
+// whichever runs first applies them and the other is a no-op. All pending migrations
+// are applied in one transaction under an advisory lock, so concurrent workers starting
+// together apply them exactly once.
+func Migrate(ctx context.Context, db *sql.DB) (int, error) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := db.ExecContext(ctx, `CREATE SCHEMA IF NOT EXISTS drizzle`); err != nil {
+		return 0, fmt.Errorf("create migrations schema: %w", err)
+	}
+	if _, err := db.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS drizzle.__drizzle_migrations (
+			id SERIAL PRIMARY KEY,
+			hash text NOT NULL,
+			created_at bigint
+		)
+	`); err != nil {
+		return 0, fmt.Errorf("create migrations table: %w", err)
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, migrationLockID); err != nil {
+		return 0, fmt.Errorf("lock migrations: %w", err)
+	}
+
+	// Read under the lock, so a migration applied by a worker that held it first is seen
+	var last sql.NullInt64
+	if err := tx.QueryRowContext(ctx, `SELECT MAX(created_at) FROM drizzle.__drizzle_migrations`).Scan(&last); err != nil {
+		return 0, fmt.Errorf("read applied migrations: %w", err)
+	}
+
+	applied := 0
+	for _, m := range migrations {
+		if last.Valid && m.when <= last.Int64 {
+			continue
+		}
+		for _, q := range m.queries {
+			if _, err := tx.ExecContext(ctx, q); err != nil {
+				return 0, fmt.Errorf("migration %s: %w", m.tag, err)
+			}
+		}
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO drizzle.__drizzle_migrations (hash, created_at) VALUES ($1, $2)`,
+			m.hash, m.when,
+		); err != nil {
+			return 0, fmt.Errorf("record migration %s: %w", m.tag, err)
+		}
+		log.Info("applied migration", "tag", m.tag)
+		applied++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("commit migrations: %w", err)
+	}
+	return applied, nil
+}
+
+// loadMigrations reads the embedded journal and SQL files, in journal order.
+func loadMigrations() ([]migration, error) {
+	raw, err := migrationFS.ReadFile("migrations/meta/_journal.json")
+	if err != nil {
+		return nil, fmt.Errorf("read migration journal: %w", err)
+	}
+	var journal struct {
+		Entries []struct {
+			When int64  `json:"when"`
+			Tag  string `json:"tag"`
+		} `json:"entries"`
+	}
+	if err := json.Unmarshal(raw, &journal); err != nil {
+		return nil, fmt.Errorf("parse migration journal: %w", err)
+	}
+
+	migrations := make([]migration, 0, len(journal.Entries))
+	for _, e := range journal.Entries {
+		body, err := migrationFS.ReadFile("migrations/" + e.Tag + ".sql")
+		if err != nil {
+			return nil, fmt.Errorf("read migration %s: %w", e.Tag, err)
+		}
+		sum := sha256.Sum256(body)
+		m := migration{tag: e.Tag, when: e.When, hash: hex.EncodeToString(sum[:])}
+		for _, q := range strings.Split(string(body), "--> statement-breakpoint") {
+			if q = strings.TrimSpace(q); q != "" {
+				m.queries = append(m.queries, q)
+			}
+		}
+		migrations = append(migrations, m)
+	}
+	return migrations, nil
+}
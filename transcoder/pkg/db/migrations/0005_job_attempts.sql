@@ -0,0 +1,2 @@
+ALTER TYPE "public"."queue_status" ADD VALUE 'dead';--> statement-breakpoint
+ALTER TABLE "transcode_queue" ADD COLUMN "next_attempt_at" timestamp DEFAULT now() NOT NULL;
\ No newline at end of file
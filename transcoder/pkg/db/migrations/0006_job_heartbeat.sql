@@ -0,0 +1 @@
+ALTER TABLE "transcode_queue" ADD COLUMN "heartbeat_at" timestamp;
\ No newline at end of file
@@ -0,0 +1,2 @@
+ALTER TABLE "transcode_queue" ADD COLUMN "priority" integer DEFAULT 0 NOT NULL;--> statement-breakpoint
+CREATE INDEX "transcode_queue_claim_idx" ON "transcode_queue" USING btree ("status","priority","created_at");
\ No newline at end of file
@@ -0,0 +1,2 @@
+ALTER TYPE "public"."queue_status" ADD VALUE 'cancelled';--> statement-breakpoint
+ALTER TABLE "transcode_queue" ADD COLUMN "cancel_requested" boolean DEFAULT false NOT NULL;
\ No newline at end of file
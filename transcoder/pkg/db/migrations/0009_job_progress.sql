@@ -0,0 +1 @@
+ALTER TABLE "transcode_queue" ADD COLUMN "progress_percent" integer DEFAULT 0 NOT NULL;
\ No newline at end of file
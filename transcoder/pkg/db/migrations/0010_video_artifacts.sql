@@ -0,0 +1,4 @@
+ALTER TABLE "video" ADD COLUMN "hls_master_key" text;--> statement-breakpoint
+ALTER TABLE "video" ADD COLUMN "poster_key" text;--> statement-breakpoint
+ALTER TABLE "video" ADD COLUMN "hover_preview_key" text;--> statement-breakpoint
+ALTER TABLE "video" ADD COLUMN "thumbnails_vtt_key" text;
\ No newline at end of file
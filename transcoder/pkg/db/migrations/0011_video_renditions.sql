@@ -0,0 +1 @@
+ALTER TABLE "video" ADD COLUMN "renditions" jsonb;
\ No newline at end of file
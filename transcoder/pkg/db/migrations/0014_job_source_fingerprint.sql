@@ -0,0 +1 @@
+ALTER TABLE "transcode_queue" ADD COLUMN "source_fingerprint" text;
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE "transcode_queue" ADD COLUMN "input_keys" text[];
\ No newline at end of file
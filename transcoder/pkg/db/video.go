@@ -3,6 +3,7 @@ package db
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"time"
 )
@@ -66,6 +67,75 @@ func UpdateVideoMetadata(ctx context.Context, db *sql.DB, videoID string, durati
 	return nil
 }
 
+// UpdateVideoArtifacts records the storage keys of the video's transcoded artifacts
+// (master playlist, poster, hover preview and thumbnails VTT) so the app server can
+// link them without rebuilding the paths. Empty keys are stored as NULL.
+func UpdateVideoArtifacts(ctx context.Context, db *sql.DB, videoID, masterKey, posterKey, hoverKey, vttKey string) error {
+	query := `
+		UPDATE video
+		SET hls_master_key = NULLIF($1, ''),
+		    poster_key = NULLIF($2, ''),
+		    hover_preview_key = NULLIF($3, ''),
+		    thumbnails_vtt_key = NULLIF($4, ''),
+		    updated_at = $5
+		WHERE id = $6
+	`
+
+	result, err := db.ExecContext(ctx, query, masterKey, posterKey, hoverKey, vttKey, time.Now(), videoID)
+	if err != nil {
+		return fmt.Errorf("update video artifacts: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("video not found: %s", videoID)
+	}
+
+	return nil
+}
+
+// Rendition is an HLS quality level encoded for a video, as stored in video.renditions.
+type Rendition struct {
+	Height           int    `json:"height"`
+	VideoBitrateKbps int    `json:"videoBitrateKbps"`
+	AudioBitrateKbps int    `json:"audioBitrateKbps"`
+	Codec            string `json:"codec,omitempty"` // ffmpeg encoder; empty is libx264
+}
+
+// UpdateVideoRenditions records the renditions selected for the video's transcode.
+func UpdateVideoRenditions(ctx context.Context, db *sql.DB, videoID string, renditions []Rendition) error {
+	data, err := json.Marshal(renditions)
+	if err != nil {
+		return fmt.Errorf("encode renditions: %w", err)
+	}
+
+	query := `
+		UPDATE video
+		SET renditions = $1::jsonb, updated_at = $2
+		WHERE id = $3
+	`
+
+	result, err := db.ExecContext(ctx, query, string(data), time.Now(), videoID)
+	if err != nil {
+		return fmt.Errorf("update video renditions: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("video not found: %s", videoID)
+	}
+
+	return nil
+}
+
 // GetVideoStatus retrieves the current status of a video.
 func GetVideoStatus(ctx context.Context, db *sql.DB, videoID string) (VideoStatus, error) {
 	query := `SELECT status FROM video WHERE id = $1`
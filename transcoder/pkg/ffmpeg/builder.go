@@ -4,7 +4,12 @@ import (
 	"bufio"
 	"context"
 	"fmt"
+	"io"
+	"os"
 	"os/exec"
+	"path/filepath"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -16,10 +21,16 @@ import (
 // Command provides a fluent API for building and running ffmpeg invocations.
 type Command struct {
 	bin              string
+	globalArgs       []string // emitted ahead of every other argument
 	args             []string
 	filters          []string
 	progressCallback func(percent float64, eta string, speed string)
 	totalDuration    float64 // in seconds, for progress calculation
+	stderrWriter     io.Writer
+	env              map[string]string
+	dryRun           bool
+	logDir           string
+	outputSeek       time.Duration // pending output-side -ss, emitted after the next Input
 }
 
 func New(bin string) *Command {
@@ -36,14 +47,55 @@ func (c *Command) Overwrite(enable bool) *Command {
 	return c
 }
 
+// Input adds an input file. An http(s) URL is read with reconnects, so a dropped
+// connection to object storage doesn't fail a long encode.
 func (c *Command) Input(path string) *Command {
+	if strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://") {
+		c.args = append(c.args, "-reconnect", "1", "-reconnect_on_network_error", "1", "-reconnect_delay_max", "10")
+	}
 	c.args = append(c.args, "-i", path)
+	if c.outputSeek > 0 {
+		c.args = append(c.args, "-ss", fmt.Sprintf("%.3f", c.outputSeek.Seconds()))
+		c.outputSeek = 0
+	}
 	return c
 }
 
+// SeekMode selects how StartAtMode positions the input.
+type SeekMode int
+
+const (
+	// SeekFast seeks the input directly (-ss before -i). Cheap, but may land on the
+	// keyframe before the timestamp depending on the demuxer.
+	SeekFast SeekMode = iota
+	// SeekAccurate input-seeks to accurateSeekPreroll before the timestamp, then decodes
+	// and drops frames up to it with an output-side -ss, landing on the exact frame.
+	SeekAccurate
+)
+
+// accurateSeekPreroll is how far before the target SeekAccurate input-seeks. Frames in
+// between are decoded and discarded, so it trades a little speed for a keyframe that
+// is reliably before the target.
+const accurateSeekPreroll = 5 * time.Second
+
+// StartAt seeks the next input to at with SeekFast. Call it before Input.
 func (c *Command) StartAt(at time.Duration) *Command {
-	if at > 0 {
-		c.args = append(c.args, "-ss", fmt.Sprintf("%.3f", at.Seconds()))
+	return c.StartAtMode(at, SeekFast)
+}
+
+// StartAtMode seeks the next input to at. Call it before Input; with SeekAccurate the
+// output-side part of the seek is emitted right after the input.
+func (c *Command) StartAtMode(at time.Duration, mode SeekMode) *Command {
+	if at <= 0 {
+		return c
+	}
+	inputSeek := at
+	if mode == SeekAccurate {
+		inputSeek = max(at-accurateSeekPreroll, 0)
+		c.outputSeek = at - inputSeek
+	}
+	if inputSeek > 0 {
+		c.args = append(c.args, "-ss", fmt.Sprintf("%.3f", inputSeek.Seconds()))
 	}
 	return c
 }
@@ -69,6 +121,14 @@ func (c *Command) AudioCodec(codec string) *Command {
 	return c
 }
 
+// X264Presets lists libx264's speed presets, fastest first.
+var X264Presets = []string{"ultrafast", "superfast", "veryfast", "faster", "fast", "medium", "slow", "slower", "veryslow", "placebo"}
+
+// ValidX264Preset reports whether p is one of X264Presets.
+func ValidX264Preset(p string) bool {
+	return slices.Contains(X264Presets, p)
+}
+
 func (c *Command) Preset(preset string) *Command {
 	if preset != "" {
 		c.args = append(c.args, "-preset", preset)
@@ -76,6 +136,47 @@ func (c *Command) Preset(preset string) *Command {
 	return c
 }
 
+// H264Profiles lists the libx264 profiles a stream can be pinned to, most compatible first.
+var H264Profiles = []string{"baseline", "main", "high"}
+
+// H264Levels lists the H.264 levels accepted by Level.
+var H264Levels = []string{"1", "1b", "1.1", "1.2", "1.3", "2", "2.1", "2.2", "3", "3.1", "3.2", "4", "4.1", "4.2", "5", "5.1", "5.2", "6", "6.1", "6.2"}
+
+// ValidH264Profile reports whether p is one of H264Profiles.
+func ValidH264Profile(p string) bool {
+	return slices.Contains(H264Profiles, p)
+}
+
+// ValidH264Level reports whether l is one of H264Levels; "3.0" style is accepted too.
+func ValidH264Level(l string) bool {
+	return slices.Contains(H264Levels, strings.TrimSuffix(l, ".0"))
+}
+
+// Profile pins the video encoder profile (e.g. "baseline" for libx264).
+func (c *Command) Profile(profile string) *Command {
+	if profile != "" {
+		c.args = append(c.args, "-profile:v", profile)
+	}
+	return c
+}
+
+// Level pins the video encoder level (e.g. "3.0").
+func (c *Command) Level(level string) *Command {
+	if level != "" {
+		c.args = append(c.args, "-level", level)
+	}
+	return c
+}
+
+// PixFmt sets the output pixel format (e.g. "yuv420p"); ffmpeg converts only when the
+// filtered frames are in a different format.
+func (c *Command) PixFmt(pixFmt string) *Command {
+	if pixFmt != "" {
+		c.args = append(c.args, "-pix_fmt", pixFmt)
+	}
+	return c
+}
+
 func (c *Command) CRF(v int) *Command {
 	if v > 0 {
 		c.args = append(c.args, "-crf", strconv.Itoa(v))
@@ -126,6 +227,33 @@ func (c *Command) AudioRate(hz int) *Command {
 	return c
 }
 
+// Threads limits the encoder threads of the output being built (-threads after the
+// inputs); 0 leaves the encoder's default of roughly one per core.
+func (c *Command) Threads(n int) *Command {
+	if n > 0 {
+		c.args = append(c.args, "-threads", strconv.Itoa(n))
+	}
+	return c
+}
+
+// Metadata sets a container tag of the output being built (-metadata key=value). An
+// empty value is skipped, leaving whatever ffmpeg copies from the input.
+func (c *Command) Metadata(key, value string) *Command {
+	if value != "" {
+		c.args = append(c.args, "-metadata", key+"="+value)
+	}
+	return c
+}
+
+// StreamMetadata sets a tag on the output streams matching spec, e.g. "a:0" for the
+// first audio stream (-metadata:s:a:0 key=value). An empty value is skipped.
+func (c *Command) StreamMetadata(spec, key, value string) *Command {
+	if value != "" {
+		c.args = append(c.args, "-metadata:s:"+spec, key+"="+value)
+	}
+	return c
+}
+
 func (c *Command) GOP(g int) *Command {
 	if g > 0 {
 		val := strconv.Itoa(g)
@@ -134,11 +262,25 @@ func (c *Command) GOP(g int) *Command {
 	return c
 }
 
+// ForceKeyFramesEvery forces a keyframe every secs seconds of output timestamps, so
+// encodes of the same source at different settings put keyframes at the same times.
+func (c *Command) ForceKeyFramesEvery(secs int) *Command {
+	if secs > 0 {
+		c.args = append(c.args, "-force_key_frames", fmt.Sprintf("expr:gte(t,n_forced*%d)", secs))
+	}
+	return c
+}
+
 func (c *Command) NoAudio() *Command {
 	c.args = append(c.args, "-an")
 	return c
 }
 
+func (c *Command) NoVideo() *Command {
+	c.args = append(c.args, "-vn")
+	return c
+}
+
 func (c *Command) Format(fmtName string) *Command {
 	if fmtName != "" {
 		c.args = append(c.args, "-f", fmtName)
@@ -146,6 +288,14 @@ func (c *Command) Format(fmtName string) *Command {
 	return c
 }
 
+// AudioFilter sets the audio filter graph (-af) for the output.
+func (c *Command) AudioFilter(filter string) *Command {
+	if filter != "" {
+		c.args = append(c.args, "-af", filter)
+	}
+	return c
+}
+
 func (c *Command) FilterChain(fc *FilterChain) *Command {
 	if fc != nil && len(fc.ops) > 0 {
 		c.filters = append(c.filters, fc.String())
@@ -177,6 +327,23 @@ func (c *Command) HLS(segmentSeconds int, playlistType, flags, segmentFilename s
 	return c
 }
 
+// HLSFMP4 switches HLS output to fragmented MP4 segments (required for VP9 and AV1).
+// initFilename is the init segment name, relative to the playlist.
+func (c *Command) HLSFMP4(initFilename string) *Command {
+	c.args = append(c.args, "-hls_segment_type", "fmp4")
+	if initFilename != "" {
+		c.args = append(c.args, "-hls_fmp4_init_filename", initFilename)
+	}
+	return c
+}
+
+// GlobalArg adds options that must come before the inputs, such as -threads, -xerror
+// or -init_hw_device. Unlike Arg, they are placed ahead of everything added so far.
+func (c *Command) GlobalArg(args ...string) *Command {
+	c.globalArgs = append(c.globalArgs, args...)
+	return c
+}
+
 func (c *Command) Arg(args ...string) *Command {
 	c.args = append(c.args, args...)
 	return c
@@ -196,6 +363,92 @@ func (c *Command) WithProgress(durationSeconds float64, callback func(percent fl
 	return c
 }
 
+// StderrTo copies every stderr line written by ffmpeg to w, in addition to the
+// usual progress parsing. Useful for filters that report results on stderr (e.g. loudnorm).
+func (c *Command) StderrTo(w io.Writer) *Command {
+	c.stderrWriter = w
+	return c
+}
+
+// LogToDir tees the complete ffmpeg stderr into a new file in dir, so failures can be
+// inspected beyond the tail kept in the returned error. The file is removed when the
+// command succeeds and kept (its path added to the error) when it fails. Empty disables it.
+func (c *Command) LogToDir(dir string) *Command {
+	c.logDir = dir
+	return c
+}
+
+// Env sets extra environment variables for the ffmpeg child process. They are merged
+// over the worker's own environment, so the worker itself is left untouched.
+func (c *Command) Env(vars map[string]string) *Command {
+	if len(vars) == 0 {
+		return c
+	}
+	if c.env == nil {
+		c.env = make(map[string]string, len(vars))
+	}
+	for k, v := range vars {
+		c.env[k] = v
+	}
+	return c
+}
+
+// DryRun makes Run log the assembled command and return nil without executing it.
+func (c *Command) DryRun(enable bool) *Command {
+	c.dryRun = enable
+	return c
+}
+
+// Args returns the exact argv (without the binary) that Run executes, including the
+// progress flags and the -vf filter graph inserted before the output path.
+func (c *Command) Args() []string {
+	return append([]string{"-progress", "pipe:2", "-stats_period", "5"}, c.buildArgs()...)
+}
+
+// String returns the command as a shell-quoted line that can be pasted into a terminal.
+func (c *Command) String() string {
+	parts := make([]string, 0, len(c.args)+8)
+	parts = append(parts, shellQuote(c.bin))
+	for _, a := range c.Args() {
+		parts = append(parts, shellQuote(a))
+	}
+	return strings.Join(parts, " ")
+}
+
+// shellQuote single-quotes s for POSIX shells unless it only contains safe characters.
+func shellQuote(s string) string {
+	if s == "" {
+		return "''"
+	}
+	safe := true
+	for _, r := range s {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_./:=+,%@", r)) {
+			safe = false
+			break
+		}
+	}
+	if safe {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func (c *Command) environ() []string {
+	if len(c.env) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(c.env))
+	for k := range c.env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	env := os.Environ()
+	for _, k := range keys {
+		env = append(env, k+"="+c.env[k])
+	}
+	return env
+}
+
 func (c *Command) buildArgs() []string {
 	// Find the output path (last added via Output())
 	// We need to insert filter args BEFORE the output path
@@ -209,7 +462,8 @@ func (c *Command) buildArgs() []string {
 		argsWithoutOutput = c.args[:len(c.args)-1]
 	}
 
-	args := make([]string, 0, len(c.args)+2)
+	args := make([]string, 0, len(c.globalArgs)+len(c.args)+2)
+	args = append(args, c.globalArgs...)
 	args = append(args, argsWithoutOutput...)
 
 	// Add filters before output path
@@ -227,22 +481,54 @@ func (c *Command) buildArgs() []string {
 }
 
 func (c *Command) Run(ctx context.Context) error {
-	args := c.buildArgs()
+	// Includes progress reporting flags
+	args := c.Args()
+
+	if c.dryRun {
+		log.Info("ffmpeg dry run", "command", c.String())
+		return nil
+	}
+	log.Debug("running ffmpeg", "command", c.String())
 
-	// Add progress reporting
-	args = append([]string{"-progress", "pipe:2", "-stats_period", "5"}, args...)
+	logFile, err := c.openLogFile()
+	if err != nil {
+		// The log is a debugging aid; never fail the encode over it
+		log.Warn("failed to create ffmpeg log file", "dir", c.logDir, "error", err)
+	}
+	var logWriter *bufio.Writer
+	if logFile != nil {
+		logWriter = bufio.NewWriter(logFile)
+		fmt.Fprintln(logWriter, c.String())
+	}
+	// closeLog flushes the log file, deleting it unless keep is set, and returns the
+	// path of a kept file.
+	closeLog := func(keep bool) string {
+		if logFile == nil {
+			return ""
+		}
+		logWriter.Flush()
+		logFile.Close()
+		if !keep {
+			os.Remove(logFile.Name())
+			return ""
+		}
+		return logFile.Name()
+	}
 
 	cmd := exec.CommandContext(ctx, c.bin, args...)
+	cmd.Env = c.environ() // nil inherits the parent environment
 
 	// Capture stderr for progress monitoring
 	stderr, err := cmd.StderrPipe()
 	if err != nil {
+		closeLog(false)
 		return fmt.Errorf("failed to create stderr pipe: %w", err)
 	}
 
 	// Capture stdout for error messages
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
+		closeLog(false)
 		return fmt.Errorf("failed to create stdout pipe: %w", err)
 	}
 
@@ -252,6 +538,7 @@ func (c *Command) Run(ctx context.Context) error {
 			"args", strings.Join(args, " "),
 			"error", err,
 		)
+		closeLog(false)
 		return fmt.Errorf("ffmpeg failed to start: %w\nargs: %s", err, strings.Join(args, " "))
 	}
 
@@ -270,6 +557,12 @@ func (c *Command) Run(ctx context.Context) error {
 
 		for scanner.Scan() {
 			line := scanner.Text()
+			if c.stderrWriter != nil {
+				fmt.Fprintln(c.stderrWriter, line)
+			}
+			if logWriter != nil {
+				fmt.Fprintln(logWriter, line)
+			}
 			
 			// Capture ALL lines for debugging (not just non-progress)
 			stderrMu.Lock()
@@ -361,18 +654,41 @@ func (c *Command) Run(ctx context.Context) error {
 			errOutput = strings.Join(errorLines, "\n")
 		}
 		stderrMu.Unlock()
+
+		var logNote string
+		if logPath := closeLog(true); logPath != "" {
+			log.Error("ffmpeg failed, full stderr kept", "log_file", logPath)
+			logNote = "\nlog: " + logPath
+		}
 		
 		if errOutput != "" {
 			log.Error("ffmpeg stderr output", "stderr", errOutput)
-			return fmt.Errorf("ffmpeg failed: %w\nstderr:\n%s\nargs: %s", err, errOutput, strings.Join(args, " "))
+			return fmt.Errorf("ffmpeg failed: %w\nstderr:\n%s\nargs: %s%s", err, errOutput, strings.Join(args, " "), logNote)
 		}
-		return fmt.Errorf("ffmpeg failed: %w (no stderr captured)\nargs: %s", err, strings.Join(args, " "))
+		return fmt.Errorf("ffmpeg failed: %w (no stderr captured)\nargs: %s%s", err, strings.Join(args, " "), logNote)
 	}
 
 	<-progressDone // Wait for progress monitoring to finish
+	closeLog(false)
 	return nil
 }
 
+// openLogFile creates the per-invocation stderr log in c.logDir, named after the
+// output file. It returns nil when logging to a file is disabled.
+func (c *Command) openLogFile() (*os.File, error) {
+	if c.logDir == "" {
+		return nil, nil
+	}
+	if err := os.MkdirAll(c.logDir, 0o755); err != nil {
+		return nil, err
+	}
+	name := "ffmpeg"
+	if n := len(c.args); n > 0 && !strings.HasPrefix(c.args[n-1], "-") {
+		name += "-" + strings.NewReplacer("%", "", "*", "").Replace(filepath.Base(c.args[n-1]))
+	}
+	return os.CreateTemp(c.logDir, name+"-*.log")
+}
+
 // FilterChain accumulates video filter operations.
 type FilterChain struct {
 	ops []string
@@ -387,6 +703,39 @@ func (f *FilterChain) Scale(width, height int) *FilterChain {
 	return f
 }
 
+// Crop keeps the width x height area whose top left corner is at x, y. Crop before
+// scaling, so it is given in source pixels.
+func (f *FilterChain) Crop(width, height, x, y int) *FilterChain {
+	if width > 0 && height > 0 {
+		f.ops = append(f.ops, fmt.Sprintf("crop=%d:%d:%d:%d", width, height, x, y))
+	}
+	return f
+}
+
+// Pad places the video at x, y on a width x height canvas of color (an ffmpeg color
+// such as "black"; empty is black). Pad after scaling, so the bars are added at the
+// output size.
+func (f *FilterChain) Pad(width, height, x, y int, color string) *FilterChain {
+	if width > 0 && height > 0 {
+		f.ops = append(f.ops, fmt.Sprintf("pad=%d:%d:%d:%d:color=%s", width, height, x, y, defaultColor(color)))
+	}
+	return f
+}
+
+// SquarePixels sets the sample aspect ratio to 1:1, so the display aspect is exactly
+// width:height.
+func (f *FilterChain) SquarePixels() *FilterChain {
+	f.ops = append(f.ops, "setsar=1")
+	return f
+}
+
+func defaultColor(color string) string {
+	if color == "" {
+		return "black"
+	}
+	return color
+}
+
 func (f *FilterChain) ScaleToHeight(height int) *FilterChain {
 	if height > 0 {
 		f.ops = append(f.ops, fmt.Sprintf("scale=-2:%d", height))
@@ -408,6 +757,42 @@ func (f *FilterChain) Tile(cols, rows int) *FilterChain {
 	return f
 }
 
+// TonemapToSDR converts HDR (PQ/HLG, BT.2020) video to SDR BT.709 with zscale and the
+// hable tonemapper. It is expensive, so only add it for HDR sources, and preferably after
+// scaling down.
+func (f *FilterChain) TonemapToSDR() *FilterChain {
+	f.ops = append(f.ops,
+		"zscale=t=linear:npl=100",
+		"format=gbrpf32le",
+		"zscale=p=bt709",
+		"tonemap=tonemap=hable:desat=0",
+		"zscale=t=bt709:m=bt709:r=tv",
+		"format=yuv420p",
+	)
+	return f
+}
+
+// Subtitles burns text subtitle stream si (0:s:si) from path into the video.
+func (f *FilterChain) Subtitles(path string, si int) *FilterChain {
+	return f.SubtitlesFrom(path, si, 0)
+}
+
+// SubtitlesFrom is Subtitles for an input seeked to offset. The filter reads the
+// subtitles on the source's timeline while input seeking resets frame timestamps to
+// zero, so frames are shifted onto the source timeline around it.
+func (f *FilterChain) SubtitlesFrom(path string, si int, offset time.Duration) *FilterChain {
+	if path == "" || si < 0 {
+		return f
+	}
+	sub := fmt.Sprintf("subtitles=filename=%s:si=%d", escapeFilterValue(path), si)
+	if offset > 0 {
+		f.ops = append(f.ops, fmt.Sprintf("setpts=PTS+%.3f/TB", offset.Seconds()), sub, "setpts=PTS-STARTPTS")
+	} else {
+		f.ops = append(f.ops, sub)
+	}
+	return f
+}
+
 func (f *FilterChain) String() string {
 	return strings.Join(f.ops, ",")
 }
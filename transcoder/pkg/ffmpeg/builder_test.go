@@ -1,6 +1,13 @@
 package ffmpeg
 
-import "testing"
+import (
+	"context"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
 
 func TestFilterChain_String(t *testing.T) {
 	fc := NewFilterChain().
@@ -13,3 +20,218 @@ func TestFilterChain_String(t *testing.T) {
 		t.Fatalf("unexpected filter chain: got %q want %q", got, want)
 	}
 }
+
+func TestFilterChain_CropPad(t *testing.T) {
+	got := NewFilterChain().Crop(1920, 800, 0, 140).Scale(1280, 720).Pad(1280, 720, 0, 0, "").SquarePixels().String()
+	want := "crop=1920:800:0:140,scale=1280:720,pad=1280:720:0:0:color=black,setsar=1"
+	if got != want {
+		t.Fatalf("unexpected filter chain: got %q want %q", got, want)
+	}
+	if got := NewFilterChain().Crop(0, 720, 0, 0).Pad(1280, 0, 0, 0, "white").String(); got != "" {
+		t.Fatalf("empty crop and pad added filters: %q", got)
+	}
+}
+
+func TestFilterChain_TonemapToSDR(t *testing.T) {
+	got := NewFilterChain().ScaleToHeight(1080).TonemapToSDR().String()
+	want := "scale=-2:1080,zscale=t=linear:npl=100,format=gbrpf32le,zscale=p=bt709,tonemap=tonemap=hable:desat=0,zscale=t=bt709:m=bt709:r=tv,format=yuv420p"
+	if got != want {
+		t.Fatalf("unexpected filter chain: got %q want %q", got, want)
+	}
+}
+
+func TestFilterChain_Subtitles(t *testing.T) {
+	got := NewFilterChain().ScaleToHeight(720).Subtitles("/tmp/job 1/it's.mkv", 2).String()
+	want := `scale=-2:720,subtitles=filename='/tmp/job 1/it'\''s.mkv':si=2`
+	if got != want {
+		t.Fatalf("unexpected filter chain: got %q want %q", got, want)
+	}
+}
+
+func TestFilterChain_SubtitlesFrom(t *testing.T) {
+	got := NewFilterChain().SubtitlesFrom("in.mkv", 0, 90*time.Second).String()
+	want := `setpts=PTS+90.000/TB,subtitles=filename='in.mkv':si=0,setpts=PTS-STARTPTS`
+	if got != want {
+		t.Fatalf("unexpected filter chain: got %q want %q", got, want)
+	}
+}
+
+func TestCommand_ArgsAndString(t *testing.T) {
+	c := New("ffmpeg").
+		Overwrite(true).
+		Input("/tmp/in put.mp4").
+		FilterChain(NewFilterChain().ScaleToHeight(720)).
+		VideoCodec("libx264").
+		Output("/tmp/out.mp4")
+	got := strings.Join(c.Args(), " ")
+	want := "-progress pipe:2 -stats_period 5 -y -i /tmp/in put.mp4 -c:v libx264 -vf scale=-2:720 /tmp/out.mp4"
+	if got != want {
+		t.Fatalf("args:\ngot  %q\nwant %q", got, want)
+	}
+	gotStr := c.String()
+	wantStr := "ffmpeg -progress pipe:2 -stats_period 5 -y -i '/tmp/in put.mp4' -c:v libx264 -vf scale=-2:720 /tmp/out.mp4"
+	if gotStr != wantStr {
+		t.Fatalf("string:\ngot  %q\nwant %q", gotStr, wantStr)
+	}
+}
+
+func TestCommand_GlobalArg(t *testing.T) {
+	c := New("ffmpeg").
+		Overwrite(true).
+		StartAt(2*time.Second).
+		Input("in.mp4").
+		VideoCodec("libx264").
+		GlobalArg("-threads", "4").
+		FilterChain(NewFilterChain().ScaleToHeight(720)).
+		Output("out.mp4")
+	want := "-threads 4 -y -ss 2.000 -i in.mp4 -c:v libx264 -vf scale=-2:720 out.mp4"
+	if got := strings.Join(c.buildArgs(), " "); got != want {
+		t.Fatalf("got %q want %q", got, want)
+	}
+}
+
+func TestCommand_InputURL(t *testing.T) {
+	c := New("ffmpeg").StartAt(2 * time.Second).Input("https://minio:9000/b/in.mp4?X-Amz-Signature=abc").Output("out.jpg")
+	want := "-ss 2.000 -reconnect 1 -reconnect_on_network_error 1 -reconnect_delay_max 10 -i https://minio:9000/b/in.mp4?X-Amz-Signature=abc out.jpg"
+	if got := strings.Join(c.buildArgs(), " "); got != want {
+		t.Fatalf("got %q want %q", got, want)
+	}
+}
+
+func TestCommand_StartAtMode(t *testing.T) {
+	cases := []struct {
+		at   time.Duration
+		mode SeekMode
+		want string
+	}{
+		{12500 * time.Millisecond, SeekFast, "-ss 12.500 -i in.mp4 out.jpg"},
+		{12500 * time.Millisecond, SeekAccurate, "-ss 7.500 -i in.mp4 -ss 5.000 out.jpg"},
+		{2 * time.Second, SeekAccurate, "-i in.mp4 -ss 2.000 out.jpg"},
+		{0, SeekAccurate, "-i in.mp4 out.jpg"},
+	}
+	for _, tc := range cases {
+		c := New("ffmpeg").StartAtMode(tc.at, tc.mode).Input("in.mp4").Output("out.jpg")
+		if got := strings.Join(c.buildArgs(), " "); got != tc.want {
+			t.Errorf("StartAtMode(%s, %d): got %q want %q", tc.at, tc.mode, got, tc.want)
+		}
+	}
+}
+
+func TestCommand_ForceKeyFramesEvery(t *testing.T) {
+	c := New("ffmpeg").Input("in.mp4").GOP(48).ForceKeyFramesEvery(4).Output("out.m3u8")
+	want := "-i in.mp4 -g 48 -keyint_min 48 -sc_threshold 0 -force_key_frames expr:gte(t,n_forced*4) out.m3u8"
+	if got := strings.Join(c.buildArgs(), " "); got != want {
+		t.Fatalf("got %q want %q", got, want)
+	}
+	if got := strings.Join(New("ffmpeg").ForceKeyFramesEvery(0).buildArgs(), " "); got != "" {
+		t.Errorf("non-positive interval: got %q want no args", got)
+	}
+}
+
+func TestCommand_Metadata(t *testing.T) {
+	c := New("ffmpeg").Input("in.mp4").
+		Metadata("title", "My Video").Metadata("language", "").
+		StreamMetadata("a:0", "language", "eng").StreamMetadata("a:1", "language", "").
+		Output("out.m3u8")
+	want := "-i in.mp4 -metadata title=My Video -metadata:s:a:0 language=eng out.m3u8"
+	if got := strings.Join(c.buildArgs(), " "); got != want {
+		t.Fatalf("got %q want %q", got, want)
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	if got := shellQuote("it's"); got != `'it'\''s'` {
+		t.Errorf("got %s", got)
+	}
+	if got := shellQuote(""); got != "''" {
+		t.Errorf("got %s", got)
+	}
+}
+
+func TestCommand_LogToDir(t *testing.T) {
+	for _, bin := range []string{"true", "false"} {
+		if _, err := exec.LookPath(bin); err != nil {
+			t.Skipf("%s not available", bin)
+		}
+	}
+	dir := t.TempDir()
+
+	if err := New("true").LogToDir(dir).Output("out.mp4").Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Fatalf("log of successful run not removed: %v", entries)
+	}
+
+	err := New("false").LogToDir(dir).Output("out.mp4").Run(context.Background())
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 || !strings.HasPrefix(entries[0].Name(), "ffmpeg-out.mp4-") {
+		t.Fatalf("expected one kept log, got %v", entries)
+	}
+	if !strings.Contains(err.Error(), entries[0].Name()) {
+		t.Fatalf("error does not reference log file: %v", err)
+	}
+}
+
+func TestValidH264ProfileLevel(t *testing.T) {
+	if !ValidH264Profile("baseline") || ValidH264Profile("High") || ValidH264Profile("high10") {
+		t.Error("unexpected profile validation")
+	}
+	for _, l := range []string{"3", "3.0", "3.1", "1b", "5.2"} {
+		if !ValidH264Level(l) {
+			t.Errorf("level %q should be valid", l)
+		}
+	}
+	for _, l := range []string{"", "31", "3.3", "7"} {
+		if ValidH264Level(l) {
+			t.Errorf("level %q should be invalid", l)
+		}
+	}
+}
+
+func TestValidX264Preset(t *testing.T) {
+	if !ValidX264Preset("slow") || !ValidX264Preset("veryfast") {
+		t.Fatal("expected known presets to be valid")
+	}
+	if ValidX264Preset("Slow") || ValidX264Preset("") || ValidX264Preset("fastest") {
+		t.Fatal("expected unknown presets to be rejected")
+	}
+}
+
+func TestCommand_Env(t *testing.T) {
+	if env := New("ffmpeg").Env(nil).environ(); env != nil {
+		t.Fatalf("no overrides: got %d entries, want nil to inherit", len(env))
+	}
+
+	t.Setenv("SPLITSCREEN_TEST_INHERITED", "kept")
+	t.Setenv("SPLITSCREEN_TEST_OVERRIDDEN", "old")
+	env := New("ffmpeg").
+		Env(map[string]string{"SPLITSCREEN_TEST_OVERRIDDEN": "new"}).
+		Env(map[string]string{"SPLITSCREEN_TEST_ADDED": "added"}).
+		environ()
+
+	// exec.Cmd keeps the last value of a duplicated key
+	lookup := func(key string) (string, bool) {
+		for i := len(env) - 1; i >= 0; i-- {
+			if v, ok := strings.CutPrefix(env[i], key+"="); ok {
+				return v, true
+			}
+		}
+		return "", false
+	}
+	for key, want := range map[string]string{
+		"SPLITSCREEN_TEST_INHERITED":  "kept",
+		"SPLITSCREEN_TEST_OVERRIDDEN": "new",
+		"SPLITSCREEN_TEST_ADDED":      "added",
+	} {
+		if got, ok := lookup(key); !ok || got != want {
+			t.Errorf("%s = %q (set %v), want %q", key, got, ok, want)
+		}
+	}
+	if _, ok := os.LookupEnv("SPLITSCREEN_TEST_ADDED"); ok {
+		t.Error("Env leaked into the worker's own environment")
+	}
+}
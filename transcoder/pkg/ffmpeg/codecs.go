@@ -0,0 +1,148 @@
+package ffmpeg
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+)
+
+// avcProfiles maps ffprobe's H.264 profile names to profile_idc and constraint flags
+// as used in RFC 6381 "avc1.PPCCLL" codec strings.
+var avcProfiles = map[string][2]byte{
+	"Constrained Baseline":  {0x42, 0xE0},
+	"Baseline":              {0x42, 0x00},
+	"Main":                  {0x4D, 0x40},
+	"Extended":              {0x58, 0x00},
+	"High":                  {0x64, 0x00},
+	"High 10":               {0x6E, 0x00},
+	"High 4:2:2":            {0x7A, 0x00},
+	"High 4:4:4 Predictive": {0xF4, 0x00},
+}
+
+// AVC1Codec returns the "avc1.PPCCLL" codec string for an H.264 stream with the given
+// ffprobe profile name and level (e.g. "High", 31 -> "avc1.64001f").
+func AVC1Codec(profile string, level int) (string, bool) {
+	p, ok := avcProfiles[profile]
+	if !ok || level <= 0 || level > 0xFF {
+		return "", false
+	}
+	return fmt.Sprintf("avc1.%02x%02x%02x", p[0], p[1], level), true
+}
+
+// vp9Levels lists the VP9 levels with their maximum luma picture size and luma
+// sample rate, lowest first.
+var vp9Levels = []struct {
+	level      int
+	maxPicture int64
+	maxRate    int64
+}{
+	{10, 36864, 829440},
+	{11, 73728, 2764800},
+	{20, 122880, 4608000},
+	{21, 245760, 9216000},
+	{30, 552960, 20736000},
+	{31, 983040, 36864000},
+	{40, 2228224, 83558400},
+	{41, 2228224, 160432128},
+	{50, 8912896, 311951360},
+	{51, 8912896, 588251136},
+	{52, 8912896, 1176502272},
+	{60, 35651584, 1176502272},
+	{61, 35651584, 2353004544},
+	{62, 35651584, 4706009088},
+}
+
+// VP9Level returns the lowest VP9 level (e.g. 31 for 3.1) that fits the given frame
+// size and rate. VP9 bitstreams don't carry a level, so it is derived from the output.
+func VP9Level(width, height int, fps float64) (int, bool) {
+	if width <= 0 || height <= 0 {
+		return 0, false
+	}
+	if fps <= 0 {
+		fps = 30
+	}
+	picture := int64(width) * int64(height)
+	rate := int64(math.Ceil(float64(picture) * fps))
+	for _, l := range vp9Levels {
+		if picture <= l.maxPicture && rate <= l.maxRate {
+			return l.level, true
+		}
+	}
+	return 0, false
+}
+
+// VP09Codec returns the "vp09.PP.LL.DD" codec string for a VP9 stream with the given
+// ffprobe profile name ("Profile 0" … "Profile 3"), level and bit depth.
+func VP09Codec(profile string, level, bitDepth int) (string, bool) {
+	p, ok := strings.CutPrefix(profile, "Profile ")
+	if !ok || len(p) != 1 || p[0] < '0' || p[0] > '3' || level <= 0 {
+		return "", false
+	}
+	return fmt.Sprintf("vp09.0%s.%02d.%02d", p, level, bitDepth), true
+}
+
+// AV01Codec returns the "av01.P.LLT.DD" codec string for an AV1 stream with the given
+// ffprobe profile name, level (seq_level_idx) and bit depth. Main tier is assumed,
+// which is what libaom and SVT-AV1 produce by default.
+func AV01Codec(profile string, level, bitDepth int) (string, bool) {
+	p := slices.Index([]string{"Main", "High", "Professional"}, profile)
+	if p < 0 || level < 0 || level > 31 {
+		return "", false
+	}
+	return fmt.Sprintf("av01.%d.%02dM.%02d", p, level, bitDepth), true
+}
+
+// PixFmtBitDepth returns the bit depth of an ffmpeg pixel format name
+// (e.g. "yuv420p10le" -> 10), defaulting to 8.
+func PixFmtBitDepth(pixFmt string) int {
+	for _, d := range []int{10, 12, 16} {
+		if strings.Contains(pixFmt, fmt.Sprintf("p%d", d)) {
+			return d
+		}
+	}
+	return 8
+}
+
+// MP4ACodec returns the "mp4a.40.N" codec string for an AAC stream with the given
+// ffprobe profile name; LC is assumed when the profile is unknown.
+func MP4ACodec(profile string) string {
+	switch strings.ToUpper(profile) {
+	case "HE-AAC":
+		return "mp4a.40.5"
+	case "HE-AACV2":
+		return "mp4a.40.29"
+	}
+	return "mp4a.40.2"
+}
+
+// HLSCodecs builds the CODECS attribute for an HLS variant from a probe of its output:
+// the video codec and, when present, the first audio stream. It reports false when the
+// video codec can't be expressed (anything but H.264, VP9 or AV1 with a known profile/level).
+func HLSCodecs(p ProbeInfo) (string, bool) {
+	var video string
+	var ok bool
+	switch p.VideoCodec {
+	case "h264":
+		video, ok = AVC1Codec(p.VideoProfile, p.VideoLevel)
+	case "vp9":
+		var level int
+		if level, ok = VP9Level(p.Width, p.Height, p.AvgFrameRate); ok {
+			video, ok = VP09Codec(p.VideoProfile, level, PixFmtBitDepth(p.PixFmt))
+		}
+	case "av1":
+		video, ok = AV01Codec(p.VideoProfile, p.VideoLevel, PixFmtBitDepth(p.PixFmt))
+	}
+	if !ok {
+		return "", false
+	}
+	codecs := []string{video}
+	if len(p.AudioStreams) > 0 {
+		if a := p.AudioStreams[0]; a.Codec == "aac" {
+			codecs = append(codecs, MP4ACodec(a.Profile))
+		} else {
+			return "", false
+		}
+	}
+	return strings.Join(codecs, ","), true
+}
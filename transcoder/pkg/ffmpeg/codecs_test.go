@@ -0,0 +1,73 @@
+package ffmpeg
+
+import "testing"
+
+func TestAVC1Codec(t *testing.T) {
+	cases := []struct {
+		profile string
+		level   int
+		want    string
+	}{
+		{"High", 31, "avc1.64001f"},
+		{"High", 40, "avc1.640028"},
+		{"Main", 30, "avc1.4d401e"},
+		{"Constrained Baseline", 30, "avc1.42e01e"},
+	}
+	for _, c := range cases {
+		got, ok := AVC1Codec(c.profile, c.level)
+		if !ok || got != c.want {
+			t.Errorf("AVC1Codec(%q, %d) = %q, %v; want %q", c.profile, c.level, got, ok, c.want)
+		}
+	}
+	if _, ok := AVC1Codec("Unknown", 31); ok {
+		t.Error("expected unknown profile to fail")
+	}
+}
+
+func TestHLSCodecs(t *testing.T) {
+	p := ProbeInfo{
+		VideoCodec: "h264", VideoProfile: "High", VideoLevel: 31,
+		AudioStreams: []AudioStream{{Codec: "aac", Profile: "LC"}},
+	}
+	if got, ok := HLSCodecs(p); !ok || got != "avc1.64001f,mp4a.40.2" {
+		t.Fatalf("got %q %v", got, ok)
+	}
+	p.AudioStreams = nil
+	if got, ok := HLSCodecs(p); !ok || got != "avc1.64001f" {
+		t.Fatalf("video only: got %q %v", got, ok)
+	}
+	p = ProbeInfo{
+		VideoCodec: "vp9", VideoProfile: "Profile 0", Width: 1280, Height: 720, AvgFrameRate: 30, PixFmt: "yuv420p",
+		AudioStreams: []AudioStream{{Codec: "aac", Profile: "LC"}},
+	}
+	if got, ok := HLSCodecs(p); !ok || got != "vp09.00.31.08,mp4a.40.2" {
+		t.Fatalf("vp9: got %q %v", got, ok)
+	}
+	p.VideoCodec, p.VideoProfile, p.VideoLevel, p.PixFmt = "av1", "Main", 8, "yuv420p10le"
+	if got, ok := HLSCodecs(p); !ok || got != "av01.0.08M.10,mp4a.40.2" {
+		t.Fatalf("av1: got %q %v", got, ok)
+	}
+	p.VideoCodec = "hevc"
+	if _, ok := HLSCodecs(p); ok {
+		t.Fatal("expected hevc to be unsupported")
+	}
+}
+
+func TestVP9Level(t *testing.T) {
+	cases := []struct {
+		w, h int
+		fps  float64
+		want int
+	}{
+		{426, 240, 30, 20},
+		{1280, 720, 30, 31},
+		{1920, 1080, 30, 40},
+		{1920, 1080, 60, 41},
+		{3840, 2160, 30, 50},
+	}
+	for _, c := range cases {
+		if got, ok := VP9Level(c.w, c.h, c.fps); !ok || got != c.want {
+			t.Errorf("VP9Level(%d, %d, %v) = %d, %v; want %d", c.w, c.h, c.fps, got, ok, c.want)
+		}
+	}
+}
@@ -3,7 +3,9 @@ package ffmpeg
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
 	"os/exec"
 	"strconv"
 	"strings"
@@ -14,6 +16,110 @@ type ProbeInfo struct {
 	Height       int
 	DurationSec  float64
 	AvgFrameRate float64
+	// r_frame_rate, the lowest rate every frame timestamp fits; far above AvgFrameRate
+	// when frames are unevenly spaced
+	RealFrameRate float64
+	// Frames are not evenly spaced (e.g. screen and phone recordings), per
+	// IsVariableFrameRate
+	VariableFrameRate bool
+	// Video bitrate in bits/s; the container's overall bitrate when the stream
+	// doesn't report one (e.g. Matroska). 0 when unknown.
+	BitrateBps   int64
+	HasAudio     bool
+	Chapters     []Chapter
+	Subtitles    []SubtitleStream
+	AudioStreams []AudioStream
+	// Codec of the first video stream, e.g. "h264" / "High" / 31 (level 3.1)
+	VideoCodec   string
+	VideoProfile string
+	VideoLevel   int
+	PixFmt       string // e.g. "yuv420p", "yuv420p10le"
+	// Color metadata of the first video stream, as reported by ffprobe
+	// (e.g. "smpte2084", "bt2020"); empty when untagged.
+	ColorTransfer  string
+	ColorPrimaries string
+	ColorSpace     string
+	// Container title and language tags; empty when untagged
+	Title    string
+	Language string
+}
+
+// IsHDR reports whether the video uses an HDR transfer function (PQ or HLG).
+// Untagged sources are assumed SDR.
+func (p ProbeInfo) IsHDR() bool {
+	switch p.ColorTransfer {
+	case "smpte2084", "arib-std-b67":
+		return true
+	}
+	return false
+}
+
+// AudioStream is an embedded audio track.
+type AudioStream struct {
+	Index      int // absolute stream index in the container
+	AudioIndex int // index among audio streams, as used by 0:a:N
+	Codec      string
+	Profile    string // e.g. "LC" or "HE-AAC" for AAC
+	Channels   int
+	SampleRate int    // Hz; 0 when unknown
+	BitrateBps int64  // 0 when unknown (e.g. Matroska)
+	Language   string // ISO 639 tag from the container, e.g. "eng"; may be empty
+	Title      string
+	Default    bool
+}
+
+// SubtitleStream is an embedded subtitle track.
+type SubtitleStream struct {
+	Index    int    // absolute stream index in the container
+	SubIndex int    // index among subtitle streams, as used by 0:s:N and subtitles=si=N
+	Codec    string // e.g. "subrip", "ass", "mov_text", "hdmv_pgs_subtitle"
+	Language string // ISO 639 tag from the container, e.g. "eng"; may be empty
+	Title    string
+	Default  bool
+	Forced   bool
+}
+
+// IsText reports whether the stream is text-based. Only text subtitles can be
+// rendered by the subtitles filter or converted to WebVTT; bitmap formats
+// (PGS, VobSub, DVB) cannot.
+func (s SubtitleStream) IsText() bool {
+	switch s.Codec {
+	case "subrip", "srt", "ass", "ssa", "mov_text", "webvtt", "text", "microdvd", "subviewer":
+		return true
+	}
+	return false
+}
+
+// Chapter is a chapter marker embedded in the source container.
+type Chapter struct {
+	StartSec float64
+	EndSec   float64
+	Title    string
+}
+
+// ErrInvalidInput is returned (wrapped, with the reason) by Probe when ffprobe ran but
+// could not read the input as media: the file is corrupt, truncated or not media at all,
+// so probing it again won't help.
+var ErrInvalidInput = errors.New("invalid input")
+
+// invalidInputReasons maps ffprobe errors that blame the input itself to the reason
+// reported for them.
+var invalidInputReasons = []struct{ match, reason string }{
+	{"moov atom not found", "moov atom not found (truncated or incomplete upload)"},
+	{"End of file", "unexpected end of file (truncated upload)"},
+	{"Invalid data found when processing input", "invalid data (not a supported media file)"},
+	{"could not find codec parameters", "no decodable streams"},
+}
+
+// invalidInputReason returns why ffprobe's output blames the input, or "" if it
+// doesn't.
+func invalidInputReason(output string) string {
+	for _, r := range invalidInputReasons {
+		if strings.Contains(output, r.match) {
+			return r.reason
+		}
+	}
+	return ""
 }
 
 func Probe(ctx context.Context, ffprobePath, inputPath string) (ProbeInfo, error) {
@@ -22,8 +128,8 @@ func Probe(ctx context.Context, ffprobePath, inputPath string) (ProbeInfo, error
 	}
 	args := []string{
 		"-v", "error",
-		"-select_streams", "v:0",
-		"-show_entries", "stream=width,height,avg_frame_rate:format=duration",
+		"-show_entries", "stream=index,codec_type,codec_name,profile,level,width,height,pix_fmt,avg_frame_rate,r_frame_rate,bit_rate,channels,sample_rate,color_transfer,color_primaries,color_space:stream_tags=language,title:stream_disposition=default,forced:format=duration,bit_rate:format_tags=title,language",
+		"-show_chapters",
 		"-of", "json",
 		inputPath,
 	}
@@ -32,6 +138,13 @@ func Probe(ctx context.Context, ffprobePath, inputPath string) (ProbeInfo, error
 	if err != nil {
 		// Include stderr output in error message for debugging
 		stderr := string(out)
+		// Only a clean exit is a verdict on the file; a killed or crashed ffprobe isn't
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.Exited() && ctx.Err() == nil {
+			if reason := invalidInputReason(stderr); reason != "" {
+				return ProbeInfo{}, fmt.Errorf("%w: %s", ErrInvalidInput, reason)
+			}
+		}
 		if stderr != "" {
 			return ProbeInfo{}, fmt.Errorf("ffprobe failed: %w (output: %s)", err, stderr)
 		}
@@ -39,31 +152,140 @@ func Probe(ctx context.Context, ffprobePath, inputPath string) (ProbeInfo, error
 	}
 	var parsed struct {
 		Streams []struct {
-			Width        int    `json:"width"`
-			Height       int    `json:"height"`
-			AvgFrameRate string `json:"avg_frame_rate"`
+			CodecType      string `json:"codec_type"`
+			Width          int    `json:"width"`
+			Height         int    `json:"height"`
+			AvgFrameRate   string `json:"avg_frame_rate"`
+			RFrameRate     string `json:"r_frame_rate"`
+			Index          int    `json:"index"`
+			CodecName      string `json:"codec_name"`
+			Profile        string `json:"profile"`
+			Level          int    `json:"level"`
+			PixFmt         string `json:"pix_fmt"`
+			BitRate        string `json:"bit_rate"`
+			Channels       int    `json:"channels"`
+			SampleRate     string `json:"sample_rate"`
+			ColorTransfer  string `json:"color_transfer"`
+			ColorPrimaries string `json:"color_primaries"`
+			ColorSpace     string `json:"color_space"`
+			Tags           struct {
+				Language string `json:"language"`
+				Title    string `json:"title"`
+			} `json:"tags"`
+			Disposition struct {
+				Default int `json:"default"`
+				Forced  int `json:"forced"`
+			} `json:"disposition"`
 		} `json:"streams"`
 		Format struct {
 			Duration string `json:"duration"`
+			BitRate  string `json:"bit_rate"`
+			Tags     struct {
+				Title    string `json:"title"`
+				Language string `json:"language"`
+			} `json:"tags"`
 		} `json:"format"`
+		Chapters []struct {
+			StartTime string `json:"start_time"`
+			EndTime   string `json:"end_time"`
+			Tags      struct {
+				Title string `json:"title"`
+			} `json:"tags"`
+		} `json:"chapters"`
 	}
 	if err := json.Unmarshal(out, &parsed); err != nil {
 		return ProbeInfo{}, fmt.Errorf("parse ffprobe json: %w", err)
 	}
 	var pi ProbeInfo
-	if len(parsed.Streams) > 0 {
-		pi.Width = parsed.Streams[0].Width
-		pi.Height = parsed.Streams[0].Height
-		pi.AvgFrameRate = parseFraction(parsed.Streams[0].AvgFrameRate)
+	foundVideo := false
+	for _, st := range parsed.Streams {
+		switch st.CodecType {
+		case "video":
+			if foundVideo {
+				continue
+			}
+			foundVideo = true
+			pi.Width = st.Width
+			pi.Height = st.Height
+			pi.AvgFrameRate = parseFraction(st.AvgFrameRate)
+			pi.RealFrameRate = parseFraction(st.RFrameRate)
+			pi.VariableFrameRate = IsVariableFrameRate(pi.AvgFrameRate, pi.RealFrameRate)
+			pi.VideoCodec = st.CodecName
+			pi.VideoProfile = st.Profile
+			pi.VideoLevel = st.Level
+			pi.PixFmt = st.PixFmt
+			pi.BitrateBps, _ = strconv.ParseInt(st.BitRate, 10, 64)
+			pi.ColorTransfer = st.ColorTransfer
+			pi.ColorPrimaries = st.ColorPrimaries
+			pi.ColorSpace = st.ColorSpace
+		case "audio":
+			pi.HasAudio = true
+			sampleRate, _ := strconv.Atoi(st.SampleRate)
+			bitrate, _ := strconv.ParseInt(st.BitRate, 10, 64)
+			pi.AudioStreams = append(pi.AudioStreams, AudioStream{
+				Index:      st.Index,
+				AudioIndex: len(pi.AudioStreams),
+				Codec:      st.CodecName,
+				Profile:    st.Profile,
+				Channels:   st.Channels,
+				SampleRate: sampleRate,
+				BitrateBps: bitrate,
+				Language:   strings.ToLower(strings.TrimSpace(st.Tags.Language)),
+				Title:      strings.TrimSpace(st.Tags.Title),
+				Default:    st.Disposition.Default == 1,
+			})
+		case "subtitle":
+			pi.Subtitles = append(pi.Subtitles, SubtitleStream{
+				Index:    st.Index,
+				SubIndex: len(pi.Subtitles),
+				Codec:    st.CodecName,
+				Language: strings.ToLower(strings.TrimSpace(st.Tags.Language)),
+				Title:    strings.TrimSpace(st.Tags.Title),
+				Default:  st.Disposition.Default == 1,
+				Forced:   st.Disposition.Forced == 1,
+			})
+		}
 	}
 	if parsed.Format.Duration != "" {
 		if d, err := strconv.ParseFloat(parsed.Format.Duration, 64); err == nil {
 			pi.DurationSec = d
 		}
 	}
+	if pi.BitrateBps <= 0 {
+		pi.BitrateBps, _ = strconv.ParseInt(parsed.Format.BitRate, 10, 64)
+	}
+	pi.Title = strings.TrimSpace(parsed.Format.Tags.Title)
+	pi.Language = strings.ToLower(strings.TrimSpace(parsed.Format.Tags.Language))
+	for _, ch := range parsed.Chapters {
+		start, err := strconv.ParseFloat(ch.StartTime, 64)
+		if err != nil {
+			continue
+		}
+		end, err := strconv.ParseFloat(ch.EndTime, 64)
+		if err != nil || end <= start {
+			continue
+		}
+		pi.Chapters = append(pi.Chapters, Chapter{
+			StartSec: start,
+			EndSec:   end,
+			Title:    strings.TrimSpace(ch.Tags.Title),
+		})
+	}
 	return pi, nil
 }
 
+// IsVariableFrameRate reports whether a stream's average frame rate and r_frame_rate
+// disagree enough that its frames can't be evenly spaced. Small rounding differences
+// and interlaced streams, whose r_frame_rate is the field rate (twice the frame rate),
+// count as constant; so do streams missing either rate.
+func IsVariableFrameRate(avg, real float64) bool {
+	if avg <= 0 || real <= 0 {
+		return false
+	}
+	near := func(a, b float64) bool { return math.Abs(a-b) <= 0.01*b }
+	return !near(avg, real) && !near(2*avg, real)
+}
+
 func parseFraction(s string) float64 {
 	parts := strings.Split(s, "/")
 	if len(parts) == 2 {
@@ -0,0 +1,41 @@
+package ffmpeg
+
+import "testing"
+
+func TestInvalidInputReason(t *testing.T) {
+	cases := []struct {
+		output string
+		want   string
+	}{
+		{"[mov,mp4,m4a,3gp,3g2,mj2 @ 0x5581] moov atom not found\nin.mp4: Invalid data found when processing input", "moov atom not found (truncated or incomplete upload)"},
+		{"in.txt: Invalid data found when processing input", "invalid data (not a supported media file)"},
+		{"in.mp4: End of file", "unexpected end of file (truncated upload)"},
+		{"in.mp4: No such file or directory", ""},
+		{"", ""},
+	}
+	for _, tc := range cases {
+		if got := invalidInputReason(tc.output); got != tc.want {
+			t.Errorf("invalidInputReason(%q) = %q, want %q", tc.output, got, tc.want)
+		}
+	}
+}
+
+func TestIsVariableFrameRate(t *testing.T) {
+	cases := []struct {
+		avg, real float64
+		want      bool
+	}{
+		{30000.0 / 1001, 30000.0 / 1001, false},
+		{23.975, 24000.0 / 1001, false}, // rounded average
+		{25, 50, false},                 // interlaced: r_frame_rate is the field rate
+		{29.32, 60, true},               // phone recording
+		{14.7, 90000, true},             // screen recording on the MPEG-TS clock
+		{0, 30, false},
+		{30, 0, false},
+	}
+	for _, tc := range cases {
+		if got := IsVariableFrameRate(tc.avg, tc.real); got != tc.want {
+			t.Errorf("IsVariableFrameRate(%v, %v) = %v, want %v", tc.avg, tc.real, got, tc.want)
+		}
+	}
+}
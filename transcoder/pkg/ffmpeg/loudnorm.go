@@ -0,0 +1,125 @@
+package ffmpeg
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LoudnormTarget holds the EBU R128 targets passed to the loudnorm filter.
+type LoudnormTarget struct {
+	I   float64 // integrated loudness in LUFS, e.g. -16
+	TP  float64 // max true peak in dBTP, e.g. -1.5
+	LRA float64 // loudness range in LU, e.g. 11
+}
+
+// LoudnormStats holds the values measured by a loudnorm analysis pass.
+type LoudnormStats struct {
+	InputI       float64
+	InputTP      float64
+	InputLRA     float64
+	InputThresh  float64
+	TargetOffset float64
+}
+
+// MeasureLoudness runs the first loudnorm pass over the input's audio and returns
+// the measured values for use in a second, linear normalization pass.
+func MeasureLoudness(ctx context.Context, ffmpegPath, inputPath string, target LoudnormTarget) (LoudnormStats, error) {
+	return MeasureLoudnessStream(ctx, ffmpegPath, inputPath, -1, target)
+}
+
+// MeasureLoudnessStream is MeasureLoudness for audio stream 0:a:audioIndex; a negative
+// index measures the stream ffmpeg selects by default.
+func MeasureLoudnessStream(ctx context.Context, ffmpegPath, inputPath string, audioIndex int, target LoudnormTarget) (LoudnormStats, error) {
+	return MeasureLoudnessRange(ctx, ffmpegPath, inputPath, audioIndex, 0, 0, target)
+}
+
+// MeasureLoudnessRange is MeasureLoudnessStream over duration of the input from start;
+// a zero duration runs to the end.
+func MeasureLoudnessRange(ctx context.Context, ffmpegPath, inputPath string, audioIndex int, start, duration time.Duration, target LoudnormTarget) (LoudnormStats, error) {
+	var stderr bytes.Buffer
+	cmd := New(ffmpegPath).
+		StartAt(start).
+		Duration(duration).
+		Input(inputPath).
+		Arg("-vn", "-sn", "-dn")
+	if audioIndex >= 0 {
+		cmd.Arg("-map", fmt.Sprintf("0:a:%d", audioIndex))
+	}
+	cmd.AudioFilter(target.filter() + ":print_format=json").
+		Format("null").
+		StderrTo(&stderr).
+		Output("-")
+	if err := cmd.Run(ctx); err != nil {
+		return LoudnormStats{}, fmt.Errorf("loudnorm analysis: %w", err)
+	}
+	return parseLoudnormStats(stderr.String())
+}
+
+// Filter returns the second-pass loudnorm filter using the measured values.
+func (t LoudnormTarget) Filter(measured LoudnormStats) string {
+	return fmt.Sprintf("%s:measured_I=%.2f:measured_TP=%.2f:measured_LRA=%.2f:measured_thresh=%.2f:offset=%.2f:linear=true",
+		t.filter(),
+		measured.InputI,
+		measured.InputTP,
+		measured.InputLRA,
+		measured.InputThresh,
+		measured.TargetOffset,
+	)
+}
+
+func (t LoudnormTarget) filter() string {
+	return fmt.Sprintf("loudnorm=I=%s:TP=%s:LRA=%s",
+		strconv.FormatFloat(t.I, 'f', -1, 64),
+		strconv.FormatFloat(t.TP, 'f', -1, 64),
+		strconv.FormatFloat(t.LRA, 'f', -1, 64),
+	)
+}
+
+// parseLoudnormStats extracts the JSON block printed by loudnorm (print_format=json)
+// from ffmpeg's stderr output.
+func parseLoudnormStats(stderr string) (LoudnormStats, error) {
+	start := strings.LastIndex(stderr, "{")
+	if start < 0 {
+		return LoudnormStats{}, errors.New("loudnorm output not found")
+	}
+	end := strings.Index(stderr[start:], "}")
+	if end < 0 {
+		return LoudnormStats{}, errors.New("loudnorm output truncated")
+	}
+	var raw struct {
+		InputI       string `json:"input_i"`
+		InputTP      string `json:"input_tp"`
+		InputLRA     string `json:"input_lra"`
+		InputThresh  string `json:"input_thresh"`
+		TargetOffset string `json:"target_offset"`
+	}
+	if err := json.Unmarshal([]byte(stderr[start:start+end+1]), &raw); err != nil {
+		return LoudnormStats{}, fmt.Errorf("parse loudnorm json: %w", err)
+	}
+	var stats LoudnormStats
+	fields := []struct {
+		name string
+		src  string
+		dst  *float64
+	}{
+		{"input_i", raw.InputI, &stats.InputI},
+		{"input_tp", raw.InputTP, &stats.InputTP},
+		{"input_lra", raw.InputLRA, &stats.InputLRA},
+		{"input_thresh", raw.InputThresh, &stats.InputThresh},
+		{"target_offset", raw.TargetOffset, &stats.TargetOffset},
+	}
+	for _, f := range fields {
+		v, err := strconv.ParseFloat(strings.TrimSpace(f.src), 64)
+		if err != nil {
+			return LoudnormStats{}, fmt.Errorf("parse loudnorm %s %q: %w", f.name, f.src, err)
+		}
+		*f.dst = v
+	}
+	return stats, nil
+}
@@ -0,0 +1,35 @@
+package ffmpeg
+
+import "testing"
+
+func TestParseLoudnormStats(t *testing.T) {
+	stderr := `size=N/A time=00:00:10.00 bitrate=N/A speed= 250x
+[Parsed_loudnorm_0 @ 0x5581c8a0c2c0]
+{
+	"input_i" : "-27.61",
+	"input_tp" : "-4.47",
+	"input_lra" : "18.06",
+	"input_thresh" : "-39.20",
+	"output_i" : "-16.58",
+	"output_tp" : "-1.50",
+	"output_lra" : "14.78",
+	"output_thresh" : "-27.71",
+	"normalization_type" : "dynamic",
+	"target_offset" : "0.58"
+}
+progress=end`
+	got, err := parseLoudnormStats(stderr)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := LoudnormStats{InputI: -27.61, InputTP: -4.47, InputLRA: 18.06, InputThresh: -39.20, TargetOffset: 0.58}
+	if got != want {
+		t.Fatalf("unexpected stats: got %+v want %+v", got, want)
+	}
+
+	target := LoudnormTarget{I: -16, TP: -1.5, LRA: 11}
+	wantFilter := "loudnorm=I=-16:TP=-1.5:LRA=11:measured_I=-27.61:measured_TP=-4.47:measured_LRA=18.06:measured_thresh=-39.20:offset=0.58:linear=true"
+	if f := target.Filter(got); f != wantFilter {
+		t.Fatalf("unexpected filter: got %q want %q", f, wantFilter)
+	}
+}
@@ -0,0 +1,98 @@
+package ffmpeg
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FrameStats describes the luma of a frame, as measured by the signalstats filter (0-255).
+type FrameStats struct {
+	TimeSec float64 // on the source timeline
+	YAvg    float64
+	YLow    float64 // 10th percentile
+	YHigh   float64 // 90th percentile
+}
+
+// Contrast returns the spread between the frame's dark and bright luma; flat (blank or
+// solid color) frames score close to zero.
+func (s FrameStats) Contrast() float64 {
+	return s.YHigh - s.YLow
+}
+
+// RepresentativeFrame picks the most representative frame in [start, start+window) with
+// the thumbnail filter and returns its luma stats. Frames are downscaled first, which
+// keeps the analysis cheap without changing the pick much.
+func RepresentativeFrame(ctx context.Context, ffmpegPath, inputPath string, start, window time.Duration) (FrameStats, error) {
+	var stderr bytes.Buffer
+	cmd := New(ffmpegPath).
+		StartAt(start).
+		Duration(window).
+		Input(inputPath).
+		Arg("-an", "-sn", "-dn").
+		Filter("scale=320:-2").
+		Filter("thumbnail=n=300"). // flushed at the end of the window when it holds fewer frames
+		Filter("signalstats").
+		Filter("metadata=print").
+		Arg("-frames:v", "1").
+		Format("null").
+		StderrTo(&stderr).
+		Output("-")
+	if err := cmd.Run(ctx); err != nil {
+		return FrameStats{}, fmt.Errorf("frame analysis: %w", err)
+	}
+	stats, ok := parseSignalstats(stderr.String())
+	if !ok {
+		return FrameStats{}, errors.New("frame analysis: no signalstats output")
+	}
+	// Input seeking resets timestamps to zero, so shift back onto the source timeline
+	stats.TimeSec += start.Seconds()
+	return stats, nil
+}
+
+// parseSignalstats reads the first frame printed by the metadata filter, e.g.
+// [Parsed_metadata_3 @ 0x55d0] frame:0    pts:61440   pts_time:4.8
+// [Parsed_metadata_3 @ 0x55d0] lavfi.signalstats.YAVG=83.2
+func parseSignalstats(stderr string) (FrameStats, bool) {
+	var s FrameStats
+	frames, found := 0, false
+	scanner := bufio.NewScanner(strings.NewReader(stderr))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.Contains(line, "Parsed_metadata") {
+			continue
+		}
+		if _, rest, ok := strings.Cut(line, "pts_time:"); ok {
+			if frames++; frames > 1 {
+				break
+			}
+			if fields := strings.Fields(rest); len(fields) > 0 {
+				s.TimeSec, _ = strconv.ParseFloat(fields[0], 64)
+			}
+			continue
+		}
+		_, kv, ok := strings.Cut(line, "lavfi.signalstats.")
+		if !ok {
+			continue
+		}
+		key, val, _ := strings.Cut(kv, "=")
+		v, err := strconv.ParseFloat(strings.TrimSpace(val), 64)
+		if err != nil {
+			continue
+		}
+		switch key {
+		case "YAVG":
+			s.YAvg, found = v, true
+		case "YLOW":
+			s.YLow = v
+		case "YHIGH":
+			s.YHigh = v
+		}
+	}
+	return s, found
+}
@@ -0,0 +1,28 @@
+package ffmpeg
+
+import "testing"
+
+func TestParseSignalstats(t *testing.T) {
+	stderr := `frame=    0 fps=0.0 q=0.0 size=N/A time=00:00:00.00
+[Parsed_metadata_3 @ 0x55d0c8a0] frame:0    pts:61440   pts_time:4.8
+[Parsed_metadata_3 @ 0x55d0c8a0] lavfi.signalstats.YMIN=16
+[Parsed_metadata_3 @ 0x55d0c8a0] lavfi.signalstats.YLOW=21
+[Parsed_metadata_3 @ 0x55d0c8a0] lavfi.signalstats.YAVG=83.25
+[Parsed_metadata_3 @ 0x55d0c8a0] lavfi.signalstats.YHIGH=180
+[Parsed_metadata_3 @ 0x55d0c8a0] frame:1    pts:62000   pts_time:4.9
+[Parsed_metadata_3 @ 0x55d0c8a0] lavfi.signalstats.YAVG=10
+progress=end`
+	s, ok := parseSignalstats(stderr)
+	if !ok {
+		t.Fatal("expected stats")
+	}
+	if s.TimeSec != 4.8 || s.YAvg != 83.25 || s.YLow != 21 || s.YHigh != 180 {
+		t.Fatalf("unexpected stats: %+v", s)
+	}
+	if s.Contrast() != 159 {
+		t.Fatalf("contrast = %v", s.Contrast())
+	}
+	if _, ok := parseSignalstats("progress=end"); ok {
+		t.Fatal("expected no stats")
+	}
+}
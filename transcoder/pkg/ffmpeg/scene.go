@@ -0,0 +1,68 @@
+package ffmpeg
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DetectScenes returns the timestamps (in seconds) of frames whose scene-change score
+// exceeds threshold (0-1, e.g. 0.4), optionally limited to the [start, end) window.
+// Frames are downscaled before scoring since full resolution adds cost without changing cuts much.
+func DetectScenes(ctx context.Context, ffmpegPath, inputPath string, threshold float64, start, end time.Duration) ([]float64, error) {
+	var stderr bytes.Buffer
+	cmd := New(ffmpegPath).
+		StartAt(start).
+		Input(inputPath)
+	if end > start {
+		cmd.Duration(end - start)
+	}
+	cmd.Arg("-an", "-sn", "-dn").
+		Filter("scale=320:-2").
+		Filter(fmt.Sprintf("select='gt(scene,%s)'", strconv.FormatFloat(threshold, 'f', -1, 64))).
+		Filter("showinfo").
+		Arg("-fps_mode", "vfr").
+		Format("null").
+		StderrTo(&stderr).
+		Output("-")
+	if err := cmd.Run(ctx); err != nil {
+		return nil, fmt.Errorf("scene detection: %w", err)
+	}
+	// Input seeking resets timestamps to zero, so shift them back onto the source timeline
+	times := parseShowinfoTimes(stderr.String())
+	for i := range times {
+		times[i] += start.Seconds()
+	}
+	return times, nil
+}
+
+// parseShowinfoTimes extracts pts_time values from showinfo filter log lines, e.g.
+// [Parsed_showinfo_2 @ 0x55d0] n:   0 pts:  61440 pts_time:4.8     duration: ...
+func parseShowinfoTimes(stderr string) []float64 {
+	var times []float64
+	scanner := bufio.NewScanner(strings.NewReader(stderr))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.Contains(line, "Parsed_showinfo") {
+			continue
+		}
+		_, rest, ok := strings.Cut(line, "pts_time:")
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(rest)
+		if len(fields) == 0 {
+			continue
+		}
+		if v, err := strconv.ParseFloat(fields[0], 64); err == nil {
+			times = append(times, v)
+		}
+	}
+	sort.Float64s(times)
+	return times
+}
@@ -0,0 +1,22 @@
+package ffmpeg
+
+import "testing"
+
+func TestParseShowinfoTimes(t *testing.T) {
+	stderr := `frame=    0 fps=0.0 q=0.0 size=N/A time=00:00:00.00
+[Parsed_showinfo_2 @ 0x55d0c8a0] config in time_base: 1/12800, frame_rate: 25/1
+[Parsed_showinfo_2 @ 0x55d0c8a0] n:   0 pts:  61440 pts_time:4.8     duration:    512 duration_time:0.04
+[Parsed_showinfo_2 @ 0x55d0c8a0] n:   1 pts: 158720 pts_time:12.4    duration:    512 duration_time:0.04
+out_time_ms=12400000
+progress=end`
+	got := parseShowinfoTimes(stderr)
+	want := []float64{4.8, 12.4}
+	if len(got) != len(want) {
+		t.Fatalf("unexpected times: got %v want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("time %d: got %v want %v", i, got[i], want[i])
+		}
+	}
+}
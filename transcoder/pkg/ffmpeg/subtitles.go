@@ -0,0 +1,93 @@
+package ffmpeg
+
+import "strings"
+
+// iso639 maps two-letter ISO 639-1 codes to the three-letter ISO 639-2 tags most
+// containers use, so callers can select "en" and match a stream tagged "eng".
+var iso639 = map[string][]string{
+	"ar": {"ara"},
+	"de": {"deu", "ger"},
+	"en": {"eng"},
+	"es": {"spa"},
+	"fr": {"fra", "fre"},
+	"hi": {"hin"},
+	"it": {"ita"},
+	"ja": {"jpn"},
+	"ko": {"kor"},
+	"nl": {"nld", "dut"},
+	"pl": {"pol"},
+	"pt": {"por"},
+	"ru": {"rus"},
+	"sv": {"swe"},
+	"tr": {"tur"},
+	"zh": {"zho", "chi"},
+}
+
+// LanguageMatches reports whether a stream's language tag matches lang, accepting
+// either ISO 639-1 ("en") or ISO 639-2 ("eng") for lang. Region suffixes ("en-US")
+// are ignored.
+func LanguageMatches(tag, lang string) bool {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	lang = strings.ToLower(strings.TrimSpace(lang))
+	if i := strings.IndexAny(lang, "-_"); i >= 0 {
+		lang = lang[:i]
+	}
+	if tag == "" || lang == "" {
+		return false
+	}
+	if tag == lang {
+		return true
+	}
+	for _, alt := range iso639[lang] {
+		if tag == alt {
+			return true
+		}
+	}
+	for short, alts := range iso639 {
+		if tag == short {
+			for _, alt := range alts {
+				if alt == lang {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// SelectSubtitle picks the text subtitle stream to burn in. With a language it
+// returns the first matching stream, preferring non-forced ones; without one it
+// returns the default stream, or the first text stream.
+func SelectSubtitle(streams []SubtitleStream, lang string) (SubtitleStream, bool) {
+	var candidates []SubtitleStream
+	for _, st := range streams {
+		if !st.IsText() {
+			continue
+		}
+		if lang == "" || LanguageMatches(st.Language, lang) {
+			candidates = append(candidates, st)
+		}
+	}
+	if len(candidates) == 0 {
+		return SubtitleStream{}, false
+	}
+	if lang == "" {
+		for _, st := range candidates {
+			if st.Default {
+				return st, true
+			}
+		}
+		return candidates[0], true
+	}
+	for _, st := range candidates {
+		if !st.Forced {
+			return st, true
+		}
+	}
+	return candidates[0], true
+}
+
+// escapeFilterValue quotes a value (e.g. a file path) for use inside a filter graph.
+func escapeFilterValue(v string) string {
+	return "'" + strings.ReplaceAll(v, "'", `'\''`) + "'"
+}
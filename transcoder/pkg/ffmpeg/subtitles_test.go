@@ -0,0 +1,41 @@
+package ffmpeg
+
+import "testing"
+
+func TestLanguageMatches(t *testing.T) {
+	cases := []struct {
+		tag, lang string
+		want      bool
+	}{
+		{"eng", "en", true},
+		{"eng", "eng", true},
+		{"eng", "EN-us", true},
+		{"en", "eng", true},
+		{"ger", "de", true},
+		{"spa", "en", false},
+		{"", "en", false},
+	}
+	for _, c := range cases {
+		if got := LanguageMatches(c.tag, c.lang); got != c.want {
+			t.Errorf("LanguageMatches(%q, %q) = %v, want %v", c.tag, c.lang, got, c.want)
+		}
+	}
+}
+
+func TestSelectSubtitle(t *testing.T) {
+	streams := []SubtitleStream{
+		{SubIndex: 0, Codec: "hdmv_pgs_subtitle", Language: "eng"},
+		{SubIndex: 1, Codec: "subrip", Language: "eng", Forced: true},
+		{SubIndex: 2, Codec: "subrip", Language: "eng"},
+		{SubIndex: 3, Codec: "ass", Language: "spa", Default: true},
+	}
+	if st, ok := SelectSubtitle(streams, "en"); !ok || st.SubIndex != 2 {
+		t.Errorf("en: got %+v %v, want SubIndex 2", st, ok)
+	}
+	if st, ok := SelectSubtitle(streams, ""); !ok || st.SubIndex != 3 {
+		t.Errorf("default: got %+v %v, want SubIndex 3", st, ok)
+	}
+	if _, ok := SelectSubtitle(streams, "fr"); ok {
+		t.Errorf("fr: expected no match")
+	}
+}
@@ -0,0 +1,111 @@
+package ffmpeg
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Corner is where a watermark is anchored in the frame.
+type Corner string
+
+const (
+	TopLeft     Corner = "top-left"
+	TopRight    Corner = "top-right"
+	BottomLeft  Corner = "bottom-left"
+	BottomRight Corner = "bottom-right"
+)
+
+// Watermark describes an image burned into the video with the overlay filter.
+type Watermark struct {
+	ImagePath string
+	Corner    Corner
+	MarginX   int     // pixels from the left/right edge
+	MarginY   int     // pixels from the top/bottom edge
+	Opacity   float64 // 0-1; 0 is treated as fully opaque
+	Scale     float64 // logo height as a fraction of the video height, e.g. 0.1; 0 keeps the image size
+}
+
+// ParseWatermarkPosition parses "corner[:marginX:marginY]", e.g. "bottom-right:10:10".
+func ParseWatermarkPosition(s string) (Corner, int, int, error) {
+	parts := strings.Split(strings.TrimSpace(s), ":")
+	corner := Corner(strings.ToLower(parts[0]))
+	switch corner {
+	case TopLeft, TopRight, BottomLeft, BottomRight:
+	default:
+		return "", 0, 0, fmt.Errorf("watermark position %q: unknown corner %q", s, parts[0])
+	}
+	var mx, my int
+	switch len(parts) {
+	case 1:
+	case 3:
+		var err error
+		if mx, err = strconv.Atoi(parts[1]); err != nil || mx < 0 {
+			return "", 0, 0, fmt.Errorf("watermark position %q: invalid x margin", s)
+		}
+		if my, err = strconv.Atoi(parts[2]); err != nil || my < 0 {
+			return "", 0, 0, fmt.Errorf("watermark position %q: invalid y margin", s)
+		}
+	default:
+		return "", 0, 0, fmt.Errorf("watermark position %q: want corner or corner:x:y", s)
+	}
+	return corner, mx, my, nil
+}
+
+// overlayXY returns the overlay filter's x/y expressions for the corner.
+func (w Watermark) overlayXY() (string, string) {
+	x := strconv.Itoa(w.MarginX)
+	y := strconv.Itoa(w.MarginY)
+	if w.Corner == TopRight || w.Corner == BottomRight {
+		x = fmt.Sprintf("W-w-%d", w.MarginX)
+	}
+	if w.Corner == BottomLeft || w.Corner == BottomRight {
+		y = fmt.Sprintf("H-h-%d", w.MarginY)
+	}
+	return x, y
+}
+
+// FilterComplex builds a -filter_complex graph that applies videoChain to input 0,
+// then overlays input 1 (the watermark image) on the result. videoHeight is the
+// height of the video after videoChain, so Scale sizes the logo proportionally to
+// each rendition. The output is labeled [vout].
+func (w Watermark) FilterComplex(videoChain string, videoHeight int) string {
+	if videoChain == "" {
+		videoChain = "null"
+	}
+	return fmt.Sprintf("[0:v]%s[base];[1:v]%s[logo];[base][logo]%s[vout]",
+		videoChain, w.logoChain(videoHeight), w.overlay())
+}
+
+// Overlay is FilterComplex between given labels, so one graph can watermark several
+// outputs: videoChain is applied to the video labeled in, the image labeled logo is
+// overlaid on it and the result labeled out (e.g. "[v0]"). Intermediate labels are
+// derived from out.
+func (w Watermark) Overlay(in, logo, videoChain string, videoHeight int, out string) string {
+	if videoChain == "" {
+		videoChain = "null"
+	}
+	name := strings.Trim(out, "[]")
+	return fmt.Sprintf("%s%s[base_%s];%s%s[logo_%s];[base_%s][logo_%s]%s%s",
+		in, videoChain, name, logo, w.logoChain(videoHeight), name, name, name, w.overlay(), out)
+}
+
+// logoChain prepares the watermark image for a video videoHeight pixels high.
+func (w Watermark) logoChain(videoHeight int) string {
+	logo := []string{"format=rgba"}
+	if w.Scale > 0 && videoHeight > 0 {
+		h := int(math.Round(float64(videoHeight) * w.Scale))
+		logo = append(logo, fmt.Sprintf("scale=-1:%d", max(h, 1)))
+	}
+	if w.Opacity > 0 && w.Opacity < 1 {
+		logo = append(logo, fmt.Sprintf("colorchannelmixer=aa=%.2f", w.Opacity))
+	}
+	return strings.Join(logo, ",")
+}
+
+// overlay returns the overlay filter placing the logo in its corner.
+func (w Watermark) overlay() string {
+	x, y := w.overlayXY()
+	return fmt.Sprintf("overlay=%s:%s:format=auto", x, y)
+}
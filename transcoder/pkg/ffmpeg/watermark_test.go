@@ -0,0 +1,37 @@
+package ffmpeg
+
+import "testing"
+
+func TestParseWatermarkPosition(t *testing.T) {
+	corner, mx, my, err := ParseWatermarkPosition("bottom-right:10:20")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if corner != BottomRight || mx != 10 || my != 20 {
+		t.Fatalf("got %s %d %d", corner, mx, my)
+	}
+	if corner, mx, my, err = ParseWatermarkPosition("top-left"); err != nil || corner != TopLeft || mx != 0 || my != 0 {
+		t.Fatalf("got %s %d %d %v", corner, mx, my, err)
+	}
+	for _, bad := range []string{"middle", "top-left:10", "top-right:a:1", "bottom-left:-1:0"} {
+		if _, _, _, err := ParseWatermarkPosition(bad); err == nil {
+			t.Errorf("expected error for %q", bad)
+		}
+	}
+}
+
+func TestWatermark_FilterComplex(t *testing.T) {
+	w := Watermark{ImagePath: "logo.png", Corner: BottomRight, MarginX: 10, MarginY: 10, Opacity: 0.5, Scale: 0.1}
+	got := w.FilterComplex("scale=-2:720", 720)
+	want := "[0:v]scale=-2:720[base];[1:v]format=rgba,scale=-1:72,colorchannelmixer=aa=0.50[logo];[base][logo]overlay=W-w-10:H-h-10:format=auto[vout]"
+	if got != want {
+		t.Fatalf("got %q\nwant %q", got, want)
+	}
+
+	w = Watermark{ImagePath: "logo.png", Corner: TopLeft, MarginX: 5, MarginY: 6}
+	got = w.FilterComplex("", 0)
+	want = "[0:v]null[base];[1:v]format=rgba[logo];[base][logo]overlay=5:6:format=auto[vout]"
+	if got != want {
+		t.Fatalf("got %q\nwant %q", got, want)
+	}
+}
@@ -2,28 +2,65 @@ package hls
 
 import (
 	"fmt"
-	"os"
+	"math"
+	"slices"
 	"strconv"
 	"strings"
 )
 
 // StreamInfAttr holds attributes for EXT-X-STREAM-INF line in a master playlist.
 type StreamInfAttr struct {
-	Bandwidth        int     // bits per second (required by spec)
-	AverageBandwidth int     // optional, bits per second
-	ResolutionW      int     // e.g., 1280
-	ResolutionH      int     // e.g., 720
-	FrameRate        float64 // e.g., 29.97
-	Codecs           string  // e.g., "avc1.64001f,mp4a.40.2"
-	Audio            string  // GROUP-ID for associated audio Renditions
-	Subtitles        string  // GROUP-ID for associated subtitles Renditions
-	ClosedCaptions   string  // "NONE" or GROUP-ID
+	Bandwidth        int      // bits per second (required by spec)
+	AverageBandwidth int      // optional, bits per second
+	ResolutionW      int      // e.g., 1280
+	ResolutionH      int      // e.g., 720
+	FrameRate        float64  // e.g., 29.97
+	Codecs           string   // e.g., "avc1.64001f,mp4a.40.2"
+	Audio            string   // GROUP-ID for associated audio Renditions
+	Subtitles        string   // GROUP-ID for associated subtitles Renditions
+	ClosedCaptions   string   // "NONE" or GROUP-ID
+	Extra            []string // other attributes as raw KEY=VALUE, written after the known ones
+}
+
+// AudioMedia describes an EXT-X-MEDIA audio rendition.
+type AudioMedia struct {
+	GroupID    string // referenced by StreamInfAttr.Audio
+	Name       string // human-readable, e.g. "English"
+	Language   string // e.g. "en" or "eng"
+	URI        string // audio-only media playlist
+	Channels   int    // optional, e.g. 2
+	Default    bool
+	Autoselect bool
+	Extra      []string // other attributes as raw KEY=VALUE, written before URI
+}
+
+// SubtitleMedia describes an EXT-X-MEDIA subtitles rendition.
+type SubtitleMedia struct {
+	GroupID    string // referenced by StreamInfAttr.Subtitles
+	Name       string // human-readable, e.g. "English"
+	Language   string // e.g. "en" or "eng"
+	URI        string // subtitle media playlist
+	Default    bool
+	Autoselect bool
+	Forced     bool
+	Extra      []string // other attributes as raw KEY=VALUE, written before URI
 }
 
 // MasterBuilder is a fluent builder for HLS master playlists.
 type MasterBuilder struct {
-	version  int
-	variants []variant
+	version     int
+	independent bool        // EXT-X-INDEPENDENT-SEGMENTS
+	start       *startPoint // EXT-X-START, nil when unset
+	tags        []string    // other playlist-level tags, kept verbatim (e.g. from ParseMaster)
+	audio       []AudioMedia
+	subtitles   []SubtitleMedia
+	variants    []variant
+}
+
+// startPoint is the preferred point to start playback at (EXT-X-START).
+type startPoint struct {
+	offset  float64 // seconds; negative counts back from the end of the playlist
+	precise bool
 }
 
 type variant struct {
@@ -42,24 +79,147 @@ func (b *MasterBuilder) Version(v int) *MasterBuilder {
 	return b
 }
 
+// IndependentSegments emits EXT-X-INDEPENDENT-SEGMENTS, declaring that every segment of
+// every variant can be decoded without the ones before it (each starts with a keyframe).
+func (b *MasterBuilder) IndependentSegments() *MasterBuilder {
+	b.independent = true
+	return b
+}
+
+// Start emits EXT-X-START so players begin playback timeOffset seconds in (negative
+// offsets count back from the end) instead of at the first segment. Unless precise is
+// set, players start at the segment containing the offset rather than decoding up to it.
+func (b *MasterBuilder) Start(timeOffset float64, precise bool) *MasterBuilder {
+	b.start = &startPoint{offset: timeOffset, precise: precise}
+	return b
+}
+
 func (b *MasterBuilder) AddVariant(uri string, attrs StreamInfAttr) *MasterBuilder {
 	b.variants = append(b.variants, variant{uri: uri, attrs: attrs})
 	return b
 }
 
+// AddAudioMedia adds an alternate audio rendition. Variants must set StreamInfAttr.Audio
+// to its GroupID, and exactly one rendition per group should be Default.
+func (b *MasterBuilder) AddAudioMedia(m AudioMedia) *MasterBuilder {
+	b.audio = append(b.audio, m)
+	return b
+}
+
+// AddSubtitleMedia adds a subtitles rendition. Variants must set StreamInfAttr.Subtitles
+// to its GroupID for players to offer it.
+func (b *MasterBuilder) AddSubtitleMedia(m SubtitleMedia) *MasterBuilder {
+	b.subtitles = append(b.subtitles, m)
+	return b
+}
+
 func (b *MasterBuilder) String() string {
 	var lines []string
 	lines = append(lines, "#EXTM3U")
 	lines = append(lines, fmt.Sprintf("#EXT-X-VERSION:%d", b.version))
-	for _, v := range b.variants {
+	// Playlist-wide tags come before any EXT-X-MEDIA or EXT-X-STREAM-INF
+	if b.independent {
+		lines = append(lines, "#EXT-X-INDEPENDENT-SEGMENTS")
+	}
+	if b.start != nil {
+		lines = append(lines, "#EXT-X-START:"+formatStartAttrs(*b.start))
+	}
+	lines = append(lines, b.tags...)
+	for _, m := range b.audio {
+		lines = append(lines, "#EXT-X-MEDIA:"+formatAudioMediaAttrs(m))
+	}
+	for _, m := range b.subtitles {
+		lines = append(lines, "#EXT-X-MEDIA:"+formatSubtitleMediaAttrs(m))
+	}
+	for _, v := range b.sortedVariants() {
 		lines = append(lines, "#EXT-X-STREAM-INF:"+formatStreamInfAttrs(v.attrs))
 		lines = append(lines, v.uri)
 	}
 	return strings.Join(lines, "\n") + "\n"
 }
 
+// WriteFile validates the playlist and writes it to path. The file is replaced
+// atomically, so concurrent readers never see a partial playlist.
 func (b *MasterBuilder) WriteFile(path string) error {
-	return os.WriteFile(path, []byte(b.String()), 0o644)
+	if err := b.Validate(); err != nil {
+		return err
+	}
+	return writeFileAtomic(path, []byte(b.String()))
+}
+
+// Clone returns an independent copy of the builder.
+func (b *MasterBuilder) Clone() *MasterBuilder {
+	c := *b
+	c.tags = slices.Clone(b.tags)
+	c.audio = slices.Clone(b.audio)
+	c.subtitles = slices.Clone(b.subtitles)
+	c.variants = slices.Clone(b.variants)
+	return &c
+}
+
+// VariantURIs returns the URIs of the variant playlists, in insertion order.
+func (b *MasterBuilder) VariantURIs() []string {
+	uris := make([]string, 0, len(b.variants))
+	for _, v := range b.variants {
+		uris = append(uris, v.uri)
+	}
+	return uris
+}
+
+// MediaURIs returns the URIs of the audio and subtitle renditions.
+func (b *MasterBuilder) MediaURIs() []string {
+	var uris []string
+	for _, m := range b.audio {
+		uris = append(uris, m.URI)
+	}
+	for _, m := range b.subtitles {
+		uris = append(uris, m.URI)
+	}
+	return uris
+}
+
+// RetainVariants removes the variants whose URI keep rejects.
+func (b *MasterBuilder) RetainVariants(keep func(uri string) bool) *MasterBuilder {
+	b.variants = slices.DeleteFunc(b.variants, func(v variant) bool { return !keep(v.uri) })
+	return b
+}
+
+// Validate checks that every variant has a URI and the BANDWIDTH the spec requires.
+func (b *MasterBuilder) Validate() error {
+	for _, v := range b.variants {
+		if v.uri == "" {
+			return fmt.Errorf("hls: variant with empty URI")
+		}
+		if v.attrs.Bandwidth <= 0 {
+			return fmt.Errorf("hls: variant %s has no bandwidth", v.uri)
+		}
+	}
+	return nil
+}
+
+// sortedVariants returns the variants ordered by ascending bandwidth, so the output
+// doesn't depend on the order renditions finished in. Ties fall back to resolution,
+// then URI.
+func (b *MasterBuilder) sortedVariants() []variant {
+	vs := slices.Clone(b.variants)
+	slices.SortStableFunc(vs, func(x, y variant) int {
+		if c := x.attrs.Bandwidth - y.attrs.Bandwidth; c != 0 {
+			return c
+		}
+		if c := x.attrs.ResolutionH - y.attrs.ResolutionH; c != 0 {
+			return c
+		}
+		return strings.Compare(x.uri, y.uri)
+	})
+	return vs
+}
+
+func formatStartAttrs(p startPoint) string {
+	attrs := "TIME-OFFSET=" + trimFloat(p.offset, 3)
+	if p.precise {
+		attrs += ",PRECISE=YES"
+	}
+	return attrs
 }
 
 func formatStreamInfAttrs(a StreamInfAttr) string {
@@ -86,11 +246,73 @@ func formatStreamInfAttrs(a StreamInfAttr) string {
 		parts = append(parts, `SUBTITLES="`+a.Subtitles+`"`)
 	}
 	if a.ClosedCaptions != "" {
-		parts = append(parts, `CLOSED-CAPTIONS="`+a.ClosedCaptions+`"`)
+		if a.ClosedCaptions == "NONE" {
+			parts = append(parts, "CLOSED-CAPTIONS=NONE") // enumerated value, never quoted
+		} else {
+			parts = append(parts, `CLOSED-CAPTIONS="`+a.ClosedCaptions+`"`)
+		}
 	}
+	parts = append(parts, a.Extra...)
 	return strings.Join(parts, ",")
 }
 
+func formatAudioMediaAttrs(m AudioMedia) string {
+	parts := []string{"TYPE=AUDIO", `GROUP-ID="` + m.GroupID + `"`, `NAME="` + strings.ReplaceAll(m.Name, `"`, "'") + `"`}
+	if m.Language != "" {
+		parts = append(parts, `LANGUAGE="`+m.Language+`"`)
+	}
+	parts = append(parts,
+		"DEFAULT="+yesNo(m.Default),
+		"AUTOSELECT="+yesNo(m.Autoselect || m.Default),
+	)
+	if m.Channels > 0 {
+		parts = append(parts, `CHANNELS="`+strconv.Itoa(m.Channels)+`"`)
+	}
+	parts = append(parts, m.Extra...)
+	parts = append(parts, `URI="`+m.URI+`"`)
+	return strings.Join(parts, ",")
+}
+
+func formatSubtitleMediaAttrs(m SubtitleMedia) string {
+	parts := []string{"TYPE=SUBTITLES", `GROUP-ID="` + m.GroupID + `"`, `NAME="` + strings.ReplaceAll(m.Name, `"`, "'") + `"`}
+	if m.Language != "" {
+		parts = append(parts, `LANGUAGE="`+m.Language+`"`)
+	}
+	parts = append(parts,
+		"DEFAULT="+yesNo(m.Default),
+		"AUTOSELECT="+yesNo(m.Autoselect || m.Default),
+	)
+	if m.Forced {
+		parts = append(parts, "FORCED=YES")
+	}
+	parts = append(parts, m.Extra...)
+	parts = append(parts, `URI="`+m.URI+`"`)
+	return strings.Join(parts, ",")
+}
+
+func yesNo(v bool) string {
+	if v {
+		return "YES"
+	}
+	return "NO"
+}
+
+// SubtitlePlaylist returns a VOD media playlist that serves a whole WebVTT file as a
+// single segment, which is what EXT-X-MEDIA subtitle URIs must point at.
+func SubtitlePlaylist(vttURI string, durationSec float64) string {
+	target := int(math.Ceil(durationSec))
+	lines := []string{
+		"#EXTM3U",
+		"#EXT-X-VERSION:3",
+		"#EXT-X-PLAYLIST-TYPE:VOD",
+		"#EXT-X-TARGETDURATION:" + strconv.Itoa(max(target, 1)),
+		"#EXTINF:" + trimFloat(durationSec, 3) + ",",
+		vttURI,
+		"#EXT-X-ENDLIST",
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
 func trimFloat(v float64, prec int) string {
 	// Format with precision then trim trailing zeros and possible dot.
 	s := strconv.FormatFloat(v, 'f', prec, 64)
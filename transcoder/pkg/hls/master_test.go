@@ -33,3 +33,99 @@ func TestMasterBuilder_AddVariantAndString(t *testing.T) {
 		t.Errorf("output should end with newline")
 	}
 }
+
+func TestMasterBuilder_StartAndIndependentSegments(t *testing.T) {
+	mb := NewMaster().Version(3).Start(6, false).IndependentSegments()
+	mb.AddAudioMedia(AudioMedia{GroupID: "aud", Name: "English", URI: "a0.m3u8", Default: true})
+	mb.AddVariant("v720.m3u8", StreamInfAttr{Bandwidth: 2500000, Audio: "aud"})
+	out := mb.String()
+	want := "#EXTM3U\n#EXT-X-VERSION:3\n#EXT-X-INDEPENDENT-SEGMENTS\n#EXT-X-START:TIME-OFFSET=6\n#EXT-X-MEDIA:"
+	if !strings.HasPrefix(out, want) {
+		t.Fatalf("playlist-wide tags not right after the version:\n%s", out)
+	}
+	if got := NewMaster().Start(2.25, true).String(); !strings.Contains(got, "#EXT-X-START:TIME-OFFSET=2.25,PRECISE=YES\n") {
+		t.Errorf("missing precise start in:\n%s", got)
+	}
+	if got := NewMaster().String(); strings.Contains(got, "INDEPENDENT") || strings.Contains(got, "START") {
+		t.Errorf("tags emitted without being set:\n%s", got)
+	}
+}
+
+func TestMasterBuilder_AddSubtitleMedia(t *testing.T) {
+	mb := NewMaster().Version(3)
+	mb.AddSubtitleMedia(SubtitleMedia{GroupID: "subs", Name: "English", Language: "eng", URI: "subs_eng.m3u8", Default: true})
+	mb.AddVariant("v720.m3u8", StreamInfAttr{Bandwidth: 2500000, Subtitles: "subs"})
+	out := mb.String()
+	want := "#EXTM3U\n#EXT-X-VERSION:3\n" +
+		`#EXT-X-MEDIA:TYPE=SUBTITLES,GROUP-ID="subs",NAME="English",LANGUAGE="eng",DEFAULT=YES,AUTOSELECT=YES,URI="subs_eng.m3u8"` + "\n" +
+		`#EXT-X-STREAM-INF:BANDWIDTH=2500000,SUBTITLES="subs"` + "\nv720.m3u8\n"
+	if out != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", out, want)
+	}
+}
+
+func TestSubtitlePlaylist(t *testing.T) {
+	got := SubtitlePlaylist("subs_eng.vtt", 61.5)
+	want := "#EXTM3U\n#EXT-X-VERSION:3\n#EXT-X-PLAYLIST-TYPE:VOD\n#EXT-X-TARGETDURATION:62\n#EXTINF:61.5,\nsubs_eng.vtt\n#EXT-X-ENDLIST\n"
+	if got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestMasterBuilder_AddAudioMedia(t *testing.T) {
+	mb := NewMaster().Version(3)
+	mb.AddAudioMedia(AudioMedia{GroupID: "aud", Name: "English", Language: "eng", URI: "a0.m3u8", Channels: 2, Default: true})
+	mb.AddAudioMedia(AudioMedia{GroupID: "aud", Name: "Deutsch", Language: "ger", URI: "a1.m3u8", Channels: 2, Autoselect: true})
+	mb.AddVariant("v720.m3u8", StreamInfAttr{Bandwidth: 2500000, Audio: "aud"})
+	out := mb.String()
+	for _, want := range []string{
+		`#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="aud",NAME="English",LANGUAGE="eng",DEFAULT=YES,AUTOSELECT=YES,CHANNELS="2",URI="a0.m3u8"`,
+		`#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="aud",NAME="Deutsch",LANGUAGE="ger",DEFAULT=NO,AUTOSELECT=YES,CHANNELS="2",URI="a1.m3u8"`,
+		`#EXT-X-STREAM-INF:BANDWIDTH=2500000,AUDIO="aud"` + "\nv720.m3u8",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in:\n%s", want, out)
+		}
+	}
+}
+
+func TestMasterBuilder_SortsVariantsByBandwidth(t *testing.T) {
+	mb := NewMaster()
+	mb.AddVariant("v1080.m3u8", StreamInfAttr{Bandwidth: 4500000})
+	mb.AddVariant("v360.m3u8", StreamInfAttr{Bandwidth: 800000})
+	mb.AddVariant("v720.m3u8", StreamInfAttr{Bandwidth: 2500000})
+	var uris []string
+	for _, line := range strings.Split(mb.String(), "\n") {
+		if strings.HasSuffix(line, ".m3u8") {
+			uris = append(uris, line)
+		}
+	}
+	want := []string{"v360.m3u8", "v720.m3u8", "v1080.m3u8"}
+	if strings.Join(uris, " ") != strings.Join(want, " ") {
+		t.Fatalf("got %v want %v", uris, want)
+	}
+}
+
+func TestMasterBuilder_Validate(t *testing.T) {
+	mb := NewMaster().AddVariant("v720.m3u8", StreamInfAttr{Bandwidth: 2500000})
+	if err := mb.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	mb.AddVariant("v480.m3u8", StreamInfAttr{})
+	if err := mb.Validate(); err == nil {
+		t.Fatal("expected error for variant without bandwidth")
+	}
+}
+
+func TestMasterBuilder_CloneAndRetainVariants(t *testing.T) {
+	mb := NewMaster()
+	mb.AddVariant("v720.m3u8", StreamInfAttr{Bandwidth: 2500000})
+	mb.AddVariant("v360.m3u8", StreamInfAttr{Bandwidth: 800000})
+	c := mb.Clone().RetainVariants(func(uri string) bool { return uri == "v360.m3u8" })
+	if got := c.VariantURIs(); len(got) != 1 || got[0] != "v360.m3u8" {
+		t.Fatalf("retained %v", got)
+	}
+	if got := mb.VariantURIs(); len(got) != 2 {
+		t.Fatalf("original changed: %v", got)
+	}
+}
@@ -0,0 +1,144 @@
+package hls
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Segment is a media segment listed in a media playlist.
+type Segment struct {
+	URI         string
+	DurationSec float64
+}
+
+// ParseMediaSegments returns the segments of a media playlist in order.
+func ParseMediaSegments(r io.Reader) ([]Segment, error) {
+	var segs []Segment
+	sc := bufio.NewScanner(r)
+	duration := -1.0 // EXTINF waiting for its URI
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "#EXTINF:"):
+			v, _, _ := strings.Cut(strings.TrimPrefix(line, "#EXTINF:"), ",")
+			d, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+			if err != nil || d < 0 {
+				return nil, fmt.Errorf("hls: invalid EXTINF %q", line)
+			}
+			duration = d
+		case strings.HasPrefix(line, "#"):
+		default:
+			if duration < 0 {
+				return nil, fmt.Errorf("hls: segment %q without EXTINF", line)
+			}
+			segs = append(segs, Segment{URI: line, DurationSec: duration})
+			duration = -1
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("hls: read playlist: %w", err)
+	}
+	return segs, nil
+}
+
+// AverageBandwidth returns the average bitrate, in bits per second, of the media
+// playlist at path: the on-disk size of its segments over their total duration.
+// Segment URIs are resolved relative to the playlist.
+func AverageBandwidth(path string) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	segs, err := ParseMediaSegments(f)
+	if err != nil {
+		return 0, err
+	}
+
+	var bytes int64
+	var duration float64
+	for _, s := range segs {
+		fi, err := os.Stat(filepath.Join(filepath.Dir(path), filepath.FromSlash(s.URI)))
+		if err != nil {
+			return 0, fmt.Errorf("hls: stat segment: %w", err)
+		}
+		bytes += fi.Size()
+		duration += s.DurationSec
+	}
+	if duration <= 0 {
+		return 0, fmt.Errorf("hls: playlist %s has no segment duration", path)
+	}
+	return int(math.Round(float64(bytes) * 8 / duration)), nil
+}
+
+// ReferencedURIs returns the files a media playlist depends on, in playlist order:
+// init segments (EXT-X-MAP) and media segments.
+func ReferencedURIs(r io.Reader) ([]string, error) {
+	var uris []string
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "#EXT-X-MAP:"):
+			for _, attr := range splitAttrList(strings.TrimPrefix(line, "#EXT-X-MAP:")) {
+				if key, val, _ := strings.Cut(attr, "="); key == "URI" {
+					uris = append(uris, unquote(val))
+				}
+			}
+		case strings.HasPrefix(line, "#"):
+		default:
+			uris = append(uris, line)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("hls: read playlist: %w", err)
+	}
+	return uris, nil
+}
+
+// SetPlaylistType rewrites the EXT-X-PLAYLIST-TYPE of the media playlist at path (e.g.
+// "VOD" once an EVENT playlist is complete), adding the tag if it is missing. The file
+// is replaced atomically.
+func SetPlaylistType(path, playlistType string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	tag := "#EXT-X-PLAYLIST-TYPE:" + playlistType
+	lines := strings.Split(string(data), "\n")
+	found := false
+	for i, line := range lines {
+		if strings.HasPrefix(strings.TrimSpace(line), "#EXT-X-PLAYLIST-TYPE:") {
+			lines[i], found = tag, true
+		}
+	}
+	if !found {
+		if len(lines) == 0 || strings.TrimSpace(lines[0]) != "#EXTM3U" {
+			return fmt.Errorf("hls: %s is not a playlist", path)
+		}
+		lines = slices.Insert(lines, 1, tag)
+	}
+	return writeFileAtomic(path, []byte(strings.Join(lines, "\n")))
+}
+
+// writeFileAtomic writes data to a temporary file next to path and renames it over path.
+func writeFileAtomic(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
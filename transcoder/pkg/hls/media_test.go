@@ -0,0 +1,69 @@
+package hls
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseMediaSegments(t *testing.T) {
+	in := "#EXTM3U\n#EXT-X-VERSION:3\n#EXT-X-TARGETDURATION:4\n#EXTINF:4.000000,\nv720_0000.ts\n#EXTINF:2.5,\nv720_0001.ts\n#EXT-X-ENDLIST\n"
+	segs, err := ParseMediaSegments(strings.NewReader(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(segs) != 2 || segs[0] != (Segment{"v720_0000.ts", 4}) || segs[1] != (Segment{"v720_0001.ts", 2.5}) {
+		t.Fatalf("unexpected segments: %+v", segs)
+	}
+	if _, err := ParseMediaSegments(strings.NewReader("#EXTM3U\nv.ts\n")); err == nil {
+		t.Fatal("expected error for segment without EXTINF")
+	}
+}
+
+func TestAverageBandwidth(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, data []byte) {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("s0.ts", make([]byte, 1000))
+	write("s1.ts", make([]byte, 500))
+	write("v.m3u8", []byte("#EXTM3U\n#EXTINF:2,\ns0.ts\n#EXTINF:1,\ns1.ts\n#EXT-X-ENDLIST\n"))
+
+	got, err := AverageBandwidth(filepath.Join(dir, "v.m3u8"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := 4000; got != want { // 1500 bytes * 8 / 3s
+		t.Fatalf("got %d want %d", got, want)
+	}
+}
+
+func TestReferencedURIs(t *testing.T) {
+	in := "#EXTM3U\n#EXT-X-VERSION:7\n#EXT-X-MAP:URI=\"v720_vp9_init.mp4\"\n#EXTINF:4.0,\nv720_vp9_0000.m4s\n#EXTINF:4.0,\nv720_vp9_0001.m4s\n"
+	got, err := ReferencedURIs(strings.NewReader(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"v720_vp9_init.mp4", "v720_vp9_0000.m4s", "v720_vp9_0001.m4s"}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestSetPlaylistType(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "v720.m3u8")
+	in := "#EXTM3U\n#EXT-X-VERSION:3\n#EXT-X-PLAYLIST-TYPE:EVENT\n#EXTINF:4.0,\nv720_0000.ts\n#EXT-X-ENDLIST\n"
+	if err := os.WriteFile(path, []byte(in), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetPlaylistType(path, "VOD"); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := os.ReadFile(path)
+	if want := strings.Replace(in, "EVENT", "VOD", 1); string(got) != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,253 @@
+package hls
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ParseMaster reads a master playlist back into a MasterBuilder so it can be amended
+// (e.g. a variant added after a partial re-transcode) and rewritten. Attributes the
+// builder doesn't model are kept in the Extra fields, and unknown playlist-level tags
+// (including EXT-X-MEDIA types other than AUDIO and SUBTITLES) are kept verbatim.
+func ParseMaster(r io.Reader) (*MasterBuilder, error) {
+	b := NewMaster()
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	lineNo := 0
+	var pending *StreamInfAttr // EXT-X-STREAM-INF waiting for its URI line
+	for sc.Scan() {
+		lineNo++
+		line := strings.TrimSpace(sc.Text())
+		if lineNo == 1 {
+			if line != "#EXTM3U" {
+				return nil, fmt.Errorf("hls: not a playlist: missing #EXTM3U header")
+			}
+			continue
+		}
+		if line == "" {
+			continue
+		}
+
+		if !strings.HasPrefix(line, "#") {
+			if pending == nil {
+				return nil, fmt.Errorf("hls: line %d: URI %q without EXT-X-STREAM-INF", lineNo, line)
+			}
+			b.AddVariant(line, *pending)
+			pending = nil
+			continue
+		}
+		if pending != nil {
+			return nil, fmt.Errorf("hls: line %d: EXT-X-STREAM-INF not followed by a URI", lineNo)
+		}
+
+		tag, value, _ := strings.Cut(line, ":")
+		switch tag {
+		case "#EXT-X-VERSION":
+			v, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, fmt.Errorf("hls: line %d: invalid version %q", lineNo, value)
+			}
+			b.Version(v)
+		case "#EXT-X-STREAM-INF":
+			attrs, err := parseStreamInf(value)
+			if err != nil {
+				return nil, fmt.Errorf("hls: line %d: %w", lineNo, err)
+			}
+			pending = &attrs
+		case "#EXT-X-MEDIA":
+			if err := b.addParsedMedia(value); err != nil {
+				return nil, fmt.Errorf("hls: line %d: %w", lineNo, err)
+			}
+		case "#EXT-X-INDEPENDENT-SEGMENTS":
+			b.IndependentSegments()
+		case "#EXT-X-START":
+			p, err := parseStart(value)
+			if err != nil {
+				return nil, fmt.Errorf("hls: line %d: %w", lineNo, err)
+			}
+			b.start = &p
+		default:
+			if strings.HasPrefix(tag, "#EXT") {
+				b.tags = append(b.tags, line)
+			}
+			// Anything else is a comment
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("hls: read playlist: %w", err)
+	}
+	if lineNo == 0 {
+		return nil, fmt.Errorf("hls: not a playlist: empty input")
+	}
+	if pending != nil {
+		return nil, fmt.Errorf("hls: EXT-X-STREAM-INF at end of playlist without a URI")
+	}
+	return b, nil
+}
+
+func parseStreamInf(value string) (StreamInfAttr, error) {
+	var a StreamInfAttr
+	for _, p := range splitAttrList(value) {
+		key, raw, ok := strings.Cut(p, "=")
+		if !ok {
+			return a, fmt.Errorf("malformed attribute %q", p)
+		}
+		val := unquote(raw)
+		var err error
+		switch key {
+		case "BANDWIDTH":
+			a.Bandwidth, err = strconv.Atoi(val)
+		case "AVERAGE-BANDWIDTH":
+			a.AverageBandwidth, err = strconv.Atoi(val)
+		case "RESOLUTION":
+			w, h, found := strings.Cut(val, "x")
+			if !found {
+				return a, fmt.Errorf("invalid RESOLUTION %q", val)
+			}
+			if a.ResolutionW, err = strconv.Atoi(w); err == nil {
+				a.ResolutionH, err = strconv.Atoi(h)
+			}
+		case "FRAME-RATE":
+			a.FrameRate, err = strconv.ParseFloat(val, 64)
+		case "CODECS":
+			a.Codecs = val
+		case "AUDIO":
+			a.Audio = val
+		case "SUBTITLES":
+			a.Subtitles = val
+		case "CLOSED-CAPTIONS":
+			a.ClosedCaptions = val
+		default:
+			a.Extra = append(a.Extra, p)
+		}
+		if err != nil {
+			return a, fmt.Errorf("invalid %s %q", key, val)
+		}
+	}
+	return a, nil
+}
+
+// parseStart parses the attributes of an EXT-X-START tag.
+func parseStart(value string) (startPoint, error) {
+	var p startPoint
+	hasOffset := false
+	for _, attr := range splitAttrList(value) {
+		key, val, ok := strings.Cut(attr, "=")
+		if !ok {
+			return p, fmt.Errorf("malformed attribute %q", attr)
+		}
+		switch key {
+		case "TIME-OFFSET":
+			offset, err := strconv.ParseFloat(val, 64)
+			if err != nil {
+				return p, fmt.Errorf("invalid TIME-OFFSET %q", val)
+			}
+			p.offset, hasOffset = offset, true
+		case "PRECISE":
+			p.precise = val == "YES"
+		}
+	}
+	if !hasOffset {
+		return p, fmt.Errorf("EXT-X-START without TIME-OFFSET")
+	}
+	return p, nil
+}
+
+// addParsedMedia adds an EXT-X-MEDIA line as audio or subtitle media; other media
+// types are kept as raw tags.
+func (b *MasterBuilder) addParsedMedia(value string) error {
+	var (
+		typ, group, name, lang, uri string
+		def, auto, forced           bool
+		channels                    int
+		extra                       []string
+	)
+	for _, p := range splitAttrList(value) {
+		key, raw, ok := strings.Cut(p, "=")
+		if !ok {
+			return fmt.Errorf("malformed attribute %q", p)
+		}
+		val := unquote(raw)
+		switch key {
+		case "TYPE":
+			typ = val
+		case "GROUP-ID":
+			group = val
+		case "NAME":
+			name = val
+		case "LANGUAGE":
+			lang = val
+		case "URI":
+			uri = val
+		case "DEFAULT":
+			def = val == "YES"
+		case "AUTOSELECT":
+			auto = val == "YES"
+		case "FORCED":
+			forced = val == "YES"
+		case "CHANNELS":
+			// e.g. "2" or "6/JOC"; only the plain channel count is modelled
+			n, err := strconv.Atoi(val)
+			if err != nil {
+				extra = append(extra, p)
+				continue
+			}
+			channels = n
+		default:
+			extra = append(extra, p)
+		}
+	}
+
+	switch typ {
+	case "AUDIO":
+		if forced {
+			extra = append(extra, "FORCED=YES")
+		}
+		b.AddAudioMedia(AudioMedia{GroupID: group, Name: name, Language: lang, URI: uri,
+			Channels: channels, Default: def, Autoselect: auto, Extra: extra})
+	case "SUBTITLES":
+		if channels > 0 {
+			extra = append(extra, `CHANNELS="`+strconv.Itoa(channels)+`"`)
+		}
+		b.AddSubtitleMedia(SubtitleMedia{GroupID: group, Name: name, Language: lang, URI: uri,
+			Default: def, Autoselect: auto, Forced: forced, Extra: extra})
+	default:
+		b.tags = append(b.tags, "#EXT-X-MEDIA:"+value)
+	}
+	return nil
+}
+
+// splitAttrList splits an attribute list on commas outside quoted strings.
+func splitAttrList(s string) []string {
+	var parts []string
+	inQuote := false
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '"':
+			inQuote = !inQuote
+		case ',':
+			if !inQuote {
+				if p := strings.TrimSpace(s[start:i]); p != "" {
+					parts = append(parts, p)
+				}
+				start = i + 1
+			}
+		}
+	}
+	if p := strings.TrimSpace(s[start:]); p != "" {
+		parts = append(parts, p)
+	}
+	return parts
+}
+
+func unquote(v string) string {
+	if len(v) >= 2 && v[0] == '"' && v[len(v)-1] == '"' {
+		return v[1 : len(v)-1]
+	}
+	return v
+}
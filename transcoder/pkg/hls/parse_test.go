@@ -0,0 +1,42 @@
+package hls
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseMaster_RoundTrip(t *testing.T) {
+	in := "#EXTM3U\n#EXT-X-VERSION:3\n#EXT-X-INDEPENDENT-SEGMENTS\n#EXT-X-START:TIME-OFFSET=-12.5,PRECISE=YES\n" +
+		`#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="aud",NAME="English",LANGUAGE="eng",DEFAULT=YES,AUTOSELECT=YES,CHANNELS="2",URI="a0.m3u8"` + "\n" +
+		`#EXT-X-MEDIA:TYPE=SUBTITLES,GROUP-ID="subs",NAME="English",LANGUAGE="eng",DEFAULT=NO,AUTOSELECT=YES,URI="subs_0.m3u8"` + "\n" +
+		`#EXT-X-STREAM-INF:BANDWIDTH=928000,RESOLUTION=640x360,FRAME-RATE=30,CODECS="avc1.4d401e,mp4a.40.2",AUDIO="aud",SUBTITLES="subs",HDCP-LEVEL=NONE` + "\nv360.m3u8\n" +
+		`#EXT-X-STREAM-INF:BANDWIDTH=2628000,RESOLUTION=1280x720,FRAME-RATE=30,CODECS="avc1.64001f,mp4a.40.2",AUDIO="aud",SUBTITLES="subs",HDCP-LEVEL=NONE` + "\nv720.m3u8\n"
+
+	mb, err := ParseMaster(strings.NewReader(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := mb.String(); got != in {
+		t.Fatalf("round trip mismatch:\ngot:\n%s\nwant:\n%s", got, in)
+	}
+
+	mb.AddVariant("v1080.m3u8", StreamInfAttr{Bandwidth: 4628000, ResolutionW: 1920, ResolutionH: 1080})
+	if !strings.HasSuffix(mb.String(), "#EXT-X-STREAM-INF:BANDWIDTH=4628000,RESOLUTION=1920x1080\nv1080.m3u8\n") {
+		t.Fatalf("added variant not last:\n%s", mb.String())
+	}
+}
+
+func TestParseMaster_Errors(t *testing.T) {
+	for name, in := range map[string]string{
+		"no header":      "#EXT-X-VERSION:3\n",
+		"missing uri":    "#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=1\n",
+		"orphan uri":     "#EXTM3U\nv720.m3u8\n",
+		"bad bandwidth":  "#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=abc\nv.m3u8\n",
+		"bad resolution": "#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=1,RESOLUTION=720\nv.m3u8\n",
+		"start offset":   "#EXTM3U\n#EXT-X-START:PRECISE=YES\n",
+	} {
+		if _, err := ParseMaster(strings.NewReader(in)); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package hls
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ValidateOutput checks that the HLS output in dir is complete: master.m3u8 lists at
+// least one variant, and every media playlist it references exists, ends with
+// EXT-X-ENDLIST and lists at least one segment, with every segment (and init segment)
+// present and non-empty. All problems found are returned, joined.
+func ValidateOutput(dir string) error {
+	f, err := os.Open(filepath.Join(dir, "master.m3u8"))
+	if err != nil {
+		return fmt.Errorf("hls: %w", err)
+	}
+	mb, err := ParseMaster(f)
+	f.Close()
+	if err != nil {
+		return fmt.Errorf("master.m3u8: %w", err)
+	}
+	variants := mb.VariantURIs()
+	if len(variants) == 0 {
+		return errors.New("master.m3u8: no variants")
+	}
+
+	var errs []error
+	for _, uri := range append(variants, mb.MediaURIs()...) {
+		if err := validateMediaPlaylist(dir, uri); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// validateMediaPlaylist checks one media playlist, uri relative to dir.
+func validateMediaPlaylist(dir, uri string) error {
+	path := filepath.Join(dir, filepath.FromSlash(uri))
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("%s: %w", uri, err)
+	}
+	if !strings.Contains(string(data), "#EXT-X-ENDLIST") {
+		return fmt.Errorf("%s: missing EXT-X-ENDLIST, playlist is incomplete", uri)
+	}
+	refs, err := ReferencedURIs(strings.NewReader(string(data)))
+	if err != nil {
+		return fmt.Errorf("%s: %w", uri, err)
+	}
+	if len(refs) == 0 {
+		return fmt.Errorf("%s: no segments", uri)
+	}
+
+	var errs []error
+	base := filepath.Dir(path)
+	for _, ref := range refs {
+		fi, err := os.Stat(filepath.Join(base, filepath.FromSlash(ref)))
+		switch {
+		case err != nil:
+			errs = append(errs, fmt.Errorf("%s: segment %s: %w", uri, ref, err))
+		case fi.Size() == 0:
+			errs = append(errs, fmt.Errorf("%s: segment %s is empty", uri, ref))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,56 @@
+package hls
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateOutput(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, data []byte) {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	master := NewMaster()
+	master.AddVariant("v720.m3u8", StreamInfAttr{Bandwidth: 2500000})
+	master.AddVariant("v480.m3u8", StreamInfAttr{Bandwidth: 1200000})
+	write("master.m3u8", []byte(master.String()))
+	write("v720.m3u8", []byte("#EXTM3U\n#EXTINF:4,\nv720_0000.ts\n#EXTINF:4,\nv720_0001.ts\n#EXT-X-ENDLIST\n"))
+	write("v720_0000.ts", make([]byte, 100))
+	write("v720_0001.ts", make([]byte, 100))
+	write("v480.m3u8", []byte("#EXTM3U\n#EXTINF:4,\nv480_0000.ts\n#EXT-X-ENDLIST\n"))
+	write("v480_0000.ts", make([]byte, 100))
+
+	if err := ValidateOutput(dir); err != nil {
+		t.Fatalf("complete output: %v", err)
+	}
+
+	// A truncated segment and a missing one are both reported
+	write("v720_0001.ts", nil)
+	os.Remove(filepath.Join(dir, "v480_0000.ts"))
+	err := ValidateOutput(dir)
+	if err == nil {
+		t.Fatal("expected error for empty and missing segments")
+	}
+	for _, want := range []string{"v720_0001.ts is empty", "v480_0000.ts"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %q", err, want)
+		}
+	}
+
+	// A playlist ffmpeg never finished
+	write("v720_0001.ts", make([]byte, 100))
+	write("v480_0000.ts", make([]byte, 100))
+	write("v480.m3u8", []byte("#EXTM3U\n#EXTINF:4,\nv480_0000.ts\n"))
+	if err := ValidateOutput(dir); err == nil || !strings.Contains(err.Error(), "EXT-X-ENDLIST") {
+		t.Fatalf("expected incomplete playlist error, got %v", err)
+	}
+
+	os.Remove(filepath.Join(dir, "v720.m3u8"))
+	if err := ValidateOutput(dir); err == nil || !strings.Contains(err.Error(), "v720.m3u8") {
+		t.Fatalf("expected missing playlist error, got %v", err)
+	}
+}
@@ -0,0 +1,146 @@
+package preview
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+// bifMagic is the 8-byte signature at the start of every BIF file.
+var bifMagic = [8]byte{0x89, 'B', 'I', 'F', '\r', '\n', 0x1a, '\n'}
+
+const (
+	bifHeaderSize = 64
+	bifEntrySize  = 8 // uint32 timestamp + uint32 offset
+	bifEndMarker  = 0xffffffff
+)
+
+type bifFrame struct {
+	at   float64 // seconds
+	path string
+}
+
+// BIFBuilder packs thumbnail JPEGs into a Roku BIF (Base Index Frames) trick-play file.
+//
+// Layout (all integers little-endian):
+//   - 64-byte header: magic, version (0), frame count, framewise separation in ms, reserved zeros
+//   - index: one (timestamp, offset) pair per frame, timestamps in units of the separation,
+//     followed by a (0xffffffff, end offset) terminator
+//   - the JPEG data, back to back
+type BIFBuilder struct {
+	intervalMs uint32
+	frames     []bifFrame
+}
+
+func NewBIF() *BIFBuilder {
+	return &BIFBuilder{intervalMs: 1000}
+}
+
+// Interval sets the spacing between thumbnails in seconds; it should match the
+// interval the thumbnails were sampled at.
+func (b *BIFBuilder) Interval(sec float64) *BIFBuilder {
+	if ms := math.Round(sec * 1000); ms >= 1 {
+		b.intervalMs = uint32(ms)
+	}
+	return b
+}
+
+// AddFrame appends a JPEG shown from `at` seconds into the video. Frames must be added in order.
+func (b *BIFBuilder) AddFrame(at float64, path string) *BIFBuilder {
+	b.frames = append(b.frames, bifFrame{at: at, path: path})
+	return b
+}
+
+func (b *BIFBuilder) Len() int {
+	return len(b.frames)
+}
+
+// WriteFile writes the BIF to path.
+func (b *BIFBuilder) WriteFile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("create bif: %w", err)
+	}
+	if err := b.Write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Write writes the BIF to w.
+func (b *BIFBuilder) Write(w io.Writer) error {
+	if len(b.frames) == 0 {
+		return errors.New("bif: no frames")
+	}
+
+	// Image sizes are needed up front to lay out the index
+	sizes := make([]int64, len(b.frames))
+	for i, fr := range b.frames {
+		st, err := os.Stat(fr.path)
+		if err != nil {
+			return fmt.Errorf("stat bif frame %d: %w", i, err)
+		}
+		sizes[i] = st.Size()
+	}
+
+	bw := bufio.NewWriter(w)
+	header := make([]byte, bifHeaderSize)
+	copy(header, bifMagic[:])
+	binary.LittleEndian.PutUint32(header[8:], 0) // version
+	binary.LittleEndian.PutUint32(header[12:], uint32(len(b.frames)))
+	binary.LittleEndian.PutUint32(header[16:], b.intervalMs)
+	if _, err := bw.Write(header); err != nil {
+		return fmt.Errorf("write bif header: %w", err)
+	}
+
+	offset := int64(bifHeaderSize + (len(b.frames)+1)*bifEntrySize)
+	entry := make([]byte, bifEntrySize)
+	lastTS := int64(-1)
+	for i, fr := range b.frames {
+		ts := int64(math.Round(fr.at * 1000 / float64(b.intervalMs)))
+		if ts <= lastTS {
+			return fmt.Errorf("bif frame %d at %.3fs is not after the previous frame", i, fr.at)
+		}
+		lastTS = ts
+		if offset > math.MaxUint32 || ts >= bifEndMarker {
+			return errors.New("bif: file too large")
+		}
+		binary.LittleEndian.PutUint32(entry[0:], uint32(ts))
+		binary.LittleEndian.PutUint32(entry[4:], uint32(offset))
+		if _, err := bw.Write(entry); err != nil {
+			return fmt.Errorf("write bif index: %w", err)
+		}
+		offset += sizes[i]
+	}
+	if offset > math.MaxUint32 {
+		return errors.New("bif: file too large")
+	}
+	binary.LittleEndian.PutUint32(entry[0:], bifEndMarker)
+	binary.LittleEndian.PutUint32(entry[4:], uint32(offset))
+	if _, err := bw.Write(entry); err != nil {
+		return fmt.Errorf("write bif index: %w", err)
+	}
+
+	for i, fr := range b.frames {
+		if err := copyFrame(bw, fr.path, sizes[i]); err != nil {
+			return fmt.Errorf("write bif frame %d: %w", i, err)
+		}
+	}
+	return bw.Flush()
+}
+
+// copyFrame copies exactly size bytes so the data always matches the index.
+func copyFrame(w io.Writer, path string, size int64) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.CopyN(w, f, size)
+	return err
+}
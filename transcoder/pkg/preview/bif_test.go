@@ -0,0 +1,47 @@
+package preview
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBIFBuilder_Layout(t *testing.T) {
+	dir := t.TempDir()
+	frames := [][]byte{[]byte("aaa"), []byte("bbbbb")}
+	b := NewBIF().Interval(2)
+	for i, data := range frames {
+		p := filepath.Join(dir, "thumb-"+string(rune('0'+i))+".jpg")
+		if err := os.WriteFile(p, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		b.AddFrame(float64(i)*2, p)
+	}
+	var buf bytes.Buffer
+	if err := b.Write(&buf); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	out := buf.Bytes()
+	if !bytes.Equal(out[:8], bifMagic[:]) {
+		t.Fatalf("bad magic % x", out[:8])
+	}
+	le := binary.LittleEndian
+	if got := le.Uint32(out[12:]); got != 2 {
+		t.Fatalf("frame count = %d, want 2", got)
+	}
+	if got := le.Uint32(out[16:]); got != 2000 {
+		t.Fatalf("separation = %d, want 2000", got)
+	}
+	// Index: (0, 88), (1, 91), (0xffffffff, 96)
+	want := []uint32{0, 88, 1, 91, 0xffffffff, 96}
+	for i, w := range want {
+		if got := le.Uint32(out[64+i*4:]); got != w {
+			t.Fatalf("index word %d = %#x, want %#x", i, got, w)
+		}
+	}
+	if len(out) != 96 || string(out[88:]) != "aaabbbbb" {
+		t.Fatalf("unexpected frame data %q (len %d)", out[88:], len(out))
+	}
+}
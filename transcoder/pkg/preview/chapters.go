@@ -0,0 +1,73 @@
+package preview
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Chapter is a single named section of the video timeline.
+type Chapter struct {
+	Start float64 `json:"start"` // seconds
+	End   float64 `json:"end"`   // seconds
+	Title string  `json:"title"`
+}
+
+// ChaptersBuilder builds a WebVTT chapters track (kind="chapters") and its JSON equivalent.
+type ChaptersBuilder struct {
+	chapters []Chapter
+}
+
+func NewChapters() *ChaptersBuilder {
+	return &ChaptersBuilder{}
+}
+
+// Add appends a chapter. Untitled chapters are numbered by position.
+func (b *ChaptersBuilder) Add(start, end float64, title string) *ChaptersBuilder {
+	if end <= start {
+		return b
+	}
+	if title == "" {
+		title = fmt.Sprintf("Chapter %d", len(b.chapters)+1)
+	}
+	b.chapters = append(b.chapters, Chapter{Start: start, End: end, Title: title})
+	return b
+}
+
+func (b *ChaptersBuilder) Len() int {
+	return len(b.chapters)
+}
+
+func (b *ChaptersBuilder) String() string {
+	lines := []string{"WEBVTT", ""}
+	for i, ch := range b.chapters {
+		// Cue payloads may not contain "-->" or blank lines
+		title := strings.ReplaceAll(ch.Title, "-->", "->")
+		title = strings.Join(strings.Fields(title), " ")
+		lines = append(lines,
+			fmt.Sprintf("chapter-%d", i+1),
+			fmt.Sprintf("%s --> %s", formatVTTTime(ch.Start), formatVTTTime(ch.End)),
+			title,
+			"",
+		)
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+func (b *ChaptersBuilder) WriteFile(path string) error {
+	return os.WriteFile(path, []byte(b.String()), 0o644)
+}
+
+// WriteJSON writes the chapters as a JSON array.
+func (b *ChaptersBuilder) WriteJSON(path string) error {
+	chapters := b.chapters
+	if chapters == nil {
+		chapters = []Chapter{}
+	}
+	data, err := json.MarshalIndent(chapters, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal chapters: %w", err)
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
@@ -0,0 +1,20 @@
+package preview
+
+import "testing"
+
+func TestChaptersBuilder_String(t *testing.T) {
+	b := NewChapters().
+		Add(0, 65.5, "Intro").
+		Add(65.5, 3725, "").
+		Add(10, 10, "empty") // ignored, zero length
+	if b.Len() != 2 {
+		t.Fatalf("expected 2 chapters, got %d", b.Len())
+	}
+	want := "WEBVTT\n\n" +
+		"chapter-1\n00:00:00.000 --> 00:01:05.500\nIntro\n\n" +
+		"chapter-2\n00:01:05.500 --> 01:02:05.000\nChapter 2\n\n"
+	if got := b.String(); got != want {
+		t.Fatalf("unexpected chapters vtt:\n%s", got)
+	}
+	assertValidVTT(t, want)
+}
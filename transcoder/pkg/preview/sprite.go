@@ -3,7 +3,10 @@ package preview
 import (
 	"context"
 	"fmt"
+	"path/filepath"
 	"strconv"
+	"strings"
+	"time"
 
 	ff "transcoder/pkg/ffmpeg"
 )
@@ -12,6 +15,8 @@ import (
 type SpriteBuilder struct {
 	ffmpegPath string
 	inputPath  string
+	start      time.Duration
+	duration   time.Duration
 	outputPath string
 	cols       int
 	rows       int
@@ -36,6 +41,14 @@ func (b *SpriteBuilder) Input(path string) *SpriteBuilder {
 	return b
 }
 
+// Range limits the sprite to duration of the input from start; a zero duration runs to
+// the end.
+func (b *SpriteBuilder) Range(start, duration time.Duration) *SpriteBuilder {
+	b.start = start
+	b.duration = duration
+	return b
+}
+
 func (b *SpriteBuilder) Output(path string) *SpriteBuilder {
 	b.outputPath = path
 	return b
@@ -72,9 +85,41 @@ func (b *SpriteBuilder) Frames(n int) *SpriteBuilder {
 	return b
 }
 
+// Sheets returns the paths of the sprite sheets Run writes. When the frame count fits
+// in one grid this is just the output path; otherwise sheets are numbered from 0
+// (sprite.jpg -> sprite-000.jpg, sprite-001.jpg, ...).
+func (b *SpriteBuilder) Sheets() []string {
+	n := SheetCount(b.frames, b.cols, b.rows)
+	if n <= 1 {
+		return []string{b.outputPath}
+	}
+	sheets := make([]string, n)
+	for i := range sheets {
+		sheets[i] = SheetName(b.outputPath, i)
+	}
+	return sheets
+}
+
+// SheetCount returns how many cols x rows sheets are needed to hold frames thumbnails.
+func SheetCount(frames, cols, rows int) int {
+	perSheet := cols * rows
+	if perSheet <= 0 || frames <= 0 {
+		return 1
+	}
+	return (frames + perSheet - 1) / perSheet
+}
+
+// SheetName returns the numbered sheet path for idx, e.g. ("sprite.jpg", 1) -> "sprite-001.jpg".
+func SheetName(path string, idx int) string {
+	ext := filepath.Ext(path)
+	return fmt.Sprintf("%s-%03d%s", strings.TrimSuffix(path, ext), idx, ext)
+}
+
 func (b *SpriteBuilder) Run(ctx context.Context) error {
 	cmd := ff.New(b.ffmpegPath).
 		Overwrite(true).
+		StartAt(b.start).
+		Duration(b.duration).
 		Input(b.inputPath)
 
 	fc := ff.NewFilterChain()
@@ -87,11 +132,17 @@ func (b *SpriteBuilder) Run(ctx context.Context) error {
 	if b.fps > 0 && float64(int(b.fps)) != b.fps {
 		cmd.Filter(fmt.Sprintf("fps=%.3f", b.fps))
 	}
-	if b.frames > 0 {
+	output := b.outputPath
+	if sheets := SheetCount(b.frames, b.cols, b.rows); sheets > 1 {
+		// Each tiled output frame is one sheet; write them as a numbered image sequence
+		ext := filepath.Ext(b.outputPath)
+		output = strings.TrimSuffix(b.outputPath, ext) + "-%03d" + ext
+		cmd.Arg("-frames:v", strconv.Itoa(sheets), "-start_number", "0")
+	} else if b.frames > 0 {
 		cmd.Arg("-frames:v", strconv.Itoa(b.frames))
 	}
 	cmd.Arg("-q:v", strconv.Itoa(b.quality)).
-		Output(b.outputPath)
+		Output(output)
 
 	return cmd.Run(ctx)
 }
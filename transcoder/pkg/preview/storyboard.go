@@ -0,0 +1,50 @@
+package preview
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// StoryboardJSON describes sprite sheet geometry so players can lay out scrubber
+// thumbnails without parsing the VTT. Thumbnail i starts at i*Interval seconds and
+// sits in cell i%(Cols*Rows) of sheet i/(Cols*Rows), filled row by row.
+type StoryboardJSON struct {
+	URL        string   `json:"url"`
+	TileWidth  int      `json:"tileWidth"`
+	TileHeight int      `json:"tileHeight"`
+	Cols       int      `json:"cols"`
+	Rows       int      `json:"rows"`
+	Interval   float64  `json:"interval"` // seconds between thumbnails
+	Count      int      `json:"count"`
+	Sheets     []string `json:"sheets,omitempty"` // all sheets in order, when there is more than one
+}
+
+// NewStoryboardJSON builds the storyboard for the same inputs given to VTTBuilder
+// (UsingSprites, Grid, AddGridTimeline), so the interval and count match the VTT cues.
+func NewStoryboardJSON(sheets []string, cols, rows, thumbW, thumbH int, fps, durationSec float64, totalThumbs int) StoryboardJSON {
+	n, interval := gridTimeline(fps, durationSec, totalThumbs, cols*rows*max(len(sheets), 1))
+	sb := StoryboardJSON{
+		TileWidth:  thumbW,
+		TileHeight: thumbH,
+		Cols:       cols,
+		Rows:       rows,
+		Interval:   interval,
+		Count:      n,
+	}
+	if len(sheets) > 0 {
+		sb.URL = sheets[0]
+	}
+	if len(sheets) > 1 {
+		sb.Sheets = sheets
+	}
+	return sb
+}
+
+func (s StoryboardJSON) WriteFile(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal storyboard: %w", err)
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
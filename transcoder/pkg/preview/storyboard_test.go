@@ -0,0 +1,19 @@
+package preview
+
+import "testing"
+
+func TestNewStoryboardJSON_MatchesVTTTimeline(t *testing.T) {
+	sb := NewStoryboardJSON([]string{"sprite-000.jpg", "sprite-001.jpg"}, 2, 2, 100, 56, 0.5, 12, 0)
+	if sb.URL != "sprite-000.jpg" || len(sb.Sheets) != 2 {
+		t.Fatalf("unexpected sheets: %+v", sb)
+	}
+	// 12s at 0.5fps => 6 thumbs every 2s
+	if sb.Count != 6 || sb.Interval != 2 {
+		t.Fatalf("count=%d interval=%v, want 6 and 2", sb.Count, sb.Interval)
+	}
+
+	sb = NewStoryboardJSON([]string{"sprite.jpg"}, 10, 10, 160, 90, 0, 50, 20)
+	if sb.Sheets != nil || sb.Count != 20 || sb.Interval != 2.5 {
+		t.Fatalf("unexpected duration-based storyboard: %+v", sb)
+	}
+}
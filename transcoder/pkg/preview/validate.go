@@ -0,0 +1,200 @@
+package preview
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// vttTimeRe matches a WebVTT timestamp: optional hours (two or more digits), then
+// minutes and seconds below 60 and exactly three fractional digits.
+var vttTimeRe = regexp.MustCompile(`^(?:(\d{2,}):)?([0-5]\d):([0-5]\d)\.(\d{3})$`)
+
+// ValidateVTT checks that r holds a WebVTT file the players we target accept: a WEBVTT
+// header, cues separated by blank lines, cue times in order without overlapping the
+// previous cue, and well-formed #xywh fragments in cue payloads. NOTE, STYLE and REGION
+// blocks are skipped. Errors name the offending line.
+func ValidateVTT(r io.Reader) error {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), 1<<20)
+
+	lineNo := 0
+	next := func() (string, bool) {
+		if !sc.Scan() {
+			return "", false
+		}
+		lineNo++
+		return strings.TrimSuffix(sc.Text(), "\r"), true
+	}
+
+	header, ok := next()
+	header = strings.TrimPrefix(header, "\ufeff")
+	if !ok || !(header == "WEBVTT" || strings.HasPrefix(header, "WEBVTT ") || strings.HasPrefix(header, "WEBVTT\t")) {
+		if err := sc.Err(); err != nil {
+			return fmt.Errorf("read vtt: %w", err)
+		}
+		return errors.New("vtt: missing WEBVTT header")
+	}
+	// Header metadata runs up to the first blank line
+	for {
+		line, ok := next()
+		if !ok || line == "" {
+			break
+		}
+		if strings.Contains(line, "-->") {
+			return fmt.Errorf("vtt line %d: cue before the blank line ending the header", lineNo)
+		}
+	}
+
+	v := vttValidator{prevStart: -1, prevEnd: -1}
+	var block []string
+	blockStart := 0
+	for {
+		line, ok := next()
+		if ok && line != "" {
+			if len(block) == 0 {
+				blockStart = lineNo
+			}
+			block = append(block, line)
+			continue
+		}
+		if len(block) > 0 {
+			if err := v.block(block, blockStart); err != nil {
+				return err
+			}
+			block = block[:0]
+		}
+		if !ok {
+			break
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return fmt.Errorf("read vtt: %w", err)
+	}
+	return nil
+}
+
+// vttValidator carries the previous cue's times between blocks.
+type vttValidator struct {
+	cues      int
+	prevStart float64
+	prevEnd   float64
+}
+
+// block checks one blank-line separated block whose first line is line number first.
+func (v *vttValidator) block(lines []string, first int) error {
+	timing := 0
+	if !strings.Contains(lines[0], "-->") {
+		if isVTTMetaBlock(lines[0]) {
+			return nil
+		}
+		// The first line is a cue identifier
+		timing = 1
+		if len(lines) < 2 || !strings.Contains(lines[1], "-->") {
+			return fmt.Errorf("vtt line %d: cue without a timing line", first)
+		}
+	}
+	v.cues++
+	lineNo := first + timing
+	start, end, err := parseVTTTiming(lines[timing])
+	if err != nil {
+		return fmt.Errorf("vtt line %d: cue %d: %w", lineNo, v.cues, err)
+	}
+	if end <= start {
+		return fmt.Errorf("vtt line %d: cue %d ends at %s, not after its start %s",
+			lineNo, v.cues, formatVTTTime(end), formatVTTTime(start))
+	}
+	if start < v.prevStart {
+		return fmt.Errorf("vtt line %d: cue %d starts at %s, before the previous cue (%s)",
+			lineNo, v.cues, formatVTTTime(start), formatVTTTime(v.prevStart))
+	}
+	if start < v.prevEnd {
+		return fmt.Errorf("vtt line %d: cue %d starts at %s, overlapping the previous cue which ends at %s",
+			lineNo, v.cues, formatVTTTime(start), formatVTTTime(v.prevEnd))
+	}
+	v.prevStart, v.prevEnd = start, end
+
+	for i, line := range lines[timing+1:] {
+		lineNo := first + timing + 1 + i
+		if strings.Contains(line, "-->") {
+			return fmt.Errorf("vtt line %d: timing line inside cue %d (missing blank line?)", lineNo, v.cues)
+		}
+		if err := checkXYWH(line); err != nil {
+			return fmt.Errorf("vtt line %d: cue %d: %w", lineNo, v.cues, err)
+		}
+	}
+	return nil
+}
+
+// isVTTMetaBlock reports whether a block starting with line is a comment, style sheet or
+// region definition rather than a cue.
+func isVTTMetaBlock(line string) bool {
+	for _, kw := range []string{"NOTE", "STYLE", "REGION"} {
+		if line == kw || strings.HasPrefix(line, kw+" ") || strings.HasPrefix(line, kw+"\t") {
+			return true
+		}
+	}
+	return false
+}
+
+// parseVTTTiming parses a "start --> end [settings]" cue timing line.
+func parseVTTTiming(line string) (start, end float64, err error) {
+	left, right, _ := strings.Cut(line, "-->")
+	fields := strings.Fields(right)
+	if len(fields) == 0 {
+		return 0, 0, fmt.Errorf("missing end time in %q", line)
+	}
+	if start, err = parseVTTTime(strings.TrimSpace(left)); err != nil {
+		return 0, 0, err
+	}
+	if end, err = parseVTTTime(fields[0]); err != nil {
+		return 0, 0, err
+	}
+	return start, end, nil
+}
+
+// parseVTTTime parses a WebVTT timestamp into seconds.
+func parseVTTTime(s string) (float64, error) {
+	m := vttTimeRe.FindStringSubmatch(s)
+	if m == nil {
+		return 0, fmt.Errorf("malformed timestamp %q", s)
+	}
+	var h int
+	if m[1] != "" {
+		h, _ = strconv.Atoi(m[1])
+	}
+	mins, _ := strconv.Atoi(m[2])
+	sec, _ := strconv.Atoi(m[3])
+	ms, _ := strconv.Atoi(m[4])
+	return float64(h*3600+mins*60+sec) + float64(ms)/1000, nil
+}
+
+// checkXYWH validates a media fragment of the form #xywh=[pixel:]x,y,w,h in a cue
+// payload, if there is one: four non-negative integers with a non-empty size.
+func checkXYWH(payload string) error {
+	_, frag, ok := strings.Cut(payload, "#xywh=")
+	if !ok {
+		return nil
+	}
+	frag = strings.TrimPrefix(frag, "pixel:")
+	parts := strings.Split(frag, ",")
+	if len(parts) != 4 {
+		return fmt.Errorf("malformed #xywh fragment %q", frag)
+	}
+	var n [4]int
+	for i, p := range parts {
+		v, err := strconv.Atoi(p)
+		if err != nil || v < 0 {
+			return fmt.Errorf("malformed #xywh fragment %q", frag)
+		}
+		n[i] = v
+	}
+	if n[2] == 0 || n[3] == 0 {
+		return fmt.Errorf("empty #xywh region %q", frag)
+	}
+	return nil
+}
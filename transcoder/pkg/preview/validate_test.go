@@ -0,0 +1,58 @@
+package preview
+
+import (
+	"strings"
+	"testing"
+)
+
+// assertValidVTT fails the test if vtt is not a well-formed WebVTT track.
+func assertValidVTT(t *testing.T, vtt string) {
+	t.Helper()
+	if err := ValidateVTT(strings.NewReader(vtt)); err != nil {
+		t.Fatalf("invalid vtt: %v\n%s", err, vtt)
+	}
+}
+
+func TestValidateVTT_Valid(t *testing.T) {
+	assertValidVTT(t, "WEBVTT\n")
+	assertValidVTT(t, "\ufeffWEBVTT - thumbnails\r\nKind: metadata\r\n\r\n"+
+		"NOTE generated\r\n\r\n"+
+		"00:00.000 --> 00:01.000\r\nsprite.jpg#xywh=0,0,160,90\r\n\r\n"+
+		"cue-2\r\n00:01.000 --> 01:00:00.000 align:start\r\nsprite.jpg#xywh=pixel:160,0,160,90\r\n")
+}
+
+func TestValidateVTT_Invalid(t *testing.T) {
+	cases := []struct {
+		name, vtt, want string
+	}{
+		{"no header", "00:00:00.000 --> 00:00:01.000\nx\n", "missing WEBVTT header"},
+		{"header prefix", "WEBVTTX\n\n", "missing WEBVTT header"},
+		{"sixty seconds", "WEBVTT\n\n00:00:60.000 --> 00:01:01.000\nx\n", "line 3: cue 1: malformed timestamp"},
+		{"no end", "WEBVTT\n\n00:00:01.000 -->\nx\n", "missing end time"},
+		{"empty cue", "WEBVTT\n\n00:00:01.000 --> 00:00:01.000\nx\n", "not after its start"},
+		{"out of order", "WEBVTT\n\n00:00:05.000 --> 00:00:06.000\na\n\n00:00:01.000 --> 00:00:02.000\nb\n", "before the previous cue"},
+		{"overlap", "WEBVTT\n\n00:00:00.000 --> 00:00:02.000\na\n\n00:00:01.000 --> 00:00:03.000\nb\n", "line 6: cue 2 starts at 00:00:01.000, overlapping"},
+		// The last cue's end clamped to the window end while its start was not
+		{"clamped end", "WEBVTT\n\n00:00:08.000 --> 00:00:09.500\na\n\n00:00:09.500 --> 00:00:09.000\nb\n", "cue 2 ends at 00:00:09.000"},
+		{"missing blank line", "WEBVTT\n\n00:00:00.000 --> 00:00:01.000\na\n00:00:01.000 --> 00:00:02.000\nb\n", "line 5: timing line inside cue 1"},
+		{"identifier only", "WEBVTT\n\nchapter-1\nIntro\n", "cue without a timing line"},
+		{"short xywh", "WEBVTT\n\n00:00:00.000 --> 00:00:01.000\nsprite.jpg#xywh=0,0,160\n", "malformed #xywh"},
+		{"negative xywh", "WEBVTT\n\n00:00:00.000 --> 00:00:01.000\nsprite.jpg#xywh=-1,0,160,90\n", "malformed #xywh"},
+		{"empty xywh", "WEBVTT\n\n00:00:00.000 --> 00:00:01.000\nsprite.jpg#xywh=0,0,0,90\n", "empty #xywh region"},
+	}
+	for _, c := range cases {
+		err := ValidateVTT(strings.NewReader(c.vtt))
+		if err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("%s: got %v, want error containing %q", c.name, err, c.want)
+		}
+	}
+}
+
+func TestFormatVTTTime_RoundsBeforeSplitting(t *testing.T) {
+	if got := formatVTTTime(59.9996); got != "00:01:00.000" {
+		t.Errorf("formatVTTTime(59.9996) = %q, want 00:01:00.000", got)
+	}
+	if got := formatVTTTime(3723.0424); got != "01:02:03.042" {
+		t.Errorf("formatVTTTime(3723.0424) = %q, want 01:02:03.042", got)
+	}
+}
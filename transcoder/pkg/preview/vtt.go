@@ -2,14 +2,16 @@ package preview
 
 import (
 	"fmt"
+	"math"
 	"os"
 	"strings"
 )
 
-// VTTBuilder builds a WebVTT file that references regions within a sprite image.
+// VTTBuilder builds a WebVTT file that references regions within one or more sprite images.
 type VTTBuilder struct {
 	lines          []string
 	spriteBasename string
+	sheets         []string
 	cols           int
 	rows           int
 	thumbW         int
@@ -28,6 +30,16 @@ func (b *VTTBuilder) UsingSprite(basename string) *VTTBuilder {
 	return b
 }
 
+// UsingSprites sets the basenames of consecutive sprite sheets (e.g., "sprite-000.jpg",
+// "sprite-001.jpg"). Cues fill each sheet's grid before moving on to the next one.
+func (b *VTTBuilder) UsingSprites(basenames ...string) *VTTBuilder {
+	b.sheets = basenames
+	if len(basenames) > 0 {
+		b.spriteBasename = basenames[0]
+	}
+	return b
+}
+
 func (b *VTTBuilder) Grid(cols, rows, thumbW, thumbH int) *VTTBuilder {
 	b.cols = cols
 	b.rows = rows
@@ -37,11 +49,60 @@ func (b *VTTBuilder) Grid(cols, rows, thumbW, thumbH int) *VTTBuilder {
 }
 
 // AddGridTimeline generates cues for a grid of thumbnails:
-// - If fps > 0 and durationSec > 0, uses ceil(duration*fps) thumbs, capped to cols*rows
-// - Else uses totalThumbs if provided (>0), capped to cols*rows
-// Each cue spans [start, end] where end = start + max(1s, 1/fps) if fps>0 else 1s.
+// - If fps > 0 and durationSec > 0, uses ceil(duration*fps) thumbs, capped to cols*rows per sheet
+// - Else uses totalThumbs if provided (>0), capped to cols*rows per sheet
+// Each cue runs until the next one starts, so cues never overlap; the last one ends at
+// durationSec when that is known.
+// With multiple sheets (UsingSprites), the cue URL rolls over to the next sheet every cols*rows thumbs.
 func (b *VTTBuilder) AddGridTimeline(fps float64, durationSec float64, totalThumbs int) *VTTBuilder {
-	maxThumbs := b.cols * b.rows
+	perSheet := b.cols * b.rows
+	n, interval := gridTimeline(fps, durationSec, totalThumbs, perSheet*max(len(b.sheets), 1))
+	for i := 0; i < n; i++ {
+		start := float64(i) * interval
+		end := start + interval
+		if durationSec > start && end > durationSec {
+			end = durationSec
+		}
+		b.addCue(i, start, end)
+	}
+	return b
+}
+
+// AddCues adds one cue per grid thumbnail at the given start times, in order; each cue
+// runs until the next one starts, the last until end. Unlike AddGridTimeline the
+// thumbnails need not be evenly spaced.
+func (b *VTTBuilder) AddCues(starts []float64, end float64) *VTTBuilder {
+	for i, start := range starts {
+		cueEnd := end
+		if i+1 < len(starts) {
+			cueEnd = starts[i+1]
+		}
+		b.addCue(i, start, cueEnd)
+	}
+	return b
+}
+
+// addCue adds a cue showing grid thumbnail i, rolling over to the next sheet every
+// cols*rows thumbnails.
+func (b *VTTBuilder) addCue(i int, start, end float64) {
+	perSheet := b.cols * b.rows
+	sprite := b.spriteBasename
+	if len(b.sheets) > 0 {
+		sprite = b.sheets[i/perSheet]
+	}
+	cell := i % perSheet
+	x := (cell % b.cols) * b.thumbW
+	y := (cell / b.cols) * b.thumbH
+	b.lines = append(b.lines,
+		fmt.Sprintf("%s --> %s", formatVTTTime(start), formatVTTTime(end)),
+		fmt.Sprintf("%s#xywh=%d,%d,%d,%d", sprite, x, y, b.thumbW, b.thumbH),
+		"",
+	)
+}
+
+// gridTimeline returns how many grid thumbnails AddGridTimeline emits and the spacing
+// between their start times (1s when neither fps nor duration is known).
+func gridTimeline(fps, durationSec float64, totalThumbs, maxThumbs int) (int, float64) {
 	n := 0
 	if fps > 0 && durationSec > 0 {
 		n = int(ceil(durationSec * fps))
@@ -55,23 +116,13 @@ func (b *VTTBuilder) AddGridTimeline(fps float64, durationSec float64, totalThum
 	if n > maxThumbs {
 		n = maxThumbs
 	}
-	for i := 0; i < n; i++ {
-		start := 0.0
-		if fps > 0 {
-			start = float64(i) / fps
-		} else if durationSec > 0 {
-			start = (durationSec * float64(i)) / float64(n)
-		}
-		end := start + maxf(1.0, invOrZero(fps))
-		x := (i % b.cols) * b.thumbW
-		y := (i / b.cols) * b.thumbH
-		b.lines = append(b.lines,
-			fmt.Sprintf("%s --> %s", formatVTTTime(start), formatVTTTime(end)),
-			fmt.Sprintf("%s#xywh=%d,%d,%d,%d", b.spriteBasename, x, y, b.thumbW, b.thumbH),
-			"",
-		)
+	switch {
+	case fps > 0:
+		return n, 1 / fps
+	case durationSec > 0 && n > 0:
+		return n, durationSec / float64(n)
 	}
-	return b
+	return n, 1
 }
 
 func (b *VTTBuilder) String() string {
@@ -82,28 +133,14 @@ func (b *VTTBuilder) WriteFile(path string) error {
 	return os.WriteFile(path, []byte(b.String()), 0o644)
 }
 
+// formatVTTTime renders sec as HH:MM:SS.mmm. It rounds to whole milliseconds before
+// splitting, so e.g. 59.9996 becomes 00:01:00.000 rather than 00:00:60.000.
 func formatVTTTime(sec float64) string {
 	if sec < 0 {
 		sec = 0
 	}
-	h := int(sec) / 3600
-	m := (int(sec) % 3600) / 60
-	s := sec - float64(h*3600+m*60)
-	return fmt.Sprintf("%02d:%02d:%06.3f", h, m, s)
-}
-
-func invOrZero(v float64) float64 {
-	if v <= 0 {
-		return 0
-	}
-	return 1.0 / v
-}
-
-func maxf(a, b float64) float64 {
-	if a > b {
-		return a
-	}
-	return b
+	ms := int64(math.Round(sec * 1000))
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
 }
 
 func ceil(v float64) float64 {
@@ -15,8 +15,8 @@ func TestVTTBuilder_GridTimeline_FPSBased(t *testing.T) {
 	if !strings.HasPrefix(out, "WEBVTT") {
 		t.Fatalf("missing WEBVTT header:\n%s", out)
 	}
-	// First cue should be 00:00:00.000 --> 00:00:01.000 and xywh=0,0,100,56
-	wantFirst := "00:00:00.000 --> 00:00:01.000"
+	// First cue should be 00:00:00.000 --> 00:00:00.500 and xywh=0,0,100,56
+	wantFirst := "00:00:00.000 --> 00:00:00.500"
 	if lines[2] != wantFirst {
 		t.Fatalf("unexpected first cue time: %q", lines[2])
 	}
@@ -28,3 +28,62 @@ func TestVTTBuilder_GridTimeline_FPSBased(t *testing.T) {
 		t.Fatalf("missing expected last tile coords in:\n%s", out)
 	}
 }
+
+func TestVTTBuilder_GridTimeline_NoOverlap(t *testing.T) {
+	// fps=2 over 2.7s => 6 thumbs half a second apart, the last cut short at the end
+	out := NewVTT().
+		UsingSprite("sprite.jpg").
+		Grid(3, 2, 100, 56).
+		AddGridTimeline(2.0, 2.7, 0).
+		String()
+	assertValidVTT(t, out)
+	for _, want := range []string{
+		"00:00:00.000 --> 00:00:00.500\n",
+		"00:00:00.500 --> 00:00:01.000\n",
+		"00:00:02.000 --> 00:00:02.500\n",
+		"00:00:02.500 --> 00:00:02.700\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing cue %q in:\n%s", want, out)
+		}
+	}
+}
+
+func TestVTTBuilder_GridTimeline_MultiSheet(t *testing.T) {
+	b := NewVTT().
+		UsingSprites("sprite-000.jpg", "sprite-001.jpg").
+		Grid(2, 2, 100, 56).
+		AddGridTimeline(1.0, 6.0, 0) // 6 thumbs over two 2x2 sheets
+	out := b.String()
+	// i=3 is the last cell of the first sheet
+	if !strings.Contains(out, "00:00:03.000 --> 00:00:04.000\nsprite-000.jpg#xywh=100,56,100,56") {
+		t.Fatalf("missing last cell of first sheet in:\n%s", out)
+	}
+	// i=4 rolls over to the first cell of the second sheet
+	if !strings.Contains(out, "00:00:04.000 --> 00:00:05.000\nsprite-001.jpg#xywh=0,0,100,56") {
+		t.Fatalf("missing first cell of second sheet in:\n%s", out)
+	}
+	if strings.Count(out, "-->") != 6 {
+		t.Fatalf("expected 6 cues in:\n%s", out)
+	}
+	assertValidVTT(t, out)
+}
+
+func TestVTTBuilder_AddCues(t *testing.T) {
+	out := NewVTT().
+		UsingSprites("thumbs/sprite-000.jpg", "thumbs/sprite-001.jpg").
+		Grid(2, 1, 100, 56).
+		AddCues([]float64{0, 2.5, 9, 30}, 42).
+		String()
+	assertValidVTT(t, out)
+	for _, want := range []string{
+		"00:00:00.000 --> 00:00:02.500\nthumbs/sprite-000.jpg#xywh=0,0,100,56",
+		"00:00:02.500 --> 00:00:09.000\nthumbs/sprite-000.jpg#xywh=100,0,100,56",
+		"00:00:09.000 --> 00:00:30.000\nthumbs/sprite-001.jpg#xywh=0,0,100,56",
+		"00:00:30.000 --> 00:00:42.000\nthumbs/sprite-001.jpg#xywh=100,0,100,56",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing cue %q in:\n%s", want, out)
+		}
+	}
+}
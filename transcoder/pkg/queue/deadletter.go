@@ -0,0 +1,116 @@
+package queue
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrNotFailed is returned by RequeueFailed for a job that is not failed or dead.
+	ErrNotFailed = errors.New("job has not failed")
+	// ErrNoAttemptsLeft is returned by RequeueFailed for a job that already used the
+	// AttemptPolicy's MaxAttempts, unless the attempts are reset.
+	ErrNoAttemptsLeft = errors.New("job has no attempts left")
+)
+
+// ListFailed returns up to limit jobs that ended in StatusDead or StatusFailed, most
+// recently finished first, for triage before RequeueFailed.
+func ListFailed(ctx context.Context, db *sql.DB, limit int) ([]Job, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+	rows, err := db.QueryContext(ctx, `
+		SELECT `+jobColumns+`
+		FROM transcode_queue
+		WHERE status IN ($1, $2)
+		ORDER BY finished_at DESC NULLS LAST, id DESC
+		LIMIT $3
+	`, StatusDead, StatusFailed, limit)
+	if err != nil {
+		return nil, fmt.Errorf("list failed: %w", err)
+	}
+	defer rows.Close()
+
+	var jobs []Job
+	for rows.Next() {
+		j, err := scanJob(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan job: %w", err)
+		}
+		jobs = append(jobs, *j)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("list failed: %w", err)
+	}
+	return jobs, nil
+}
+
+// RequeueFailed puts a dead or failed job back in the queue, ready to be claimed
+// immediately, clears its error and sends a NOTIFY on NotifyChannel so idle workers pick
+// it up. A job that already used all its attempts is refused with ErrNoAttemptsLeft
+// unless resetAttempts starts its count over. It returns sql.ErrNoRows if the job does
+// not exist and ErrNotFailed if it is in any other status.
+func RequeueFailed(ctx context.Context, db *sql.DB, id string, resetAttempts bool) error {
+	err := withRetry(ctx, "requeue failed", func() error {
+		return requeueFailed(ctx, db, id, resetAttempts)
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		return fmt.Errorf("requeue failed: %w", err)
+	}
+	return nil
+}
+
+func requeueFailed(ctx context.Context, db *sql.DB, id string, resetAttempts bool) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	var (
+		status   Status
+		attempts int
+	)
+	err = tx.QueryRowContext(ctx, `
+		SELECT status, attempts FROM transcode_queue WHERE id = $1 FOR UPDATE
+	`, id).Scan(&status, &attempts)
+	if err != nil {
+		return err
+	}
+	if status != StatusDead && status != StatusFailed {
+		return fmt.Errorf("%w: status is %s", ErrNotFailed, status)
+	}
+	if !resetAttempts && attempts >= attemptPolicy.MaxAttempts {
+		return fmt.Errorf("%w: %d of %d used", ErrNoAttemptsLeft, attempts, attemptPolicy.MaxAttempts)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE transcode_queue
+		SET status = $1,
+		    attempts = CASE WHEN $2 THEN 0 ELSE attempts END,
+		    error = NULL,
+		    cancel_requested = FALSE,
+		    progress_percent = 0,
+		    next_attempt_at = NOW(),
+		    finished_at = NULL,
+		    updated_at = NOW()
+		WHERE id = $3
+	`, StatusQueued, resetAttempts, id)
+	if err != nil {
+		return fmt.Errorf("update job: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `SELECT pg_notify($1, $2)`, NotifyChannel, "1"); err != nil {
+		return fmt.Errorf("notify: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit: %w", err)
+	}
+	return nil
+}
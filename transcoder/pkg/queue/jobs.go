@@ -0,0 +1,121 @@
+package queue
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Job is the full transcode_queue record, for admin tooling and inspection.
+// Unlike TranscodeJob it carries status, timestamps and the last error.
+type Job struct {
+	ID                    string
+	VideoID               string
+	InputKey              string
+	OutputPrefix          string
+	Status                Status
+	Attempts              int
+	Priority              int
+	WorkerClass           string // empty when the job is untagged
+	Error                 string // empty when no error was recorded
+	ProgressPercent       int
+	CancelRequested       bool
+	HLSStatus             ProcessingStatus
+	PosterStatus          ProcessingStatus
+	ScrubberPreviewStatus ProcessingStatus
+	HoverPreviewStatus    ProcessingStatus
+	CreatedAt             time.Time
+	UpdatedAt             time.Time
+	NextAttemptAt         time.Time
+	StartedAt             *time.Time
+	FinishedAt            *time.Time
+	HeartbeatAt           *time.Time
+}
+
+const jobColumns = `
+	id, video_id, input_key, output_prefix, status, attempts, priority,
+	worker_class, error, progress_percent, cancel_requested,
+	hls_status, poster_status, scrubber_preview_status, hover_preview_status,
+	created_at, updated_at, next_attempt_at, started_at, finished_at, heartbeat_at
+`
+
+// GetJob returns the job with the given id, or sql.ErrNoRows if it does not exist.
+func GetJob(ctx context.Context, db *sql.DB, id string) (*Job, error) {
+	row := db.QueryRowContext(ctx, `SELECT `+jobColumns+` FROM transcode_queue WHERE id = $1`, id)
+	j, err := scanJob(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, err
+		}
+		return nil, fmt.Errorf("get job: %w", err)
+	}
+	return j, nil
+}
+
+// ListJobs returns jobs newest first, optionally filtered by status ("" for all).
+// Ties on created_at are broken by id so paging with limit/offset is stable.
+func ListJobs(ctx context.Context, db *sql.DB, status Status, limit, offset int) ([]Job, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+	offset = max(offset, 0)
+	rows, err := db.QueryContext(ctx, `
+		SELECT `+jobColumns+`
+		FROM transcode_queue
+		WHERE ($1::text = '' OR status::text = $1)
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2 OFFSET $3
+	`, status, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("list jobs: %w", err)
+	}
+	defer rows.Close()
+
+	var jobs []Job
+	for rows.Next() {
+		j, err := scanJob(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan job: %w", err)
+		}
+		jobs = append(jobs, *j)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("list jobs: %w", err)
+	}
+	return jobs, nil
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanJob(row rowScanner) (*Job, error) {
+	var (
+		j                                  Job
+		workerClass, errMsg                sql.NullString
+		startedAt, finishedAt, heartbeatAt sql.NullTime
+	)
+	err := row.Scan(
+		&j.ID, &j.VideoID, &j.InputKey, &j.OutputPrefix, &j.Status, &j.Attempts, &j.Priority,
+		&workerClass, &errMsg, &j.ProgressPercent, &j.CancelRequested,
+		&j.HLSStatus, &j.PosterStatus, &j.ScrubberPreviewStatus, &j.HoverPreviewStatus,
+		&j.CreatedAt, &j.UpdatedAt, &j.NextAttemptAt, &startedAt, &finishedAt, &heartbeatAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	j.WorkerClass = workerClass.String
+	j.Error = errMsg.String
+	j.StartedAt = nullTimePtr(startedAt)
+	j.FinishedAt = nullTimePtr(finishedAt)
+	j.HeartbeatAt = nullTimePtr(heartbeatAt)
+	return &j, nil
+}
+
+func nullTimePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
@@ -3,30 +3,67 @@ package queue
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
+
+	"github.com/lib/pq"
 )
 
 type Status string
 
 const (
-	StatusQueued  Status = "queued"
-	StatusRunning Status = "running"
-	StatusDone    Status = "done"
-	StatusFailed  Status = "failed"
+	StatusQueued    Status = "queued"
+	StatusRunning   Status = "running"
+	StatusDone      Status = "done"
+	StatusFailed    Status = "failed"
+	StatusDead      Status = "dead" // gave up after AttemptPolicy.MaxAttempts failures
+	StatusCancelled Status = "cancelled"
 )
 
+// ErrNotOwner is returned by the updates a worker makes to its job (Complete, Fail,
+// Heartbeat, UpdateProgress, ...) when the update was ignored because the job is no
+// longer running under the caller's claim: it was reclaimed as stale, and possibly
+// claimed by another worker, while the caller was still working.
+var ErrNotOwner = errors.New("job is no longer owned by this worker")
+
 type TranscodeJob struct {
 	ID           string
 	VideoID      string
 	InputKey     string
 	OutputPrefix string
 	Attempts     int
+	Priority     int    // higher is claimed first
+	WorkerClass  string // empty when the job is untagged
+	// Optional trim, in seconds into the source; 0 when unset (OutPoint 0 runs to the end)
+	InPoint  float64
+	OutPoint float64
+	// Parts of a multi-part source, in play order; the job joins them into one video
+	// first. Empty for a single-file source, which is InputKey.
+	InputKeys []string
+	// Optional title and ISO 639 language written into the outputs' metadata; empty
+	// falls back to the source's tags
+	Title    string
+	Language string
 }
 
-// ClaimNext atomically claims the oldest queued job using SKIP LOCKED pattern.
+// ClaimNext atomically claims the highest-priority queued job whose backoff has elapsed
+// using SKIP LOCKED pattern. Jobs of equal priority are claimed oldest first.
+// If workerClass is non-empty, only jobs tagged with that class or untagged jobs are claimed.
 // Returns sql.ErrNoRows if no jobs are available.
-func ClaimNext(ctx context.Context, db *sql.DB) (*TranscodeJob, error) {
+func ClaimNext(ctx context.Context, db *sql.DB, workerClass string) (*TranscodeJob, error) {
+	var j *TranscodeJob
+	err := withRetry(ctx, "claim next", func() error {
+		var err error
+		j, err = claimNext(ctx, db, workerClass)
+		return err
+	})
+	return j, err
+}
+
+func claimNext(ctx context.Context, db *sql.DB, workerClass string) (*TranscodeJob, error) {
 	tx, err := db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelReadCommitted})
 	if err != nil {
 		return nil, fmt.Errorf("begin tx: %w", err)
@@ -42,7 +79,9 @@ func ClaimNext(ctx context.Context, db *sql.DB) (*TranscodeJob, error) {
 			SELECT id
 			FROM transcode_queue
 			WHERE status = $1
-			ORDER BY created_at ASC
+			  AND next_attempt_at <= NOW()
+			  AND ($3::text = '' OR worker_class IS NULL OR worker_class = $3)
+			ORDER BY priority DESC, created_at ASC
 			FOR UPDATE SKIP LOCKED
 			LIMIT 1
 		)
@@ -50,12 +89,16 @@ func ClaimNext(ctx context.Context, db *sql.DB) (*TranscodeJob, error) {
 		SET status = $2,
 		    attempts = q.attempts + 1,
 		    started_at = NOW(),
+		    heartbeat_at = NOW(),
+		    progress_percent = 0,
 		    updated_at = NOW()
 		FROM next
 		WHERE q.id = next.id
-		RETURNING q.id, q.video_id, q.input_key, q.output_prefix, q.attempts
-	`, StatusQueued, StatusRunning)
-	if err := row.Scan(&j.ID, &j.VideoID, &j.InputKey, &j.OutputPrefix, &j.Attempts); err != nil {
+		RETURNING q.id, q.video_id, q.input_key, q.output_prefix, q.attempts, q.priority, COALESCE(q.worker_class, ''),
+		          COALESCE(q.in_point, 0), COALESCE(q.out_point, 0), q.input_keys,
+		          COALESCE(q.title, ''), COALESCE(q.language, '')
+	`, StatusQueued, StatusRunning, workerClass)
+	if err := row.Scan(&j.ID, &j.VideoID, &j.InputKey, &j.OutputPrefix, &j.Attempts, &j.Priority, &j.WorkerClass, &j.InPoint, &j.OutPoint, pq.Array(&j.InputKeys), &j.Title, &j.Language); err != nil {
 		if err == sql.ErrNoRows {
 			return nil, err
 		}
@@ -67,47 +110,367 @@ func ClaimNext(ctx context.Context, db *sql.DB) (*TranscodeJob, error) {
 	return &j, nil
 }
 
-func Complete(ctx context.Context, db *sql.DB, jobID string) error {
-	_, err := db.ExecContext(ctx, `
+// Complete marks the job done. attempt is the job's Attempts as returned by ClaimNext,
+// which identifies the claim: every claim increments it, so a worker whose job was
+// reclaimed and claimed again gets ErrNotOwner instead of overwriting the new run.
+// Completing a job that is already done under the same claim succeeds, so repeating
+// the call is harmless.
+func Complete(ctx context.Context, db *sql.DB, jobID string, attempt int) error {
+	var n int64
+	err := withRetry(ctx, "complete", func() error {
+		res, err := db.ExecContext(ctx, `
+			UPDATE transcode_queue
+			SET status = $1,
+			    progress_percent = 100,
+			    finished_at = COALESCE(finished_at, NOW()),
+			    updated_at = NOW()
+			WHERE id = $2 AND attempts = $3 AND status IN ($1, $4)
+		`, StatusDone, jobID, attempt, StatusRunning)
+		if err != nil {
+			return err
+		}
+		n, err = res.RowsAffected()
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("complete: %w", err)
+	}
+	if n == 0 {
+		return fmt.Errorf("complete: %w", ErrNotOwner)
+	}
+	return nil
+}
+
+// Fail records a failed attempt. While the job has attempts left it is re-queued
+// with exponential backoff (see AttemptPolicy); after that it moves to StatusDead.
+// It returns the status the job ended up in, or ErrNotOwner if the job is no longer
+// running under the claim identified by attempt (see Complete).
+func Fail(ctx context.Context, db *sql.DB, jobID string, attempt int, message string) (Status, error) {
+	p := attemptPolicy
+	var status Status
+	err := withRetry(ctx, "fail", func() error {
+		return db.QueryRowContext(ctx, `
+			UPDATE transcode_queue
+			SET status = CASE WHEN attempts >= $3 THEN $1::queue_status ELSE $2::queue_status END,
+			    error = $4,
+			    next_attempt_at = NOW() + LEAST($5 * POWER(2, GREATEST(attempts - 1, 0)), $6) * INTERVAL '1 second',
+			    finished_at = CASE WHEN attempts >= $3 THEN NOW() END,
+			    updated_at = NOW()
+			WHERE id = $7 AND attempts = $8 AND status = $9
+			RETURNING status
+		`, StatusDead, StatusQueued, p.MaxAttempts, truncate(message, 2000),
+			p.BaseBackoff.Seconds(), p.MaxBackoff.Seconds(), jobID, attempt, StatusRunning).Scan(&status)
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", fmt.Errorf("fail: %w", ErrNotOwner)
+	}
+	if err != nil {
+		return "", fmt.Errorf("fail: %w", err)
+	}
+	return status, nil
+}
+
+// FailPermanently records a failed attempt that retrying can't fix, such as a corrupt
+// source: the job moves to StatusDead whatever attempts it has left. Like Fail, it
+// returns ErrNotOwner if the job is no longer running under the claim identified by
+// attempt.
+func FailPermanently(ctx context.Context, db *sql.DB, jobID string, attempt int, message string) error {
+	var n int64
+	err := withRetry(ctx, "fail permanently", func() error {
+		res, err := db.ExecContext(ctx, `
+			UPDATE transcode_queue
+			SET status = $1,
+			    error = $2,
+			    finished_at = NOW(),
+			    updated_at = NOW()
+			WHERE id = $3 AND attempts = $4 AND status = $5
+		`, StatusDead, truncate(message, 2000), jobID, attempt, StatusRunning)
+		if err != nil {
+			return err
+		}
+		n, err = res.RowsAffected()
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("fail permanently: %w", err)
+	}
+	if n == 0 {
+		return fmt.Errorf("fail permanently: %w", ErrNotOwner)
+	}
+	return nil
+}
+
+// AttachLog stores the log captured while the job ran, typically after Fail. It
+// replaces the log of any earlier attempt.
+func AttachLog(ctx context.Context, db *sql.DB, jobID string, jobLog string) error {
+	err := withRetry(ctx, "attach log", func() error {
+		_, err := db.ExecContext(ctx, `
+			UPDATE transcode_queue
+			SET log = $1,
+			    updated_at = NOW()
+			WHERE id = $2
+		`, jobLog, jobID)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("attach log: %w", err)
+	}
+	return nil
+}
+
+// UpdateProgress records the overall progress of a running job. The stored value
+// only ever increases and stays below 100; Complete sets it to 100. It returns
+// ErrNotOwner if the job is no longer running under the claim identified by attempt
+// (see Complete).
+func UpdateProgress(ctx context.Context, db *sql.DB, jobID string, attempt int, percent int) error {
+	percent = min(max(percent, 0), 99)
+	return runningUpdate(ctx, db, "update progress", `
 		UPDATE transcode_queue
-		SET status = $1,
-		    finished_at = NOW(),
+		SET progress_percent = GREATEST(progress_percent, $3),
 		    updated_at = NOW()
-		WHERE id = $2
-	`, StatusDone, jobID)
+		WHERE id = $1 AND attempts = $2 AND status = $4
+	`, jobID, attempt, percent, StatusRunning)
+}
+
+// Heartbeat records that the worker running jobID is still alive. It returns
+// ErrNotOwner once the job is no longer running under the claim identified by
+// attempt, so the worker can stop instead of keeping a new run's heartbeat fresh.
+func Heartbeat(ctx context.Context, db *sql.DB, jobID string, attempt int) error {
+	return runningUpdate(ctx, db, "heartbeat", `
+		UPDATE transcode_queue
+		SET heartbeat_at = NOW()
+		WHERE id = $1 AND attempts = $2 AND status = $3
+	`, jobID, attempt, StatusRunning)
+}
+
+// runningUpdate runs query, an UPDATE of the job's row whose first two parameters are
+// the job ID and the attempt of the caller's claim, and returns ErrNotOwner when it
+// matched no row.
+func runningUpdate(ctx context.Context, db *sql.DB, op string, query string, jobID string, attempt int, args ...any) error {
+	var n int64
+	err := withRetry(ctx, op, func() error {
+		res, err := db.ExecContext(ctx, query, append([]any{jobID, attempt}, args...)...)
+		if err != nil {
+			return err
+		}
+		n, err = res.RowsAffected()
+		return err
+	})
 	if err != nil {
-		return fmt.Errorf("complete: %w", err)
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if n == 0 {
+		return fmt.Errorf("%s: %w", op, ErrNotOwner)
 	}
 	return nil
 }
 
-func Fail(ctx context.Context, db *sql.DB, jobID string, message string) error {
-	_, err := db.ExecContext(ctx, `
+// ReclaimStale puts running jobs whose heartbeat is older than olderThan back in the
+// queue, on the assumption that their worker died. Jobs that have used all their
+// attempts are marked dead instead, and jobs with a pending cancel request are
+// cancelled. It returns the number of jobs reclaimed.
+func ReclaimStale(ctx context.Context, db *sql.DB, olderThan time.Duration) (int64, error) {
+	p := attemptPolicy
+	var n int64
+	err := withRetry(ctx, "reclaim stale", func() error {
+		res, err := db.ExecContext(ctx, `
+			UPDATE transcode_queue
+			SET status = CASE
+			        WHEN cancel_requested THEN $6::queue_status
+			        WHEN attempts >= $3 THEN $4::queue_status
+			        ELSE $5::queue_status
+			    END,
+			    error = 'worker stopped sending heartbeats',
+			    next_attempt_at = NOW(),
+			    finished_at = CASE WHEN cancel_requested OR attempts >= $3 THEN NOW() END,
+			    updated_at = NOW()
+			WHERE status = $1
+			  AND COALESCE(heartbeat_at, started_at, updated_at) < NOW() - $2 * INTERVAL '1 second'
+		`, StatusRunning, olderThan.Seconds(), p.MaxAttempts, StatusDead, StatusQueued, StatusCancelled)
+		if err != nil {
+			return err
+		}
+		n, err = res.RowsAffected()
+		return err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("reclaim stale: %w", err)
+	}
+	return n, nil
+}
+
+// Cancel requests cancellation of a job. Queued jobs are cancelled immediately;
+// running jobs are flagged and stopped by their worker (see CancelRequested).
+func Cancel(ctx context.Context, db *sql.DB, jobID string) error {
+	err := withRetry(ctx, "cancel", func() error {
+		_, err := db.ExecContext(ctx, `
+			UPDATE transcode_queue
+			SET cancel_requested = TRUE,
+			    status = CASE WHEN status = $1 THEN $3::queue_status ELSE status END,
+			    finished_at = CASE WHEN status = $1 THEN NOW() ELSE finished_at END,
+			    updated_at = NOW()
+			WHERE id = $4 AND status IN ($1, $2)
+		`, StatusQueued, StatusRunning, StatusCancelled, jobID)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("cancel: %w", err)
+	}
+	return nil
+}
+
+// CancelRequested reports whether the job has been asked to stop. A job whose row
+// no longer exists (e.g. its video was deleted) is treated as cancelled.
+func CancelRequested(ctx context.Context, db *sql.DB, jobID string) (bool, error) {
+	var requested bool
+	err := db.QueryRowContext(ctx, `
+		SELECT cancel_requested FROM transcode_queue WHERE id = $1
+	`, jobID).Scan(&requested)
+	if err == sql.ErrNoRows {
+		return true, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("cancel requested: %w", err)
+	}
+	return requested, nil
+}
+
+// MarkCancelled moves a job whose worker stopped on a cancel request to StatusCancelled.
+// It returns ErrNotOwner if the job is no longer running under the claim identified by
+// attempt (see Complete), leaving the new run alone.
+func MarkCancelled(ctx context.Context, db *sql.DB, jobID string, attempt int) error {
+	return runningUpdate(ctx, db, "mark cancelled", `
 		UPDATE transcode_queue
-		SET status = $1,
-		    error = $2,
+		SET status = $3,
 		    finished_at = NOW(),
 		    updated_at = NOW()
-		WHERE id = $3
-	`, StatusFailed, truncate(message, 2000), jobID)
+		WHERE id = $1 AND attempts = $2 AND status = $4
+	`, jobID, attempt, StatusCancelled, StatusRunning)
+}
+
+// Requeue hands a running job back to the queue without counting the attempt, for a
+// worker that stops mid-job (e.g. on shutdown) so another worker can claim it right
+// away. Jobs with a pending cancel request are cancelled instead. It returns the
+// status the job ended up in, or ErrNotOwner as for Fail.
+func Requeue(ctx context.Context, db *sql.DB, jobID string, attempt int) (Status, error) {
+	var status Status
+	err := withRetry(ctx, "requeue", func() error {
+		return db.QueryRowContext(ctx, `
+			UPDATE transcode_queue
+			SET status = CASE WHEN cancel_requested THEN $2::queue_status ELSE $3::queue_status END,
+			    attempts = GREATEST(attempts - 1, 0),
+			    next_attempt_at = NOW(),
+			    progress_percent = 0,
+			    finished_at = CASE WHEN cancel_requested THEN NOW() END,
+			    updated_at = NOW()
+			WHERE id = $4 AND attempts = $5 AND status = $1
+			RETURNING status
+		`, StatusRunning, StatusCancelled, StatusQueued, jobID, attempt).Scan(&status)
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", fmt.Errorf("requeue: %w", ErrNotOwner)
+	}
 	if err != nil {
-		return fmt.Errorf("fail: %w", err)
+		return "", fmt.Errorf("requeue: %w", err)
 	}
-	return nil
+	return status, nil
 }
 
-// Enqueue inserts a new job in queued state.
-func Enqueue(ctx context.Context, db *sql.DB, id string, videoID string, inputKey string, outputPrefix string) error {
-	_, err := db.ExecContext(ctx, `
-		INSERT INTO transcode_queue (id, video_id, input_key, output_prefix, status, attempts, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, 0, $6, $6)
-	`, id, videoID, inputKey, outputPrefix, StatusQueued, time.Now())
+// Enqueue inserts a new job in queued state. Jobs with a higher priority are claimed
+// ahead of older, lower-priority jobs; 0 is the default.
+func Enqueue(ctx context.Context, db *sql.DB, id string, videoID string, inputKey string, outputPrefix string, priority int) error {
+	err := withRetry(ctx, "enqueue", func() error {
+		_, err := db.ExecContext(ctx, `
+			INSERT INTO transcode_queue (id, video_id, input_key, output_prefix, status, attempts, priority, created_at, updated_at)
+			VALUES ($1, $2, $3, $4, $5, 0, $6, $7, $7)
+		`, id, videoID, inputKey, outputPrefix, StatusQueued, priority, time.Now())
+		return err
+	})
 	if err != nil {
 		return fmt.Errorf("enqueue: %w", err)
 	}
 	return nil
 }
 
+// NotifyChannel is the Postgres NOTIFY channel signalled when EnqueueBatch adds jobs.
+const NotifyChannel = "transcode_queue"
+
+// enqueueBatchChunk bounds the rows per INSERT so the statement stays well under
+// Postgres' 65535 bind parameter limit.
+const enqueueBatchChunk = 1000
+
+// EnqueueBatch inserts jobs in queued state in a single transaction, so either all
+// new jobs are queued or none are. Jobs whose id already exists are skipped, which
+// makes re-importing the same batch a no-op. One NOTIFY is sent on NotifyChannel
+// after the inserts. It returns the number of jobs actually inserted.
+func EnqueueBatch(ctx context.Context, db *sql.DB, jobs []TranscodeJob) (int64, error) {
+	if len(jobs) == 0 {
+		return 0, nil
+	}
+	var inserted int64
+	err := withRetry(ctx, "enqueue batch", func() error {
+		var err error
+		inserted, err = enqueueBatch(ctx, db, jobs)
+		return err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("enqueue batch: %w", err)
+	}
+	return inserted, nil
+}
+
+func enqueueBatch(ctx context.Context, db *sql.DB, jobs []TranscodeJob) (int64, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	const cols = 11
+	now := time.Now()
+	var inserted int64
+	for start := 0; start < len(jobs); start += enqueueBatchChunk {
+		chunk := jobs[start:min(start+enqueueBatchChunk, len(jobs))]
+		var values strings.Builder
+		args := make([]any, 0, len(chunk)*cols+2)
+		args = append(args, StatusQueued, now)
+		for i, j := range chunk {
+			if i > 0 {
+				values.WriteString(", ")
+			}
+			n := len(args)
+			fmt.Fprintf(&values, "($%d, $%d, $%d, $%d, $1, 0, $%d, NULLIF($%d, ''), NULLIF($%d::real, 0), NULLIF($%d::real, 0), NULLIF($%d::text[], '{}'), NULLIF($%d, ''), NULLIF($%d, ''), $2, $2, $2)",
+				n+1, n+2, n+3, n+4, n+5, n+6, n+7, n+8, n+9, n+10, n+11)
+			args = append(args, j.ID, j.VideoID, j.InputKey, j.OutputPrefix, j.Priority, j.WorkerClass, j.InPoint, j.OutPoint, pq.Array(j.InputKeys), j.Title, j.Language)
+		}
+		res, err := tx.ExecContext(ctx, `
+			INSERT INTO transcode_queue (id, video_id, input_key, output_prefix, status, attempts, priority, worker_class, in_point, out_point, input_keys, title, language, next_attempt_at, created_at, updated_at)
+			VALUES `+values.String()+`
+			ON CONFLICT (id) DO NOTHING
+		`, args...)
+		if err != nil {
+			return 0, fmt.Errorf("insert jobs %d-%d: %w", start, start+len(chunk)-1, err)
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return 0, fmt.Errorf("rows affected: %w", err)
+		}
+		inserted += n
+	}
+
+	if inserted > 0 {
+		if _, err := tx.ExecContext(ctx, `SELECT pg_notify($1, $2)`, NotifyChannel, strconv.FormatInt(inserted, 10)); err != nil {
+			return 0, fmt.Errorf("notify: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("commit: %w", err)
+	}
+	return inserted, nil
+}
+
 func truncate(s string, n int) string {
 	if n <= 0 || len(s) <= n {
 		return s
@@ -125,69 +488,96 @@ const (
 	ProcessingStatusFailed     ProcessingStatus = "failed"
 )
 
-// UpdateHLSStatus updates the HLS transcoding status
-func UpdateHLSStatus(ctx context.Context, db *sql.DB, jobID string, status ProcessingStatus) error {
-	_, err := db.ExecContext(ctx, `
+// UpdateHLSStatus updates the HLS transcoding status. Like the other task status
+// updates, it returns ErrNotOwner if the job is no longer running under the claim
+// identified by attempt (see Complete).
+func UpdateHLSStatus(ctx context.Context, db *sql.DB, jobID string, attempt int, status ProcessingStatus) error {
+	return runningUpdate(ctx, db, "update hls status", `
 		UPDATE transcode_queue
-		SET hls_status = $1,
+		SET hls_status = $3,
 		    updated_at = NOW()
-		WHERE id = $2
-	`, status, jobID)
-	if err != nil {
-		return fmt.Errorf("update hls status: %w", err)
-	}
-	return nil
+		WHERE id = $1 AND attempts = $2 AND status = $4
+	`, jobID, attempt, status, StatusRunning)
 }
 
 // UpdatePosterStatus updates the poster generation status
-func UpdatePosterStatus(ctx context.Context, db *sql.DB, jobID string, status ProcessingStatus) error {
-	_, err := db.ExecContext(ctx, `
+func UpdatePosterStatus(ctx context.Context, db *sql.DB, jobID string, attempt int, status ProcessingStatus) error {
+	return runningUpdate(ctx, db, "update poster status", `
 		UPDATE transcode_queue
-		SET poster_status = $1,
+		SET poster_status = $3,
 		    updated_at = NOW()
-		WHERE id = $2
-	`, status, jobID)
-	if err != nil {
-		return fmt.Errorf("update poster status: %w", err)
-	}
-	return nil
+		WHERE id = $1 AND attempts = $2 AND status = $4
+	`, jobID, attempt, status, StatusRunning)
 }
 
 // UpdateScrubberPreviewStatus updates the scrubber preview (thumbnails/VTT) generation status
-func UpdateScrubberPreviewStatus(ctx context.Context, db *sql.DB, jobID string, status ProcessingStatus) error {
-	_, err := db.ExecContext(ctx, `
+func UpdateScrubberPreviewStatus(ctx context.Context, db *sql.DB, jobID string, attempt int, status ProcessingStatus) error {
+	return runningUpdate(ctx, db, "update scrubber preview status", `
 		UPDATE transcode_queue
-		SET scrubber_preview_status = $1,
+		SET scrubber_preview_status = $3,
 		    updated_at = NOW()
-		WHERE id = $2
-	`, status, jobID)
-	if err != nil {
-		return fmt.Errorf("update scrubber preview status: %w", err)
-	}
-	return nil
+		WHERE id = $1 AND attempts = $2 AND status = $4
+	`, jobID, attempt, status, StatusRunning)
 }
 
 // UpdateHoverPreviewStatus updates the hover preview generation status
-func UpdateHoverPreviewStatus(ctx context.Context, db *sql.DB, jobID string, status ProcessingStatus) error {
-	_, err := db.ExecContext(ctx, `
+func UpdateHoverPreviewStatus(ctx context.Context, db *sql.DB, jobID string, attempt int, status ProcessingStatus) error {
+	return runningUpdate(ctx, db, "update hover preview status", `
 		UPDATE transcode_queue
-		SET hover_preview_status = $1,
+		SET hover_preview_status = $3,
 		    updated_at = NOW()
-		WHERE id = $2
-	`, status, jobID)
+		WHERE id = $1 AND attempts = $2 AND status = $4
+	`, jobID, attempt, status, StatusRunning)
+}
+
+// TaskStatuses holds the per-task processing statuses of a job.
+type TaskStatuses struct {
+	HLS             ProcessingStatus
+	Poster          ProcessingStatus
+	ScrubberPreview ProcessingStatus
+	HoverPreview    ProcessingStatus
+}
+
+// StartAttempt records the fingerprint of the source an attempt works from and returns
+// the task statuses left by earlier attempts. When the fingerprint differs from the
+// recorded one (or none was recorded), the source changed since those tasks ran, so
+// their statuses are reset to pending first and every task runs again. It returns
+// ErrNotOwner if the job is no longer running under the claim identified by attempt.
+func StartAttempt(ctx context.Context, db *sql.DB, jobID string, attempt int, fingerprint string) (TaskStatuses, error) {
+	var ts TaskStatuses
+	err := withRetry(ctx, "start attempt", func() error {
+		// The CASEs see the row as it was before the update, so they compare against
+		// the previous attempt's fingerprint
+		return db.QueryRowContext(ctx, `
+			UPDATE transcode_queue
+			SET hls_status = CASE WHEN source_fingerprint IS DISTINCT FROM $1 THEN $2 ELSE hls_status END,
+			    poster_status = CASE WHEN source_fingerprint IS DISTINCT FROM $1 THEN $2 ELSE poster_status END,
+			    scrubber_preview_status = CASE WHEN source_fingerprint IS DISTINCT FROM $1 THEN $2 ELSE scrubber_preview_status END,
+			    hover_preview_status = CASE WHEN source_fingerprint IS DISTINCT FROM $1 THEN $2 ELSE hover_preview_status END,
+			    source_fingerprint = $1,
+			    updated_at = NOW()
+			WHERE id = $3 AND attempts = $4 AND status = $5
+			RETURNING hls_status, poster_status, scrubber_preview_status, hover_preview_status
+		`, fingerprint, ProcessingStatusPending, jobID, attempt, StatusRunning).Scan(&ts.HLS, &ts.Poster, &ts.ScrubberPreview, &ts.HoverPreview)
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return ts, fmt.Errorf("start attempt: %w", ErrNotOwner)
+	}
 	if err != nil {
-		return fmt.Errorf("update hover preview status: %w", err)
+		return ts, fmt.Errorf("start attempt: %w", err)
 	}
-	return nil
+	return ts, nil
 }
 
 // QueueStats represents statistics about the transcode queue
 type QueueStats struct {
 	Queued          int
+	Ready           int           // queued jobs not waiting out a retry backoff
+	OldestQueuedAge time.Duration // since the oldest queued job was created; 0 when none are queued
 	Running         int
 	RunningJobs     []RunningJobInfo
 	RecentCompleted int // Completed in last 5 minutes
-	RecentFailed    int // Failed in last 5 minutes
+	RecentFailed    int // Dead, failed or re-queued after an error in last 5 minutes
 }
 
 // RunningJobInfo contains information about a running job
@@ -195,6 +585,7 @@ type RunningJobInfo struct {
 	ID                    string
 	VideoID               string
 	StartedAt             time.Time
+	ProgressPercent       int
 	HLSStatus             ProcessingStatus
 	PosterStatus          ProcessingStatus
 	ScrubberPreviewStatus ProcessingStatus
@@ -205,25 +596,27 @@ type RunningJobInfo struct {
 func GetQueueStats(ctx context.Context, db *sql.DB) (*QueueStats, error) {
 	stats := &QueueStats{}
 
-	// Count queued jobs
+	// Count queued and running jobs in one pass over the status index; the age is
+	// computed by the database so worker clock skew doesn't affect it
+	var oldestSec sql.NullFloat64
 	err := db.QueryRowContext(ctx, `
-		SELECT COUNT(*) FROM transcode_queue WHERE status = $1
-	`, StatusQueued).Scan(&stats.Queued)
+		SELECT COUNT(*) FILTER (WHERE status = $1),
+		       COUNT(*) FILTER (WHERE status = $1 AND next_attempt_at <= NOW()),
+		       EXTRACT(EPOCH FROM NOW() - MIN(created_at) FILTER (WHERE status = $1)),
+		       COUNT(*) FILTER (WHERE status = $2)
+		FROM transcode_queue
+		WHERE status IN ($1, $2)
+	`, StatusQueued, StatusRunning).Scan(&stats.Queued, &stats.Ready, &oldestSec, &stats.Running)
 	if err != nil {
-		return nil, fmt.Errorf("count queued: %w", err)
+		return nil, fmt.Errorf("count queued and running: %w", err)
 	}
-
-	// Count running jobs
-	err = db.QueryRowContext(ctx, `
-		SELECT COUNT(*) FROM transcode_queue WHERE status = $1
-	`, StatusRunning).Scan(&stats.Running)
-	if err != nil {
-		return nil, fmt.Errorf("count running: %w", err)
+	if oldestSec.Valid && oldestSec.Float64 > 0 {
+		stats.OldestQueuedAge = time.Duration(oldestSec.Float64 * float64(time.Second))
 	}
 
 	// Get details of running jobs
 	rows, err := db.QueryContext(ctx, `
-		SELECT id, video_id, started_at, 
+		SELECT id, video_id, started_at, progress_percent,
 		       hls_status, poster_status, scrubber_preview_status, hover_preview_status
 		FROM transcode_queue
 		WHERE status = $1
@@ -236,7 +629,7 @@ func GetQueueStats(ctx context.Context, db *sql.DB) (*QueueStats, error) {
 
 	for rows.Next() {
 		var job RunningJobInfo
-		err := rows.Scan(&job.ID, &job.VideoID, &job.StartedAt,
+		err := rows.Scan(&job.ID, &job.VideoID, &job.StartedAt, &job.ProgressPercent,
 			&job.HLSStatus, &job.PosterStatus, &job.ScrubberPreviewStatus, &job.HoverPreviewStatus)
 		if err != nil {
 			return nil, fmt.Errorf("scan running job: %w", err)
@@ -253,11 +646,13 @@ func GetQueueStats(ctx context.Context, db *sql.DB) (*QueueStats, error) {
 		return nil, fmt.Errorf("count recent completed: %w", err)
 	}
 
-	// Count recently failed jobs (last 5 minutes)
+	// Count recently failed attempts (last 5 minutes): jobs that gave up, plus jobs Fail
+	// or ReclaimStale put back in the queue with an error for another attempt
 	err = db.QueryRowContext(ctx, `
-		SELECT COUNT(*) FROM transcode_queue 
-		WHERE status = $1 AND finished_at > NOW() - INTERVAL '5 minutes'
-	`, StatusFailed).Scan(&stats.RecentFailed)
+		SELECT COUNT(*) FROM transcode_queue
+		WHERE (status IN ($1, $2) AND finished_at > NOW() - INTERVAL '5 minutes')
+		   OR (status = $3 AND error IS NOT NULL AND updated_at > NOW() - INTERVAL '5 minutes')
+	`, StatusDead, StatusFailed, StatusQueued).Scan(&stats.RecentFailed)
 	if err != nil {
 		return nil, fmt.Errorf("count recent failed: %w", err)
 	}
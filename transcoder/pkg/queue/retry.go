@@ -0,0 +1,114 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/lib/pq"
+)
+
+// Postgres SQLSTATEs that indicate the transaction lost a race and is safe to retry.
+const (
+	sqlStateSerializationFailure = "40001"
+	sqlStateDeadlockDetected     = "40P01"
+)
+
+// RetryPolicy controls how write paths retry on serialization failures and deadlocks.
+type RetryPolicy struct {
+	MaxAttempts int           // total attempts including the first; <= 1 disables retries
+	BaseDelay   time.Duration // backoff before the second attempt, doubled after each retry
+}
+
+var retryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	BaseDelay:   50 * time.Millisecond,
+}
+
+// SetRetryPolicy replaces the retry policy used by the queue write paths.
+// It should be called once at startup, before any jobs are processed.
+func SetRetryPolicy(p RetryPolicy) {
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = 1
+	}
+	if p.BaseDelay < 0 {
+		p.BaseDelay = 0
+	}
+	retryPolicy = p
+}
+
+// IsRetryable reports whether err is a Postgres serialization failure or deadlock.
+func IsRetryable(err error) bool {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return false
+	}
+	switch string(pqErr.Code) {
+	case sqlStateSerializationFailure, sqlStateDeadlockDetected:
+		return true
+	}
+	return false
+}
+
+// withRetry runs fn, retrying with exponential backoff and jitter while it fails
+// with a retryable Postgres error. fn must be safe to re-run from scratch
+// (e.g. it opens its own transaction).
+func withRetry(ctx context.Context, op string, fn func() error) error {
+	p := retryPolicy
+	delay := p.BaseDelay
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = fn()
+		if err == nil || !IsRetryable(err) || attempt >= p.MaxAttempts {
+			return err
+		}
+
+		wait := delay
+		if delay > 0 {
+			wait += rand.N(delay/2 + 1)
+		}
+		log.Warn("retrying queue operation after transient db error",
+			"op", op,
+			"attempt", attempt,
+			"max_attempts", p.MaxAttempts,
+			"backoff", wait,
+			"error", err,
+		)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(wait):
+		}
+		delay *= 2
+	}
+}
+
+// AttemptPolicy controls how often a failing job is retried before it is marked dead.
+type AttemptPolicy struct {
+	MaxAttempts int           // claims allowed before a failure becomes terminal
+	BaseBackoff time.Duration // delay before the second attempt, doubled after each failure
+	MaxBackoff  time.Duration // upper bound for the delay between attempts
+}
+
+var attemptPolicy = AttemptPolicy{
+	MaxAttempts: 3,
+	BaseBackoff: 30 * time.Second,
+	MaxBackoff:  30 * time.Minute,
+}
+
+// SetAttemptPolicy replaces the job attempt policy used by Fail.
+// It should be called once at startup, before any jobs are processed.
+func SetAttemptPolicy(p AttemptPolicy) {
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = 1
+	}
+	if p.BaseBackoff < 0 {
+		p.BaseBackoff = 0
+	}
+	if p.MaxBackoff < p.BaseBackoff {
+		p.MaxBackoff = p.BaseBackoff
+	}
+	attemptPolicy = p
+}
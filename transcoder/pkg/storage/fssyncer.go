@@ -0,0 +1,290 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/charmbracelet/log"
+)
+
+// FSSyncer stores objects on the local filesystem at <base>/<bucket>/<key>, for
+// running the pipeline without any cloud dependencies. Keys are joined exactly
+// as for S3, so a synced directory keeps its relative layout.
+type FSSyncer struct {
+	base string
+}
+
+func NewFSSyncer(base string) (*FSSyncer, error) {
+	abs, err := filepath.Abs(base)
+	if err != nil {
+		return nil, fmt.Errorf("resolve base path %s: %w", base, err)
+	}
+	if err := os.MkdirAll(abs, 0755); err != nil {
+		return nil, fmt.Errorf("create base path %s: %w", abs, err)
+	}
+	return &FSSyncer{base: abs}, nil
+}
+
+// objectPath maps bucket/key to a path under the base directory, rejecting keys
+// that would escape it.
+func (f *FSSyncer) objectPath(bucket string, key string) (string, error) {
+	rel := filepath.FromSlash(strings.TrimPrefix(bucket+"/"+key, "/"))
+	if !filepath.IsLocal(rel) {
+		return "", fmt.Errorf("invalid object key %q in bucket %q", key, bucket)
+	}
+	return filepath.Join(f.base, rel), nil
+}
+
+func (f *FSSyncer) SyncDirectory(ctx context.Context, localDir string, bucket string, prefix string) error {
+	return f.SyncDirectoryWithOptions(ctx, localDir, bucket, prefix, SyncOptions{})
+}
+
+func (f *FSSyncer) SyncDirectoryWithOptions(ctx context.Context, localDir string, bucket string, prefix string, opts SyncOptions) error {
+	tasks, err := collectFiles(localDir, prefix, opts.Exclude)
+	if err != nil {
+		return err
+	}
+	if len(tasks) == 0 && !opts.Delete {
+		return nil
+	}
+
+	log.Info("syncing directory", "files", len(tasks), "bucket", bucket, "prefix", prefix, "backend", "fs")
+
+	uploaded, skipped := 0, 0
+	for _, t := range tasks {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		var skip bool
+		if opts.Compare {
+			skip, err = f.objectMatches(bucket, t.key, t.localPath)
+		} else {
+			skip, err = f.FileExists(ctx, bucket, t.key)
+		}
+		if err != nil {
+			return fmt.Errorf("check exists %s: %w", t.key, err)
+		}
+		if skip {
+			skipped++
+			continue
+		}
+		if err := f.UploadFile(ctx, t.localPath, bucket, t.key); err != nil {
+			return err
+		}
+		uploaded++
+	}
+
+	log.Info("sync complete", "uploaded", uploaded, "skipped", skipped, "total", len(tasks))
+
+	if opts.Delete {
+		if strings.Trim(prefix, "/") == "" {
+			// Never mirror-delete across a whole bucket
+			return errors.New("refusing to delete stale objects without a prefix")
+		}
+		keep := make(map[string]struct{}, len(tasks))
+		for _, t := range tasks {
+			keep[t.key] = struct{}{}
+		}
+		keys, err := f.listKeys(bucket, prefix)
+		if err != nil {
+			return err
+		}
+		for _, key := range keys {
+			if _, ok := keep[key]; ok {
+				continue
+			}
+			if err := f.deleteKey(bucket, key); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// UploadFile copies localPath to <base>/<bucket>/<key>. The copy is written to a
+// temporary file and renamed into place so readers never see a partial object.
+func (f *FSSyncer) UploadFile(ctx context.Context, localPath string, bucket string, key string) error {
+	dst, err := f.objectPath(bucket, key)
+	if err != nil {
+		return err
+	}
+	if err := copyFileAtomic(localPath, dst); err != nil {
+		return fmt.Errorf("upload %s to %s/%s: %w", localPath, bucket, key, err)
+	}
+	return nil
+}
+
+// DownloadFile copies <base>/<bucket>/<key> to localPath.
+func (f *FSSyncer) DownloadFile(ctx context.Context, bucket string, key string, localPath string) error {
+	src, err := f.objectPath(bucket, key)
+	if err != nil {
+		return err
+	}
+	if err := copyFileAtomic(src, localPath); err != nil {
+		return fmt.Errorf("download %s/%s: %w", bucket, key, err)
+	}
+	return nil
+}
+
+// FileExists reports whether a regular file is stored at bucket/key. A directory at
+// that path (i.e. a key that is only a prefix of other keys) does not count.
+func (f *FSSyncer) FileExists(ctx context.Context, bucket string, key string) (bool, error) {
+	p, err := f.objectPath(bucket, key)
+	if err != nil {
+		return false, err
+	}
+	info, err := os.Stat(p)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("stat %s/%s: %w", bucket, key, err)
+	}
+	return info.Mode().IsRegular(), nil
+}
+
+// ObjectSize returns the size in bytes of the object at bucket/key.
+func (f *FSSyncer) ObjectSize(ctx context.Context, bucket string, key string) (int64, error) {
+	p, err := f.objectPath(bucket, key)
+	if err != nil {
+		return 0, err
+	}
+	info, err := os.Stat(p)
+	if err != nil {
+		return 0, fmt.Errorf("stat %s/%s: %w", bucket, key, err)
+	}
+	return info.Size(), nil
+}
+
+// DeletePrefix deletes every object under prefix and returns how many were removed.
+func (f *FSSyncer) DeletePrefix(ctx context.Context, bucket string, prefix string) (int, error) {
+	keys, err := f.listKeys(bucket, prefix)
+	if err != nil {
+		return 0, err
+	}
+	for _, key := range keys {
+		if err := f.deleteKey(bucket, key); err != nil {
+			return 0, err
+		}
+	}
+	return len(keys), nil
+}
+
+// objectMatches reports whether key exists with the same content as localPath.
+func (f *FSSyncer) objectMatches(bucket string, key string, localPath string) (bool, error) {
+	p, err := f.objectPath(bucket, key)
+	if err != nil {
+		return false, err
+	}
+	remote, err := os.Stat(p)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	local, err := os.Stat(localPath)
+	if err != nil {
+		return false, err
+	}
+	if remote.Size() != local.Size() {
+		return false, nil
+	}
+	a, err := fileMD5(p)
+	if err != nil {
+		return false, err
+	}
+	b, err := fileMD5(localPath)
+	if err != nil {
+		return false, err
+	}
+	return a == b, nil
+}
+
+// listKeys returns every object key under prefix.
+func (f *FSSyncer) listKeys(bucket string, prefix string) ([]string, error) {
+	prefix = strings.Trim(prefix, "/")
+	dir, err := f.objectPath(bucket, prefix)
+	if err != nil {
+		return nil, err
+	}
+	var keys []string
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, joinKey(prefix, rel))
+		return nil
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("list %s/%s: %w", bucket, prefix, err)
+	}
+	return keys, nil
+}
+
+// deleteKey removes an object and any directories left empty under the bucket.
+func (f *FSSyncer) deleteKey(bucket string, key string) error {
+	p, err := f.objectPath(bucket, key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("delete %s/%s: %w", bucket, key, err)
+	}
+	root := filepath.Join(f.base, bucket)
+	for dir := filepath.Dir(p); dir != root && strings.HasPrefix(dir, root); dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			break // not empty
+		}
+	}
+	return nil
+}
+
+// copyFileAtomic copies src to dst via a temporary file in dst's directory.
+func copyFileAtomic(src string, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(dst), "."+filepath.Base(dst)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	// CreateTemp uses 0600; objects should be readable like any other output
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if _, err := io.Copy(tmp, in); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), dst)
+}
@@ -0,0 +1,207 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// writeFiles writes each rel path under dir with the given content.
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for rel, content := range files {
+		p := filepath.Join(dir, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// readObject returns the content stored at bucket/key, failing t if it is missing.
+func readObject(t *testing.T, f *FSSyncer, bucket, key string) string {
+	t.Helper()
+	p, err := f.objectPath(bucket, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func newTestFSSyncer(t *testing.T) *FSSyncer {
+	t.Helper()
+	f, err := NewFSSyncer(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func TestFSSyncer_SyncDirectory(t *testing.T) {
+	ctx := context.Background()
+	f := newTestFSSyncer(t)
+	local := t.TempDir()
+	writeFiles(t, local, map[string]string{
+		"master.m3u8":      "master",
+		"720p/index.m3u8":  "v1",
+		"720p/seg_001.ts":  "segment",
+		"poster.jpg":       "poster",
+		"720p/excluded.ts": "excluded",
+	})
+
+	opts := SyncOptions{Exclude: func(rel string) bool { return filepath.Base(rel) == "excluded.ts" }}
+	if err := f.SyncDirectoryWithOptions(ctx, local, "bucket", "videos/abc", opts); err != nil {
+		t.Fatal(err)
+	}
+	keys, err := f.listKeys("bucket", "videos/abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(keys)
+	want := []string{"videos/abc/720p/index.m3u8", "videos/abc/720p/seg_001.ts", "videos/abc/master.m3u8", "videos/abc/poster.jpg"}
+	if !slices.Equal(keys, want) {
+		t.Fatalf("keys = %v, want %v", keys, want)
+	}
+
+	// Without Compare an existing object is left alone even if the file changed
+	writeFiles(t, local, map[string]string{"720p/index.m3u8": "v2"})
+	if err := f.SyncDirectory(ctx, local, "bucket", "videos/abc"); err != nil {
+		t.Fatal(err)
+	}
+	if got := readObject(t, f, "bucket", "videos/abc/720p/index.m3u8"); got != "v1" {
+		t.Errorf("without Compare: object = %q, want %q", got, "v1")
+	}
+
+	// With Compare it is re-uploaded
+	if err := f.SyncDirectoryWithOptions(ctx, local, "bucket", "videos/abc", SyncOptions{Compare: true}); err != nil {
+		t.Fatal(err)
+	}
+	if got := readObject(t, f, "bucket", "videos/abc/720p/index.m3u8"); got != "v2" {
+		t.Errorf("with Compare: object = %q, want %q", got, "v2")
+	}
+}
+
+func TestFSSyncer_SyncDirectoryDelete(t *testing.T) {
+	ctx := context.Background()
+	f := newTestFSSyncer(t)
+	local := t.TempDir()
+	writeFiles(t, local, map[string]string{
+		"master.m3u8":      "master",
+		"1080p/index.m3u8": "1080p",
+		"1080p/seg_001.ts": "segment",
+	})
+	if err := f.SyncDirectory(ctx, local, "bucket", "videos/abc"); err != nil {
+		t.Fatal(err)
+	}
+	// An object of another video sharing the prefix's parent is never touched
+	other := t.TempDir()
+	writeFiles(t, other, map[string]string{"master.m3u8": "other"})
+	if err := f.SyncDirectory(ctx, other, "bucket", "videos/abcd"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The new output drops the 1080p rendition
+	if err := os.RemoveAll(filepath.Join(local, "1080p")); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.SyncDirectoryWithOptions(ctx, local, "bucket", "videos/abc", SyncOptions{Delete: true}); err != nil {
+		t.Fatal(err)
+	}
+	keys, err := f.listKeys("bucket", "videos")
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(keys)
+	want := []string{"videos/abc/master.m3u8", "videos/abcd/master.m3u8"}
+	if !slices.Equal(keys, want) {
+		t.Errorf("keys = %v, want %v", keys, want)
+	}
+	if _, err := os.Stat(filepath.Join(f.base, "bucket", "videos", "abc", "1080p")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("emptied rendition directory still present: %v", err)
+	}
+
+	if err := f.SyncDirectoryWithOptions(ctx, local, "bucket", "/", SyncOptions{Delete: true}); err == nil {
+		t.Error("Delete without a prefix succeeded, want an error")
+	}
+}
+
+func TestFSSyncer_ObjectPathRejectsEscapes(t *testing.T) {
+	f := newTestFSSyncer(t)
+	for _, key := range []string{"../../etc/passwd", "a/../../../x", "../../"} {
+		if p, err := f.objectPath("bucket", key); err == nil {
+			t.Errorf("objectPath(%q) = %s, want an error", key, p)
+		}
+	}
+	if _, err := f.objectPath("bucket", "videos/abc/master.m3u8"); err != nil {
+		t.Errorf("objectPath on a plain key: %v", err)
+	}
+	if err := f.UploadFile(context.Background(), "/dev/null", "bucket", "../../escaped"); err == nil {
+		t.Error("UploadFile with an escaping key succeeded, want an error")
+	}
+}
+
+func TestFSSyncer_FileExists(t *testing.T) {
+	ctx := context.Background()
+	f := newTestFSSyncer(t)
+	local := filepath.Join(t.TempDir(), "seg.ts")
+	writeFiles(t, filepath.Dir(local), map[string]string{"seg.ts": "segment"})
+	if err := f.UploadFile(ctx, local, "bucket", "videos/abc/seg.ts"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		key  string
+		want bool
+	}{
+		{"videos/abc/seg.ts", true},
+		{"videos/abc", false}, // a directory, only a prefix of other keys
+		{"videos/abc/missing.ts", false},
+	}
+	for _, tt := range tests {
+		got, err := f.FileExists(ctx, "bucket", tt.key)
+		if err != nil {
+			t.Fatalf("FileExists(%q): %v", tt.key, err)
+		}
+		if got != tt.want {
+			t.Errorf("FileExists(%q) = %v, want %v", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestFSSyncer_DeletePrefixPrunesEmptyDirs(t *testing.T) {
+	ctx := context.Background()
+	f := newTestFSSyncer(t)
+	local := t.TempDir()
+	writeFiles(t, local, map[string]string{
+		"master.m3u8":     "master",
+		"720p/index.m3u8": "720p",
+	})
+	if err := f.SyncDirectory(ctx, local, "bucket", "videos/abc"); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := f.DeletePrefix(ctx, "bucket", "videos/abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("deleted %d objects, want 2", n)
+	}
+	if _, err := os.Stat(filepath.Join(f.base, "bucket", "videos")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("empty directories left under the bucket: %v", err)
+	}
+	// The bucket directory itself stays
+	if _, err := os.Stat(filepath.Join(f.base, "bucket")); err != nil {
+		t.Errorf("bucket directory removed: %v", err)
+	}
+}
@@ -0,0 +1,306 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"cloud.google.com/go/storage"
+	"github.com/charmbracelet/log"
+	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
+)
+
+// GCSOptions configures the GCSSyncer.
+type GCSOptions struct {
+	// Optional service account key file. If empty, Application Default Credentials are used.
+	CredentialsFile string
+	Endpoint        string // e.g. a fake-gcs-server URL for local development
+	PredefinedACL   string // e.g., "publicRead"
+	CacheControl    string // used for extensions without a cache policy
+	// Cache-Control by file extension, merged over DefaultCachePolicy.
+	CachePolicy     map[string]string
+	ContentTypeFunc func(path string) string
+	ContentTypes    map[string]string
+	Attachments     []string // key suffixes served with Content-Disposition: attachment
+}
+
+// GCSSyncer is the Google Cloud Storage counterpart of S3Syncer. Keys, Content-Type,
+// Cache-Control and Content-Disposition are resolved exactly as for S3 so either backend serves the same tree.
+type GCSSyncer struct {
+	client *storage.Client
+	acl    string
+	objectMeta
+}
+
+func NewGCSSyncer(ctx context.Context, opts GCSOptions) (*GCSSyncer, error) {
+	var co []option.ClientOption
+	if opts.CredentialsFile != "" {
+		co = append(co, option.WithCredentialsFile(opts.CredentialsFile))
+	}
+	if opts.Endpoint != "" {
+		co = append(co, option.WithEndpoint(opts.Endpoint))
+	}
+	client, err := storage.NewClient(ctx, co...)
+	if err != nil {
+		return nil, fmt.Errorf("create gcs client: %w", err)
+	}
+	return &GCSSyncer{
+		client:     client,
+		acl:        opts.PredefinedACL,
+		objectMeta: newObjectMeta(opts.CacheControl, opts.CachePolicy, opts.ContentTypeFunc, opts.ContentTypes, opts.Attachments),
+	}, nil
+}
+
+func (g *GCSSyncer) SyncDirectory(ctx context.Context, localDir string, bucket string, prefix string) error {
+	return g.SyncDirectoryWithOptions(ctx, localDir, bucket, prefix, SyncOptions{})
+}
+
+func (g *GCSSyncer) SyncDirectoryWithOptions(ctx context.Context, localDir string, bucket string, prefix string, opts SyncOptions) error {
+	tasks, err := collectFiles(localDir, prefix, opts.Exclude)
+	if err != nil {
+		return err
+	}
+	if len(tasks) == 0 && !opts.Delete {
+		return nil
+	}
+
+	log.Info("syncing directory", "files", len(tasks), "bucket", bucket, "prefix", prefix, "backend", "gcs")
+
+	const maxConcurrency = 10
+	sem := make(chan struct{}, maxConcurrency)
+	errChan := make(chan error, len(tasks))
+	var wg sync.WaitGroup
+	var uploaded, skipped int
+	var mu sync.Mutex
+
+	for _, task := range tasks {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(t fileTask) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			var skip bool
+			var err error
+			if opts.Compare {
+				skip, err = g.objectMatches(ctx, bucket, t.key, t.localPath)
+			} else {
+				skip, err = g.FileExists(ctx, bucket, t.key)
+			}
+			if err != nil {
+				errChan <- fmt.Errorf("check exists %s: %w", t.key, err)
+				return
+			}
+			if skip {
+				mu.Lock()
+				skipped++
+				mu.Unlock()
+				return
+			}
+
+			log.Info("uploading file", "local_path", t.localPath, "bucket", bucket, "key", t.key)
+			if err := g.UploadFile(ctx, t.localPath, bucket, t.key); err != nil {
+				errChan <- err
+				return
+			}
+			mu.Lock()
+			uploaded++
+			mu.Unlock()
+		}(task)
+	}
+	wg.Wait()
+	close(errChan)
+
+	var errs []error
+	for err := range errChan {
+		errs = append(errs, err)
+		log.Error("sync error", "error", err)
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("sync failed with %d errors (first: %w)", len(errs), errs[0])
+	}
+
+	log.Info("sync complete", "uploaded", uploaded, "skipped", skipped, "total", len(tasks))
+
+	if opts.Delete {
+		if strings.Trim(prefix, "/") == "" {
+			// Never mirror-delete across a whole bucket
+			return errors.New("refusing to delete stale objects without a prefix")
+		}
+		keep := make(map[string]struct{}, len(tasks))
+		for _, t := range tasks {
+			keep[t.key] = struct{}{}
+		}
+		keys, err := g.listKeys(ctx, bucket, prefix)
+		if err != nil {
+			return err
+		}
+		var stale []string
+		for _, key := range keys {
+			if _, ok := keep[key]; !ok {
+				stale = append(stale, key)
+			}
+		}
+		if len(stale) > 0 {
+			log.Info("deleting stale objects", "count", len(stale), "bucket", bucket, "prefix", prefix)
+			if err := g.deleteKeys(ctx, bucket, stale); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// UploadFile uploads a single file with the same metadata SyncDirectory would set.
+func (g *GCSSyncer) UploadFile(ctx context.Context, localPath string, bucket string, key string) error {
+	f, err := os.Open(localPath)
+	if err != nil {
+		return fmt.Errorf("open %s: %w", localPath, err)
+	}
+	defer f.Close()
+
+	// Cancelling ctx aborts the upload; Close must still be called to release the writer
+	w := g.client.Bucket(bucket).Object(key).NewWriter(ctx)
+	w.ContentType = g.resolveContentType(localPath)
+	w.CacheControl = g.resolveCacheControl(localPath)
+	w.ContentDisposition = g.resolveContentDisposition(key)
+	w.PredefinedACL = g.acl
+	if _, err := io.Copy(w, f); err != nil {
+		w.Close()
+		return fmt.Errorf("upload %s to gs://%s/%s: %w", localPath, bucket, key, err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("upload %s to gs://%s/%s: %w", localPath, bucket, key, err)
+	}
+	return nil
+}
+
+// DownloadFile downloads gs://bucket/key to localPath, removing the partial file on failure.
+func (g *GCSSyncer) DownloadFile(ctx context.Context, bucket string, key string, localPath string) error {
+	if err := os.MkdirAll(filepath.Dir(localPath), 0755); err != nil {
+		return fmt.Errorf("create parent dir: %w", err)
+	}
+	r, err := g.client.Bucket(bucket).Object(key).NewReader(ctx)
+	if err != nil {
+		return fmt.Errorf("get object gs://%s/%s: %w", bucket, key, err)
+	}
+	defer r.Close()
+
+	f, err := os.Create(localPath)
+	if err != nil {
+		return fmt.Errorf("create local file %s: %w", localPath, err)
+	}
+	_, err = io.Copy(f, r)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(localPath)
+		return fmt.Errorf("download gs://%s/%s: %w", bucket, key, err)
+	}
+	return nil
+}
+
+// FileExists checks if an object exists at the given bucket and key.
+func (g *GCSSyncer) FileExists(ctx context.Context, bucket string, key string) (bool, error) {
+	_, err := g.client.Bucket(bucket).Object(key).Attrs(ctx)
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("get attrs gs://%s/%s: %w", bucket, key, err)
+	}
+	return true, nil
+}
+
+// ObjectSize returns the size in bytes of the object at bucket/key.
+func (g *GCSSyncer) ObjectSize(ctx context.Context, bucket string, key string) (int64, error) {
+	attrs, err := g.client.Bucket(bucket).Object(key).Attrs(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("get attrs gs://%s/%s: %w", bucket, key, err)
+	}
+	return attrs.Size, nil
+}
+
+// DeletePrefix deletes every object under prefix and returns how many were removed.
+func (g *GCSSyncer) DeletePrefix(ctx context.Context, bucket string, prefix string) (int, error) {
+	keys, err := g.listKeys(ctx, bucket, prefix)
+	if err != nil {
+		return 0, err
+	}
+	if err := g.deleteKeys(ctx, bucket, keys); err != nil {
+		return 0, err
+	}
+	return len(keys), nil
+}
+
+// objectMatches reports whether key exists with the same size and MD5 as localPath.
+// Composite objects carry no MD5, so those are compared by size only.
+func (g *GCSSyncer) objectMatches(ctx context.Context, bucket string, key string, localPath string) (bool, error) {
+	attrs, err := g.client.Bucket(bucket).Object(key).Attrs(ctx)
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("get attrs gs://%s/%s: %w", bucket, key, err)
+	}
+	info, err := os.Stat(localPath)
+	if err != nil {
+		return false, err
+	}
+	if attrs.Size != info.Size() {
+		return false, nil
+	}
+	if len(attrs.MD5) == 0 {
+		return true, nil
+	}
+	sum, err := fileMD5(localPath)
+	if err != nil {
+		return false, err
+	}
+	want, err := hex.DecodeString(sum)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(attrs.MD5, want), nil
+}
+
+// listKeys returns every object key under prefix.
+func (g *GCSSyncer) listKeys(ctx context.Context, bucket string, prefix string) ([]string, error) {
+	q := &storage.Query{Prefix: strings.Trim(prefix, "/") + "/"}
+	if err := q.SetAttrSelection([]string{"Name"}); err != nil {
+		return nil, err
+	}
+	it := g.client.Bucket(bucket).Objects(ctx, q)
+	var keys []string
+	for {
+		attrs, err := it.Next()
+		if errors.Is(err, iterator.Done) {
+			return keys, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("list gs://%s/%s: %w", bucket, prefix, err)
+		}
+		keys = append(keys, attrs.Name)
+	}
+}
+
+// deleteKeys deletes objects one by one; GCS has no multi-object delete in the Go client.
+// Objects that are already gone are ignored.
+func (g *GCSSyncer) deleteKeys(ctx context.Context, bucket string, keys []string) error {
+	b := g.client.Bucket(bucket)
+	for _, key := range keys {
+		if err := b.Object(key).Delete(ctx); err != nil && !errors.Is(err, storage.ErrObjectNotExist) {
+			return fmt.Errorf("delete gs://%s/%s: %w", bucket, key, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,209 @@
+package storage
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultCachePolicy is the per-extension Cache-Control applied unless overridden by
+// S3Options.CachePolicy or GCSOptions.CachePolicy. Playlists change between runs and
+// must be revalidated; segments are never rewritten in place so they can be cached forever.
+var DefaultCachePolicy = map[string]string{
+	".m3u8": "no-cache",
+	".ts":   "max-age=31536000,immutable",
+	".m4s":  "max-age=31536000,immutable",
+	".vtt":  "max-age=300",
+	".json": "max-age=300",
+	".jpg":  "max-age=86400",
+	".jpeg": "max-age=86400",
+	".webp": "max-age=86400",
+	".avif": "max-age=86400",
+	".gif":  "max-age=86400",
+	".mp4":  "max-age=86400",
+	".webm": "max-age=86400",
+}
+
+// objectMeta resolves the Content-Type, Cache-Control and Content-Disposition of
+// uploaded files. It is shared by every backend so the same output tree is served
+// identically from any of them.
+type objectMeta struct {
+	cacheControl string
+	cachePolicy  map[string]string
+	contentType  func(path string) string
+	contentTypes map[string]string
+	attachments  []string
+}
+
+func newObjectMeta(cacheControl string, cachePolicy map[string]string, contentType func(string) string, contentTypes map[string]string, attachments []string) objectMeta {
+	return objectMeta{
+		cacheControl: cacheControl,
+		cachePolicy:  mergeByExtension(DefaultCachePolicy, cachePolicy),
+		contentType:  contentType,
+		contentTypes: mergeByExtension(nil, contentTypes),
+		attachments:  attachments,
+	}
+}
+
+// resolveContentType prefers the configured ContentTypeFunc, then ContentTypes, and
+// falls back to detectContentType.
+func (m objectMeta) resolveContentType(path string) string {
+	if m.contentType != nil {
+		if ct := m.contentType(path); ct != "" {
+			return ct
+		}
+	}
+	if ct := m.contentTypes[strings.ToLower(filepath.Ext(path))]; ct != "" {
+		return ct
+	}
+	return detectContentType(path)
+}
+
+// resolveCacheControl returns the extension's cache policy, or the global CacheControl.
+func (m objectMeta) resolveCacheControl(path string) string {
+	if cc, ok := m.cachePolicy[strings.ToLower(filepath.Ext(path))]; ok {
+		return cc
+	}
+	return m.cacheControl
+}
+
+// resolveContentDisposition returns an attachment disposition for keys ending in one of
+// the configured attachment suffixes, so browsers save them instead of playing them
+// inline, and "" (no header, inline) for everything else. The suggested filename is
+// the key's base name prefixed with its parent directory, typically the video's ID
+// (e.g. "hls/abc123/audio.m4a" saves as "abc123-audio.m4a").
+func (m objectMeta) resolveContentDisposition(key string) string {
+	for _, suffix := range m.attachments {
+		if suffix == "" || !strings.HasSuffix(key, suffix) {
+			continue
+		}
+		name := path.Base(key)
+		if dir := path.Base(path.Dir(key)); dir != "." && dir != "/" {
+			name = dir + "-" + name
+		}
+		name = strings.Map(func(r rune) rune {
+			if r == '"' || r == '\\' || r < ' ' {
+				return '_'
+			}
+			return r
+		}, name)
+		return fmt.Sprintf(`attachment; filename="%s"`, name)
+	}
+	return ""
+}
+
+// mergeByExtension copies base and applies overrides on top, normalizing keys to
+// lowercase extensions with a leading dot. Empty override values delete the key.
+func mergeByExtension(base, overrides map[string]string) map[string]string {
+	merged := make(map[string]string, len(base)+len(overrides))
+	for ext, v := range base {
+		merged[ext] = v
+	}
+	for ext, v := range overrides {
+		ext = strings.ToLower(ext)
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		if v == "" {
+			delete(merged, ext)
+			continue
+		}
+		merged[ext] = v
+	}
+	return merged
+}
+
+func detectContentType(path string) string {
+	ext := strings.ToLower(filepath.Ext(path))
+	switch ext {
+	case ".m3u8":
+		return "application/vnd.apple.mpegurl"
+	case ".ts":
+		return "video/mp2t"
+	case ".m4s":
+		return "video/iso.segment"
+	case ".mp4":
+		return "video/mp4"
+	case ".webm":
+		return "video/webm"
+	case ".jpg", ".jpeg":
+		return "image/jpeg"
+	case ".png":
+		return "image/png"
+	case ".webp":
+		return "image/webp"
+	case ".avif":
+		return "image/avif"
+	case ".gif":
+		return "image/gif"
+	case ".vtt":
+		return "text/vtt"
+	}
+	if ct := mime.TypeByExtension(ext); ct != "" {
+		return ct
+	}
+	return "application/octet-stream"
+}
+
+func joinKey(prefix, rel string) string {
+	rel = strings.ReplaceAll(rel, string(filepath.Separator), "/")
+	prefix = strings.Trim(prefix, "/")
+	if prefix == "" {
+		return rel
+	}
+	return prefix + "/" + rel
+}
+
+// fileTask is a local file and the object key it syncs to.
+type fileTask struct {
+	localPath string
+	key       string
+	size      int64
+}
+
+// collectFiles walks localDir and maps every file not excluded to its key under prefix.
+func collectFiles(localDir string, prefix string, exclude func(rel string) bool) ([]fileTask, error) {
+	root := filepath.Clean(localDir)
+	var tasks []fileTask
+	err := filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		if exclude != nil && exclude(rel) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		tasks = append(tasks, fileTask{localPath: path, key: joinKey(prefix, rel), size: info.Size()})
+		return nil
+	})
+	return tasks, err
+}
+
+func fileMD5(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := md5.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("hash %s: %w", path, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,34 @@
+package storage
+
+import "testing"
+
+func TestObjectMeta_CachePolicy(t *testing.T) {
+	m := newObjectMeta("max-age=60", map[string]string{
+		"ts":    "max-age=3600",  // override without a leading dot
+		".VTT":  "no-store",      // override in upper case
+		".m3u8": "",              // drop the default
+		".png":  "max-age=86400", // new extension
+	}, nil, nil, nil)
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"720p/seg_001.ts", "max-age=3600"},
+		{"thumbnails.vtt", "no-store"},
+		{"master.m3u8", "max-age=60"}, // falls back to CacheControl
+		{"sprite.png", "max-age=86400"},
+		{"720p/seg_001.m4s", DefaultCachePolicy[".m4s"]},
+		{"POSTER.JPG", DefaultCachePolicy[".jpg"]},
+		{"manifest.mpd", "max-age=60"},
+	}
+	for _, tt := range tests {
+		if got := m.resolveCacheControl(tt.path); got != tt.want {
+			t.Errorf("resolveCacheControl(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+
+	if DefaultCachePolicy[".m3u8"] != "no-cache" || DefaultCachePolicy[".ts"] != "max-age=31536000,immutable" {
+		t.Error("overrides modified DefaultCachePolicy")
+	}
+}
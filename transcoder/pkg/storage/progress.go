@@ -0,0 +1,105 @@
+package storage
+
+import (
+	"io"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// ProgressFunc receives the number of bytes uploaded so far out of total.
+// It may be called concurrently from multipart upload goroutines.
+type ProgressFunc func(uploaded, total int64)
+
+// progressLogThreshold is the file size above which uploads are wrapped in a
+// progressReader. Smaller files are reported once, on completion, so they pay
+// nothing for progress tracking.
+const progressLogThreshold = 32 * 1024 * 1024
+
+// progressLogInterval limits how often per-file progress is logged.
+const progressLogInterval = 5 * time.Second
+
+// progressReader counts bytes read from a file for upload. It keeps ReadAt and
+// Seek so the upload manager can still read parts in parallel.
+type progressReader struct {
+	f          *os.File
+	size       int64
+	read       atomic.Int64
+	onProgress func(read int64)
+}
+
+var (
+	_ io.ReadSeeker = (*progressReader)(nil)
+	_ io.ReaderAt   = (*progressReader)(nil)
+)
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.f.Read(p)
+	r.add(n)
+	return n, err
+}
+
+func (r *progressReader) ReadAt(p []byte, off int64) (int, error) {
+	n, err := r.f.ReadAt(p, off)
+	r.add(n)
+	return n, err
+}
+
+func (r *progressReader) Seek(offset int64, whence int) (int64, error) {
+	return r.f.Seek(offset, whence)
+}
+
+// add records n bytes, capped at the file size: retried parts are read twice but
+// should not push progress past 100%.
+func (r *progressReader) add(n int) {
+	if n <= 0 {
+		return
+	}
+	for {
+		cur := r.read.Load()
+		next := min(cur+int64(n), r.size)
+		if next == cur {
+			return
+		}
+		if r.read.CompareAndSwap(cur, next) {
+			r.onProgress(next)
+			return
+		}
+	}
+}
+
+// highWater turns absolute progress values, possibly reported out of order or
+// restarting from zero on a retry, into monotonic deltas.
+type highWater struct {
+	max atomic.Int64
+}
+
+// advance records v and returns how far it moved the high-water mark.
+func (h *highWater) advance(v int64) int64 {
+	for {
+		cur := h.max.Load()
+		if v <= cur {
+			return 0
+		}
+		if h.max.CompareAndSwap(cur, v) {
+			return v - cur
+		}
+	}
+}
+
+// byteCounter aggregates bytes across files for a directory-level percentage.
+type byteCounter struct {
+	done  atomic.Int64
+	total int64
+	fn    ProgressFunc
+}
+
+func (c *byteCounter) add(n int64) {
+	if c == nil || n == 0 {
+		return
+	}
+	done := c.done.Add(n)
+	if c.fn != nil {
+		c.fn(done, c.total)
+	}
+}
@@ -1,15 +1,18 @@
 package storage
 
 import (
+	"cmp"
 	"context"
 	"errors"
 	"fmt"
 	"io"
-	"mime"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
@@ -17,6 +20,7 @@ import (
 	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
 	"github.com/charmbracelet/log"
 )
 
@@ -26,7 +30,38 @@ type S3Options struct {
 	Endpoint     string
 	UsePathStyle bool
 	ACL          string // e.g., "public-read"
-	CacheControl string // e.g., "max-age=60"
+	// DisableACL never sends an ACL with uploads, even if ACL is set. Buckets with
+	// Object Ownership set to "bucket owner enforced" (the default for new buckets)
+	// reject any ACL with AccessControlListNotSupported.
+	DisableACL   bool
+	CacheControl string // e.g., "max-age=60"; used for extensions without a cache policy
+	// Cache-Control by file extension (e.g. ".m3u8": "no-cache"), merged over
+	// DefaultCachePolicy. An empty value removes the default for that extension.
+	CachePolicy map[string]string
+	// Optional content-type resolver; takes precedence over the built-in detection.
+	// Returning "" falls back to the built-in detection for that path.
+	ContentTypeFunc func(path string) string
+	// Content-Type by file extension (e.g. ".bif": "application/octet-stream"),
+	// consulted after ContentTypeFunc and before the built-in detection.
+	ContentTypes map[string]string
+	// Key suffixes (e.g. "audio.m4a") uploaded with Content-Disposition: attachment so
+	// browsers download them; other objects are served inline.
+	Attachments []string
+	// Multipart upload tuning. Files larger than one part are uploaded as parts
+	// that are retried individually; smaller files use a single PUT.
+	UploadPartSizeMB  int // 0 uses the SDK default; values below 5 are raised to S3's 5MB minimum
+	UploadConcurrency int // parts uploaded in parallel per file; 0 uses the SDK default
+	// Whole-file upload retries on throttling, 5xx and network errors
+	UploadAttempts       int           // total attempts per file; 0 means 3
+	UploadRetryBaseDelay time.Duration // doubled after each failure; 0 means 500ms
+	// VerifyUploads checks each object uploaded by SyncDirectory with a HeadObject and
+	// fails the sync if its size differs from the local file. Doubles request count.
+	VerifyUploads bool
+	// Ranged parallel downloads. A retry resumes the download: it only fetches the
+	// parts earlier attempts didn't finish, unless the object changed in between.
+	DownloadPartSizeMB  int // 0 means 5
+	DownloadConcurrency int // parts fetched in parallel per file; 0 means 5
+	DownloadAttempts    int // total attempts per download; 0 means 3
 	// Optional static credentials. If empty, default provider chain is used.
 	AccessKeyID     string
 	SecretAccessKey string
@@ -34,10 +69,17 @@ type S3Options struct {
 }
 
 type S3Syncer struct {
-	client       *s3.Client
-	uploader     *manager.Uploader
-	acl          string
-	cacheControl string
+	client           *s3.Client
+	presigner        *s3.PresignClient
+	uploader         *manager.Uploader
+	downloadPartSize int64
+	downloadParallel int
+	downloadTry      int
+	uploadTry        int
+	uploadRetryDelay time.Duration
+	verifyUploads    bool
+	acl              string
+	objectMeta
 }
 
 func NewS3Syncer(ctx context.Context, opts S3Options) (*S3Syncer, error) {
@@ -62,44 +104,66 @@ func NewS3Syncer(ctx context.Context, opts S3Options) (*S3Syncer, error) {
 			o.BaseEndpoint = aws.String(opts.Endpoint)
 		}
 	})
+	acl := opts.ACL
+	if opts.DisableACL {
+		acl = ""
+	}
 	return &S3Syncer{
-		client:       client,
-		uploader:     manager.NewUploader(client),
-		acl:          opts.ACL,
-		cacheControl: opts.CacheControl,
+		client:           client,
+		presigner:        s3.NewPresignClient(client),
+		uploader:         manager.NewUploader(client, uploaderOptions(opts)),
+		downloadPartSize: cmp.Or(int64(max(opts.DownloadPartSizeMB, 0))*1024*1024, manager.DefaultDownloadPartSize),
+		downloadParallel: cmp.Or(max(opts.DownloadConcurrency, 0), manager.DefaultDownloadConcurrency),
+		downloadTry:      cmp.Or(max(opts.DownloadAttempts, 0), 3),
+		uploadTry:        cmp.Or(max(opts.UploadAttempts, 0), 3),
+		uploadRetryDelay: cmp.Or(max(opts.UploadRetryBaseDelay, 0), 500*time.Millisecond),
+		verifyUploads:    opts.VerifyUploads,
+		acl:              acl,
+		objectMeta:       newObjectMeta(opts.CacheControl, opts.CachePolicy, opts.ContentTypeFunc, opts.ContentTypes, opts.Attachments),
 	}, nil
 }
 
-func (s *S3Syncer) SyncDirectory(ctx context.Context, localDir string, bucket string, prefix string) error {
-	root := filepath.Clean(localDir)
-	
-	// Collect all files to upload
-	type fileTask struct {
-		localPath string
-		key       string
-	}
-	var tasks []fileTask
-	
-	err := filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
-		if err != nil {
-			return err
-		}
-		if d.IsDir() {
-			return nil
+// SyncOptions tunes SyncDirectoryWithOptions.
+type SyncOptions struct {
+	// Delete removes objects under the prefix that have no local counterpart, once
+	// every upload has succeeded (mirror mode).
+	Delete bool
+	// Compare re-uploads existing objects whose size or MD5 ETag differs from the
+	// local file, instead of skipping every key that already exists. Multipart
+	// ETags are not content MD5s, so those objects are compared by size only.
+	Compare bool
+	// Progress, if set, receives bytes uploaded across the whole directory. Files
+	// skipped as already present count as uploaded. Reported by S3Syncer only.
+	Progress ProgressFunc
+	// Exclude, if set, leaves out local files by path relative to the directory. They
+	// count as absent, so with Delete their remote copies are removed.
+	Exclude func(rel string) bool
+}
+
+// uploaderOptions applies the multipart settings from opts to the upload manager.
+func uploaderOptions(opts S3Options) func(*manager.Uploader) {
+	return func(u *manager.Uploader) {
+		if opts.UploadPartSizeMB > 0 {
+			u.PartSize = max(int64(opts.UploadPartSizeMB)*1024*1024, manager.MinUploadPartSize)
 		}
-		rel, err := filepath.Rel(root, path)
-		if err != nil {
-			return err
+		if opts.UploadConcurrency > 0 {
+			u.Concurrency = opts.UploadConcurrency
 		}
-		key := joinKey(prefix, rel)
-		tasks = append(tasks, fileTask{localPath: path, key: key})
-		return nil
-	})
+	}
+}
+
+func (s *S3Syncer) SyncDirectory(ctx context.Context, localDir string, bucket string, prefix string) error {
+	return s.SyncDirectoryWithOptions(ctx, localDir, bucket, prefix, SyncOptions{})
+}
+
+func (s *S3Syncer) SyncDirectoryWithOptions(ctx context.Context, localDir string, bucket string, prefix string, opts SyncOptions) error {
+	// Collect all files to upload
+	tasks, err := collectFiles(localDir, prefix, opts.Exclude)
 	if err != nil {
 		return err
 	}
 	
-	if len(tasks) == 0 {
+	if len(tasks) == 0 && !opts.Delete {
 		return nil
 	}
 	
@@ -114,6 +178,11 @@ func (s *S3Syncer) SyncDirectory(ctx context.Context, localDir string, bucket st
 	uploadedCount := 0
 	skippedCount := 0
 	var mu sync.Mutex
+
+	progress := &byteCounter{fn: opts.Progress}
+	for _, t := range tasks {
+		progress.total += t.size
+	}
 	
 	for _, task := range tasks {
 		wg.Add(1)
@@ -123,27 +192,40 @@ func (s *S3Syncer) SyncDirectory(ctx context.Context, localDir string, bucket st
 			defer wg.Done()
 			defer func() { <-sem }() // Release semaphore
 			
-			// Check if file already exists in S3
-			exists, err := s.FileExists(ctx, bucket, t.key)
+			// Check if file already exists in S3 (and, when comparing, is unchanged)
+			var skip bool
+			var err error
+			if opts.Compare {
+				skip, err = s.objectMatches(ctx, bucket, t.key, t.localPath)
+			} else {
+				skip, err = s.FileExists(ctx, bucket, t.key)
+			}
 			if err != nil {
 				errChan <- fmt.Errorf("check exists %s: %w", t.key, err)
 				return
 			}
 			
-			if exists {
+			if skip {
 				mu.Lock()
 				skippedCount++
 				mu.Unlock()
+				progress.add(t.size)
 				return // Skip upload
 			}
 
 			log.Info("uploading file", "local_path", t.localPath, "bucket", bucket, "key", t.key)
 			
 			// Upload the file
-			if err := s.uploadOne(ctx, t.localPath, bucket, t.key); err != nil {
+			if err := s.uploadOne(ctx, t.localPath, bucket, t.key, progress.add); err != nil {
 				errChan <- err
 				return
 			}
+			if s.verifyUploads {
+				if err := s.verifyUpload(ctx, bucket, t.key, t.size); err != nil {
+					errChan <- err
+					return
+				}
+			}
 			
 			mu.Lock()
 			uploadedCount++
@@ -167,45 +249,321 @@ func (s *S3Syncer) SyncDirectory(ctx context.Context, localDir string, bucket st
 	}
 	
 	log.Info("sync complete", "uploaded", uploadedCount, "skipped", skippedCount, "total", len(tasks))
+
+	if opts.Delete {
+		local := make(map[string]struct{}, len(tasks))
+		for _, t := range tasks {
+			local[t.key] = struct{}{}
+		}
+		if err := s.deleteStale(ctx, bucket, prefix, local); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteStale deletes objects under prefix whose keys are not in keep.
+func (s *S3Syncer) deleteStale(ctx context.Context, bucket string, prefix string, keep map[string]struct{}) error {
+	if strings.Trim(prefix, "/") == "" {
+		// Never mirror-delete across a whole bucket
+		return errors.New("refusing to delete stale objects without a prefix")
+	}
+	keys, err := s.listKeys(ctx, bucket, prefix)
+	if err != nil {
+		return err
+	}
+	var stale []string
+	for _, key := range keys {
+		if _, ok := keep[key]; !ok {
+			stale = append(stale, key)
+		}
+	}
+	if len(stale) == 0 {
+		return nil
+	}
+	log.Info("deleting stale objects", "count", len(stale), "bucket", bucket, "prefix", prefix)
+	return s.deleteKeys(ctx, bucket, stale)
+}
+
+// listKeys returns every object key under prefix.
+func (s *S3Syncer) listKeys(ctx context.Context, bucket string, prefix string) ([]string, error) {
+	prefix = strings.Trim(prefix, "/") + "/"
+	var keys []string
+	p := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(bucket),
+		Prefix: aws.String(prefix),
+	})
+	for p.HasMorePages() {
+		page, err := p.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("list s3://%s/%s: %w", bucket, prefix, err)
+		}
+		for _, obj := range page.Contents {
+			keys = append(keys, aws.ToString(obj.Key))
+		}
+	}
+	return keys, nil
+}
+
+// deleteKeys deletes keys in batches of up to 1000, the DeleteObjects limit.
+func (s *S3Syncer) deleteKeys(ctx context.Context, bucket string, keys []string) error {
+	const batchSize = 1000
+	for start := 0; start < len(keys); start += batchSize {
+		batch := keys[start:min(start+batchSize, len(keys))]
+		ids := make([]types.ObjectIdentifier, len(batch))
+		for i, key := range batch {
+			ids[i] = types.ObjectIdentifier{Key: aws.String(key)}
+		}
+		out, err := s.client.DeleteObjects(ctx, &s3.DeleteObjectsInput{
+			Bucket: aws.String(bucket),
+			Delete: &types.Delete{Objects: ids, Quiet: aws.Bool(true)},
+		})
+		if err != nil {
+			return fmt.Errorf("delete objects in s3://%s: %w", bucket, err)
+		}
+		if len(out.Errors) > 0 {
+			return fmt.Errorf("delete s3://%s/%s: %s", bucket, aws.ToString(out.Errors[0].Key), aws.ToString(out.Errors[0].Message))
+		}
+	}
 	return nil
 }
 
 func (s *S3Syncer) UploadFile(ctx context.Context, localPath string, bucket string, key string) error {
-	return s.uploadOne(ctx, localPath, bucket, key)
+	return s.uploadOne(ctx, localPath, bucket, key, nil)
+}
+
+// UploadFileWithProgress is UploadFile with a callback receiving bytes uploaded so far.
+func (s *S3Syncer) UploadFileWithProgress(ctx context.Context, localPath string, bucket string, key string, fn ProgressFunc) error {
+	if fn == nil {
+		return s.UploadFile(ctx, localPath, bucket, key)
+	}
+	info, err := os.Stat(localPath)
+	if err != nil {
+		return fmt.Errorf("stat %s: %w", localPath, err)
+	}
+	var done atomic.Int64
+	return s.uploadOne(ctx, localPath, bucket, key, func(delta int64) {
+		fn(done.Add(delta), info.Size())
+	})
 }
 
-// DownloadFile downloads a file from S3 to a local path.
+// DownloadFile downloads a file from S3 to a local path using ranged, parallel
+// part requests. Failed downloads are retried with backoff and resume where they left
+// off: parts already written are kept, and only the missing ones are fetched again.
+// If the object changes between attempts the download starts over. The partial file
+// is removed if the download ultimately fails so callers never see a truncated file.
 func (s *S3Syncer) DownloadFile(ctx context.Context, bucket string, key string, localPath string) error {
 	// Create parent directory if it doesn't exist
 	if err := os.MkdirAll(filepath.Dir(localPath), 0755); err != nil {
 		return fmt.Errorf("create parent dir: %w", err)
 	}
 
-	// Create the local file
-	f, err := os.Create(localPath)
-	if err != nil {
-		return fmt.Errorf("create local file %s: %w", localPath, err)
+	var d partialDownload
+	delay := time.Second
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = s.downloadAttempt(ctx, bucket, key, localPath, &d)
+		if err == nil {
+			return nil
+		}
+		if attempt >= s.downloadTry || ctx.Err() != nil {
+			_ = os.Remove(localPath)
+			return err
+		}
+		log.Warn("download failed, resuming",
+			"key", key,
+			"attempt", attempt,
+			"max_attempts", s.downloadTry,
+			"parts_done", d.partsDone(),
+			"parts", len(d.done),
+			"backoff", delay,
+			"error", err,
+		)
+		select {
+		case <-ctx.Done():
+			_ = os.Remove(localPath)
+			return err
+		case <-time.After(delay):
+		}
+		delay *= 2
 	}
-	defer f.Close()
+}
+
+// partialDownload is what DownloadFile keeps between attempts: the version of the
+// object being downloaded and which of its parts are already on disk.
+type partialDownload struct {
+	etag string
+	size int64
+	done []bool // by part
+}
 
-	// Download from S3
-	result, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+func (d *partialDownload) partsDone() int {
+	n := 0
+	for _, ok := range d.done {
+		if ok {
+			n++
+		}
+	}
+	return n
+}
+
+// downloadAttempt fetches the parts of s3://bucket/key that d doesn't have yet into
+// localPath, recording each one that completes in d.
+func (s *S3Syncer) downloadAttempt(ctx context.Context, bucket string, key string, localPath string, d *partialDownload) error {
+	head, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{
 		Bucket: aws.String(bucket),
 		Key:    aws.String(key),
 	})
 	if err != nil {
-		return fmt.Errorf("get object s3://%s/%s: %w", bucket, key, err)
+		return fmt.Errorf("head s3://%s/%s: %w", bucket, key, err)
+	}
+	etag, size := aws.ToString(head.ETag), aws.ToInt64(head.ContentLength)
+	flag := os.O_RDWR | os.O_CREATE
+	if d.done == nil || etag != d.etag || size != d.size {
+		if d.done != nil {
+			log.Warn("object changed since the download started, starting over", "key", key)
+		}
+		parts := (size + s.downloadPartSize - 1) / s.downloadPartSize
+		*d = partialDownload{etag: etag, size: size, done: make([]bool, parts)}
+		flag |= os.O_TRUNC
 	}
-	defer result.Body.Close()
 
-	// Copy to local file
-	if _, err := io.Copy(f, result.Body); err != nil {
-		return fmt.Errorf("write to %s: %w", localPath, err)
+	f, err := os.OpenFile(localPath, flag, 0644)
+	if err != nil {
+		return fmt.Errorf("create local file %s: %w", localPath, err)
+	}
+	if err := f.Truncate(size); err != nil {
+		f.Close()
+		return fmt.Errorf("size local file %s: %w", localPath, err)
 	}
 
+	var mu sync.Mutex
+	var firstErr error
+	sem := make(chan struct{}, s.downloadParallel)
+	var wg sync.WaitGroup
+	for i, done := range d.done {
+		if done {
+			continue
+		}
+		mu.Lock()
+		failed := firstErr != nil
+		mu.Unlock()
+		if failed {
+			// Stop starting parts; those already running finish and are kept
+			break
+		}
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			off := int64(i) * s.downloadPartSize
+			err := s.downloadPart(ctx, bucket, key, etag, f, off, min(s.downloadPartSize, size-off))
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				return
+			}
+			d.done[i] = true
+		}(i)
+	}
+	wg.Wait()
+
+	if closeErr := f.Close(); firstErr == nil && closeErr != nil {
+		firstErr = fmt.Errorf("close %s: %w", localPath, closeErr)
+	}
+	return firstErr
+}
+
+// downloadPart writes the n bytes of the object at off into f at the same offset. The
+// request is conditional on etag, so a part of a newer version of the object is never
+// mixed into the file.
+func (s *S3Syncer) downloadPart(ctx context.Context, bucket string, key string, etag string, f *os.File, off int64, n int64) error {
+	input := &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+		Range:  aws.String(fmt.Sprintf("bytes=%d-%d", off, off+n-1)),
+	}
+	if etag != "" {
+		input.IfMatch = aws.String(etag)
+	}
+	out, err := s.client.GetObject(ctx, input)
+	if err != nil {
+		return fmt.Errorf("download s3://%s/%s bytes %d-%d: %w", bucket, key, off, off+n-1, err)
+	}
+	defer out.Body.Close()
+	written, err := io.Copy(io.NewOffsetWriter(f, off), out.Body)
+	if err != nil {
+		return fmt.Errorf("download s3://%s/%s bytes %d-%d: %w", bucket, key, off, off+n-1, err)
+	}
+	if written != n {
+		return fmt.Errorf("download s3://%s/%s bytes %d-%d: got %d bytes", bucket, key, off, off+n-1, written)
+	}
 	return nil
 }
 
+// PresignedURL is a time-limited URL for a single object operation.
+type PresignedURL struct {
+	URL     string
+	Method  string
+	Expires time.Time
+}
+
+const (
+	defaultPresignTTL = 15 * time.Minute
+	maxPresignTTL     = 7 * 24 * time.Hour // SigV4 limit
+)
+
+// PresignGet returns a URL that can GET s3://bucket/key without credentials until it
+// expires. ttl <= 0 uses a 15 minute default. The presign client shares the syncer's
+// endpoint and path-style settings, so URLs point at MinIO/R2 when configured.
+// Relative URIs inside a signed playlist are not signed, so private buckets also need
+// the variant playlists and segments to be readable (or signed) for playback.
+func (s *S3Syncer) PresignGet(ctx context.Context, bucket string, key string, ttl time.Duration) (PresignedURL, error) {
+	ttl, err := presignTTL(ttl)
+	if err != nil {
+		return PresignedURL{}, err
+	}
+	req, err := s.presigner.PresignGetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	}, s3.WithPresignExpires(ttl))
+	if err != nil {
+		return PresignedURL{}, fmt.Errorf("presign get s3://%s/%s: %w", bucket, key, err)
+	}
+	return PresignedURL{URL: req.URL, Method: req.Method, Expires: time.Now().Add(ttl)}, nil
+}
+
+// PresignPut returns a URL that a client can PUT the object body to directly. No
+// Content-Type is signed, so the uploader may send any.
+func (s *S3Syncer) PresignPut(ctx context.Context, bucket string, key string, ttl time.Duration) (PresignedURL, error) {
+	ttl, err := presignTTL(ttl)
+	if err != nil {
+		return PresignedURL{}, err
+	}
+	req, err := s.presigner.PresignPutObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	}, s3.WithPresignExpires(ttl))
+	if err != nil {
+		return PresignedURL{}, fmt.Errorf("presign put s3://%s/%s: %w", bucket, key, err)
+	}
+	return PresignedURL{URL: req.URL, Method: req.Method, Expires: time.Now().Add(ttl)}, nil
+}
+
+func presignTTL(ttl time.Duration) (time.Duration, error) {
+	if ttl <= 0 {
+		return defaultPresignTTL, nil
+	}
+	if ttl > maxPresignTTL {
+		return 0, fmt.Errorf("presign ttl %s exceeds the maximum of %s", ttl, maxPresignTTL)
+	}
+	return ttl, nil
+}
+
 // FileExists checks if a file exists in S3 at the given bucket and key.
 func (s *S3Syncer) FileExists(ctx context.Context, bucket string, key string) (bool, error) {
 	_, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{
@@ -224,24 +582,174 @@ func (s *S3Syncer) FileExists(ctx context.Context, bucket string, key string) (b
 	return true, nil
 }
 
-func (s *S3Syncer) uploadOne(ctx context.Context, localPath string, bucket string, key string) error {
+// ObjectSize returns the size in bytes of the object at bucket/key.
+func (s *S3Syncer) ObjectSize(ctx context.Context, bucket string, key string) (int64, error) {
+	head, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return 0, fmt.Errorf("head object s3://%s/%s: %w", bucket, key, err)
+	}
+	return aws.ToInt64(head.ContentLength), nil
+}
+
+// DeletePrefix deletes every object under prefix and returns how many were removed.
+func (s *S3Syncer) DeletePrefix(ctx context.Context, bucket string, prefix string) (int, error) {
+	keys, err := s.listKeys(ctx, bucket, prefix)
+	if err != nil {
+		return 0, err
+	}
+	if err := s.deleteKeys(ctx, bucket, keys); err != nil {
+		return 0, err
+	}
+	return len(keys), nil
+}
+
+// objectMatches reports whether key exists with the same content as localPath.
+func (s *S3Syncer) objectMatches(ctx context.Context, bucket string, key string, localPath string) (bool, error) {
+	head, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		var notFound *types.NotFound
+		var noSuchKey *types.NoSuchKey
+		if errors.As(err, &notFound) || errors.As(err, &noSuchKey) {
+			return false, nil
+		}
+		return false, fmt.Errorf("head object s3://%s/%s: %w", bucket, key, err)
+	}
+	info, err := os.Stat(localPath)
+	if err != nil {
+		return false, err
+	}
+	if aws.ToInt64(head.ContentLength) != info.Size() {
+		return false, nil
+	}
+	etag := strings.Trim(aws.ToString(head.ETag), `"`)
+	if etag == "" || strings.Contains(etag, "-") {
+		// Multipart ETags aren't an MD5 of the content; same size is the best we can do
+		return true, nil
+	}
+	sum, err := fileMD5(localPath)
+	if err != nil {
+		return false, err
+	}
+	return strings.EqualFold(etag, sum), nil
+}
+
+// verifyUpload checks that key holds size bytes, catching uploads the store
+// truncated without reporting an error.
+func (s *S3Syncer) verifyUpload(ctx context.Context, bucket string, key string, size int64) error {
+	head, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return fmt.Errorf("verify s3://%s/%s: %w", bucket, key, err)
+	}
+	if got := aws.ToInt64(head.ContentLength); got != size {
+		return fmt.Errorf("verify s3://%s/%s: uploaded %d bytes, local file has %d", bucket, key, got, size)
+	}
+	return nil
+}
+
+// uploadOne uploads a file, retrying transient failures (throttling, 5xx, network
+// errors) with exponential backoff. The file is re-opened for every attempt since
+// the uploader consumes the body.
+//
+// progress, if non-nil, receives byte deltas that sum to the file size. Files larger
+// than progressLogThreshold report (and log) as they upload; smaller ones report once
+// on success so they skip the counting wrapper entirely.
+func (s *S3Syncer) uploadOne(ctx context.Context, localPath string, bucket string, key string, progress func(delta int64)) error {
+	info, err := os.Stat(localPath)
+	if err != nil {
+		return fmt.Errorf("stat %s: %w", localPath, err)
+	}
+	size := info.Size()
+
+	var hw highWater
+	var onRead func(read int64)
+	if size >= progressLogThreshold {
+		var lastLog atomic.Int64
+		onRead = func(read int64) {
+			d := hw.advance(read)
+			if d == 0 {
+				return
+			}
+			if progress != nil {
+				progress(d)
+			}
+			now := time.Now().UnixNano()
+			last := lastLog.Load()
+			if now-last >= int64(progressLogInterval) && lastLog.CompareAndSwap(last, now) {
+				log.Info("upload progress",
+					"key", key,
+					"uploaded_mb", read/(1024*1024),
+					"total_mb", size/(1024*1024),
+					"percent", read*100/size,
+				)
+			}
+		}
+	}
+
+	delay := s.uploadRetryDelay
+	for attempt := 1; ; attempt++ {
+		err := s.uploadAttempt(ctx, localPath, bucket, key, size, onRead)
+		if err == nil {
+			if progress != nil {
+				// Small files, or the remainder if the SDK buffered the tail of a large one
+				if d := hw.advance(size); d > 0 {
+					progress(d)
+				}
+			}
+			return nil
+		}
+		if attempt >= s.uploadTry || !retryableS3Error(ctx, err) {
+			return err
+		}
+		log.Warn("upload failed, retrying",
+			"key", key,
+			"attempt", attempt,
+			"max_attempts", s.uploadTry,
+			"backoff", delay,
+			"error", err,
+		)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+func (s *S3Syncer) uploadAttempt(ctx context.Context, localPath string, bucket string, key string, size int64, onRead func(int64)) error {
 	f, err := os.Open(localPath)
 	if err != nil {
 		return fmt.Errorf("open %s: %w", localPath, err)
 	}
 	defer f.Close()
-	ct := detectContentType(localPath)
+	body := io.Reader(f)
+	if onRead != nil {
+		body = &progressReader{f: f, size: size, onProgress: onRead}
+	}
+	ct := s.resolveContentType(localPath)
 	input := &s3.PutObjectInput{
 		Bucket:      aws.String(bucket),
 		Key:         aws.String(key),
-		Body:        io.Reader(f),
+		Body:        body,
 		ContentType: aws.String(ct),
 	}
 	if s.acl != "" {
 		input.ACL = types.ObjectCannedACL(s.acl)
 	}
-	if s.cacheControl != "" {
-		input.CacheControl = aws.String(s.cacheControl)
+	if cc := s.resolveCacheControl(localPath); cc != "" {
+		input.CacheControl = aws.String(cc)
+	}
+	if cd := s.resolveContentDisposition(key); cd != "" {
+		input.ContentDisposition = aws.String(cd)
 	}
 	_, err = s.uploader.Upload(ctx, input)
 	if err != nil {
@@ -250,35 +758,30 @@ func (s *S3Syncer) uploadOne(ctx context.Context, localPath string, bucket strin
 	return nil
 }
 
-func joinKey(prefix, rel string) string {
-	rel = strings.ReplaceAll(rel, string(filepath.Separator), "/")
-	prefix = strings.Trim(prefix, "/")
-	if prefix == "" {
-		return rel
+// retryableS3Error reports whether err is worth retrying: throttling, server-side
+// (5xx) and transport errors are; client errors such as 403/404 and local file
+// errors are not.
+func retryableS3Error(ctx context.Context, err error) bool {
+	if ctx.Err() != nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
 	}
-	return prefix + "/" + rel
-}
-
-func detectContentType(path string) string {
-	ext := strings.ToLower(filepath.Ext(path))
-	switch ext {
-	case ".m3u8":
-		return "application/vnd.apple.mpegurl"
-	case ".ts":
-		return "video/mp2t"
-	case ".mp4":
-		return "video/mp4"
-	case ".webm":
-		return "video/webm"
-	case ".jpg", ".jpeg":
-		return "image/jpeg"
-	case ".png":
-		return "image/png"
-	case ".vtt":
-		return "text/vtt"
-	}
-	if ct := mime.TypeByExtension(ext); ct != "" {
-		return ct
-	}
-	return "application/octet-stream"
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		return false
+	}
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.ErrorCode() {
+		case "Throttling", "ThrottlingException", "SlowDown", "RequestTimeout",
+			"RequestTimeTooSkewed", "InternalError", "ServiceUnavailable":
+			return true
+		}
+	}
+	var respErr interface{ HTTPStatusCode() int }
+	if errors.As(err, &respErr) {
+		code := respErr.HTTPStatusCode()
+		return code == 429 || code >= 500
+	}
+	// No HTTP response at all: connection reset, DNS failure, timeout
+	return apiErr == nil
 }
@@ -0,0 +1,87 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestS3Syncer_DownloadFileResumes(t *testing.T) {
+	const partSize = 1024 * 1024
+	object := bytes.Repeat([]byte("0123456789abcdef"), (2*partSize+partSize/2)/16)
+
+	var mu sync.Mutex
+	requests := map[string]int{} // ranged GETs by Range header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		if r.Method == http.MethodHead {
+			w.Header().Set("Content-Length", strconv.Itoa(len(object)))
+			return
+		}
+		rng := r.Header.Get("Range")
+		var start, end int
+		if _, err := fmt.Sscanf(strings.TrimPrefix(rng, "bytes="), "%d-%d", &start, &end); err != nil {
+			http.Error(w, "bad range", http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		requests[rng]++
+		n := requests[rng]
+		mu.Unlock()
+		w.Header().Set("Content-Length", strconv.Itoa(end-start+1))
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, len(object)))
+		w.WriteHeader(http.StatusPartialContent)
+		body := object[start : end+1]
+		if start == partSize && n == 1 {
+			// The connection drops halfway through the second part
+			w.Write(body[:len(body)/2])
+			panic(http.ErrAbortHandler)
+		}
+		w.Write(body)
+	}))
+	defer srv.Close()
+
+	t.Setenv("AWS_EC2_METADATA_DISABLED", "true")
+	s, err := NewS3Syncer(context.Background(), S3Options{
+		Region:              "us-east-1",
+		Endpoint:            srv.URL,
+		UsePathStyle:        true,
+		AccessKeyID:         "test",
+		SecretAccessKey:     "test",
+		DownloadPartSizeMB:  1,
+		DownloadConcurrency: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dest := filepath.Join(t.TempDir(), "src.mp4")
+	if err := s.DownloadFile(context.Background(), "bucket", "key", dest); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, object) {
+		t.Fatalf("downloaded %d bytes that differ from the %d byte object", len(got), len(object))
+	}
+
+	want := map[string]int{
+		fmt.Sprintf("bytes=0-%d", partSize-1):                 1,
+		fmt.Sprintf("bytes=%d-%d", partSize, 2*partSize-1):    2,
+		fmt.Sprintf("bytes=%d-%d", 2*partSize, len(object)-1): 1,
+	}
+	for rng, n := range want {
+		if requests[rng] != n {
+			t.Errorf("%s requested %d times, want %d (all: %v)", rng, requests[rng], n, requests)
+		}
+	}
+}
@@ -19,3 +19,24 @@ type Syncer interface {
 	// FileExists checks if a file exists in object storage at the given bucket and key.
 	FileExists(ctx context.Context, bucket string, key string) (bool, error)
 }
+
+// Backend is the full set of operations the worker needs from object storage.
+// S3Syncer, GCSSyncer and FSSyncer all implement it.
+type Backend interface {
+	Syncer
+
+	// SyncDirectoryWithOptions is SyncDirectory with mirror-delete and change detection.
+	SyncDirectoryWithOptions(ctx context.Context, localDir string, bucket string, prefix string, opts SyncOptions) error
+
+	// DeletePrefix deletes every object under prefix and returns how many were removed.
+	DeletePrefix(ctx context.Context, bucket string, prefix string) (int, error)
+
+	// ObjectSize returns the size in bytes of the object at bucket/key.
+	ObjectSize(ctx context.Context, bucket string, key string) (int64, error)
+}
+
+var (
+	_ Backend = (*S3Syncer)(nil)
+	_ Backend = (*GCSSyncer)(nil)
+	_ Backend = (*FSSyncer)(nil)
+)
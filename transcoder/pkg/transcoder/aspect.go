@@ -0,0 +1,111 @@
+package transcoder
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	ff "transcoder/pkg/ffmpeg"
+)
+
+// AspectMode selects how TranscodeHLS brings a source to the target aspect ratio (see
+// SetAspect).
+type AspectMode string
+
+const (
+	// AspectCrop cuts the source's excess width or height around the center, e.g. the
+	// letterbox bars of a 16:9 master of a scope film.
+	AspectCrop AspectMode = "crop"
+	// AspectPad scales the whole picture to fit and fills the rest with black bars
+	// (letterbox or pillarbox).
+	AspectPad AspectMode = "pad"
+)
+
+// ParseAspect parses an aspect ratio given as "W:H" (e.g. "16:9") or as a decimal
+// ratio (e.g. "2.39").
+func ParseAspect(s string) (float64, error) {
+	s = strings.TrimSpace(s)
+	var aspect float64
+	if w, h, ok := strings.Cut(s, ":"); ok {
+		wf, err1 := strconv.ParseFloat(w, 64)
+		hf, err2 := strconv.ParseFloat(h, 64)
+		if err1 != nil || err2 != nil || hf <= 0 {
+			return 0, fmt.Errorf("invalid aspect ratio %q", s)
+		}
+		aspect = wf / hf
+	} else {
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid aspect ratio %q", s)
+		}
+		aspect = f
+	}
+	if aspect <= 0 || math.IsInf(aspect, 0) || math.IsNaN(aspect) {
+		return 0, fmt.Errorf("invalid aspect ratio %q", s)
+	}
+	return aspect, nil
+}
+
+// SetAspect makes TranscodeHLS normalize every rendition to the display aspect ratio
+// aspect (width / height), cropping or padding sources of other shapes as mode says,
+// so mixed-aspect sources come out alike. 0 keeps each source's own aspect (default).
+func (t *FFmpegTranscoder) SetAspect(aspect float64, mode AspectMode) error {
+	switch mode {
+	case AspectCrop, AspectPad:
+	default:
+		return fmt.Errorf("unknown aspect mode %q", mode)
+	}
+	if aspect < 0 {
+		return fmt.Errorf("invalid aspect ratio %v", aspect)
+	}
+	t.aspect, t.aspectMode = aspect, mode
+	return nil
+}
+
+// aspectWidth returns the even width of a video height pixels high at aspect.
+func aspectWidth(height int, aspect float64) int {
+	return roundEven(int(math.Round(float64(height) * aspect)))
+}
+
+// fitAspect adds the filters that bring a srcW x srcH source to aspect at the given
+// output height (0 keeps the source's). Cropping goes before scaling, so it cuts
+// source pixels; padding goes after, so the bars are added at the output size and the
+// picture isn't scaled twice.
+func fitAspect(fc *ff.FilterChain, srcW, srcH, height int, aspect float64, mode AspectMode) {
+	srcAspect := float64(srcW) / float64(srcH)
+	switch mode {
+	case AspectCrop:
+		// Rounded down to even sizes, so the crop never exceeds the source
+		cw, ch := srcW, srcH
+		if srcAspect > aspect {
+			cw = int(float64(srcH)*aspect) &^ 1
+		} else {
+			ch = int(float64(srcW)/aspect) &^ 1
+		}
+		if cw != srcW || ch != srcH {
+			fc.Crop(cw, ch, (srcW-cw)/2, (srcH-ch)/2)
+		}
+		if height <= 0 {
+			height = ch
+		}
+		fc.Scale(aspectWidth(height, aspect), height)
+	case AspectPad:
+		if height <= 0 {
+			height = srcH
+		}
+		w := aspectWidth(height, aspect)
+		sw, sh := w, height
+		if srcAspect > aspect {
+			sh = roundEven(int(math.Round(float64(w) / srcAspect)))
+		} else {
+			sw = aspectWidth(height, srcAspect)
+		}
+		fc.Scale(sw, sh)
+		if sw != w || sh != height {
+			fc.Pad(w, height, (w-sw)/2, (height-sh)/2, "black")
+		}
+	}
+	// Scaling to a size only close to the source's shape would otherwise leave a
+	// slightly non-square pixel aspect that players honor
+	fc.SquarePixels()
+}
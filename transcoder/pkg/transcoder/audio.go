@@ -0,0 +1,211 @@
+package transcoder
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"path/filepath"
+	ff "transcoder/pkg/ffmpeg"
+	hls "transcoder/pkg/hls"
+
+	"github.com/charmbracelet/log"
+)
+
+// audioGroupID is the EXT-X-MEDIA GROUP-ID shared by all alternate audio renditions.
+const audioGroupID = "aud"
+
+// measureLoudness runs the loudnorm analysis pass on audio stream 0:a:audioIndex (the
+// default stream when negative) and returns the second-pass filter. Silent audio has no
+// measurable loudness, so it yields an empty filter and nil info.
+func (t *FFmpegTranscoder) measureLoudness(ctx context.Context, inputPath string, audioIndex int) (string, *LoudnessInfo, error) {
+	log.Info("measuring source loudness", "target_lufs", loudnessTarget.I, "audio_index", audioIndex)
+	tr := t.job.Trim
+	stats, err := ff.MeasureLoudnessRange(ctx, t.ffmpegPath, inputPath, audioIndex, tr.In, tr.span(), loudnessTarget)
+	if err != nil {
+		return "", nil, fmt.Errorf("measure loudness: %w", err)
+	}
+	log.Info("source loudness measured",
+		"audio_index", audioIndex,
+		"integrated_lufs", stats.InputI,
+		"true_peak_dbtp", stats.InputTP,
+		"lra", stats.InputLRA,
+	)
+	if math.IsInf(stats.InputI, 0) || math.IsNaN(stats.InputI) {
+		log.Warn("source audio is silent, skipping loudness normalization", "audio_index", audioIndex)
+		return "", nil, nil
+	}
+	return loudnessTarget.Filter(stats), &LoudnessInfo{
+		IntegratedLUFS: stats.InputI,
+		TruePeakDBTP:   stats.InputTP,
+		LRA:            stats.InputLRA,
+	}, nil
+}
+
+// transcodeAudioTracks encodes every source audio stream into its own audio-only HLS
+// playlist with the audio settings of ar (see sharedAudioRendition) and adds them to mb
+// as one audio group, tagged with md and each track's language (md's for untagged
+// tracks). The stream flagged default in the source (or the
+// first one) is marked DEFAULT. When loudness normalization is on, each
+// track is measured and normalized separately; the default track's loudness is returned,
+// along with the highest measured average bitrate of the tracks (bits per second, 0 when
+// unknown) for the variants' AVERAGE-BANDWIDTH.
+func (t *FFmpegTranscoder) transcodeAudioTracks(ctx context.Context, inputPath, outDir string, src ff.ProbeInfo, md Metadata, ar Rendition, mb *hls.MasterBuilder) (*LoudnessInfo, int, error) {
+	def := defaultAudioTrack(src.AudioStreams)
+	var loudness *LoudnessInfo
+	avgBandwidth := 0
+	for i, st := range src.AudioStreams {
+		st.Language = audioLanguage(st, md)
+		var audioFilter string
+		if t.loudnessNorm {
+			filter, info, err := t.measureLoudness(ctx, inputPath, st.AudioIndex)
+			if err != nil {
+				return nil, 0, err
+			}
+			audioFilter = filter
+			if i == def {
+				loudness = info
+			}
+		}
+
+		playlist := fmt.Sprintf("a%d.m3u8", st.AudioIndex)
+		segmentPattern := fmt.Sprintf("a%d_%%04d.ts", st.AudioIndex)
+		log.Info("starting HLS audio track", "audio_index", st.AudioIndex, "language", st.Language, "default", i == def)
+		cmd := t.trimmedInput(t.command().Overwrite(true), inputPath).
+			Arg("-map", fmt.Sprintf("0:a:%d", st.AudioIndex)).
+			NoVideo()
+		channels, sampleRate := audioFormat(ar, st)
+		if t.copiesAudio(st, audioFilter, ar) {
+			log.Info("copying HLS audio track, source is already compatible AAC", "audio_index", st.AudioIndex, "bitrate_kbps", st.BitrateBps/1000)
+			cmd.AudioCodec("copy")
+		} else {
+			cmd.AudioFilter(audioFilter).
+				AudioCodec("aac").AudioBitrateKbps(audioKbps(ar)).AudioChannels(channels).AudioRate(sampleRate)
+		}
+		cmd.StreamMetadata("a:0", "language", st.Language).
+			Metadata("title", md.Title).Metadata("language", st.Language).
+			HLS(t.hlsSegSecs, "vod", "independent_segments", filepath.Join(outDir, segmentPattern)).
+			Output(filepath.Join(outDir, playlist))
+		if err := cmd.Run(ctx); err != nil {
+			return nil, 0, fmt.Errorf("ffmpeg HLS audio track %d: %w", st.AudioIndex, err)
+		}
+		if avg, err := hls.AverageBandwidth(filepath.Join(outDir, playlist)); err != nil {
+			log.Warn("measure audio track bandwidth failed", "audio_index", st.AudioIndex, "error", err)
+		} else {
+			avgBandwidth = max(avgBandwidth, avg)
+		}
+
+		mb.AddAudioMedia(hls.AudioMedia{
+			GroupID:    audioGroupID,
+			Name:       audioTrackName(st),
+			Language:   st.Language,
+			URI:        playlist,
+			Channels:   channels,
+			Default:    i == def,
+			Autoselect: true,
+		})
+	}
+	return loudness, avgBandwidth, nil
+}
+
+// SetAudioCopy makes TranscodeHLS copy source audio that is already what it would
+// encode (see hlsCompatibleAudio) instead of re-encoding it, which loses quality and
+// time. Loudness normalization needs a re-encode, so it takes precedence.
+func (t *FFmpegTranscoder) SetAudioCopy(enable bool) {
+	t.audioCopy = enable
+}
+
+// copiesAudio reports whether audio stream st goes into rendition r's output as is:
+// audio copy is on, no filter (loudness normalization) applies and st is compatible.
+func (t *FFmpegTranscoder) copiesAudio(st ff.AudioStream, audioFilter string, r Rendition) bool {
+	return t.audioCopy && audioFilter == "" && hlsCompatibleAudio(st, r)
+}
+
+// hlsCompatibleAudio reports whether st can be stream copied into HLS output where r's
+// AAC would otherwise be encoded: AAC-LC, which every HLS player decodes, already at the
+// channel count and sample rate r's audio is normalized to (see audioFormat), at a
+// known bitrate within r's so the variants' BANDWIDTH stays an upper bound.
+func hlsCompatibleAudio(st ff.AudioStream, r Rendition) bool {
+	channels, sampleRate := audioFormat(r, st)
+	return st.Codec == "aac" && st.Profile == "LC" &&
+		st.Channels == channels && st.SampleRate == sampleRate &&
+		st.BitrateBps > 0 && st.BitrateBps <= int64(audioKbps(r))*1000
+}
+
+// audioFormat returns the channel count and sample rate r's audio is encoded at from
+// source stream st: r's own settings, else the source's, with surround downmixed to
+// stereo and rates above 48 kHz resampled to it (the ladder's AAC bitrates are sized for
+// stereo). A source that wasn't probed gets stereo 48 kHz.
+func audioFormat(r Rendition, st ff.AudioStream) (channels, sampleRate int) {
+	channels, sampleRate = r.AudioChannels, r.AudioSampleRate
+	if channels <= 0 {
+		channels = 2
+		if st.Channels == 1 {
+			channels = 1
+		}
+	}
+	if sampleRate <= 0 {
+		sampleRate = 48000
+		if st.SampleRate > 0 && st.SampleRate < 48000 {
+			sampleRate = st.SampleRate
+		}
+	}
+	return channels, sampleRate
+}
+
+// muxedAudio returns the source audio stream muxed into renditions (the first), or a
+// zero stream when the source has none.
+func muxedAudio(src ff.ProbeInfo) ff.AudioStream {
+	if len(src.AudioStreams) == 0 {
+		return ff.AudioStream{}
+	}
+	return src.AudioStreams[0]
+}
+
+// sharedAudioRendition returns the audio settings of the alternate audio tracks, which
+// every variant plays: the ladder's highest audio bitrate (128 kbps when none is set)
+// and highest channel count and sample rate, or the source's where any rendition keeps
+// them.
+func sharedAudioRendition(ladder []Rendition) Rendition {
+	var shared Rendition
+	keepChannels, keepRate := false, false
+	for _, r := range ladder {
+		shared.AudioBitrateKbps = max(shared.AudioBitrateKbps, r.AudioBitrateKbps)
+		shared.AudioChannels = max(shared.AudioChannels, r.AudioChannels)
+		shared.AudioSampleRate = max(shared.AudioSampleRate, r.AudioSampleRate)
+		keepChannels = keepChannels || r.AudioChannels <= 0
+		keepRate = keepRate || r.AudioSampleRate <= 0
+	}
+	if shared.AudioBitrateKbps <= 0 {
+		shared.AudioBitrateKbps = 128
+	}
+	if keepChannels {
+		shared.AudioChannels = 0
+	}
+	if keepRate {
+		shared.AudioSampleRate = 0
+	}
+	return shared
+}
+
+// defaultAudioTrack returns the position in streams of the track flagged default in the
+// source, or 0 when none is.
+func defaultAudioTrack(streams []ff.AudioStream) int {
+	for i, st := range streams {
+		if st.Default {
+			return i
+		}
+	}
+	return 0
+}
+
+// audioTrackName picks a player-facing name: the stream title, else its language tag,
+// else a numbered fallback.
+func audioTrackName(st ff.AudioStream) string {
+	switch {
+	case st.Title != "":
+		return st.Title
+	case st.Language != "":
+		return st.Language
+	}
+	return fmt.Sprintf("Audio %d", st.AudioIndex+1)
+}
@@ -7,9 +7,14 @@ import (
 	"math"
 	"os"
 	"path/filepath"
+	"regexp"
+	"runtime"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
+	"transcoder/pkg/config"
 	ff "transcoder/pkg/ffmpeg"
 	hls "transcoder/pkg/hls"
 	prev "transcoder/pkg/preview"
@@ -19,6 +24,34 @@ import (
 
 var _ Transcoder = (*FFmpegTranscoder)(nil)
 
+// defaultHoverClipCount is the number of clips stitched into a hover preview when not specified.
+const defaultHoverClipCount = 3
+
+// Scene-change thumbnail tuning: a frame counts as a new scene above sceneChangeThreshold,
+// and fewer than minSceneThumbnails detected scenes falls back to interval sampling.
+const (
+	sceneChangeThreshold = 0.4
+	minSceneThumbnails   = 5
+)
+
+// Smart poster tuning: candidate windows as fractions of the duration (preferred first),
+// how much of each window is analyzed, the overall analysis budget, and the luma (0-255)
+// below which a frame counts as near-black and the contrast below which it is blank.
+var smartPosterFractions = []float64{0.25, 0.4, 0.15, 0.55, 0.7}
+
+const (
+	smartPosterWindow      = 2 * time.Second
+	smartPosterTimeout     = 30 * time.Second
+	smartPosterMinLuma     = 24
+	smartPosterMinContrast = 20
+)
+
+// GIF hover previews are capped to keep the file size sane.
+const (
+	maxGIFWidth = 480
+	maxGIFFPS   = 12
+)
+
 // FFmpegTranscoder implements Transcoder by invoking ffmpeg/ffprobe binaries.
 type FFmpegTranscoder struct {
 	ffmpegPath            string
@@ -26,8 +59,41 @@ type FFmpegTranscoder struct {
 	x264Preset            string
 	hlsSegSecs            int
 	maxParallelRenditions int
+	loudnessNorm          bool
+	thumbnailMode         ThumbnailMode
+	generateBIF           bool
+	thumbnailFormat       ImageFormat
+	thumbnailQuality      int
+	spriteCols            int
+	spriteRows            int
+	thumbnailMosaic       bool
+	watermark             *ff.Watermark
+	subtitleMode          SubtitleMode
+	toneMapMode           ToneMapMode
+	preserve10Bit         bool
+	progressive           bool
+	subtitleLang          string
+	ffmpegLogDir          string
+	probeTimeout          time.Duration
+	singlePass            bool
+	sharedAudio           bool
+	extraArgs             []string
+	renditionThreads      int
+	hlsStartOffset        float64
+	metadataPassthrough   bool
+	renditionDirs         bool
+	audioCopy             bool
+	aspect                float64 // target display aspect, 0 keeps the source's
+	aspectMode            AspectMode
+	fastStart             bool
+	probes                *probeCache
+	workDir               string     // scratch space for intermediate files, see SetWorkDir
+	job                   JobOptions // see ForJob
 }
 
+// loudnessTarget is the EBU R128 target applied when loudness normalization is enabled.
+var loudnessTarget = ff.LoudnormTarget{I: -16, TP: -1.5, LRA: 11}
+
 func NewFFmpegTranscoder(ffmpegPath, ffprobePath string) *FFmpegTranscoder {
 	return &FFmpegTranscoder{
 		ffmpegPath:            defaultIfEmpty(ffmpegPath, "ffmpeg"),
@@ -35,9 +101,31 @@ func NewFFmpegTranscoder(ffmpegPath, ffprobePath string) *FFmpegTranscoder {
 		x264Preset:            "veryfast",
 		hlsSegSecs:            4,
 		maxParallelRenditions: 2, // Default to 2 parallel renditions
+		thumbnailMode:         ThumbnailModeInterval,
+		thumbnailFormat:       ImageFormatJPEG,
+		thumbnailQuality:      defaultJPEGQuality,
+		subtitleMode:          SubtitleModeOff,
+		toneMapMode:           ToneMapAuto,
+		probeTimeout:          defaultProbeTimeout,
+		probes:                newProbeCache(),
 	}
 }
 
+// Clone returns a copy of t to change settings on without affecting operations already
+// running on t. The copy shares t's probe cache.
+func (t *FFmpegTranscoder) Clone() *FFmpegTranscoder {
+	c := *t
+	return &c
+}
+
+// ForJob returns a copy of t whose operations apply opts. The copy shares t's probe
+// cache, which holds whole-source probes whatever the trim.
+func (t *FFmpegTranscoder) ForJob(opts JobOptions) Transcoder {
+	c := t.Clone()
+	c.job = opts
+	return c
+}
+
 // SetMaxParallelRenditions configures the maximum number of renditions to encode in parallel
 func (t *FFmpegTranscoder) SetMaxParallelRenditions(max int) {
 	if max > 0 {
@@ -45,134 +133,566 @@ func (t *FFmpegTranscoder) SetMaxParallelRenditions(max int) {
 	}
 }
 
+// SetRenditionThreads sets the encoder threads of every rendition that doesn't set its
+// own. 0 (the default) splits the CPUs evenly between the renditions encoded at once,
+// so parallel encodes don't each start a thread per core.
+func (t *FFmpegTranscoder) SetRenditionThreads(n int) {
+	t.renditionThreads = max(n, 0)
+}
+
+// SetSinglePassHLS makes TranscodeHLS encode the whole ladder from one ffmpeg process
+// that decodes the source once (see TranscodeHLSSinglePass), instead of one process per
+// rendition.
+func (t *FFmpegTranscoder) SetSinglePassHLS(enabled bool) {
+	t.singlePass = enabled
+}
+
+// SetSharedAudio encodes a source's only audio track once, into an audio-only playlist
+// that every variant references as its EXT-X-MEDIA audio group, instead of once per
+// rendition. Sources with several tracks always get alternate audio.
+func (t *FFmpegTranscoder) SetSharedAudio(enabled bool) {
+	t.sharedAudio = enabled
+}
+
+// SetHLSStartOffset makes the master playlist ask players to start playback offset
+// seconds in (EXT-X-START), or offset seconds before the end when negative; 0 starts at
+// the beginning.
+func (t *FFmpegTranscoder) SetHLSStartOffset(offset float64) {
+	t.hlsStartOffset = offset
+}
+
+// SetProbeTimeout bounds each ffprobe run, so a source ffprobe chokes on can't hold a
+// job forever; non-positive values are ignored
+func (t *FFmpegTranscoder) SetProbeTimeout(d time.Duration) {
+	if d > 0 {
+		t.probeTimeout = d
+	}
+}
+
+// SetHLSSegmentSeconds sets the target HLS segment duration; non-positive values are ignored
+func (t *FFmpegTranscoder) SetHLSSegmentSeconds(secs int) {
+	if secs > 0 {
+		t.hlsSegSecs = secs
+	}
+}
+
+// SetX264Preset sets the libx264 speed preset used for video encodes
+func (t *FFmpegTranscoder) SetX264Preset(preset string) error {
+	if !ff.ValidX264Preset(preset) {
+		return fmt.Errorf("unknown x264 preset %q (want one of %s)", preset, strings.Join(ff.X264Presets, ", "))
+	}
+	t.x264Preset = preset
+	return nil
+}
+
+// SetLoudnessNorm enables two-pass EBU R128 loudness normalization of the HLS audio
+func (t *FFmpegTranscoder) SetLoudnessNorm(enable bool) {
+	t.loudnessNorm = enable
+}
+
+// SetGenerateBIF enables writing a Roku BIF trick-play file next to the thumbnails VTT
+func (t *FFmpegTranscoder) SetGenerateBIF(enabled bool) {
+	t.generateBIF = enabled
+}
+
+// SetThumbnailFormat sets the image format of scrubber thumbnails (JPEG by default)
+func (t *FFmpegTranscoder) SetThumbnailFormat(f ImageFormat) error {
+	if f.Ext() == "" {
+		return fmt.Errorf("unknown image format %q", f)
+	}
+	t.thumbnailFormat = f
+	return nil
+}
+
+// SetThumbnailQuality sets the JPEG quality of scrubber thumbnails as an ffmpeg -q:v
+// value, 2 (best, the default) to 31 (smallest); out of range values are ignored.
+// WebP and AVIF thumbnails keep their fixed settings.
+func (t *FFmpegTranscoder) SetThumbnailQuality(q int) {
+	if q >= 2 && q <= 31 {
+		t.thumbnailQuality = q
+	}
+}
+
+// SetThumbnailSprites packs scrubber thumbnails into cols x rows sprite sheets, with the
+// VTT cues pointing into the sheets, instead of writing one file per thumbnail. Zero
+// (or negative) dimensions write individual files.
+func (t *FFmpegTranscoder) SetThumbnailSprites(cols, rows int) {
+	if cols <= 0 || rows <= 0 {
+		cols, rows = 0, 0
+	}
+	t.spriteCols, t.spriteRows = cols, rows
+}
+
+// SetThumbnailMosaic packs all scrubber thumbnails into a single sprite image whose grid
+// is sized from the thumbnail count and size, with the VTT cues pointing into it. It
+// takes precedence over SetThumbnailSprites.
+func (t *FFmpegTranscoder) SetThumbnailMosaic(enabled bool) {
+	t.thumbnailMosaic = enabled
+}
+
+// SetWatermark burns w into every HLS rendition; nil disables the watermark
+func (t *FFmpegTranscoder) SetWatermark(w *ff.Watermark) {
+	t.watermark = w
+}
+
+// SetToneMapMode configures HDR-to-SDR tone mapping of HLS renditions
+func (t *FFmpegTranscoder) SetToneMapMode(mode ToneMapMode) {
+	switch mode {
+	case ToneMapAuto, ToneMapOff:
+		t.toneMapMode = mode
+	}
+}
+
+// SetPreserve10Bit keeps 10-bit sources at 10-bit 4:2:0 in VP9/AV1 renditions instead of
+// reducing them to 8-bit. H.264 renditions are always 8-bit 4:2:0.
+func (t *FFmpegTranscoder) SetPreserve10Bit(enabled bool) {
+	t.preserve10Bit = enabled
+}
+
+// SetProgressivePlaylists makes TranscodeHLS write renditions as EVENT playlists that
+// grow segment by segment (segments and playlists are renamed into place once complete)
+// and a provisional master playlist before encoding starts, so output can be published
+// while it is encoded. Finished playlists are rewritten as VOD.
+func (t *FFmpegTranscoder) SetProgressivePlaylists(enabled bool) {
+	t.progressive = enabled
+}
+
+// SetRenditionDirs makes TranscodeHLS write each rendition's playlist and segments into
+// a subdirectory of its own (e.g. "720/v720.m3u8" and "720/seg_0001.ts") instead of
+// flat into the output directory; the master playlist refers to the subpaths.
+func (t *FFmpegTranscoder) SetRenditionDirs(enabled bool) {
+	t.renditionDirs = enabled
+}
+
+// SetFastStart makes TranscodeHLS encode the ladder's lowest H.264 rendition on its own
+// before the others, and, unless progressive playlists are on (which publish every
+// rendition as it grows), write master.m3u8 as soon as it is done, rewriting it as each
+// further rendition completes. Every version of the master lists only finished
+// renditions, so it can be published as is and playback starts at low resolution while
+// higher qualities are still encoding.
+func (t *FFmpegTranscoder) SetFastStart(enabled bool) {
+	t.fastStart = enabled
+}
+
+// SetFFmpegLogDir keeps the full stderr of every failed ffmpeg run in a file under dir;
+// logs of successful runs are deleted. Empty disables the log files.
+func (t *FFmpegTranscoder) SetFFmpegLogDir(dir string) {
+	t.ffmpegLogDir = dir
+}
+
+// SetWorkDir sets where intermediate files that never reach the output, such as GIF
+// palettes and thumbnails packed into sprites, are written. Empty uses the system
+// temp directory.
+func (t *FFmpegTranscoder) SetWorkDir(dir string) {
+	t.workDir = dir
+}
+
+// SetFFmpegExtraArgs adds args as global options (before the inputs) to every ffmpeg
+// encode, e.g. "-threads", "4". Analysis runs (loudness, scene detection) don't get them.
+func (t *FFmpegTranscoder) SetFFmpegExtraArgs(args []string) {
+	t.extraArgs = slices.Clone(args)
+}
+
+// command starts an ffmpeg invocation with the transcoder-wide options applied.
+func (t *FFmpegTranscoder) command() *ff.Command {
+	return ff.New(t.ffmpegPath).LogToDir(t.ffmpegLogDir).GlobalArg(t.extraArgs...)
+}
+
+// SetSubtitles configures handling of embedded subtitles. lang (ISO 639-1 or 639-2,
+// e.g. "en" or "eng") selects the stream to burn in, or limits which streams are
+// passed through; empty picks the default stream / passes through all of them.
+func (t *FFmpegTranscoder) SetSubtitles(mode SubtitleMode, lang string) {
+	switch mode {
+	case SubtitleModeOff, SubtitleModeBurn, SubtitleModePassthrough:
+		t.subtitleMode = mode
+	}
+	t.subtitleLang = lang
+}
+
+// SetThumbnailMode configures how scrubber thumbnail times are chosen
+func (t *FFmpegTranscoder) SetThumbnailMode(mode ThumbnailMode) {
+	switch mode {
+	case ThumbnailModeInterval, ThumbnailModeScene:
+		t.thumbnailMode = mode
+	}
+}
+
 func (t *FFmpegTranscoder) ProbeVideo(ctx context.Context, inputPath string) (VideoInfo, error) {
-	info, err := ff.Probe(ctx, t.ffprobePath, inputPath)
+	info, err := t.probe(ctx, inputPath)
 	if err != nil {
 		return VideoInfo{}, err
 	}
+	// A still image probes with no duration; it is a one-frame video (see IsStill)
+	if info.Width <= 0 || info.Height <= 0 {
+		return VideoInfo{}, fmt.Errorf("%w: no video stream", ErrUnprobeable)
+	}
 	return VideoInfo{
-		Width:        info.Width,
-		Height:       info.Height,
-		DurationSec:  info.DurationSec,
-		AvgFrameRate: info.AvgFrameRate,
+		Width:             info.Width,
+		Height:            info.Height,
+		DurationSec:       info.DurationSec,
+		AvgFrameRate:      info.AvgFrameRate,
+		BitrateBps:        info.BitrateBps,
+		HasAudio:          info.HasAudio,
+		VariableFrameRate: info.VariableFrameRate,
 	}, nil
 }
 
-func (t *FFmpegTranscoder) TranscodeHLS(ctx context.Context, inputPath, outDir string, ladder []Rendition) error {
+// TranscodeHLS encodes the ladder with one ffmpeg process per rendition, at most
+// SetMaxParallelRenditions at a time, or in a single process when SetSinglePassHLS is
+// enabled.
+func (t *FFmpegTranscoder) TranscodeHLS(ctx context.Context, inputPath, outDir string, ladder []Rendition, progress ProgressFunc) (HLSResult, error) {
+	if t.singlePass {
+		return t.TranscodeHLSSinglePass(ctx, inputPath, outDir, ladder, progress)
+	}
+	return t.transcodeHLS(ctx, inputPath, outDir, ladder, progress, t.encodeRenditions)
+}
+
+// TranscodeHLSSinglePass is TranscodeHLS with every rendition encoded by one ffmpeg
+// process: the source is decoded once and the frames split between the renditions'
+// filter chains, instead of each rendition decoding it again. The renditions finish
+// together, at the pace of the slowest encoder, and a failure of any of them fails all.
+func (t *FFmpegTranscoder) TranscodeHLSSinglePass(ctx context.Context, inputPath, outDir string, ladder []Rendition, progress ProgressFunc) (HLSResult, error) {
+	return t.transcodeHLS(ctx, inputPath, outDir, ladder, progress, t.encodeRenditionsSinglePass)
+}
+
+// hlsPlan is what transcodeHLS works out about the source before encoding: the
+// settings every rendition is encoded with.
+type hlsPlan struct {
+	inputPath         string
+	outDir            string
+	renditionDirs     bool // each rendition in its own subdirectory, see SetRenditionDirs
+	srcInfo           ff.ProbeInfo
+	altAudio          bool // audio is served from the alternate audio group, not muxed
+	audioFilter       string
+	audioGroup        string
+	audioAvgBandwidth int // bits per second of the largest alternate audio track
+	subtitleGroup     string
+	burnSubtitle      int // subtitle stream to burn in, or -1
+	tonemap           bool
+	playlistType      string
+	hlsFlags          string
+	metadata          Metadata
+	audioLanguage     string // language tag of the muxed audio track, when not altAudio
+}
+
+// renditionEncoder encodes the ladder's renditions into plan.outDir, reporting its
+// progress to progress and calling done after each rendition that finished successfully.
+type renditionEncoder func(ctx context.Context, plan *hlsPlan, ladder []Rendition, progress ProgressFunc, done func(Rendition) error) error
+
+func (t *FFmpegTranscoder) transcodeHLS(ctx context.Context, inputPath, outDir string, ladder []Rendition, progress ProgressFunc, encode renditionEncoder) (HLSResult, error) {
+	var result HLSResult
 	if len(ladder) == 0 {
-		return errors.New("ladder must contain at least one rendition")
+		return result, errors.New("ladder must contain at least one rendition")
+	}
+	for _, r := range ladder {
+		if !r.Codec.Valid() {
+			return result, fmt.Errorf("rendition %dp: unsupported codec %q", r.Height, r.Codec)
+		}
+		if err := validateProfileLevel(r); err != nil {
+			return result, fmt.Errorf("rendition %dp: %w", r.Height, err)
+		}
 	}
 	if err := os.MkdirAll(outDir, 0o755); err != nil {
-		return fmt.Errorf("create out dir: %w", err)
+		return result, fmt.Errorf("create out dir: %w", err)
+	}
+	plan := &hlsPlan{inputPath: inputPath, outDir: outDir, renditionDirs: t.renditionDirs, burnSubtitle: -1}
+	if plan.renditionDirs {
+		for _, r := range ladder {
+			playlist, _, _ := plan.layout(r)
+			if err := os.MkdirAll(filepath.Join(outDir, filepath.Dir(filepath.FromSlash(playlist))), 0o755); err != nil {
+				return result, fmt.Errorf("create rendition dir: %w", err)
+			}
+		}
+	}
+	srcInfo, _ := t.probe(ctx, inputPath)
+	plan.srcInfo = srcInfo
+
+	// Sources with several audio tracks get video-only renditions plus one audio-only
+	// playlist per track, so players can offer a language menu. With shared audio a
+	// single track goes the same way, so it is encoded once rather than per rendition.
+	plan.altAudio = len(srcInfo.AudioStreams) > 1 || (t.sharedAudio && len(srcInfo.AudioStreams) == 1)
+
+	// Tagged into every output so players can label the tracks; the muxed audio is the
+	// source's first track
+	plan.metadata = t.outputMetadata(srcInfo)
+	if !plan.altAudio && len(srcInfo.AudioStreams) > 0 {
+		plan.audioLanguage = audioLanguage(srcInfo.AudioStreams[0], plan.metadata)
+	}
+
+	// Measure loudness once up front; every rendition applies the same linear gain.
+	// Alternate audio tracks are each measured on their own in transcodeAudioTracks.
+	if t.loudnessNorm && !plan.altAudio {
+		if !srcInfo.HasAudio {
+			log.Info("skipping loudness normalization, source has no audio")
+		} else {
+			filter, info, err := t.measureLoudness(ctx, inputPath, -1)
+			if err != nil {
+				return result, err
+			}
+			plan.audioFilter, result.Loudness = filter, info
+		}
+	}
+
+	// Every segment starts on a forced keyframe (see the independent_segments hls_flags)
+	mb := hls.NewMaster().Version(3).IndependentSegments()
+	if off := t.hlsStartOffset; off != 0 {
+		if srcInfo.DurationSec > 0 && math.Abs(off) >= srcInfo.DurationSec {
+			log.Info("skipping playlist start offset, source is too short", "offset_sec", off, "duration_sec", srcInfo.DurationSec)
+		} else {
+			mb.Start(off, false)
+		}
+	}
+
+	if plan.altAudio {
+		shared := sharedAudioRendition(ladder)
+		loudness, avg, err := t.transcodeAudioTracks(ctx, inputPath, outDir, srcInfo, plan.metadata, shared, mb)
+		if err != nil {
+			return result, err
+		}
+		result.Loudness = loudness
+		plan.audioAvgBandwidth = avg
+		plan.audioGroup = audioGroupID
+		// Every variant plays the group's tracks, so that's the audio bitrate it peaks at
+		ladder = withAudioBitrate(ladder, shared.AudioBitrateKbps)
 	}
-	srcInfo, _ := ff.Probe(ctx, t.ffprobePath, inputPath)
-	mb := hls.NewMaster().Version(3)
 
+	plan.tonemap = t.toneMapMode == ToneMapAuto && srcInfo.IsHDR()
+	if plan.tonemap {
+		log.Info("HDR source, tone mapping renditions to SDR",
+			"transfer", srcInfo.ColorTransfer,
+			"primaries", srcInfo.ColorPrimaries,
+		)
+	}
+
+	if t.aspect > 0 && srcInfo.Width > 0 && srcInfo.Height > 0 {
+		log.Info("normalizing renditions to the target aspect ratio",
+			"source", fmt.Sprintf("%dx%d", srcInfo.Width, srcInfo.Height),
+			"aspect", fmt.Sprintf("%.3f", t.aspect),
+			"mode", t.aspectMode,
+		)
+	}
+
+	if srcInfo.VariableFrameRate {
+		log.Info("variable frame rate source, converting renditions to a constant rate",
+			"avg_frame_rate", srcInfo.AvgFrameRate,
+			"r_frame_rate", srcInfo.RealFrameRate,
+		)
+	}
+
+	switch t.subtitleMode {
+	case SubtitleModePassthrough:
+		result.Subtitles = t.extractSubtitles(ctx, inputPath, outDir, srcInfo, mb)
+		if len(result.Subtitles) > 0 {
+			plan.subtitleGroup = subtitleGroupID
+		}
+	case SubtitleModeBurn:
+		if st, ok := ff.SelectSubtitle(srcInfo.Subtitles, t.subtitleLang); ok {
+			log.Info("burning in subtitles", "index", st.Index, "language", st.Language, "codec", st.Codec)
+			plan.burnSubtitle = st.SubIndex
+		} else {
+			log.Warn("no text subtitle stream to burn in", "language", t.subtitleLang, "streams", len(srcInfo.Subtitles))
+		}
+	}
+
+	plan.playlistType, plan.hlsFlags = "vod", "independent_segments"
+	if t.progressive {
+		// ffmpeg only writes VOD playlists at the end; EVENT ones after every segment
+		plan.playlistType, plan.hlsFlags = "event", "independent_segments+temp_file"
+		provisional := mb.Clone()
+		for _, r := range ladder {
+			playlist, _, _ := plan.layout(r)
+			provisional.AddVariant(playlist, variantAttrs(r, srcInfo, t.aspect, plan.audioGroup, plan.subtitleGroup))
+		}
+		if err := provisional.WriteFile(filepath.Join(outDir, "master.m3u8")); err != nil {
+			return result, fmt.Errorf("write provisional master playlist: %w", err)
+		}
+	}
+
+	var mu sync.Mutex
+	done := func(r Rendition) error {
+		playlist, _, _ := plan.layout(r)
+		playlistPath := filepath.Join(outDir, filepath.FromSlash(playlist))
+		if t.progressive {
+			if err := hls.SetPlaylistType(playlistPath, "VOD"); err != nil {
+				return fmt.Errorf("finalize %s: %w", playlist, err)
+			}
+		}
+		log.Info("HLS rendition complete", "height", r.Height, "codec", r.Codec.encoder())
+
+		codecs := t.variantCodecs(ctx, playlistPath, plan.altAudio)
+		// Measured from the segments written; BANDWIDTH stays the configured peak
+		avgBandwidth, err := hls.AverageBandwidth(playlistPath)
+		if err != nil {
+			log.Warn("measure rendition bandwidth failed, omitting AVERAGE-BANDWIDTH", "height", r.Height, "error", err)
+			avgBandwidth = 0
+		} else if plan.altAudio {
+			avgBandwidth += plan.audioAvgBandwidth
+		}
+
+		attrs := variantAttrs(r, srcInfo, t.aspect, plan.audioGroup, plan.subtitleGroup)
+		attrs.AverageBandwidth = avgBandwidth
+		attrs.Codecs = codecs
+
+		// Protect shared master playlist builder with mutex
+		mu.Lock()
+		defer mu.Unlock()
+		mb.AddVariant(playlist, attrs)
+		result.Variants = append(result.Variants, VariantInfo{
+			Height:           r.Height,
+			Playlist:         playlist,
+			Bandwidth:        attrs.Bandwidth,
+			AverageBandwidth: avgBandwidth,
+			Codecs:           codecs,
+		})
+		if t.fastStart && !t.progressive {
+			// Only finished renditions so far; the media groups were written up front
+			if err := mb.WriteFile(filepath.Join(outDir, "master.m3u8")); err != nil {
+				return fmt.Errorf("write master playlist: %w", err)
+			}
+		}
+		return nil
+	}
+	var err error
+	if t.fastStart && len(ladder) > 1 {
+		err = t.encodeFastStart(ctx, plan, ladder, encode, progress, done)
+	} else {
+		err = encode(ctx, plan, ladder, progress, done)
+	}
+	if err != nil {
+		return result, err
+	}
+	slices.SortFunc(result.Variants, func(a, b VariantInfo) int { return a.Bandwidth - b.Bandwidth })
+
+	if err := mb.WriteFile(filepath.Join(outDir, "master.m3u8")); err != nil {
+		return result, fmt.Errorf("write master playlist: %w", err)
+	}
+	return result, nil
+}
+
+// encodeFastStart encodes the ladder's fast start rendition (see SetFastStart) on its
+// own with encode, then the rest. Each rendition counts for an equal share of progress.
+func (t *FFmpegTranscoder) encodeFastStart(ctx context.Context, plan *hlsPlan, ladder []Rendition, encode renditionEncoder, progress ProgressFunc, done func(Rendition) error) error {
+	i := fastStartRendition(ladder)
+	first := ladder[i]
+	rest := slices.Delete(slices.Clone(ladder), i, i+1)
+	log.Info("encoding fast start rendition first", "height", first.Height, "codec", first.Codec.encoder())
+	share := 100 / float64(len(ladder))
+	if err := encode(ctx, plan, []Rendition{first}, progress.scaled(0, share), done); err != nil {
+		return err
+	}
+	return encode(ctx, plan, rest, progress.scaled(share, 100), done)
+}
+
+// fastStartRendition returns the index of the rendition to encode first for a fast
+// start: the lowest H.264 one, which is quickest to encode and plays everywhere, else
+// the lowest of any codec. Source height renditions (0) count as the highest.
+func fastStartRendition(ladder []Rendition) int {
+	best := -1
+	lower := func(a, b Rendition) bool {
+		if a.Codec.isH264() != b.Codec.isH264() {
+			return a.Codec.isH264()
+		}
+		if (a.Height > 0) != (b.Height > 0) {
+			return a.Height > 0
+		}
+		if a.Height != b.Height {
+			return a.Height < b.Height
+		}
+		return a.VideoBitrateKbps < b.VideoBitrateKbps
+	}
+	for i, r := range ladder {
+		if best < 0 || lower(r, ladder[best]) {
+			best = i
+		}
+	}
+	return best
+}
+
+// encodeRenditions runs one ffmpeg process per rendition, at most maxParallelRenditions
+// at once.
+func (t *FFmpegTranscoder) encodeRenditions(ctx context.Context, plan *hlsPlan, ladder []Rendition, progress ProgressFunc, done func(Rendition) error) error {
 	var wg sync.WaitGroup
 	var mu sync.Mutex
 	errChan := make(chan error, len(ladder))
 
-	// Semaphore to limit parallel renditions
+	// Overall progress is the mean of the renditions' progress
+	renditionPercent := make([]float64, len(ladder))
+	setRenditionPercent := func(i int, percent float64) {
+		mu.Lock()
+		renditionPercent[i] = percent
+		total := 0.0
+		for _, p := range renditionPercent {
+			total += p
+		}
+		mu.Unlock()
+		progress.report(total / float64(len(renditionPercent)))
+	}
+
+	// Semaphore to limit parallel renditions (also what keeps slow AV1 encodes from
+	// piling up: they take a slot like any other rendition)
 	renditionSem := make(chan struct{}, t.maxParallelRenditions)
 
-	for _, r := range ladder {
+	for i, r := range ladder {
 		wg.Add(1)
 		renditionSem <- struct{}{} // Acquire semaphore
-		go func(r Rendition) {
+		go func(i int, r Rendition) {
 			defer wg.Done()
 			defer func() { <-renditionSem }() // Release semaphore
 
 			// Log start of rendition processing
 			log.Info("starting HLS rendition",
 				"height", r.Height,
+				"codec", r.Codec.encoder(),
 				"bitrate_kbps", r.VideoBitrateKbps,
 				"crf", r.CRF,
 			)
 
-			playlist := fmt.Sprintf("v%d.m3u8", r.Height)
-			segmentPattern := fmt.Sprintf("v%d_%%04d.ts", r.Height)
-			cmd := ff.New(t.ffmpegPath).Overwrite(true).Input(inputPath)
-			fc := ff.NewFilterChain()
-			if r.Height > 0 {
-				fc.ScaleToHeight(r.Height)
-			}
-			if r.FPS > 0 {
-				fc.FPS(r.FPS)
-			}
-			cmd.FilterChain(fc)
-			cmd.VideoCodec("libx264").Preset(t.x264Preset).CRF(r.CRF)
-
-			if r.VideoBitrateKbps > 0 {
-				cmd.VideoBitrateKbps(r.VideoBitrateKbps).
-					MaxrateKbps(r.VideoBitrateKbps).
-					BufsizeKbps(r.VideoBitrateKbps * 2)
-			}
-			g := r.KeyframeInterval
-			if g <= 0 {
-				// default to ~2s GOP based on FPS when available
-				fps := r.FPS
-				if fps <= 0 && srcInfo.AvgFrameRate > 0 {
-					fps = int(math.Round(srcInfo.AvgFrameRate))
+			cmd := t.trimmedInput(t.command().Overwrite(true), plan.inputPath)
+			fc := t.renditionFilter(ctx, plan, r)
+			if t.watermark != nil {
+				// The overlay runs after scaling so the logo is sized per rendition
+				cmd.Input(t.watermark.ImagePath).
+					Arg("-filter_complex", t.watermark.FilterComplex(fc.String(), watermarkHeight(plan, r))).
+					Arg("-map", "[vout]")
+				if !plan.altAudio {
+					cmd.Arg("-map", "0:a:0?")
 				}
-				if fps <= 0 {
-					fps = 24
+			} else {
+				cmd.FilterChain(fc)
+				if plan.altAudio {
+					cmd.Arg("-map", "0:v:0")
 				}
-				g = fps * 2
 			}
-			cmd.GOP(g)
-			ab := r.AudioBitrateKbps
-			if ab <= 0 {
-				ab = 128
-			}
-			cmd.AudioCodec("aac").AudioBitrateKbps(ab).AudioChannels(2).AudioRate(48000)
-			cmd.HLS(t.hlsSegSecs, "vod", "independent_segments", filepath.Join(outDir, segmentPattern)).
-				Output(filepath.Join(outDir, playlist))
+			t.renditionOutput(cmd, plan, r, min(t.maxParallelRenditions, len(ladder)))
 
 			// Add progress callback if we have duration info
-			if srcInfo.DurationSec > 0 {
-				cmd.WithProgress(srcInfo.DurationSec, func(percent float64, position string, speed string) {
+			if plan.srcInfo.DurationSec > 0 {
+				cmd.WithProgress(plan.srcInfo.DurationSec, func(percent float64, position string, speed string) {
 					log.Info("HLS rendition progress",
 						"height", r.Height,
+						"codec", r.Codec.encoder(),
 						"percent", fmt.Sprintf("%.1f%%", percent),
 						"position", position,
 						"speed", speed,
 					)
+					setRenditionPercent(i, percent)
 				})
 			}
 
 			if err := cmd.Run(ctx); err != nil {
 				log.Error("HLS rendition failed",
 					"height", r.Height,
+					"codec", r.Codec.encoder(),
 					"error", err,
 				)
-				errChan <- fmt.Errorf("ffmpeg HLS %dp: %w", r.Height, err)
+				errChan <- fmt.Errorf("ffmpeg HLS %dp %s: %w", r.Height, r.Codec.encoder(), err)
 				return
 			}
-			log.Info("HLS rendition complete", "height", r.Height)
-			bandwidth := r.VideoBitrateKbps
-			if bandwidth <= 0 {
-				bandwidth = estimateBitrateForHeight(r.Height)
-			}
-			bandwidth += ab
-			width := 0
-			if srcInfo.Width > 0 && srcInfo.Height > 0 && r.Height > 0 {
-				width = roundEven(int(float64(r.Height) * float64(srcInfo.Width) / float64(srcInfo.Height)))
+			setRenditionPercent(i, 100)
+			if err := done(r); err != nil {
+				errChan <- err
 			}
-			frameRate := r.FPS
-			if frameRate <= 0 {
-				frameRate = int(math.Round(srcInfo.AvgFrameRate))
-			}
-
-			// Protect shared master playlist builder with mutex
-			mu.Lock()
-			mb.AddVariant(playlist, hls.StreamInfAttr{
-				Bandwidth:   bandwidth * 1000,
-				ResolutionW: max(width, 0),
-				ResolutionH: r.Height,
-				FrameRate:   float64(max(frameRate, 0)),
-			})
-			mu.Unlock()
-		}(r)
+		}(i, r)
 	}
 
 	// Wait for all renditions to complete
@@ -180,36 +700,349 @@ func (t *FFmpegTranscoder) TranscodeHLS(ctx context.Context, inputPath, outDir s
 	close(errChan)
 
 	// Check for any errors
-	if err := <-errChan; err != nil {
-		return err
+	return <-errChan
+}
+
+// encodeRenditionsSinglePass encodes every rendition from one ffmpeg process, which
+// decodes the source once and splits the frames between the renditions.
+func (t *FFmpegTranscoder) encodeRenditionsSinglePass(ctx context.Context, plan *hlsPlan, ladder []Rendition, progress ProgressFunc, done func(Rendition) error) error {
+	chains := make([]string, len(ladder))
+	heights := make([]int, len(ladder))
+	for i, r := range ladder {
+		chains[i] = t.renditionFilter(ctx, plan, r).String()
+		heights[i] = watermarkHeight(plan, r)
+		log.Info("starting HLS rendition",
+			"height", r.Height,
+			"codec", r.Codec.encoder(),
+			"bitrate_kbps", r.VideoBitrateKbps,
+			"crf", r.CRF,
+			"single_pass", true,
+		)
 	}
 
-	if err := mb.WriteFile(filepath.Join(outDir, "master.m3u8")); err != nil {
-		return fmt.Errorf("write master playlist: %w", err)
+	cmd := t.trimmedInput(t.command().Overwrite(true), plan.inputPath)
+	if t.watermark != nil {
+		cmd.Input(t.watermark.ImagePath)
+	}
+	cmd.Arg("-filter_complex", singlePassFilter(chains, t.watermark, heights))
+	for i, r := range ladder {
+		cmd.Arg("-map", fmt.Sprintf("[v%d]", i))
+		if !plan.altAudio {
+			cmd.Arg("-map", "0:a:0?")
+		}
+		t.renditionOutput(cmd, plan, r, len(ladder))
+	}
+	if plan.srcInfo.DurationSec > 0 {
+		cmd.WithProgress(plan.srcInfo.DurationSec, func(percent float64, position string, speed string) {
+			log.Info("HLS single pass progress",
+				"renditions", len(ladder),
+				"percent", fmt.Sprintf("%.1f%%", percent),
+				"position", position,
+				"speed", speed,
+			)
+			progress.report(percent)
+		})
+	}
+
+	if err := cmd.Run(ctx); err != nil {
+		// ffmpeg names the output (rendition) whose encoder or muxer gave up
+		if i, ok := failedOutput(err.Error()); ok && i < len(ladder) {
+			r := ladder[i]
+			log.Error("HLS rendition failed", "height", r.Height, "codec", r.Codec.encoder(), "single_pass", true, "error", err)
+			return fmt.Errorf("ffmpeg HLS single pass, %dp %s: %w", r.Height, r.Codec.encoder(), err)
+		}
+		names := make([]string, len(ladder))
+		for i, r := range ladder {
+			names[i] = renditionName(r)
+		}
+		log.Error("HLS single pass failed", "renditions", names, "error", err)
+		return fmt.Errorf("ffmpeg HLS single pass (%s): %w", strings.Join(names, ", "), err)
+	}
+	progress.report(100)
+	for _, r := range ladder {
+		if err := done(r); err != nil {
+			return err
+		}
 	}
 	return nil
 }
 
-func (t *FFmpegTranscoder) GeneratePoster(ctx context.Context, inputPath, outPath string, at time.Duration, width int) error {
+// renditionFilter returns the video filters of rendition r: aspect cropping or padding,
+// scaling, frame rate, tone mapping and burnt-in subtitles.
+func (t *FFmpegTranscoder) renditionFilter(ctx context.Context, plan *hlsPlan, r Rendition) *ff.FilterChain {
+	fc := ff.NewFilterChain()
+	if t.aspect > 0 && plan.srcInfo.Width > 0 && plan.srcInfo.Height > 0 {
+		fitAspect(fc, plan.srcInfo.Width, plan.srcInfo.Height, r.Height, t.aspect, t.aspectMode)
+	} else if r.Height > 0 {
+		fc.ScaleToHeight(r.Height)
+	}
+	if r.FPS > 0 {
+		fc.FPS(r.FPS)
+	} else if plan.srcInfo.VariableFrameRate {
+		// Frames at the timestamps they're shown, evenly spaced: players and the
+		// keyframe interval assume a constant rate, and audio stays in sync with it
+		fc.FPS(renditionFPS(plan.srcInfo, r))
+	}
+	if plan.tonemap {
+		// After scaling: tonemapping is per-pixel and far cheaper at the rendition size
+		fc.TonemapToSDR()
+	}
+	if plan.burnSubtitle >= 0 {
+		// Rendered at the scaled size so text stays sharp in every rendition
+		fc.SubtitlesFrom(plan.inputPath, plan.burnSubtitle, t.job.Trim.In)
+	}
+	return fc
+}
+
+// renditionFPS returns the frame rate rendition r is encoded at: its configured rate,
+// else the source's average rounded to a whole number, else 24.
+func renditionFPS(src ff.ProbeInfo, r Rendition) int {
+	fps := r.FPS
+	if fps <= 0 && src.AvgFrameRate > 0 {
+		fps = int(math.Round(src.AvgFrameRate))
+	}
+	if fps <= 0 {
+		fps = 24
+	}
+	return fps
+}
+
+// watermarkHeight is the height of rendition r's video, which the watermark is sized
+// against.
+func watermarkHeight(plan *hlsPlan, r Rendition) int {
+	if r.Height > 0 {
+		return r.Height
+	}
+	return plan.srcInfo.Height
+}
+
+// renditionOutput adds rendition r's encoder, keyframe, audio and HLS muxer options to
+// cmd, followed by its playlist as the output. The video to encode is already mapped;
+// concurrent is how many renditions are being encoded at the same time.
+func (t *FFmpegTranscoder) renditionOutput(cmd *ff.Command, plan *hlsPlan, r Rendition, concurrent int) {
+	playlist, segmentPattern, initSegment := plan.layout(r)
+	t.setVideoEncoder(cmd, r)
+	cmd.Threads(renditionThreads(r, t.renditionThreads, runtime.GOMAXPROCS(0), concurrent))
+	cmd.PixFmt(t.renditionPixFmt(plan.srcInfo, r.Codec, plan.tonemap))
+	if plan.tonemap {
+		cmd.Arg("-color_primaries", "bt709", "-color_trc", "bt709", "-colorspace", "bt709")
+	}
+
+	fps := renditionFPS(plan.srcInfo, r)
+	g := r.KeyframeInterval
+	if g <= 0 {
+		g = defaultGOP(fps, t.hlsSegSecs)
+	} else if (fps*t.hlsSegSecs)%g != 0 {
+		log.Warn("keyframe interval does not divide the HLS segment, GOPs will be uneven",
+			"height", r.Height, "gop_frames", g, "fps", fps, "segment_seconds", t.hlsSegSecs)
+	}
+	// Keyframes at every segment boundary, from the same interval as -hls_time, so
+	// segments of all renditions start at the same timestamps and players can
+	// switch between them at any boundary
+	cmd.GOP(g).ForceKeyFramesEvery(t.hlsSegSecs)
+	if plan.altAudio {
+		// Audio is served from the shared audio group playlists
+		cmd.NoAudio()
+	} else if st := muxedAudio(plan.srcInfo); t.copiesAudio(st, plan.audioFilter, r) {
+		cmd.AudioCodec("copy").StreamMetadata("a:0", "language", plan.audioLanguage)
+	} else {
+		channels, sampleRate := audioFormat(r, st)
+		cmd.AudioFilter(plan.audioFilter)
+		cmd.AudioCodec("aac").AudioBitrateKbps(audioKbps(r)).AudioChannels(channels).AudioRate(sampleRate).
+			StreamMetadata("a:0", "language", plan.audioLanguage)
+	}
+	setMetadata(cmd, plan.metadata)
+	cmd.HLS(t.hlsSegSecs, plan.playlistType, plan.hlsFlags, filepath.Join(plan.outDir, filepath.FromSlash(segmentPattern)))
+	if initSegment != "" {
+		cmd.HLSFMP4(initSegment)
+	}
+	cmd.Output(filepath.Join(plan.outDir, filepath.FromSlash(playlist)))
+}
+
+// layout returns r's media playlist and segment pattern, relative to the output
+// directory and slash-separated as the master playlist refers to them, and for
+// fragmented MP4 renditions the init segment, relative to the playlist as ffmpeg takes
+// it. H.264 keeps MPEG-TS segments; VP9/AV1 need fragmented MP4.
+func (plan *hlsPlan) layout(r Rendition) (playlist, segments, initSegment string) {
+	name := renditionName(r)
+	ext := ".ts"
+	if !r.Codec.isH264() {
+		ext = ".m4s"
+	}
+	if plan.renditionDirs {
+		// "v720" lives in "720", "v720_vp9" in "720_vp9"
+		dir := strings.TrimPrefix(name, "v")
+		if ext == ".m4s" {
+			initSegment = "init.mp4"
+		}
+		return dir + "/" + name + ".m3u8", dir + "/seg_%04d" + ext, initSegment
+	}
+	if ext == ".m4s" {
+		initSegment = name + "_init.mp4"
+	}
+	return name + ".m3u8", name + "_%04d" + ext, initSegment
+}
+
+// singlePassFilter builds the -filter_complex graph of a single pass encode: the
+// source's video is split once per rendition and each copy run through that
+// rendition's filter chain (and watermark overlay, sized by heights), labeled [v0],
+// [v1], ... in ladder order.
+func singlePassFilter(chains []string, wm *ff.Watermark, heights []int) string {
+	n := len(chains)
+	var b strings.Builder
+	fmt.Fprintf(&b, "[0:v:0]split=%d", n)
+	for i := range n {
+		fmt.Fprintf(&b, "[src%d]", i)
+	}
+	if wm != nil {
+		fmt.Fprintf(&b, ";[1:v]split=%d", n)
+		for i := range n {
+			fmt.Fprintf(&b, "[wm%d]", i)
+		}
+	}
+	for i, chain := range chains {
+		b.WriteString(";")
+		if wm != nil {
+			b.WriteString(wm.Overlay(fmt.Sprintf("[src%d]", i), fmt.Sprintf("[wm%d]", i), chain, heights[i], fmt.Sprintf("[v%d]", i)))
+			continue
+		}
+		if chain == "" {
+			chain = "null"
+		}
+		fmt.Fprintf(&b, "[src%d]%s[v%d]", i, chain, i)
+	}
+	return b.String()
+}
+
+// failedOutputRe matches ffmpeg's references to an output file by index in its error
+// messages: "[vost#1:0/libx264 @ ...]", "[out#1/hls @ ...]" or, in older versions,
+// "Error initializing output stream 1:0".
+var failedOutputRe = regexp.MustCompile(`\b(?:[va]?ost#|out#|output stream #?)(\d+)[:/]`)
+
+// failedOutput returns the index of the output ffmpeg's stderr blames for a failure,
+// from the first error line that names one.
+func failedOutput(stderr string) (int, bool) {
+	for _, line := range strings.Split(stderr, "\n") {
+		if !strings.Contains(strings.ToLower(line), "error") {
+			continue
+		}
+		if m := failedOutputRe.FindStringSubmatch(line); m != nil {
+			i, err := strconv.Atoi(m[1])
+			return i, err == nil
+		}
+	}
+	return 0, false
+}
+
+func (t *FFmpegTranscoder) GeneratePoster(ctx context.Context, inputPath, outPath string, at time.Duration, width int, seek SeekMode) error {
+	return t.writeFrame(ctx, inputPath, outPath, at, width, seek, defaultJPEGQuality)
+}
+
+// defaultJPEGQuality is the -q:v of posters and, unless configured, thumbnails.
+const defaultJPEGQuality = 2
+
+// writeFrame is GeneratePoster with the JPEG quality (-q:v) as a parameter.
+func (t *FFmpegTranscoder) writeFrame(ctx context.Context, inputPath, outPath string, at time.Duration, width int, seek SeekMode, jpegQuality int) error {
 	if err := os.MkdirAll(filepath.Dir(outPath), 0o755); err != nil {
 		return fmt.Errorf("create poster dir: %w", err)
 	}
 	fc := ff.NewFilterChain().Scale(width, -2)
-	cmd := ff.New(t.ffmpegPath).
+	cmd := t.command().
 		Overwrite(true).
-		StartAt(at).
+		StartAtMode(t.job.Trim.In+at, seek).
 		Input(inputPath).
 		Arg("-vframes", "1").
-		FilterChain(fc).
-		Arg("-q:v", "2").
-		Output(outPath)
+		FilterChain(fc)
+	imageEncoder(cmd, outPath, jpegQuality)
+	cmd.Output(outPath)
 	if err := cmd.Run(ctx); err != nil {
 		return fmt.Errorf("ffmpeg poster: %w", err)
 	}
 	return nil
 }
 
-func (t *FFmpegTranscoder) GenerateThumbnailsAndVTT(ctx context.Context, inputPath, outDir, vttPath string, thumbHeight int, maxThumbnails int) error {
+// imageEncoder sets the encoder for still images written to outPath, by extension.
+func imageEncoder(cmd *ff.Command, outPath string, jpegQuality int) {
+	switch strings.ToLower(filepath.Ext(outPath)) {
+	case ".webp":
+		cmd.VideoCodec("libwebp").Arg("-quality", "80")
+	case ".avif":
+		cmd.VideoCodec("libaom-av1").Arg("-still-picture", "1", "-cpu-used", "6").CRF(32).PixFmt("yuv420p")
+	default:
+		cmd.Arg("-q:v", strconv.Itoa(jpegQuality))
+	}
+}
+
+func (t *FFmpegTranscoder) GenerateSmartPoster(ctx context.Context, inputPath, outPath string, width int) error {
+	info, err := t.probe(ctx, inputPath)
+	if err != nil {
+		return fmt.Errorf("probe: %w", err)
+	}
+	if isStill(info.DurationSec) {
+		// Nothing to choose from, and a seek could miss the only frame
+		return t.GeneratePoster(ctx, inputPath, outPath, 0, width, SeekAccurate)
+	}
+	at, err := t.pickPosterFrame(ctx, inputPath, info.DurationSec)
+	if err != nil {
+		log.Warn("smart poster selection failed, using the 25% frame", "error", err)
+		at = secondsToDuration(info.DurationSec * 0.25)
+	} else {
+		log.Info("selected poster frame", "at", at.Truncate(time.Millisecond))
+	}
+	return t.GeneratePoster(ctx, inputPath, outPath, at, width, SeekAccurate)
+}
+
+// pickPosterFrame takes the representative frame of each candidate window and returns
+// the time of the best one. Analysis stops after smartPosterTimeout, keeping whatever
+// candidates were measured by then.
+func (t *FFmpegTranscoder) pickPosterFrame(ctx context.Context, inputPath string, durationSec float64) (time.Duration, error) {
+	if durationSec <= 0 {
+		return 0, errors.New("unknown duration")
+	}
+	analyzeCtx, cancel := context.WithTimeout(ctx, smartPosterTimeout)
+	defer cancel()
+
+	// Windows are relative to the trimmed range, the analysis runs on the source timeline
+	in := t.job.Trim.In
+	var candidates []ff.FrameStats
+	for _, f := range smartPosterFractions {
+		start := durationSec * f
+		window := min(smartPosterWindow.Seconds(), durationSec-start)
+		stats, err := ff.RepresentativeFrame(analyzeCtx, t.ffmpegPath, inputPath, in+secondsToDuration(start), secondsToDuration(window))
+		if err != nil {
+			if analyzeCtx.Err() != nil {
+				log.Warn("poster frame analysis timed out", "candidates", len(candidates))
+				break
+			}
+			log.Warn("poster frame analysis failed", "at_sec", fmt.Sprintf("%.1f", start), "error", err)
+			continue
+		}
+		candidates = append(candidates, stats)
+	}
+	best, ok := bestPosterFrame(candidates)
+	if !ok {
+		return 0, fmt.Errorf("no usable frame among %d candidates", len(candidates))
+	}
+	return secondsToDuration(best.TimeSec) - in, nil
+}
+
+// bestPosterFrame returns the highest-contrast candidate that is neither near-black nor
+// blank; ties go to the earlier (more preferred) candidate.
+func bestPosterFrame(candidates []ff.FrameStats) (ff.FrameStats, bool) {
+	var best ff.FrameStats
+	found := false
+	for _, c := range candidates {
+		if c.YAvg < smartPosterMinLuma || c.Contrast() < smartPosterMinContrast {
+			continue
+		}
+		if !found || c.Contrast() > best.Contrast() {
+			best, found = c, true
+		}
+	}
+	return best, found
+}
+
+func (t *FFmpegTranscoder) GenerateThumbnailsAndVTT(ctx context.Context, inputPath, outDir, vttPath string, thumbHeight int, maxThumbnails int, start, end time.Duration, progress ProgressFunc) error {
 	startTime := time.Now()
 
 	if thumbHeight <= 0 {
@@ -228,45 +1061,64 @@ func (t *FFmpegTranscoder) GenerateThumbnailsAndVTT(ctx context.Context, inputPa
 	}
 
 	// Probe video to get duration and dimensions
-	// Add debugging info about the file
-	fileInfo, statErr := os.Stat(inputPath)
-	if statErr != nil {
-		log.Error("failed to stat input file before probe", 
-			"file", inputPath,
-			"error", statErr,
-		)
-		return fmt.Errorf("stat input file: %w", statErr)
-	}
-	
-	log.Info("probing video for thumbnails", 
-		"file", filepath.Base(inputPath),
-		"full_path", inputPath,
-		"size_bytes", fileInfo.Size(),
-	)
-	
-	info, err := ff.Probe(ctx, t.ffprobePath, inputPath)
+	info, err := t.probe(ctx, inputPath)
 	if err != nil {
-		log.Error("ffprobe failed for thumbnails",
-			"file", inputPath,
-			"size_bytes", fileInfo.Size(),
-			"error", err,
-		)
+		log.Error("ffprobe failed for thumbnails", "error", err)
 		return fmt.Errorf("probe: %w", err)
 	}
 
-	// Determine number of thumbnails based on video duration
+	// Restrict thumbnails to the requested window (defaults to the full duration)
+	windowStart, windowEnd := thumbnailWindow(info.DurationSec, start, end)
+	still := isStill(info.DurationSec)
+	if still {
+		// One thumbnail of the first frame, its cue lasting at least a second so the
+		// VTT stays valid for a source without duration
+		windowStart, windowEnd = 0, math.Max(info.DurationSec, 1)
+	}
+	windowSec := windowEnd - windowStart
+
+	// Determine number of thumbnails based on window duration
 	// Aim for reasonable coverage without generating too many
-	numThumbs := min(int(math.Ceil(info.DurationSec)), maxThumbnails)
+	numThumbs := min(int(math.Ceil(windowSec)), maxThumbnails)
 	if numThumbs == 0 {
 		numThumbs = 1
 	}
 
 	// Calculate interval based on number of thumbnails
-	intervalSec := info.DurationSec / float64(numThumbs)
+	intervalSec := windowSec / float64(numThumbs)
 	if intervalSec <= 0 {
 		intervalSec = 1.0
 	}
 
+	// Thumbnail times, each one starting a cue that runs until the next (or the window end)
+	var cueStarts []float64
+	for i := 0; i < numThumbs; i++ {
+		timestamp := windowStart + float64(i)*intervalSec
+		if timestamp >= windowEnd {
+			break
+		}
+		cueStarts = append(cueStarts, timestamp)
+	}
+	mode := ThumbnailModeInterval
+	if t.thumbnailMode == ThumbnailModeScene && !still {
+		in := t.job.Trim.In
+		scenes, err := ff.DetectScenes(ctx, t.ffmpegPath, inputPath, sceneChangeThreshold,
+			in+secondsToDuration(windowStart), in+secondsToDuration(windowEnd))
+		for i := range scenes {
+			scenes[i] -= in.Seconds()
+		}
+		switch {
+		case err != nil:
+			log.Warn("scene detection failed, using interval thumbnails", "error", err)
+		case len(scenes) < minSceneThumbnails:
+			log.Info("too few scene changes, using interval thumbnails", "scenes", len(scenes))
+		default:
+			cueStarts = sceneCueStarts(scenes, windowStart, windowEnd, maxThumbnails)
+			numThumbs = len(cueStarts)
+			mode = ThumbnailModeScene
+		}
+	}
+
 	// Calculate thumbnail width based on height and video aspect ratio
 	thumbWidth := thumbHeight
 	if info.Width > 0 && info.Height > 0 {
@@ -276,26 +1128,45 @@ func (t *FFmpegTranscoder) GenerateThumbnailsAndVTT(ctx context.Context, inputPa
 
 	log.Info("generating thumbnails",
 		"count", numThumbs,
+		"mode", mode,
 		"size", fmt.Sprintf("%dx%d", thumbWidth, thumbHeight),
 		"interval_sec", fmt.Sprintf("%.1f", intervalSec),
 		"duration_sec", fmt.Sprintf("%.1f", info.DurationSec),
+		"window", fmt.Sprintf("%.1f-%.1f", windowStart, windowEnd),
 	)
 
-	// Generate individual thumbnail images
-	lastLogTime := time.Now()
-	for i := 0; i < numThumbs; i++ {
-		timestamp := float64(i) * intervalSec
-		if timestamp >= info.DurationSec {
-			break
+	// Generate individual thumbnail images. Thumbnails packed into sprites are only an
+	// intermediate step, so they go to a temporary dir at full quality and the
+	// configured quality is applied to the sheets instead.
+	thumbExt := t.thumbnailFormat.Ext()
+	frameDir, quality := outDir, t.thumbnailQuality
+	spriteCols, spriteRows := t.spriteCols, t.spriteRows
+	if t.thumbnailMosaic {
+		spriteCols, spriteRows = mosaicGrid(len(cueStarts), thumbWidth, thumbHeight)
+	}
+	if spriteCols > 0 {
+		frameDir, err = os.MkdirTemp(t.workDir, "thumbs-*")
+		if err != nil {
+			return fmt.Errorf("create thumbnail frame dir: %w", err)
 		}
+		defer os.RemoveAll(frameDir)
+		quality = defaultJPEGQuality
+	}
+	// Fast seeks land on the keyframe before the cue; variable frame rate recordings
+	// often have keyframes far apart, which would show the wrong frame
+	seek := SeekFast
+	if info.VariableFrameRate {
+		seek = SeekAccurate
+	}
+	lastLogTime := time.Now()
+	for i, timestamp := range cueStarts {
+		thumbFilename := fmt.Sprintf("thumb-%05d%s", i, thumbExt)
+		thumbPath := filepath.Join(frameDir, thumbFilename)
 
-		thumbFilename := fmt.Sprintf("thumb-%05d.jpg", i)
-		thumbPath := filepath.Join(outDir, thumbFilename)
-
-		// Use GeneratePoster method to create each thumbnail
-		if err := t.GeneratePoster(ctx, inputPath, thumbPath, time.Duration(timestamp*float64(time.Second)), thumbWidth); err != nil {
+		if err := t.writeFrame(ctx, inputPath, thumbPath, secondsToDuration(timestamp), thumbWidth, seek, quality); err != nil {
 			return fmt.Errorf("generate thumbnail %d: %w", i, err)
 		}
+		progress.report(float64(i+1) / float64(numThumbs) * 100)
 
 		// Log progress every 10 thumbnails or every 5 seconds
 		if (i+1)%10 == 0 || time.Since(lastLogTime) >= 5*time.Second {
@@ -315,34 +1186,68 @@ func (t *FFmpegTranscoder) GenerateThumbnailsAndVTT(ctx context.Context, inputPa
 		"duration", time.Since(startTime).Truncate(time.Millisecond),
 	)
 
-	// Generate VTT file
+	// Generate VTT file, pointing either at the individual thumbnails or into the sheets
 	log.Info("writing VTT file", "file", filepath.Base(vttPath))
-	vttContent := "WEBVTT\n\n"
 	thumbsDirName := filepath.Base(outDir)
-
-	for i := 0; i < numThumbs; i++ {
-		startTimeVtt := float64(i) * intervalSec
-		endTime := startTimeVtt + intervalSec
-		if endTime > info.DurationSec {
-			endTime = info.DurationSec
+	var vttContent string
+	if spriteCols > 0 {
+		sheets, err := t.packThumbnailSprites(ctx, frameDir, thumbExt, len(cueStarts), spriteCols, spriteRows, thumbWidth, thumbHeight, outDir)
+		if err != nil {
+			return err
 		}
-		if startTimeVtt >= info.DurationSec {
-			break
+		log.Info("packed thumbnails into sprite sheets", "thumbnails", len(cueStarts), "sheets", len(sheets))
+		for i, sheet := range sheets {
+			sheets[i] = thumbsDirName + "/" + sheet
 		}
+		vttContent = prev.NewVTT().
+			UsingSprites(sheets...).
+			Grid(spriteCols, spriteRows, thumbWidth, thumbHeight).
+			AddCues(cueStarts, windowEnd).
+			String()
+	} else {
+		vttContent = "WEBVTT\n\n"
+		for i, startTimeVtt := range cueStarts {
+			endTime := windowEnd
+			if i+1 < len(cueStarts) {
+				endTime = cueStarts[i+1]
+			}
 
-		thumbFilename := fmt.Sprintf("thumb-%05d.jpg", i)
-		thumbReference := fmt.Sprintf("%s/%s", thumbsDirName, thumbFilename)
+			thumbFilename := fmt.Sprintf("thumb-%05d%s", i, thumbExt)
+			thumbReference := fmt.Sprintf("%s/%s", thumbsDirName, thumbFilename)
 
-		vttContent += fmt.Sprintf("%s --> %s\n%s\n\n",
-			formatVTTTimestamp(startTimeVtt),
-			formatVTTTimestamp(endTime),
-			thumbReference,
-		)
+			vttContent += fmt.Sprintf("%s --> %s\n%s\n\n",
+				formatVTTTimestamp(startTimeVtt),
+				formatVTTTimestamp(endTime),
+				thumbReference,
+			)
+		}
 	}
 
 	if err := os.WriteFile(vttPath, []byte(vttContent), 0o644); err != nil {
 		return fmt.Errorf("write vtt: %w", err)
 	}
+	if err := validateVTTFile(vttPath); err != nil {
+		return fmt.Errorf("invalid thumbnails vtt: %w", err)
+	}
+
+	// BIF needs evenly spaced JPEG frames, so it is only written for interval JPEG thumbnails
+	if t.generateBIF {
+		if mode != ThumbnailModeInterval {
+			log.Warn("skipping BIF output, scene thumbnails are not evenly spaced")
+		} else if t.thumbnailFormat != ImageFormatJPEG {
+			log.Warn("skipping BIF output, BIF requires JPEG thumbnails", "format", t.thumbnailFormat)
+		} else {
+			bifPath := strings.TrimSuffix(vttPath, filepath.Ext(vttPath)) + ".bif"
+			bif := prev.NewBIF().Interval(intervalSec)
+			for i, timestamp := range cueStarts {
+				bif.AddFrame(timestamp, filepath.Join(frameDir, fmt.Sprintf("thumb-%05d.jpg", i)))
+			}
+			if err := bif.WriteFile(bifPath); err != nil {
+				return fmt.Errorf("write bif: %w", err)
+			}
+			log.Info("wrote BIF file", "file", filepath.Base(bifPath), "frames", bif.Len())
+		}
+	}
 
 	log.Info("thumbnail generation complete",
 		"total_time", time.Since(startTime).Truncate(time.Millisecond),
@@ -350,11 +1255,206 @@ func (t *FFmpegTranscoder) GenerateThumbnailsAndVTT(ctx context.Context, inputPa
 	return nil
 }
 
+// packThumbnailSprites tiles the n thumbnails thumb-00000<ext>, thumb-00001<ext>, ... in
+// frameDir into sprite sheets of cols x rows cells of width x height,
+// written to outDir as sprite<ext> or, when more than one is needed, sprite-000<ext>,
+// sprite-001<ext>, ... It returns the sheet file names in order.
+func (t *FFmpegTranscoder) packThumbnailSprites(ctx context.Context, frameDir, ext string, n, cols, rows, width, height int, outDir string) ([]string, error) {
+	spritePath := filepath.Join(outDir, "sprite"+ext)
+	count := prev.SheetCount(n, cols, rows)
+	perSheet := cols * rows
+	sheets := make([]string, count)
+	for i := range sheets {
+		sheetPath := spritePath
+		if count > 1 {
+			sheetPath = prev.SheetName(spritePath, i)
+		}
+		// One run per sheet: the image sequence is read from this sheet's first
+		// thumbnail and tile emits the sheet, partially filled if the thumbnails run out
+		cmd := t.command().
+			Overwrite(true).
+			Arg("-framerate", "1", "-start_number", strconv.Itoa(i*perSheet)).
+			Input(filepath.Join(frameDir, "thumb-%05d"+ext)).
+			Arg("-vframes", "1").
+			FilterChain(ff.NewFilterChain().Scale(width, height).Tile(cols, rows))
+		imageEncoder(cmd, sheetPath, t.thumbnailQuality)
+		cmd.Output(sheetPath)
+		if err := cmd.Run(ctx); err != nil {
+			return nil, fmt.Errorf("ffmpeg sprite sheet %d: %w", i, err)
+		}
+		sheets[i] = filepath.Base(sheetPath)
+	}
+	return sheets, nil
+}
+
+// mosaicGrid returns the columns and rows of a single sprite holding n thumbnails of
+// width x height, choosing the column count that makes the image closest to square.
+func mosaicGrid(n, width, height int) (int, int) {
+	if n <= 0 {
+		return 1, 1
+	}
+	cols := 1
+	if width > 0 && height > 0 {
+		cols = int(math.Round(math.Sqrt(float64(n) * float64(height) / float64(width))))
+	}
+	cols = min(max(cols, 1), n)
+	return cols, (n + cols - 1) / cols
+}
+
+// sceneCueStarts turns detected scene-change times into thumbnail times within the window.
+// The window start is always included so the first cue has a thumbnail, and when there are
+// more scenes than maxThumbs they are thinned out evenly.
+func sceneCueStarts(scenes []float64, windowStart, windowEnd float64, maxThumbs int) []float64 {
+	starts := []float64{windowStart}
+	for _, ts := range scenes {
+		// Skip cuts too close to the previous thumbnail or the window end to be useful;
+		// the last cue is clamped to windowEnd and must not end before it starts
+		if ts > windowEnd-1 || ts-starts[len(starts)-1] < 1 {
+			continue
+		}
+		starts = append(starts, ts)
+	}
+	if maxThumbs <= 0 || len(starts) <= maxThumbs {
+		return starts
+	}
+	thinned := make([]float64, 0, maxThumbs)
+	step := float64(len(starts)) / float64(maxThumbs)
+	for i := 0; i < maxThumbs; i++ {
+		thinned = append(thinned, starts[int(float64(i)*step)])
+	}
+	return thinned
+}
+
+func secondsToDuration(sec float64) time.Duration {
+	return time.Duration(sec * float64(time.Second))
+}
+
+// thumbnailWindow clamps the optional [start, end) bounds to the video duration.
+// A zero end means "until the end of the video".
+func thumbnailWindow(durationSec float64, start, end time.Duration) (float64, float64) {
+	startSec := math.Max(0, start.Seconds())
+	endSec := end.Seconds()
+	if endSec <= 0 || endSec > durationSec {
+		endSec = durationSec
+	}
+	if startSec >= endSec {
+		// Invalid window, fall back to the full duration
+		return 0, durationSec
+	}
+	return startSec, endSec
+}
+
+// validateVTTFile checks a WebVTT file as written, so a malformed track fails the task
+// instead of reaching players.
+func validateVTTFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return prev.ValidateVTT(f)
+}
+
 func formatVTTTimestamp(seconds float64) string {
-	h := int(seconds) / 3600
-	m := (int(seconds) % 3600) / 60
-	s := seconds - float64(h*3600+m*60)
-	return fmt.Sprintf("%02d:%02d:%06.3f", h, m, s)
+	// Round to whole milliseconds first so 59.9996 can't print as 00:00:60.000
+	ms := int64(math.Round(max(seconds, 0) * 1000))
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
+
+// GenerateChaptersVTT writes a WebVTT chapters track and a JSON sidecar from the chapter
+// markers embedded in the source. Sources without chapters are skipped and nothing is written.
+func (t *FFmpegTranscoder) GenerateChaptersVTT(ctx context.Context, inputPath, vttPath, jsonPath string) error {
+	info, err := t.probe(ctx, inputPath)
+	if err != nil {
+		return fmt.Errorf("probe: %w", err)
+	}
+	if len(info.Chapters) == 0 {
+		log.Info("no chapter markers in source, skipping chapters")
+		return nil
+	}
+
+	b := prev.NewChapters()
+	for _, ch := range info.Chapters {
+		b.Add(ch.StartSec, ch.EndSec, ch.Title)
+	}
+
+	if vttPath != "" {
+		if err := os.MkdirAll(filepath.Dir(vttPath), 0o755); err != nil {
+			return fmt.Errorf("chapters vtt dir: %w", err)
+		}
+		if err := b.WriteFile(vttPath); err != nil {
+			return fmt.Errorf("write chapters vtt: %w", err)
+		}
+		if err := validateVTTFile(vttPath); err != nil {
+			return fmt.Errorf("invalid chapters vtt: %w", err)
+		}
+	}
+	if jsonPath != "" {
+		if err := os.MkdirAll(filepath.Dir(jsonPath), 0o755); err != nil {
+			return fmt.Errorf("chapters json dir: %w", err)
+		}
+		if err := b.WriteJSON(jsonPath); err != nil {
+			return fmt.Errorf("write chapters json: %w", err)
+		}
+	}
+
+	log.Info("chapters written", "count", b.Len())
+	return nil
+}
+
+func (t *FFmpegTranscoder) ExtractAudio(ctx context.Context, inputPath, outPath string, format AudioFormat, bitrateKbps int) error {
+	var codec, muxer string
+	switch format {
+	case AudioFormatAAC:
+		codec, muxer = "aac", "ipod"
+	case AudioFormatMP3:
+		codec, muxer = "libmp3lame", "mp3"
+	case AudioFormatOpus:
+		codec, muxer = "libopus", "ogg"
+	default:
+		return fmt.Errorf("unsupported audio format %q", format)
+	}
+	if bitrateKbps <= 0 {
+		bitrateKbps = 192
+	}
+
+	info, err := t.probe(ctx, inputPath)
+	if err != nil {
+		return fmt.Errorf("probe: %w", err)
+	}
+	if !info.HasAudio {
+		return ErrNoAudio
+	}
+	if err := os.MkdirAll(filepath.Dir(outPath), 0o755); err != nil {
+		return fmt.Errorf("create audio dir: %w", err)
+	}
+
+	cmd := t.trimmedInput(t.command().Overwrite(true), inputPath).
+		NoVideo().
+		Arg("-sn", "-dn").
+		Arg("-map", "0:a:0").
+		AudioCodec(codec).
+		AudioBitrateKbps(bitrateKbps).
+		Format(muxer)
+	if format == AudioFormatAAC {
+		// Put the moov atom up front so downloads can start playing immediately
+		cmd.Arg("-movflags", "+faststart")
+	}
+	cmd.Output(outPath)
+	if info.DurationSec > 0 {
+		cmd.WithProgress(info.DurationSec, func(percent float64, position string, speed string) {
+			log.Info("audio extraction progress",
+				"percent", fmt.Sprintf("%.1f%%", percent),
+				"position", position,
+				"speed", speed,
+			)
+		})
+	}
+	if err := cmd.Run(ctx); err != nil {
+		return fmt.Errorf("ffmpeg audio: %w", err)
+	}
+	log.Info("audio extracted", "format", format, "bitrate_kbps", bitrateKbps, "path", outPath)
+	return nil
 }
 
 // Legacy sprite-based method kept for compatibility - can be removed if not used elsewhere
@@ -374,7 +1474,7 @@ func (t *FFmpegTranscoder) GenerateVTT(ctx context.Context, inputPath, spritePat
 	if err := os.MkdirAll(filepath.Dir(vttPath), 0o755); err != nil {
 		return fmt.Errorf("vtt dir: %w", err)
 	}
-	info, err := ff.Probe(ctx, t.ffprobePath, inputPath)
+	info, err := t.probe(ctx, inputPath)
 	if err != nil {
 		log.Error("ffprobe failed for sprite generation",
 			"file", inputPath,
@@ -386,150 +1486,311 @@ func (t *FFmpegTranscoder) GenerateVTT(ctx context.Context, inputPath, spritePat
 	if info.Width > 0 && info.Height > 0 {
 		scaledH = roundEven(int(float64(thumbWidth) * float64(info.Height) / float64(info.Width)))
 	}
-	maxThumbs := cols * rows
+	// Long videos spill over into additional numbered sheets rather than being truncated
 	var numFrames int
 	if fps > 0 && info.DurationSec > 0 {
 		numFrames = int(math.Ceil(info.DurationSec * fps))
 	}
 	if numFrames == 0 {
-		numFrames = maxThumbs
-	}
-	if numFrames > maxThumbs {
-		numFrames = maxThumbs
+		numFrames = cols * rows
 	}
-	if err := prev.NewSprite(t.ffmpegPath).
+	tr := t.job.Trim
+	sprite := prev.NewSprite(t.ffmpegPath).
 		Input(inputPath).
+		Range(tr.In, tr.span()).
 		Grid(cols, rows).
 		ThumbWidth(thumbWidth).
 		FPS(fps).
 		Frames(numFrames).
 		Quality(3).
-		Output(spritePath).
-		Run(ctx); err != nil {
+		Output(spritePath)
+	if err := sprite.Run(ctx); err != nil {
 		return fmt.Errorf("ffmpeg sprite: %w", err)
 	}
-	// Build VTT mapping each sampled frame to its cell in the single sprite sheet.
-	var totalThumbs int
-	if fps > 0 && info.DurationSec > 0 {
-		totalThumbs = int(math.Ceil(info.DurationSec * fps))
-	}
-	if totalThumbs == 0 {
-		totalThumbs = numFrames
+	// Build VTT mapping each sampled frame to its cell in the sprite sheets.
+	sheets := sprite.Sheets()
+	for i, sheet := range sheets {
+		sheets[i] = filepath.Base(sheet)
 	}
-	if totalThumbs > maxThumbs {
-		totalThumbs = maxThumbs
+	if len(sheets) > 1 {
+		log.Info("sprite spans multiple sheets", "sheets", len(sheets), "frames", numFrames)
 	}
 	if err := prev.NewVTT().
-		UsingSprite(filepath.Base(spritePath)).
+		UsingSprites(sheets...).
 		Grid(cols, rows, thumbWidth, max(scaledH, 0)).
-		AddGridTimeline(fps, info.DurationSec, totalThumbs).
+		AddGridTimeline(fps, info.DurationSec, numFrames).
 		WriteFile(vttPath); err != nil {
 		return fmt.Errorf("write vtt: %w", err)
 	}
+	if err := validateVTTFile(vttPath); err != nil {
+		return fmt.Errorf("invalid sprite vtt: %w", err)
+	}
+	// Storyboard JSON for players that lay out the sprite themselves, written next to the VTT
+	storyboardPath := strings.TrimSuffix(vttPath, filepath.Ext(vttPath)) + ".json"
+	storyboard := prev.NewStoryboardJSON(sheets, cols, rows, thumbWidth, max(scaledH, 0), fps, info.DurationSec, numFrames)
+	if err := storyboard.WriteFile(storyboardPath); err != nil {
+		return fmt.Errorf("write storyboard: %w", err)
+	}
+	return nil
+}
+
+func (t *FFmpegTranscoder) GenerateHoverPreview(ctx context.Context, inputPath, outWebM, outMP4 string, duration time.Duration, width int, fps int, clipCount int, fractions []float64) error {
+	duration, width, fps = hoverPreviewDefaults(duration, width, fps)
+	timestamps, err := t.hoverPreviewTimestamps(ctx, inputPath, duration, clipCount, fractions)
+	if err != nil {
+		return err
+	}
+	clipDurationSec := duration.Seconds()
+
+	if outWebM != "" {
+		if err := os.MkdirAll(filepath.Dir(outWebM), 0o755); err != nil {
+			return fmt.Errorf("webm dir: %w", err)
+		}
+		if err := t.generateHoverPreviewWebM(ctx, inputPath, outWebM, timestamps, clipDurationSec, width, fps); err != nil {
+			return err
+		}
+	}
+
+	if outMP4 != "" {
+		if err := os.MkdirAll(filepath.Dir(outMP4), 0o755); err != nil {
+			return fmt.Errorf("mp4 dir: %w", err)
+		}
+		if err := t.generateHoverPreviewMP4(ctx, inputPath, outMP4, timestamps, clipDurationSec, width, fps); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GenerateHoverPreviewWebP creates a looping animated WebP with the same clips as GenerateHoverPreview.
+func (t *FFmpegTranscoder) GenerateHoverPreviewWebP(ctx context.Context, inputPath, outPath string, duration time.Duration, width int, fps int, clipCount int, fractions []float64) error {
+	duration, width, fps = hoverPreviewDefaults(duration, width, fps)
+	timestamps, err := t.hoverPreviewTimestamps(ctx, inputPath, duration, clipCount, fractions)
+	if err != nil {
+		return err
+	}
+	clipDurationSec := duration.Seconds()
+
+	if err := os.MkdirAll(filepath.Dir(outPath), 0o755); err != nil {
+		return fmt.Errorf("webp dir: %w", err)
+	}
+
+	log.Info("generating hover preview WebP", "width", width, "fps", fps)
+
+	cmd := t.trimmedInput(t.command().Overwrite(true), inputPath).
+		Arg("-filter_complex", hoverPreviewFilter(timestamps, clipDurationSec, width, fps)).
+		Arg("-map", "[out]").
+		NoAudio().
+		VideoCodec("libwebp_anim").
+		Arg("-lossless", "0").
+		Arg("-q:v", "60").
+		Arg("-compression_level", "6").
+		Arg("-loop", "0"). // Loop forever
+		Output(outPath)
+
+	// Add progress callback (total duration is all clips back to back)
+	totalDuration := clipDurationSec * float64(len(timestamps))
+	cmd.WithProgress(totalDuration, func(percent float64, position string, speed string) {
+		log.Info("hover preview WebP progress",
+			"percent", fmt.Sprintf("%.1f%%", percent),
+			"position", position,
+			"speed", speed,
+		)
+	})
+
+	if err := cmd.Run(ctx); err != nil {
+		return fmt.Errorf("ffmpeg webp: %w", err)
+	}
+
+	log.Info("hover preview WebP complete")
+	return nil
+}
+
+// GenerateHoverPreviewGIF creates a looping GIF with the same clips as GenerateHoverPreview.
+// It runs two passes: palettegen into a temporary palette PNG, then paletteuse for the final GIF.
+// Width and fps are capped to keep the file size reasonable.
+func (t *FFmpegTranscoder) GenerateHoverPreviewGIF(ctx context.Context, inputPath, outPath string, duration time.Duration, width int, fps int, clipCount int, fractions []float64) error {
+	duration, width, fps = hoverPreviewDefaults(duration, width, fps)
+	width = min(width, maxGIFWidth)
+	fps = min(fps, maxGIFFPS)
+	timestamps, err := t.hoverPreviewTimestamps(ctx, inputPath, duration, clipCount, fractions)
+	if err != nil {
+		return err
+	}
+	clipDurationSec := duration.Seconds()
+
+	if err := os.MkdirAll(filepath.Dir(outPath), 0o755); err != nil {
+		return fmt.Errorf("gif dir: %w", err)
+	}
+	// Keep the palette out of the output dir so it never gets synced
+	paletteDir, err := os.MkdirTemp(t.workDir, "palette-*")
+	if err != nil {
+		return fmt.Errorf("create palette dir: %w", err)
+	}
+	defer os.RemoveAll(paletteDir)
+	palettePath := filepath.Join(paletteDir, "palette.png")
+
+	log.Info("generating hover preview GIF", "width", width, "fps", fps)
+
+	clips := hoverPreviewFilter(timestamps, clipDurationSec, width, fps)
+
+	// Pass 1: build an optimized palette from the clips
+	paletteCmd := t.trimmedInput(t.command().Overwrite(true), inputPath).
+		Arg("-filter_complex", clips+"; [out] palettegen=max_colors=128:stats_mode=diff [pal]").
+		Arg("-map", "[pal]").
+		Arg("-update", "1").
+		Arg("-frames:v", "1").
+		Output(palettePath)
+	if err := paletteCmd.Run(ctx); err != nil {
+		return fmt.Errorf("ffmpeg gif palette: %w", err)
+	}
+
+	// Pass 2: render the clips through the palette
+	cmd := t.trimmedInput(t.command().Overwrite(true), inputPath).
+		Input(palettePath).
+		Arg("-filter_complex", clips+"; [out][1:v] paletteuse=dither=bayer:bayer_scale=5:diff_mode=rectangle [gif]").
+		Arg("-map", "[gif]").
+		NoAudio().
+		Arg("-loop", "0"). // Loop forever
+		Output(outPath)
+
+	// Add progress callback (total duration is all clips back to back)
+	totalDuration := clipDurationSec * float64(len(timestamps))
+	cmd.WithProgress(totalDuration, func(percent float64, position string, speed string) {
+		log.Info("hover preview GIF progress",
+			"percent", fmt.Sprintf("%.1f%%", percent),
+			"position", position,
+			"speed", speed,
+		)
+	})
+
+	if err := cmd.Run(ctx); err != nil {
+		return fmt.Errorf("ffmpeg gif: %w", err)
+	}
+
+	log.Info("hover preview GIF complete")
 	return nil
 }
 
-func (t *FFmpegTranscoder) GenerateHoverPreview(ctx context.Context, inputPath, outWebM, outMP4 string, duration time.Duration, width int, fps int) error {
+// hoverPreviewDefaults fills in unset hover preview settings with the documented
+// HOVER_* defaults.
+func hoverPreviewDefaults(duration time.Duration, width int, fps int) (time.Duration, int, int) {
 	if duration <= 0 {
-		duration = 5 * time.Second
+		duration = config.DefaultHoverDurationSec * time.Second
 	}
 	if fps <= 0 {
-		fps = 24
+		fps = config.DefaultHoverFPS
 	}
 	if width <= 0 {
-		width = 480
+		width = config.DefaultHoverWidth
 	}
+	return duration, width, fps
+}
 
+// hoverPreviewTimestamps returns clip start times for the hover preview, pulled back
+// where a clip would run past the end of the video.
+func (t *FFmpegTranscoder) hoverPreviewTimestamps(ctx context.Context, inputPath string, duration time.Duration, clipCount int, fractions []float64) ([]float64, error) {
 	// Probe video to get total duration
-	info, err := ff.Probe(ctx, t.ffprobePath, inputPath)
+	info, err := t.probe(ctx, inputPath)
 	if err != nil {
 		log.Error("ffprobe failed for hover preview",
 			"file", inputPath,
 			"error", err,
 		)
-		return fmt.Errorf("probe: %w", err)
+		return nil, fmt.Errorf("probe: %w", err)
+	}
+	if isStill(info.DurationSec) {
+		return nil, fmt.Errorf("%w: %.3fs long", ErrTooShort, info.DurationSec)
 	}
 
-	// Calculate timestamps at 25%, 50%, and 75% of video duration
 	clipDurationSec := duration.Seconds()
-	
+
 	log.Info("calculating hover preview timestamps",
 		"video_duration_sec", info.DurationSec,
 		"clip_duration_sec", clipDurationSec,
+		"clip_count", clipCount,
 	)
-	
-	timestamps := []float64{
-		info.DurationSec * 0.25,
-		info.DurationSec * 0.50,
-		info.DurationSec * 0.75,
-	}
 
-	// Ensure clips don't exceed video duration
-	var adjustments []string
-	for i, ts := range timestamps {
-		original := ts
-		if ts+clipDurationSec > info.DurationSec {
-			timestamps[i] = math.Max(0, info.DurationSec-clipDurationSec)
-			adjustments = append(adjustments, 
-				fmt.Sprintf("clip%d: %.3f->%.3f (would exceed duration)", i, original, timestamps[i]))
-		}
-	}
-	
+	timestamps, adjustments := hoverClipStarts(info.DurationSec, clipDurationSec, clipCount, fractions)
+
 	if len(adjustments) > 0 {
 		log.Warn("adjusted hover preview timestamps", "adjustments", strings.Join(adjustments, "; "))
 	}
-	
-	log.Info("hover preview timestamps finalized",
-		"clip0_start", timestamps[0],
-		"clip1_start", timestamps[1],
-		"clip2_start", timestamps[2],
-	)
 
-	if outWebM != "" {
-		if err := os.MkdirAll(filepath.Dir(outWebM), 0o755); err != nil {
-			return fmt.Errorf("webm dir: %w", err)
+	log.Info("hover preview timestamps finalized", "clip_starts", timestamps)
+
+	return timestamps, nil
+}
+
+// hoverClipStarts places clips at the given fractions of the video, or evenly spaced
+// (25/50/75% for the default of three) when fractions is empty, and clamps any clip
+// that would run past the end. It also returns a description of each adjustment made.
+func hoverClipStarts(durationSec, clipDurationSec float64, clipCount int, fractions []float64) ([]float64, []string) {
+	if len(fractions) == 0 {
+		if clipCount <= 0 {
+			clipCount = defaultHoverClipCount
 		}
-		if err := t.generateHoverPreviewWebM(ctx, inputPath, outWebM, timestamps, clipDurationSec, width, fps); err != nil {
-			return err
+		fractions = make([]float64, clipCount)
+		for i := range fractions {
+			fractions[i] = float64(i+1) / float64(clipCount+1)
 		}
 	}
 
-	if outMP4 != "" {
-		if err := os.MkdirAll(filepath.Dir(outMP4), 0o755); err != nil {
-			return fmt.Errorf("mp4 dir: %w", err)
-		}
-		if err := t.generateHoverPreviewMP4(ctx, inputPath, outMP4, timestamps, clipDurationSec, width, fps); err != nil {
-			return err
+	timestamps := make([]float64, len(fractions))
+	var adjustments []string
+	for i, f := range fractions {
+		f = math.Min(math.Max(f, 0), 1)
+		ts := durationSec * f
+		// Ensure clips don't exceed video duration
+		if ts+clipDurationSec > durationSec {
+			clamped := math.Max(0, durationSec-clipDurationSec)
+			adjustments = append(adjustments,
+				fmt.Sprintf("clip%d: %.3f->%.3f (would exceed duration)", i, ts, clamped))
+			ts = clamped
 		}
+		timestamps[i] = ts
 	}
+	return timestamps, adjustments
+}
 
-	return nil
+// hoverPreviewFilter builds the filter_complex that extracts N clips and concatenates them, e.g. for three:
+// [0:v] split=3 [v0][v1][v2];
+// [v0] trim=start=T1:duration=D, setpts=PTS-STARTPTS, scale=W:-2, fps=FPS [clip0];
+// [v1] trim=start=T2:duration=D, setpts=PTS-STARTPTS, scale=W:-2, fps=FPS [clip1];
+// [v2] trim=start=T3:duration=D, setpts=PTS-STARTPTS, scale=W:-2, fps=FPS [clip2];
+// [clip0][clip1][clip2] concat=n=3:v=1:a=0 [out]
+func hoverPreviewFilter(timestamps []float64, clipDurationSec float64, width int, fps int) string {
+	trim := func(ts float64) string {
+		return fmt.Sprintf("trim=start=%.3f:duration=%.3f, setpts=PTS-STARTPTS, scale=%d:-2, fps=%d",
+			ts, clipDurationSec, width, fps)
+	}
+	if len(timestamps) == 1 {
+		return "[0:v] " + trim(timestamps[0]) + " [out]"
+	}
+
+	n := len(timestamps)
+	var splitOuts, concatIns strings.Builder
+	parts := make([]string, 0, n+2)
+	for i := range timestamps {
+		fmt.Fprintf(&splitOuts, "[v%d]", i)
+		fmt.Fprintf(&concatIns, "[clip%d]", i)
+	}
+	parts = append(parts, fmt.Sprintf("[0:v] split=%d %s", n, splitOuts.String()))
+	for i, ts := range timestamps {
+		parts = append(parts, fmt.Sprintf("[v%d] %s [clip%d]", i, trim(ts), i))
+	}
+	parts = append(parts, fmt.Sprintf("%s concat=n=%d:v=1:a=0 [out]", concatIns.String(), n))
+	return strings.Join(parts, "; ")
 }
 
 func (t *FFmpegTranscoder) generateHoverPreviewWebM(ctx context.Context, inputPath, outPath string, timestamps []float64, clipDurationSec float64, width int, fps int) error {
 	log.Info("generating hover preview WebM", "width", width, "fps", fps)
 
 	// Build complex filter to extract and concatenate clips
-	// [0:v] split=3 [v0][v1][v2];
-	// [v0] trim=start=T1:duration=D, setpts=PTS-STARTPTS, scale=W:-2, fps=FPS [clip0];
-	// [v1] trim=start=T2:duration=D, setpts=PTS-STARTPTS, scale=W:-2, fps=FPS [clip1];
-	// [v2] trim=start=T3:duration=D, setpts=PTS-STARTPTS, scale=W:-2, fps=FPS [clip2];
-	// [clip0][clip1][clip2] concat=n=3:v=1:a=0 [out]
-
-	filterComplex := fmt.Sprintf(
-		"[0:v] split=3 [v0][v1][v2]; "+
-			"[v0] trim=start=%.3f:duration=%.3f, setpts=PTS-STARTPTS, scale=%d:-2, fps=%d [clip0]; "+
-			"[v1] trim=start=%.3f:duration=%.3f, setpts=PTS-STARTPTS, scale=%d:-2, fps=%d [clip1]; "+
-			"[v2] trim=start=%.3f:duration=%.3f, setpts=PTS-STARTPTS, scale=%d:-2, fps=%d [clip2]; "+
-			"[clip0][clip1][clip2] concat=n=3:v=1:a=0 [out]",
-		timestamps[0], clipDurationSec, width, fps,
-		timestamps[1], clipDurationSec, width, fps,
-		timestamps[2], clipDurationSec, width, fps,
-	)
+	filterComplex := hoverPreviewFilter(timestamps, clipDurationSec, width, fps)
 
-	cmd := ff.New(t.ffmpegPath).
-		Overwrite(true).
-		Input(inputPath).
+	cmd := t.trimmedInput(t.command().Overwrite(true), inputPath).
 		Arg("-filter_complex", filterComplex).
 		Arg("-map", "[out]").
 		NoAudio().
@@ -537,10 +1798,11 @@ func (t *FFmpegTranscoder) generateHoverPreviewWebM(ctx context.Context, inputPa
 		Arg("-b:v", "0").
 		CRF(32).
 		Arg("-row-mt", "1").
+		PixFmt("yuv420p"). // no-op for 8-bit 4:2:0 sources
 		Output(outPath)
 
-	// Add progress callback (total duration is 3 clips)
-	totalDuration := clipDurationSec * 3
+	// Add progress callback (total duration is all clips back to back)
+	totalDuration := clipDurationSec * float64(len(timestamps))
 	cmd.WithProgress(totalDuration, func(percent float64, position string, speed string) {
 		log.Info("hover preview WebM progress",
 			"percent", fmt.Sprintf("%.1f%%", percent),
@@ -561,31 +1823,21 @@ func (t *FFmpegTranscoder) generateHoverPreviewMP4(ctx context.Context, inputPat
 	log.Info("generating hover preview MP4", "width", width, "fps", fps)
 
 	// Build complex filter to extract and concatenate clips
-	filterComplex := fmt.Sprintf(
-		"[0:v] split=3 [v0][v1][v2]; "+
-			"[v0] trim=start=%.3f:duration=%.3f, setpts=PTS-STARTPTS, scale=%d:-2, fps=%d [clip0]; "+
-			"[v1] trim=start=%.3f:duration=%.3f, setpts=PTS-STARTPTS, scale=%d:-2, fps=%d [clip1]; "+
-			"[v2] trim=start=%.3f:duration=%.3f, setpts=PTS-STARTPTS, scale=%d:-2, fps=%d [clip2]; "+
-			"[clip0][clip1][clip2] concat=n=3:v=1:a=0 [out]",
-		timestamps[0], clipDurationSec, width, fps,
-		timestamps[1], clipDurationSec, width, fps,
-		timestamps[2], clipDurationSec, width, fps,
-	)
+	filterComplex := hoverPreviewFilter(timestamps, clipDurationSec, width, fps)
 
-	cmd := ff.New(t.ffmpegPath).
-		Overwrite(true).
-		Input(inputPath).
+	cmd := t.trimmedInput(t.command().Overwrite(true), inputPath).
 		Arg("-filter_complex", filterComplex).
 		Arg("-map", "[out]").
 		NoAudio().
 		VideoCodec("libx264").
 		Preset(t.x264Preset).
 		CRF(28).
+		PixFmt("yuv420p"). // no-op for 8-bit 4:2:0 sources
 		Arg("-movflags", "+faststart").
 		Output(outPath)
 
-	// Add progress callback (total duration is 3 clips)
-	totalDuration := clipDurationSec * 3
+	// Add progress callback (total duration is all clips back to back)
+	totalDuration := clipDurationSec * float64(len(timestamps))
 	cmd.WithProgress(totalDuration, func(percent float64, position string, speed string) {
 		log.Info("hover preview MP4 progress",
 			"percent", fmt.Sprintf("%.1f%%", percent),
@@ -631,6 +1883,182 @@ func max[T ~int | ~float64](a, b T) T {
 	return b
 }
 
+// renditionName is the base name of r's playlist and segments, e.g. "v720" or "v720_vp9".
+func renditionName(r Rendition) string {
+	name := fmt.Sprintf("v%d", r.Height)
+	if short := r.Codec.shortName(); short != "" {
+		name += "_" + short
+	}
+	return name
+}
+
+// renditionThreads returns the encoder threads for r: its own Threads, else the
+// transcoder-wide setting, else an even share of cpus between the concurrent renditions
+// (at least one), so together they use about one thread per core.
+func renditionThreads(r Rendition, configured, cpus, concurrent int) int {
+	switch {
+	case r.Threads > 0:
+		return r.Threads
+	case configured > 0:
+		return configured
+	}
+	return max(cpus/max(concurrent, 1), 1)
+}
+
+// withAudioBitrate returns a copy of ladder with every rendition's audio bitrate set to kbps.
+func withAudioBitrate(ladder []Rendition, kbps int) []Rendition {
+	out := slices.Clone(ladder)
+	for i := range out {
+		out[i].AudioBitrateKbps = kbps
+	}
+	return out
+}
+
+// audioKbps is r's AAC bitrate, 128 when unset.
+func audioKbps(r Rendition) int {
+	if r.AudioBitrateKbps <= 0 {
+		return 128
+	}
+	return r.AudioBitrateKbps
+}
+
+// variantAttrs returns the EXT-X-STREAM-INF attributes of r known before it is encoded:
+// the peak bandwidth (configured or estimated from the height, plus audio), resolution
+// (at aspect when renditions are normalized to one, else the source's) and frame rate.
+// CODECS and AVERAGE-BANDWIDTH are measured on the output.
+func variantAttrs(r Rendition, src ff.ProbeInfo, aspect float64, audioGroup, subtitleGroup string) hls.StreamInfAttr {
+	bandwidth := r.VideoBitrateKbps
+	if bandwidth <= 0 {
+		bandwidth = estimateBitrateForHeight(r.Height)
+	}
+	bandwidth += audioKbps(r)
+	width := 0
+	if src.Width > 0 && src.Height > 0 && r.Height > 0 {
+		width = roundEven(int(float64(r.Height) * float64(src.Width) / float64(src.Height)))
+		if aspect > 0 {
+			width = aspectWidth(r.Height, aspect)
+		}
+	}
+	frameRate := r.FPS
+	if frameRate <= 0 {
+		frameRate = int(math.Round(src.AvgFrameRate))
+	}
+	return hls.StreamInfAttr{
+		Bandwidth:   bandwidth * 1000,
+		ResolutionW: max(width, 0),
+		ResolutionH: r.Height,
+		FrameRate:   float64(max(frameRate, 0)),
+		Audio:       audioGroup,
+		Subtitles:   subtitleGroup,
+	}
+}
+
+// setVideoEncoder adds r's encoder and rate control to cmd. CRF alone is constant
+// quality; a target bitrate caps the rendition at it.
+func (t *FFmpegTranscoder) setVideoEncoder(cmd *ff.Command, r Rendition) {
+	crf := codecCRF(r.Codec, r.CRF)
+	cmd.VideoCodec(r.Codec.encoder())
+	switch r.Codec {
+	case VideoCodecVP9:
+		// libvpx only treats -crf as constant quality with -b:v 0; a bitrate turns it
+		// into constrained quality capped at that rate
+		cmd.CRF(crf)
+		if r.VideoBitrateKbps > 0 {
+			cmd.VideoBitrateKbps(r.VideoBitrateKbps)
+		} else {
+			cmd.Arg("-b:v", "0")
+		}
+		cmd.Arg("-deadline", "good", "-cpu-used", "4", "-row-mt", "1")
+	case VideoCodecAV1:
+		cmd.CRF(crf).VideoBitrateKbps(r.VideoBitrateKbps).Arg("-cpu-used", "6", "-row-mt", "1")
+	case VideoCodecSVTAV1:
+		cmd.Preset("8").CRF(crf)
+		if r.VideoBitrateKbps > 0 {
+			cmd.MaxrateKbps(r.VideoBitrateKbps).BufsizeKbps(r.VideoBitrateKbps * 2)
+		}
+	default:
+		cmd.Preset(t.x264Preset).CRF(crf).Profile(r.Profile).Level(r.Level)
+		if r.VideoBitrateKbps > 0 {
+			cmd.VideoBitrateKbps(r.VideoBitrateKbps).
+				MaxrateKbps(r.VideoBitrateKbps).
+				BufsizeKbps(r.VideoBitrateKbps * 2)
+		}
+	}
+}
+
+// renditionPixFmt returns the pixel format a rendition is converted to, or "" to keep
+// the source's. 4:2:2/4:4:4 and high bit depth sources don't play in most browsers, so
+// everything but 8-bit yuv420p is reduced to it, except 10-bit video in a VP9/AV1
+// rendition when preserve10Bit is set. Tonemapped video is always 8-bit.
+func (t *FFmpegTranscoder) renditionPixFmt(src ff.ProbeInfo, codec VideoCodec, tonemap bool) string {
+	target := "yuv420p"
+	if t.preserve10Bit && !tonemap && !codec.isH264() && ff.PixFmtBitDepth(src.PixFmt) == 10 {
+		target = "yuv420p10le"
+	}
+	if src.PixFmt == "" || src.PixFmt == target {
+		return ""
+	}
+	return target
+}
+
+// validateProfileLevel checks r's H.264 profile and level, which other codecs don't take.
+func validateProfileLevel(r Rendition) error {
+	if r.Profile == "" && r.Level == "" {
+		return nil
+	}
+	if !r.Codec.isH264() {
+		return fmt.Errorf("profile/level are only supported for H.264, not %s", r.Codec)
+	}
+	if r.Profile != "" && !ff.ValidH264Profile(r.Profile) {
+		return fmt.Errorf("unknown H.264 profile %q (want one of %s)", r.Profile, strings.Join(ff.H264Profiles, ", "))
+	}
+	if r.Level != "" && !ff.ValidH264Level(r.Level) {
+		return fmt.Errorf("unknown H.264 level %q", r.Level)
+	}
+	return nil
+}
+
+// codecCRF maps an x264-scale CRF (0-51) onto the 0-63 scale of the VP9 and AV1
+// encoders. Zero is passed through so the encoder's default applies.
+func codecCRF(c VideoCodec, crf int) int {
+	if c.isH264() || crf <= 0 {
+		return crf
+	}
+	return min(int(math.Round(float64(crf)*63/51)), 63)
+}
+
+// variantCodecs probes a finished rendition playlist and returns its CODECS attribute,
+// so the profile/level advertised is what ffmpeg actually produced. With alternate
+// audio the rendition is video-only and the AAC LC audio group is added. Returns ""
+// (CODECS omitted) when the output can't be probed.
+func (t *FFmpegTranscoder) variantCodecs(ctx context.Context, playlistPath string, altAudio bool) string {
+	info, err := ff.Probe(ctx, t.ffprobePath, playlistPath)
+	if err != nil {
+		log.Warn("probe rendition for CODECS failed, omitting it", "playlist", playlistPath, "error", err)
+		return ""
+	}
+	codecs, ok := ff.HLSCodecs(info)
+	if !ok {
+		log.Warn("rendition codec not expressible as CODECS, omitting it",
+			"playlist", playlistPath, "codec", info.VideoCodec, "profile", info.VideoProfile, "level", info.VideoLevel)
+		return ""
+	}
+	if altAudio {
+		codecs += "," + ff.MP4ACodec("LC")
+	}
+	return codecs
+}
+
+// defaultGOP returns a ~2s keyframe interval in frames. With -sc_threshold 0 keyframes
+// land exactly every GOP, so the GOP must divide the segment for every segment to start
+// on one; odd segment durations fall back to a 1s GOP.
+func defaultGOP(fps, segSecs int) int {
+	if segSecs%2 != 0 {
+		return fps
+	}
+	return fps * 2
+}
+
 func defaultIfEmpty(s, def string) string {
 	if s == "" {
 		return def
@@ -1 +1,430 @@
-package transcoder_test
+package transcoder
+
+import (
+	"strings"
+	"testing"
+	"time"
+	ff "transcoder/pkg/ffmpeg"
+)
+
+func TestHoverClipStarts_DefaultThree(t *testing.T) {
+	got, adj := hoverClipStarts(100, 5, 0, nil)
+	want := []float64{25, 50, 75}
+	if len(got) != len(want) {
+		t.Fatalf("unexpected clip count: got %v want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("clip %d: got %.3f want %.3f", i, got[i], want[i])
+		}
+	}
+	if len(adj) != 0 {
+		t.Fatalf("expected no adjustments, got %v", adj)
+	}
+}
+
+func TestHoverClipStarts_ClampsToDuration(t *testing.T) {
+	got, adj := hoverClipStarts(10, 3, 0, []float64{0.9, 1.5})
+	for i, ts := range got {
+		if ts != 7 {
+			t.Fatalf("clip %d: got %.3f want 7.000", i, ts)
+		}
+	}
+	if len(adj) != 2 {
+		t.Fatalf("expected 2 adjustments, got %v", adj)
+	}
+}
+
+func TestHoverPreviewFilter_NClips(t *testing.T) {
+	starts, _ := hoverClipStarts(60, 3, 5, nil)
+	f := hoverPreviewFilter(starts, 3, 480, 24)
+	if !strings.HasPrefix(f, "[0:v] split=5 [v0][v1][v2][v3][v4]; ") {
+		t.Fatalf("unexpected split: %s", f)
+	}
+	if !strings.Contains(f, "[v0] trim=start=10.000:duration=3.000, setpts=PTS-STARTPTS, scale=480:-2, fps=24 [clip0]") {
+		t.Fatalf("unexpected first clip: %s", f)
+	}
+	if !strings.HasSuffix(f, "[clip0][clip1][clip2][clip3][clip4] concat=n=5:v=1:a=0 [out]") {
+		t.Fatalf("unexpected concat: %s", f)
+	}
+
+	single := hoverPreviewFilter([]float64{12}, 3, 480, 24)
+	if single != "[0:v] trim=start=12.000:duration=3.000, setpts=PTS-STARTPTS, scale=480:-2, fps=24 [out]" {
+		t.Fatalf("unexpected single-clip filter: %s", single)
+	}
+}
+
+func TestSceneCueStarts(t *testing.T) {
+	got := sceneCueStarts([]float64{0.4, 5, 5.5, 12, 30}, 0, 20, 0)
+	want := []float64{0, 5, 12}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+	}
+}
+
+func TestSceneCueStarts_Thinned(t *testing.T) {
+	scenes := []float64{10, 20, 30, 40, 50, 60, 70}
+	got := sceneCueStarts(scenes, 0, 100, 4)
+	if len(got) != 4 || got[0] != 0 {
+		t.Fatalf("got %v, want 4 starts beginning at 0", got)
+	}
+}
+
+func TestDefaultAudioTrack(t *testing.T) {
+	streams := []ff.AudioStream{{AudioIndex: 0, Language: "eng"}, {AudioIndex: 1, Language: "ger", Default: true}}
+	if got := defaultAudioTrack(streams); got != 1 {
+		t.Errorf("got %d, want 1", got)
+	}
+	streams[1].Default = false
+	if got := defaultAudioTrack(streams); got != 0 {
+		t.Errorf("got %d, want 0", got)
+	}
+}
+
+func TestWithAudioBitrate(t *testing.T) {
+	ladder := []Rendition{{Height: 1080, AudioBitrateKbps: 128}, {Height: 360, AudioBitrateKbps: 64}}
+	got := withAudioBitrate(ladder, 128)
+	if got[1].AudioBitrateKbps != 128 || got[1].Height != 360 {
+		t.Errorf("got %+v, want every rendition at 128 kbps", got)
+	}
+	if ladder[1].AudioBitrateKbps != 64 {
+		t.Errorf("input ladder modified: %+v", ladder)
+	}
+	// Variants advertise the shared track's bitrate in BANDWIDTH
+	if bw := variantAttrs(got[1], ff.ProbeInfo{}, 0, audioGroupID, "").Bandwidth; bw != (estimateBitrateForHeight(360)+128)*1000 {
+		t.Errorf("bandwidth = %d", bw)
+	}
+}
+
+func TestRenditionThreads(t *testing.T) {
+	cases := []struct {
+		threads, configured, cpus, concurrent, want int
+	}{
+		{0, 0, 16, 2, 8},
+		{0, 0, 16, 3, 5},
+		{0, 0, 2, 4, 1},
+		{0, 0, 8, 0, 8},
+		{0, 6, 16, 2, 6},
+		{3, 6, 16, 2, 3},
+	}
+	for _, c := range cases {
+		got := renditionThreads(Rendition{Threads: c.threads}, c.configured, c.cpus, c.concurrent)
+		if got != c.want {
+			t.Errorf("renditionThreads(%d, %d, %d cpus, %d concurrent) = %d, want %d",
+				c.threads, c.configured, c.cpus, c.concurrent, got, c.want)
+		}
+	}
+}
+
+func TestHLSPlanLayout(t *testing.T) {
+	cases := []struct {
+		r                           Rendition
+		dirs                        bool
+		playlist, segments, initSeg string
+	}{
+		{Rendition{Height: 720}, false, "v720.m3u8", "v720_%04d.ts", ""},
+		{Rendition{Height: 720, Codec: VideoCodecVP9}, false, "v720_vp9.m3u8", "v720_vp9_%04d.m4s", "v720_vp9_init.mp4"},
+		{Rendition{Height: 720}, true, "720/v720.m3u8", "720/seg_%04d.ts", ""},
+		{Rendition{Height: 720, Codec: VideoCodecVP9}, true, "720_vp9/v720_vp9.m3u8", "720_vp9/seg_%04d.m4s", "init.mp4"},
+	}
+	for _, c := range cases {
+		plan := &hlsPlan{renditionDirs: c.dirs}
+		playlist, segments, initSeg := plan.layout(c.r)
+		if playlist != c.playlist || segments != c.segments || initSeg != c.initSeg {
+			t.Errorf("layout(%dp %s, dirs=%v) = %q, %q, %q; want %q, %q, %q",
+				c.r.Height, c.r.Codec, c.dirs, playlist, segments, initSeg, c.playlist, c.segments, c.initSeg)
+		}
+	}
+}
+
+func TestDefaultGOP(t *testing.T) {
+	cases := []struct{ fps, seg, want int }{
+		{30, 4, 60},
+		{24, 6, 48},
+		{30, 5, 30},
+		{25, 3, 25},
+	}
+	for _, c := range cases {
+		g := defaultGOP(c.fps, c.seg)
+		if g != c.want {
+			t.Errorf("defaultGOP(%d, %d) = %d, want %d", c.fps, c.seg, g, c.want)
+		}
+		if (c.fps*c.seg)%g != 0 {
+			t.Errorf("GOP %d does not divide a %ds segment at %dfps", g, c.seg, c.fps)
+		}
+	}
+}
+
+func TestCodecCRF(t *testing.T) {
+	cases := []struct {
+		codec VideoCodec
+		crf   int
+		want  int
+	}{
+		{"", 23, 23},
+		{VideoCodecH264, 23, 23},
+		{VideoCodecVP9, 23, 28},
+		{VideoCodecSVTAV1, 51, 63},
+		{VideoCodecAV1, 0, 0},
+	}
+	for _, c := range cases {
+		if got := codecCRF(c.codec, c.crf); got != c.want {
+			t.Errorf("codecCRF(%q, %d) = %d, want %d", c.codec, c.crf, got, c.want)
+		}
+	}
+}
+
+func TestRenditionPixFmt(t *testing.T) {
+	tr := NewFFmpegTranscoder("", "")
+	cases := []struct {
+		pixFmt   string
+		codec    VideoCodec
+		preserve bool
+		want     string
+	}{
+		{"yuv420p", "", false, ""},
+		{"", "", false, ""},
+		{"yuv422p", "", false, "yuv420p"},
+		{"yuv420p10le", "", true, "yuv420p"},
+		{"yuv420p10le", VideoCodecVP9, false, "yuv420p"},
+		{"yuv420p10le", VideoCodecVP9, true, ""},
+		{"yuv444p10le", VideoCodecAV1, true, "yuv420p10le"},
+		{"yuv420p", VideoCodecAV1, true, ""},
+	}
+	for _, c := range cases {
+		tr.SetPreserve10Bit(c.preserve)
+		got := tr.renditionPixFmt(ff.ProbeInfo{PixFmt: c.pixFmt}, c.codec, false)
+		if got != c.want {
+			t.Errorf("renditionPixFmt(%q, %q, preserve=%v) = %q, want %q", c.pixFmt, c.codec, c.preserve, got, c.want)
+		}
+	}
+}
+
+func TestBestPosterFrame(t *testing.T) {
+	candidates := []ff.FrameStats{
+		{TimeSec: 25, YAvg: 8, YLow: 2, YHigh: 30},      // near-black
+		{TimeSec: 40, YAvg: 120, YLow: 115, YHigh: 125}, // blank
+		{TimeSec: 15, YAvg: 90, YLow: 30, YHigh: 160},
+		{TimeSec: 55, YAvg: 100, YLow: 20, YHigh: 200},
+	}
+	got, ok := bestPosterFrame(candidates)
+	if !ok || got.TimeSec != 55 {
+		t.Fatalf("got %+v %v, want the 55s frame", got, ok)
+	}
+	if _, ok := bestPosterFrame(candidates[:2]); ok {
+		t.Fatal("expected no usable frame")
+	}
+}
+
+func TestMosaicGrid(t *testing.T) {
+	tests := []struct {
+		n, w, h            int
+		wantCols, wantRows int
+	}{
+		{n: 100, w: 178, h: 100, wantCols: 7, wantRows: 15},
+		{n: 16, w: 100, h: 100, wantCols: 4, wantRows: 4},
+		{n: 3, w: 178, h: 100, wantCols: 1, wantRows: 3},
+		{n: 1, w: 178, h: 100, wantCols: 1, wantRows: 1},
+	}
+	for _, tt := range tests {
+		cols, rows := mosaicGrid(tt.n, tt.w, tt.h)
+		if cols != tt.wantCols || rows != tt.wantRows {
+			t.Errorf("mosaicGrid(%d, %d, %d) = %dx%d, want %dx%d", tt.n, tt.w, tt.h, cols, rows, tt.wantCols, tt.wantRows)
+		}
+		if cols*rows < tt.n {
+			t.Errorf("mosaicGrid(%d, ...) = %dx%d holds fewer than %d thumbnails", tt.n, cols, rows, tt.n)
+		}
+	}
+}
+
+func TestSinglePassFilter(t *testing.T) {
+	got := singlePassFilter([]string{"scale=-2:1080", ""}, nil, []int{1080, 720})
+	want := "[0:v:0]split=2[src0][src1];[src0]scale=-2:1080[v0];[src1]null[v1]"
+	if got != want {
+		t.Errorf("singlePassFilter = %q\nwant %q", got, want)
+	}
+
+	wm := &ff.Watermark{Corner: ff.TopLeft, MarginX: 5, MarginY: 6, Scale: 0.1}
+	got = singlePassFilter([]string{"scale=-2:720", "scale=-2:360"}, wm, []int{720, 360})
+	want = "[0:v:0]split=2[src0][src1];[1:v]split=2[wm0][wm1]" +
+		";[src0]scale=-2:720[base_v0];[wm0]format=rgba,scale=-1:72[logo_v0];[base_v0][logo_v0]overlay=5:6:format=auto[v0]" +
+		";[src1]scale=-2:360[base_v1];[wm1]format=rgba,scale=-1:36[logo_v1];[base_v1][logo_v1]overlay=5:6:format=auto[v1]"
+	if got != want {
+		t.Errorf("singlePassFilter with watermark = %q\nwant %q", got, want)
+	}
+}
+
+func TestFailedOutput(t *testing.T) {
+	cases := []struct {
+		stderr string
+		want   int
+		ok     bool
+	}{
+		{"[out#0/hls @ 0x55] video:1024KiB audio:64KiB\n[vost#2:0/libsvtav1 @ 0x56] Error while opening encoder - maybe incorrect parameters", 2, true},
+		{"Error initializing output stream 1:0 -- Error while opening encoder for output stream #1:0", 1, true},
+		{"[out#3/hls @ 0x57] Error opening output v1080_av1.m3u8: No space left on device", 3, true},
+		{"Stream #0:0 -> #1:0 (h264 (native) -> h264 (libx264))\nin.mp4: Invalid data found when processing input", 0, false},
+	}
+	for _, tc := range cases {
+		got, ok := failedOutput(tc.stderr)
+		if got != tc.want || ok != tc.ok {
+			t.Errorf("failedOutput(%q) = %d, %v, want %d, %v", tc.stderr, got, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestHLSCompatibleAudio(t *testing.T) {
+	ok := ff.AudioStream{Codec: "aac", Profile: "LC", Channels: 2, SampleRate: 48000, BitrateBps: 128000}
+	r := Rendition{AudioBitrateKbps: 128, AudioChannels: 2, AudioSampleRate: 48000}
+	if !hlsCompatibleAudio(ok, r) {
+		t.Errorf("stereo 48 kHz AAC-LC at 128 kbps not copyable into 128 kbps")
+	}
+	mono := ff.AudioStream{Codec: "aac", Profile: "LC", Channels: 1, SampleRate: 44100, BitrateBps: 64000}
+	if !hlsCompatibleAudio(mono, Rendition{AudioBitrateKbps: 64}) {
+		t.Errorf("mono 44.1 kHz AAC-LC not copyable into a rendition keeping the source's format")
+	}
+	cases := map[string]func(*ff.AudioStream){
+		"HE-AAC":          func(s *ff.AudioStream) { s.Profile = "HE-AAC" },
+		"AC-3":            func(s *ff.AudioStream) { s.Codec = "ac3" },
+		"5.1":             func(s *ff.AudioStream) { s.Channels = 6 },
+		"44.1 kHz":        func(s *ff.AudioStream) { s.SampleRate = 44100 },
+		"over bitrate":    func(s *ff.AudioStream) { s.BitrateBps = 192000 },
+		"unknown bitrate": func(s *ff.AudioStream) { s.BitrateBps = 0 },
+	}
+	for name, mod := range cases {
+		st := ok
+		mod(&st)
+		if hlsCompatibleAudio(st, r) {
+			t.Errorf("%s: copyable, want re-encode", name)
+		}
+	}
+
+	tr := NewFFmpegTranscoder("", "")
+	if tr.copiesAudio(ok, "", r) {
+		t.Errorf("copies audio with audio copy off")
+	}
+	tr.SetAudioCopy(true)
+	if !tr.copiesAudio(ok, "", r) {
+		t.Errorf("doesn't copy compatible audio with audio copy on")
+	}
+	if tr.copiesAudio(ok, "loudnorm", r) {
+		t.Errorf("copies audio that needs a filter")
+	}
+}
+
+func TestFitAspect(t *testing.T) {
+	cases := []struct {
+		name       string
+		w, h, outH int
+		mode       AspectMode
+		want       string
+	}{
+		{"crop letterboxed 4:3", 1440, 1080, 720, AspectCrop, "crop=1440:810:0:135,scale=1280:720,setsar=1"},
+		{"crop scope to 16:9", 1920, 800, 720, AspectCrop, "crop=1422:800:249:0,scale=1280:720,setsar=1"},
+		{"pillarbox 4:3", 1440, 1080, 720, AspectPad, "scale=960:720,pad=1280:720:160:0:color=black,setsar=1"},
+		{"letterbox scope", 1920, 800, 720, AspectPad, "scale=1280:534,pad=1280:720:0:93:color=black,setsar=1"},
+		{"already 16:9", 1920, 1080, 720, AspectPad, "scale=1280:720,setsar=1"},
+		{"source height", 1440, 1080, 0, AspectPad, "scale=1440:1080,pad=1920:1080:240:0:color=black,setsar=1"},
+	}
+	for _, tc := range cases {
+		fc := ff.NewFilterChain()
+		fitAspect(fc, tc.w, tc.h, tc.outH, 16.0/9, tc.mode)
+		if got := fc.String(); got != tc.want {
+			t.Errorf("%s: got %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestParseAspect(t *testing.T) {
+	for in, want := range map[string]float64{"16:9": 16.0 / 9, "4:3": 4.0 / 3, "2.39": 2.39} {
+		if got, err := ParseAspect(in); err != nil || got != want {
+			t.Errorf("ParseAspect(%q) = %v, %v, want %v", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"", "16:0", "wide", "-1", "16:x"} {
+		if _, err := ParseAspect(in); err == nil {
+			t.Errorf("ParseAspect(%q) succeeded, want error", in)
+		}
+	}
+}
+
+func TestFastStartRendition(t *testing.T) {
+	ladder := []Rendition{
+		{Height: 1080},
+		{Height: 360, Codec: VideoCodecVP9},
+		{Height: 0},
+		{Height: 480, VideoBitrateKbps: 1200},
+		{Height: 480, VideoBitrateKbps: 900},
+	}
+	if got := fastStartRendition(ladder); got != 4 {
+		t.Errorf("fastStartRendition = %d, want the lower bitrate 480p H.264 rendition (4)", got)
+	}
+	if got := fastStartRendition([]Rendition{{Height: 0, Codec: VideoCodecVP9}, {Height: 720, Codec: VideoCodecVP9}, {Height: 360, Codec: VideoCodecVP9}}); got != 2 {
+		t.Errorf("fastStartRendition without an H.264 rendition = %d, want the lowest (2)", got)
+	}
+}
+
+func TestAudioFormat(t *testing.T) {
+	cases := []struct {
+		name         string
+		r            Rendition
+		st           ff.AudioStream
+		channels, hz int
+	}{
+		{"explicit", Rendition{AudioChannels: 2, AudioSampleRate: 48000}, ff.AudioStream{Channels: 1, SampleRate: 44100}, 2, 48000},
+		{"keep mono 44.1 kHz", Rendition{}, ff.AudioStream{Channels: 1, SampleRate: 44100}, 1, 44100},
+		{"downmix surround", Rendition{}, ff.AudioStream{Channels: 6, SampleRate: 96000}, 2, 48000},
+		{"not probed", Rendition{}, ff.AudioStream{}, 2, 48000},
+		{"mono rendition", Rendition{AudioChannels: 1}, ff.AudioStream{Channels: 2, SampleRate: 48000}, 1, 48000},
+	}
+	for _, tc := range cases {
+		channels, hz := audioFormat(tc.r, tc.st)
+		if channels != tc.channels || hz != tc.hz {
+			t.Errorf("%s: got %d ch %d Hz, want %d ch %d Hz", tc.name, channels, hz, tc.channels, tc.hz)
+		}
+	}
+}
+
+func TestSharedAudioRendition(t *testing.T) {
+	ladder := []Rendition{
+		{AudioBitrateKbps: 128, AudioChannels: 2, AudioSampleRate: 48000},
+		{AudioBitrateKbps: 64, AudioChannels: 2, AudioSampleRate: 48000},
+	}
+	if got, want := sharedAudioRendition(ladder), (Rendition{AudioBitrateKbps: 128, AudioChannels: 2, AudioSampleRate: 48000}); got != want {
+		t.Errorf("sharedAudioRendition = %+v, want %+v", got, want)
+	}
+	ladder[1].AudioChannels, ladder[1].AudioSampleRate = 0, 0
+	if got, want := sharedAudioRendition(ladder), (Rendition{AudioBitrateKbps: 128}); got != want {
+		t.Errorf("sharedAudioRendition keeping the source's format = %+v, want %+v", got, want)
+	}
+}
+
+func TestThumbnailWindow(t *testing.T) {
+	tests := []struct {
+		name               string
+		start, end         time.Duration
+		wantStart, wantEnd float64
+	}{
+		{"whole video", 0, 0, 0, 100},
+		{"start only", 10 * time.Second, 0, 10, 100},
+		{"start and end", 10 * time.Second, 60 * time.Second, 10, 60},
+		{"end past duration", 10 * time.Second, 200 * time.Second, 10, 100},
+		{"negative start", -5 * time.Second, 60 * time.Second, 0, 60},
+		{"negative end", 10 * time.Second, -time.Second, 10, 100},
+		{"start after end", 60 * time.Second, 10 * time.Second, 0, 100},
+		{"start equals end", 30 * time.Second, 30 * time.Second, 0, 100},
+		{"start past duration", 200 * time.Second, 0, 0, 100},
+		{"fractional", 1500 * time.Millisecond, 2500 * time.Millisecond, 1.5, 2.5},
+	}
+	for _, tt := range tests {
+		start, end := thumbnailWindow(100, tt.start, tt.end)
+		if start != tt.wantStart || end != tt.wantEnd {
+			t.Errorf("%s: thumbnailWindow(100, %s, %s) = [%g, %g), want [%g, %g)", tt.name, tt.start, tt.end, start, end, tt.wantStart, tt.wantEnd)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package transcoder
+
+import (
+	ff "transcoder/pkg/ffmpeg"
+)
+
+// Metadata is the descriptive metadata written into the HLS outputs, where downstream
+// systems and players read it from the container.
+type Metadata struct {
+	Title string
+	// ISO 639 tag, e.g. "eng"; also given to audio tracks the source left untagged
+	Language string
+}
+
+// SetMetadataPassthrough makes TranscodeHLS copy the source's container title and
+// language into its outputs where the job's Metadata (see JobOptions) doesn't set them.
+func (t *FFmpegTranscoder) SetMetadataPassthrough(enable bool) {
+	t.metadataPassthrough = enable
+}
+
+// outputMetadata resolves the metadata of a transcode of src: the job's values first,
+// then the source's own tags when passthrough is on.
+func (t *FFmpegTranscoder) outputMetadata(src ff.ProbeInfo) Metadata {
+	md := t.job.Metadata
+	if t.metadataPassthrough {
+		md.Title = defaultIfEmpty(md.Title, src.Title)
+		md.Language = defaultIfEmpty(md.Language, src.Language)
+	}
+	return md
+}
+
+// audioLanguage picks the language an audio track is tagged and listed with: its own
+// tag from the source, else the output's language.
+func audioLanguage(st ff.AudioStream, md Metadata) string {
+	return defaultIfEmpty(st.Language, md.Language)
+}
+
+// setMetadata tags the output being built with md's title and language.
+func setMetadata(cmd *ff.Command, md Metadata) {
+	cmd.Metadata("title", md.Title).Metadata("language", md.Language)
+}
@@ -0,0 +1,36 @@
+package transcoder
+
+import (
+	"testing"
+	ff "transcoder/pkg/ffmpeg"
+)
+
+func TestOutputMetadata(t *testing.T) {
+	src := ff.ProbeInfo{Title: "Source title", Language: "fra"}
+	base := NewFFmpegTranscoder("", "")
+	job := JobOptions{Metadata: Metadata{Language: "eng"}}
+
+	tr := base.ForJob(job).(*FFmpegTranscoder)
+	if got := tr.outputMetadata(src); got != (Metadata{Language: "eng"}) {
+		t.Errorf("without passthrough = %+v, want only the job's language", got)
+	}
+	base.SetMetadataPassthrough(true)
+	tr = base.ForJob(job).(*FFmpegTranscoder)
+	want := Metadata{Title: "Source title", Language: "eng"}
+	if got := tr.outputMetadata(src); got != want {
+		t.Errorf("with passthrough = %+v, want %+v", got, want)
+	}
+	if got := base.outputMetadata(src); got != (Metadata{Title: "Source title", Language: "fra"}) {
+		t.Errorf("without job metadata = %+v, want the source's tags", got)
+	}
+}
+
+func TestAudioLanguage(t *testing.T) {
+	md := Metadata{Language: "eng"}
+	if got := audioLanguage(ff.AudioStream{Language: "spa"}, md); got != "spa" {
+		t.Errorf("tagged track = %q, want its own tag", got)
+	}
+	if got := audioLanguage(ff.AudioStream{}, md); got != "eng" {
+		t.Errorf("untagged track = %q, want the output's language", got)
+	}
+}
@@ -0,0 +1,136 @@
+package transcoder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	ff "transcoder/pkg/ffmpeg"
+)
+
+// probe runs ffprobe on a source file, reusing the result while the file is unchanged.
+// The result describes the range of the job's trim (see ForJob), if any; the cache holds the whole
+// source's.
+func (t *FFmpegTranscoder) probe(ctx context.Context, inputPath string) (ff.ProbeInfo, error) {
+	info, err := t.probeWhole(ctx, inputPath)
+	if err != nil {
+		return info, err
+	}
+	return t.job.Trim.apply(info)
+}
+
+// probeWhole is probe ignoring any trim, for sources other than the trimmed one (the
+// parts of a stitched source).
+func (t *FFmpegTranscoder) probeWhole(ctx context.Context, inputPath string) (ff.ProbeInfo, error) {
+	return t.probes.get(ctx, inputPath, func(ctx context.Context) (ff.ProbeInfo, error) {
+		return t.runProbe(ctx, inputPath)
+	})
+}
+
+// defaultProbeTimeout bounds an ffprobe run unless SetProbeTimeout says otherwise.
+const defaultProbeTimeout = time.Minute
+
+// runProbe runs ffprobe within the probe timeout. A source ffprobe can't read is
+// reported as ErrUnprobeable; a timeout is not, since a busy machine can cause one too.
+func (t *FFmpegTranscoder) runProbe(ctx context.Context, inputPath string) (ff.ProbeInfo, error) {
+	probeCtx, cancel := context.WithTimeout(ctx, t.probeTimeout)
+	defer cancel()
+	info, err := ff.Probe(probeCtx, t.ffprobePath, inputPath)
+	switch {
+	case errors.Is(err, ff.ErrInvalidInput):
+		return info, fmt.Errorf("%w: %w", ErrUnprobeable, err)
+	case err != nil && ctx.Err() == nil && errors.Is(probeCtx.Err(), context.DeadlineExceeded):
+		return info, fmt.Errorf("ffprobe timed out after %s: %w", t.probeTimeout, err)
+	}
+	return info, err
+}
+
+// probeCacheSize bounds the number of files whose probe results are kept. A job only
+// probes its source, so a handful covers every job a worker runs at once.
+const probeCacheSize = 16
+
+// probeCache memoizes ffprobe results so the tasks of a job, which each need the
+// source's streams, run ffprobe once. Entries are keyed by path, size and mod time,
+// so a file replaced or rewritten in place is probed again. Concurrent probes of the
+// same file share one ffprobe run; failures are not cached.
+type probeCache struct {
+	mu      sync.Mutex
+	entries map[probeKey]*probeEntry
+	order   []probeKey // cached keys, oldest first
+}
+
+type probeKey struct {
+	path    string
+	size    int64
+	modTime int64 // UnixNano
+}
+
+type probeEntry struct {
+	done chan struct{} // closed once info and err are set
+	info ff.ProbeInfo
+	err  error
+}
+
+func newProbeCache() *probeCache {
+	return &probeCache{entries: make(map[probeKey]*probeEntry)}
+}
+
+// get returns the cached result for path, or runs probe and caches it. Waiting on
+// another caller's probe returns early if ctx is cancelled; if that probe fails, the
+// wait is retried with this caller's own probe.
+func (c *probeCache) get(ctx context.Context, path string, probe func(ctx context.Context) (ff.ProbeInfo, error)) (ff.ProbeInfo, error) {
+	var key probeKey
+	if fi, err := os.Stat(path); err == nil {
+		key = probeKey{path: path, size: fi.Size(), modTime: fi.ModTime().UnixNano()}
+	} else if strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://") {
+		// A remote source's URL is presigned for the one job reading it
+		key = probeKey{path: path}
+	} else {
+		// Let ffprobe report the error
+		return probe(ctx)
+	}
+
+	for {
+		c.mu.Lock()
+		e, ok := c.entries[key]
+		if !ok {
+			e = &probeEntry{done: make(chan struct{})}
+			c.entries[key] = e
+			c.mu.Unlock()
+
+			e.info, e.err = probe(ctx)
+			c.mu.Lock()
+			if e.err != nil {
+				delete(c.entries, key)
+			} else {
+				c.add(key)
+			}
+			c.mu.Unlock()
+			close(e.done)
+			return e.info, e.err
+		}
+		c.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return ff.ProbeInfo{}, ctx.Err()
+		case <-e.done:
+		}
+		if e.err == nil {
+			return e.info, nil
+		}
+	}
+}
+
+// add records key as cached, evicting the oldest entries beyond probeCacheSize.
+// Callers hold c.mu.
+func (c *probeCache) add(key probeKey) {
+	c.order = append(c.order, key)
+	for len(c.order) > probeCacheSize {
+		delete(c.entries, c.order[0])
+		c.order = c.order[1:]
+	}
+}
@@ -0,0 +1,137 @@
+package transcoder
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	ff "transcoder/pkg/ffmpeg"
+)
+
+func TestProbeCache_ReusesUntilFileChanges(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "in.mp4")
+	if err := os.WriteFile(path, []byte("v1"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	calls := 0
+	probe := func(context.Context) (ff.ProbeInfo, error) {
+		calls++
+		return ff.ProbeInfo{Height: 720 + calls}, nil
+	}
+	c := newProbeCache()
+	ctx := context.Background()
+
+	for range 3 {
+		info, err := c.get(ctx, path, probe)
+		if err != nil || info.Height != 721 {
+			t.Fatalf("got %+v, %v; want cached height 721", info, err)
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("probe ran %d times, want 1", calls)
+	}
+
+	if err := os.WriteFile(path, []byte("v2 longer"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if info, _ := c.get(ctx, path, probe); info.Height != 722 || calls != 2 {
+		t.Fatalf("changed file not re-probed: height %d, calls %d", info.Height, calls)
+	}
+}
+
+func TestProbeCache_CachesURLs(t *testing.T) {
+	calls := 0
+	probe := func(context.Context) (ff.ProbeInfo, error) {
+		calls++
+		return ff.ProbeInfo{Height: 1080}, nil
+	}
+	c := newProbeCache()
+	for _, path := range []string{"https://minio:9000/b/in.mp4?sig=1", "https://minio:9000/b/in.mp4?sig=1", "/missing/in.mp4", "/missing/in.mp4"} {
+		if _, err := c.get(context.Background(), path, probe); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The URL is probed once; a missing local file is never cached
+	if calls != 3 {
+		t.Fatalf("probe ran %d times, want 3", calls)
+	}
+}
+
+func TestProbeCache_DoesNotCacheErrors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "in.mp4")
+	if err := os.WriteFile(path, []byte("v1"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	calls := 0
+	probe := func(context.Context) (ff.ProbeInfo, error) {
+		calls++
+		if calls == 1 {
+			return ff.ProbeInfo{}, errors.New("boom")
+		}
+		return ff.ProbeInfo{Height: 480}, nil
+	}
+	c := newProbeCache()
+	if _, err := c.get(context.Background(), path, probe); err == nil {
+		t.Fatal("expected first probe to fail")
+	}
+	if info, err := c.get(context.Background(), path, probe); err != nil || info.Height != 480 {
+		t.Fatalf("got %+v, %v; want a fresh probe", info, err)
+	}
+}
+
+func TestProbeCache_WaiterHonorsContext(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "in.mp4")
+	if err := os.WriteFile(path, []byte("v1"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	c := newProbeCache()
+	started, release := make(chan struct{}), make(chan struct{})
+	go c.get(context.Background(), path, func(context.Context) (ff.ProbeInfo, error) {
+		close(started)
+		<-release
+		return ff.ProbeInfo{}, nil
+	})
+	defer close(release)
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := c.get(ctx, path, func(context.Context) (ff.ProbeInfo, error) {
+		t.Error("waiter should not run its own probe")
+		return ff.ProbeInfo{}, nil
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v, want deadline exceeded", err)
+	}
+}
+
+func TestStillSource(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "photo.jpg")
+	if err := os.WriteFile(path, []byte("jpeg"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tr := NewFFmpegTranscoder("", "")
+	ctx := context.Background()
+	// An image probes as a video stream without duration
+	if _, err := tr.probes.get(ctx, path, func(context.Context) (ff.ProbeInfo, error) {
+		return ff.ProbeInfo{Width: 1200, Height: 800}, nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := tr.ProbeVideo(ctx, path)
+	if err != nil {
+		t.Fatalf("ProbeVideo: %v, want a still accepted", err)
+	}
+	if !info.IsStill() {
+		t.Errorf("zero-duration source not a still")
+	}
+	if _, err := tr.hoverPreviewTimestamps(ctx, path, 5*time.Second, 0, nil); !errors.Is(err, ErrTooShort) {
+		t.Errorf("hover preview of a still: %v, want ErrTooShort", err)
+	}
+	if (VideoInfo{DurationSec: 3}).IsStill() {
+		t.Errorf("3s clip counted as a still")
+	}
+}
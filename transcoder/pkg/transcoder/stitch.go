@@ -0,0 +1,145 @@
+package transcoder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	ff "transcoder/pkg/ffmpeg"
+
+	"github.com/charmbracelet/log"
+)
+
+// Stitched sources are re-encoded close to losslessly: the intermediate is transcoded
+// again by every task, so it shouldn't add visible loss of its own.
+const (
+	stitchCRF       = 16
+	stitchMaxFPS    = 60
+	stitchFPS       = 30 // when no part reports a frame rate
+	stitchAudioRate = 48000
+)
+
+// stitchPart is what the stitch filtergraph needs to know about one part.
+type stitchPart struct {
+	durationSec float64
+	hasAudio    bool
+}
+
+// Stitch joins parts, in order, into one continuous video at outPath (Matroska). Parts
+// may differ in resolution, frame rate and audio layout: every part is scaled and
+// letterboxed to the frame size of the largest one, converted to a common frame rate
+// and 8-bit 4:2:0, and parts without audio get silence if any other part has sound.
+// Parts are read whole; the job's trim (see ForJob) applies to the stitched result.
+func (t *FFmpegTranscoder) Stitch(ctx context.Context, parts []string, outPath string) error {
+	if len(parts) == 0 {
+		return errors.New("stitch: no parts")
+	}
+	infos := make([]ff.ProbeInfo, len(parts))
+	sparts := make([]stitchPart, len(parts))
+	var totalSec float64
+	for i, p := range parts {
+		info, err := t.probeWhole(ctx, p)
+		if err != nil {
+			return fmt.Errorf("probe part %d: %w", i+1, err)
+		}
+		if info.Width <= 0 || info.Height <= 0 {
+			return fmt.Errorf("%w: part %d has no video stream", ErrUnprobeable, i+1)
+		}
+		infos[i] = info
+		sparts[i] = stitchPart{durationSec: info.DurationSec, hasAudio: info.HasAudio}
+		totalSec += info.DurationSec
+	}
+	if err := os.MkdirAll(filepath.Dir(outPath), 0o755); err != nil {
+		return fmt.Errorf("create stitch dir: %w", err)
+	}
+
+	w, h, fps := stitchCanvas(infos)
+	graph, audio := stitchFilter(sparts, w, h, fps)
+
+	cmd := t.command().Overwrite(true)
+	for _, p := range parts {
+		cmd.Input(p)
+	}
+	cmd.Arg("-filter_complex", graph, "-map", "[v]")
+	if audio {
+		cmd.Arg("-map", "[a]").AudioCodec("flac")
+	}
+	cmd.Arg("-sn", "-dn").
+		VideoCodec("libx264").
+		Preset(t.x264Preset).
+		CRF(stitchCRF).
+		Format("matroska").
+		Output(outPath)
+	if totalSec > 0 {
+		cmd.WithProgress(totalSec, func(percent float64, position string, speed string) {
+			log.Info("stitch progress",
+				"percent", fmt.Sprintf("%.1f%%", percent),
+				"position", position,
+				"speed", speed,
+			)
+		})
+	}
+	if err := cmd.Run(ctx); err != nil {
+		return fmt.Errorf("ffmpeg stitch: %w", err)
+	}
+	log.Info("parts stitched", "parts", len(parts), "width", w, "height", h, "fps", fps, "audio", audio, "path", outPath)
+	return nil
+}
+
+// stitchCanvas picks the frame size and rate parts are normalized to: the size of the
+// part with the most pixels, rounded down to even dimensions, and the highest frame
+// rate among the parts, capped at stitchMaxFPS.
+func stitchCanvas(infos []ff.ProbeInfo) (w, h int, fps float64) {
+	for _, info := range infos {
+		if info.Width*info.Height > w*h {
+			w, h = info.Width, info.Height
+		}
+		fps = max(fps, info.AvgFrameRate)
+	}
+	if fps <= 0 {
+		fps = stitchFPS
+	}
+	return w &^ 1, h &^ 1, min(fps, stitchMaxFPS)
+}
+
+// stitchFilter builds the filtergraph joining parts into [v] (and [a], when audio is
+// true). Each part's first video stream is fitted into w x h, padded to it and set to
+// fps; audio is resampled to 48 kHz stereo, with parts lacking audio given silence of
+// their length so the tracks stay in sync.
+func stitchFilter(parts []stitchPart, w, h int, fps float64) (graph string, audio bool) {
+	for _, p := range parts {
+		audio = audio || p.hasAudio
+	}
+	var b, concat strings.Builder
+	for i, p := range parts {
+		fmt.Fprintf(&b, "[%d:v:0]scale=%d:%d:force_original_aspect_ratio=decrease,pad=%d:%d:(ow-iw)/2:(oh-ih)/2,setsar=1,fps=%s,format=yuv420p,setpts=PTS-STARTPTS[v%d];",
+			i, w, h, w, h, formatFPS(fps), i)
+		fmt.Fprintf(&concat, "[v%d]", i)
+		if !audio {
+			continue
+		}
+		if p.hasAudio {
+			fmt.Fprintf(&b, "[%d:a:0]aresample=%d,aformat=channel_layouts=stereo,asetpts=PTS-STARTPTS[a%d];",
+				i, stitchAudioRate, i)
+		} else {
+			// concat pads a short audio segment with silence up to its video's end, so a
+			// part of unknown length only needs a token amount
+			fmt.Fprintf(&b, "anullsrc=r=%d:cl=stereo,atrim=duration=%.3f[a%d];",
+				stitchAudioRate, max(p.durationSec, 0.001), i)
+		}
+		fmt.Fprintf(&concat, "[a%d]", i)
+	}
+	a, outs := 0, "[v]"
+	if audio {
+		a, outs = 1, "[v][a]"
+	}
+	fmt.Fprintf(&b, "%sconcat=n=%d:v=1:a=%d%s", concat.String(), len(parts), a, outs)
+	return b.String(), audio
+}
+
+// formatFPS renders a frame rate for the fps filter, e.g. "30" or "29.97".
+func formatFPS(fps float64) string {
+	return strings.TrimRight(strings.TrimRight(fmt.Sprintf("%.3f", fps), "0"), ".")
+}
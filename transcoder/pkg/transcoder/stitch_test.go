@@ -0,0 +1,43 @@
+package transcoder
+
+import (
+	"testing"
+	ff "transcoder/pkg/ffmpeg"
+)
+
+func TestStitchCanvas(t *testing.T) {
+	w, h, fps := stitchCanvas([]ff.ProbeInfo{
+		{Width: 1280, Height: 720, AvgFrameRate: 29.97},
+		{Width: 1081, Height: 1921, AvgFrameRate: 60},
+		{Width: 1920, Height: 1080, AvgFrameRate: 120},
+	})
+	if w != 1080 || h != 1920 || fps != 60 {
+		t.Errorf("canvas = %dx%d @ %v, want 1080x1920 @ 60", w, h, fps)
+	}
+	if _, _, fps := stitchCanvas([]ff.ProbeInfo{{Width: 640, Height: 360}}); fps != stitchFPS {
+		t.Errorf("fps without a reported rate = %v, want %d", fps, stitchFPS)
+	}
+}
+
+func TestStitchFilter(t *testing.T) {
+	graph, audio := stitchFilter([]stitchPart{
+		{durationSec: 10, hasAudio: true},
+		{durationSec: 4.5},
+	}, 1920, 1080, 29.97)
+	want := "[0:v:0]scale=1920:1080:force_original_aspect_ratio=decrease,pad=1920:1080:(ow-iw)/2:(oh-ih)/2,setsar=1,fps=29.97,format=yuv420p,setpts=PTS-STARTPTS[v0];" +
+		"[0:a:0]aresample=48000,aformat=channel_layouts=stereo,asetpts=PTS-STARTPTS[a0];" +
+		"[1:v:0]scale=1920:1080:force_original_aspect_ratio=decrease,pad=1920:1080:(ow-iw)/2:(oh-ih)/2,setsar=1,fps=29.97,format=yuv420p,setpts=PTS-STARTPTS[v1];" +
+		"anullsrc=r=48000:cl=stereo,atrim=duration=4.500[a1];" +
+		"[v0][a0][v1][a1]concat=n=2:v=1:a=1[v][a]"
+	if !audio || graph != want {
+		t.Errorf("stitchFilter = %q, %v\nwant %q, true", graph, audio, want)
+	}
+
+	graph, audio = stitchFilter([]stitchPart{{durationSec: 3}, {durationSec: 3}}, 640, 360, 30)
+	want = "[0:v:0]scale=640:360:force_original_aspect_ratio=decrease,pad=640:360:(ow-iw)/2:(oh-ih)/2,setsar=1,fps=30,format=yuv420p,setpts=PTS-STARTPTS[v0];" +
+		"[1:v:0]scale=640:360:force_original_aspect_ratio=decrease,pad=640:360:(ow-iw)/2:(oh-ih)/2,setsar=1,fps=30,format=yuv420p,setpts=PTS-STARTPTS[v1];" +
+		"[v0][v1]concat=n=2:v=1:a=0[v]"
+	if audio || graph != want {
+		t.Errorf("stitchFilter without audio = %q, %v\nwant %q, false", graph, audio, want)
+	}
+}
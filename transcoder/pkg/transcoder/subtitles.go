@@ -0,0 +1,82 @@
+package transcoder
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	ff "transcoder/pkg/ffmpeg"
+	hls "transcoder/pkg/hls"
+
+	"github.com/charmbracelet/log"
+)
+
+// subtitleGroupID is the EXT-X-MEDIA GROUP-ID shared by all subtitle renditions.
+const subtitleGroupID = "subs"
+
+// extractSubtitles converts the source's text subtitle streams (only those matching
+// t.subtitleLang, when set) to WebVTT with a one-segment media playlist each, and adds
+// them to mb. Streams that fail to convert are skipped: captions are an extra, not a
+// reason to fail the whole transcode.
+func (t *FFmpegTranscoder) extractSubtitles(ctx context.Context, inputPath, outDir string, src ff.ProbeInfo, mb *hls.MasterBuilder) []SubtitleTrack {
+	var tracks []SubtitleTrack
+	hasDefault := false
+	for _, st := range src.Subtitles {
+		if !st.IsText() {
+			log.Info("skipping bitmap subtitle stream", "index", st.Index, "codec", st.Codec, "language", st.Language)
+			continue
+		}
+		if t.subtitleLang != "" && !ff.LanguageMatches(st.Language, t.subtitleLang) {
+			continue
+		}
+
+		vttFile := fmt.Sprintf("subs_%d.vtt", st.SubIndex)
+		playlist := fmt.Sprintf("subs_%d.m3u8", st.SubIndex)
+		cmd := t.trimmedInput(t.command().Overwrite(true), inputPath).
+			Arg("-map", fmt.Sprintf("0:s:%d", st.SubIndex)).
+			Arg("-c:s", "webvtt").
+			Output(filepath.Join(outDir, vttFile))
+		if err := cmd.Run(ctx); err != nil {
+			log.Warn("subtitle extraction failed, skipping stream", "index", st.Index, "language", st.Language, "error", err)
+			continue
+		}
+		if err := os.WriteFile(filepath.Join(outDir, playlist), []byte(hls.SubtitlePlaylist(vttFile, src.DurationSec)), 0o644); err != nil {
+			log.Warn("write subtitle playlist failed, skipping stream", "index", st.Index, "error", err)
+			continue
+		}
+
+		name := subtitleName(st, len(tracks)+1)
+		// Only one rendition in a group may be DEFAULT
+		isDefault := st.Default && !hasDefault
+		hasDefault = hasDefault || isDefault
+		mb.AddSubtitleMedia(hls.SubtitleMedia{
+			GroupID:    subtitleGroupID,
+			Name:       name,
+			Language:   st.Language,
+			URI:        playlist,
+			Default:    isDefault,
+			Autoselect: true,
+			Forced:     st.Forced,
+		})
+		tracks = append(tracks, SubtitleTrack{
+			Language: st.Language,
+			Name:     name,
+			VTTFile:  vttFile,
+			Playlist: playlist,
+		})
+		log.Info("subtitle track extracted", "index", st.Index, "language", st.Language, "name", name)
+	}
+	return tracks
+}
+
+// subtitleName picks a player-facing name: the stream title, else its language tag,
+// else a numbered fallback.
+func subtitleName(st ff.SubtitleStream, n int) string {
+	switch {
+	case st.Title != "":
+		return st.Title
+	case st.Language != "":
+		return st.Language
+	}
+	return fmt.Sprintf("Subtitles %d", n)
+}
@@ -2,7 +2,9 @@ package transcoder
 
 import (
 	"context"
+	"errors"
 	"time"
+	ff "transcoder/pkg/ffmpeg"
 )
 
 // Rendition defines a single HLS output variant.
@@ -13,25 +15,302 @@ type Rendition struct {
 	FPS              int // 24/30; can be 0 to keep source
 	KeyframeInterval int // in frames (e.g., 48 for 24fps, ~2s)
 	CRF              int // e.g., 21–28; lower = higher quality
+	// H.264 only: profile ("baseline", "main" or "high") and level (e.g. "3.0") for
+	// older devices. Empty leaves them to libx264, which picks High and the lowest
+	// level that fits for 8-bit 4:2:0 sources.
+	Profile string
+	Level   string
+	// Encoder; empty means H.264 (libx264). CRF is given on the x264 scale and mapped
+	// to the encoder's own range.
+	Codec VideoCodec
+	// Encoder threads; 0 uses the transcoder's setting (see SetRenditionThreads)
+	Threads int
+	// Audio sample rate in Hz (e.g. 48000) and channel count (1 mono, 2 stereo); 0
+	// keeps the source's, so a mono or 44.1 kHz source isn't upmixed or resampled.
+	// Surround is still downmixed to stereo and rates above 48 kHz resampled to it.
+	AudioSampleRate int
+	AudioChannels   int
 }
 
+// VideoCodec is the ffmpeg encoder used for an HLS rendition.
+type VideoCodec string
+
+const (
+	// VideoCodecH264 encodes H.264 into MPEG-TS segments (default).
+	VideoCodecH264 VideoCodec = "libx264"
+	// VideoCodecVP9 encodes VP9 into fragmented MP4 segments.
+	VideoCodecVP9 VideoCodec = "libvpx-vp9"
+	// VideoCodecAV1 encodes AV1 with libaom into fragmented MP4 segments.
+	VideoCodecAV1 VideoCodec = "libaom-av1"
+	// VideoCodecSVTAV1 encodes AV1 with SVT-AV1 (much faster than libaom) into
+	// fragmented MP4 segments.
+	VideoCodecSVTAV1 VideoCodec = "libsvtav1"
+)
+
+// Valid reports whether c is a supported encoder; empty counts as H.264.
+func (c VideoCodec) Valid() bool {
+	switch c {
+	case "", VideoCodecH264, VideoCodecVP9, VideoCodecAV1, VideoCodecSVTAV1:
+		return true
+	}
+	return false
+}
+
+// isH264 reports whether c encodes H.264 (the default when empty).
+func (c VideoCodec) isH264() bool {
+	return c == "" || c == VideoCodecH264
+}
+
+// encoder returns the ffmpeg encoder name, resolving empty to libx264.
+func (c VideoCodec) encoder() string {
+	if c == "" {
+		return string(VideoCodecH264)
+	}
+	return string(c)
+}
+
+// shortName is the codec's tag in output file names ("" for H.264, whose files keep
+// their historical names).
+func (c VideoCodec) shortName() string {
+	switch c {
+	case VideoCodecVP9:
+		return "vp9"
+	case VideoCodecAV1, VideoCodecSVTAV1:
+		return "av1"
+	}
+	return ""
+}
+
+// ThumbnailMode selects how scrubber thumbnail times are chosen.
+type ThumbnailMode string
+
+const (
+	// ThumbnailModeInterval samples thumbnails at a fixed interval (default).
+	ThumbnailModeInterval ThumbnailMode = "interval"
+	// ThumbnailModeScene samples thumbnails at detected scene changes, falling back
+	// to interval sampling when too few scenes are found.
+	ThumbnailModeScene ThumbnailMode = "scene"
+)
+
+// ToneMapMode controls HDR-to-SDR conversion in TranscodeHLS.
+type ToneMapMode string
+
+const (
+	// ToneMapAuto tonemaps HDR (PQ/HLG) sources to SDR BT.709; SDR sources are untouched (default).
+	ToneMapAuto ToneMapMode = "auto"
+	// ToneMapOff re-encodes HDR sources without conversion.
+	ToneMapOff ToneMapMode = "off"
+)
+
+// SubtitleMode selects what TranscodeHLS does with embedded subtitle streams.
+type SubtitleMode string
+
+const (
+	// SubtitleModeOff drops embedded subtitles (default).
+	SubtitleModeOff SubtitleMode = "off"
+	// SubtitleModeBurn renders one subtitle stream into the video of every rendition.
+	SubtitleModeBurn SubtitleMode = "burn"
+	// SubtitleModePassthrough converts text subtitle streams to WebVTT and offers them
+	// as selectable EXT-X-MEDIA subtitle renditions in the master playlist.
+	SubtitleModePassthrough SubtitleMode = "passthrough"
+)
+
 type VideoInfo struct {
 	Width        int
 	Height       int
 	DurationSec  float64
 	AvgFrameRate float64
+	BitrateBps   int64 // 0 when unknown
+	HasAudio     bool
+	// Frames are unevenly spaced; TranscodeHLS converts such sources to a constant
+	// rate of about AvgFrameRate
+	VariableFrameRate bool
+}
+
+// stillDurationSec is the duration below which a source counts as a still: an image
+// upload (which probes with no duration) or a clip of a frame or two.
+const stillDurationSec = 0.5
+
+// IsStill reports whether the source is a still image or too short to take several
+// frames or clips from: it gets a single thumbnail and a poster of its first frame, and
+// no hover preview.
+func (v VideoInfo) IsStill() bool {
+	return isStill(v.DurationSec)
+}
+
+func isStill(durationSec float64) bool {
+	return durationSec < stillDurationSec
+}
+
+// LoudnessInfo holds the EBU R128 loudness measured on the source audio.
+type LoudnessInfo struct {
+	IntegratedLUFS float64
+	TruePeakDBTP   float64
+	LRA            float64
+}
+
+// SubtitleTrack is a WebVTT subtitle rendition written next to the HLS output.
+type SubtitleTrack struct {
+	Language string // as tagged in the source; may be empty
+	Name     string
+	VTTFile  string // relative to the HLS output directory
+	Playlist string // relative to the HLS output directory
+}
+
+// HLSResult describes what TranscodeHLS produced.
+type HLSResult struct {
+	// Loudness is set when loudness normalization measured the source; nil otherwise.
+	Loudness *LoudnessInfo
+	// Subtitles lists the subtitle renditions added in passthrough mode.
+	Subtitles []SubtitleTrack
+	// Variants lists the video renditions written, lowest bandwidth first.
+	Variants []VariantInfo
+}
+
+// VariantInfo describes a video rendition as advertised in the master playlist.
+type VariantInfo struct {
+	Height           int
+	Playlist         string
+	Bandwidth        int    // peak, bits per second
+	AverageBandwidth int    // measured from the segments; 0 when unknown
+	Codecs           string // empty when unknown
+}
+
+// ProgressFunc receives the completion percentage (0-100) of a transcoder operation.
+// Operations that take one accept nil when the caller doesn't follow their progress.
+type ProgressFunc func(percent float64)
+
+// report passes percent, clamped to 0-100, to fn if it is set.
+func (fn ProgressFunc) report(percent float64) {
+	if fn != nil {
+		fn(min(max(percent, 0), 100))
+	}
+}
+
+// scaled returns a ProgressFunc whose 0-100 reports map onto from-to of fn's, for an
+// operation that is one step of a larger one.
+func (fn ProgressFunc) scaled(from, to float64) ProgressFunc {
+	return func(percent float64) {
+		fn.report(from + percent*(to-from)/100)
+	}
+}
+
+// AudioFormat is an ExtractAudio output format.
+type AudioFormat string
+
+const (
+	AudioFormatAAC  AudioFormat = "aac"  // AAC in an .m4a container
+	AudioFormatMP3  AudioFormat = "mp3"  // MP3 via libmp3lame
+	AudioFormatOpus AudioFormat = "opus" // Opus in an Ogg container
+)
+
+// Ext returns the file extension (with leading dot) for the format, or "" if unknown.
+func (f AudioFormat) Ext() string {
+	switch f {
+	case AudioFormatAAC:
+		return ".m4a"
+	case AudioFormatMP3:
+		return ".mp3"
+	case AudioFormatOpus:
+		return ".opus"
+	}
+	return ""
+}
+
+// ImageFormat is the encoding of posters and scrubber thumbnails.
+type ImageFormat string
+
+const (
+	ImageFormatJPEG ImageFormat = "jpg"  // JPEG (default)
+	ImageFormatWebP ImageFormat = "webp" // lossy WebP via libwebp
+	ImageFormatAVIF ImageFormat = "avif" // AV1 still image via libaom-av1
+)
+
+// Ext returns the file extension (with leading dot) for the format, or "" if unknown.
+func (f ImageFormat) Ext() string {
+	switch f {
+	case ImageFormatJPEG:
+		return ".jpg"
+	case ImageFormatWebP:
+		return ".webp"
+	case ImageFormatAVIF:
+		return ".avif"
+	}
+	return ""
+}
+
+// SeekMode selects how frame grabs seek to their timestamp.
+type SeekMode = ff.SeekMode
+
+const (
+	SeekFast     = ff.SeekFast
+	SeekAccurate = ff.SeekAccurate
+)
+
+// ErrNoAudio is returned by ExtractAudio when the source has no audio stream.
+var ErrNoAudio = errors.New("source has no audio stream")
+
+// ErrTooShort is returned (wrapped) by the hover preview generators when the source is
+// a still (see VideoInfo.IsStill), which has no clips to take.
+var ErrTooShort = errors.New("source is too short for a hover preview")
+
+// ErrUnprobeable is returned (wrapped, with the reason) by ProbeVideo when the source
+// can't be read as a video: it is corrupt, truncated or has no video stream. Unlike
+// other probe failures, retrying won't help.
+var ErrUnprobeable = errors.New("source is not a readable video")
+
+// JobOptions are the settings of one job's operations on its source, given to
+// Transcoder.ForJob.
+type JobOptions struct {
+	// Trim limits every operation to part of the source: probes report its length and
+	// chapters, times passed in (poster and thumbnail windows) are relative to Trim.In,
+	// and every output covers exactly the range. The zero Trim is the whole source.
+	Trim Trim
+	// Metadata tags the HLS outputs, e.g. from the job record. Fields left empty fall
+	// back to the source's container tags when metadata passthrough is on (see
+	// FFmpegTranscoder.SetMetadataPassthrough).
+	Metadata Metadata
 }
 
 type Transcoder interface {
+	// ForJob returns a transcoder whose operations apply opts, for one job's work.
+	ForJob(opts JobOptions) Transcoder
 	// ProbeVideo returns information about the source video
 	ProbeVideo(ctx context.Context, inputPath string) (VideoInfo, error)
-	// TranscodeHLS writes variant playlists/segments into outDir following the ladder.
-	TranscodeHLS(ctx context.Context, inputPath, outDir string, ladder []Rendition) error
-	// GeneratePoster captures a single frame thumbnail at the given offset.
-	GeneratePoster(ctx context.Context, inputPath, outPath string, at time.Duration, width int) error
-	// GenerateThumbnailsAndVTT creates individual thumbnail images and a WebVTT file for scrubber previews.
+	// TranscodeHLS writes variant playlists/segments into outDir following the ladder,
+	// reporting its progress to progress.
+	TranscodeHLS(ctx context.Context, inputPath, outDir string, ladder []Rendition, progress ProgressFunc) (HLSResult, error)
+	// GeneratePoster captures a single frame thumbnail at the given offset, encoded
+	// according to outPath's extension (.jpg, .webp or .avif). SeekAccurate grabs the
+	// exact frame at the offset; SeekFast is cheaper but may land on a nearby keyframe.
+	GeneratePoster(ctx context.Context, inputPath, outPath string, at time.Duration, width int, seek SeekMode) error
+	// GenerateSmartPoster is GeneratePoster with the frame chosen by content: it samples
+	// several windows of the video and picks a representative frame that is not near-black
+	// or blank, falling back to the 25% point. Frame analysis is bounded to ~30s. A still
+	// (see VideoInfo.IsStill) gets its first frame.
+	GenerateSmartPoster(ctx context.Context, inputPath, outPath string, width int) error
+	// GenerateThumbnailsAndVTT creates thumbnail images and a WebVTT file for scrubber previews. Thumbnails
+	// are written as individual files or, when configured, packed into sprite sheets the cues point into.
 	// It automatically determines the interval based on video duration and calculates width from height.
-	GenerateThumbnailsAndVTT(ctx context.Context, inputPath, outDir, vttPath string, thumbHeight int, maxThumbnails int) error
+	// start/end optionally restrict thumbnails to a window of the video; zero values cover the full duration.
+	// A still (see VideoInfo.IsStill) gets a single thumbnail of its first frame. Progress is reported to progress.
+	GenerateThumbnailsAndVTT(ctx context.Context, inputPath, outDir, vttPath string, thumbHeight int, maxThumbnails int, start, end time.Duration, progress ProgressFunc) error
+	// GenerateChaptersVTT writes a WebVTT chapters track and JSON sidecar from embedded chapter markers.
+	// Sources without chapters are skipped.
+	GenerateChaptersVTT(ctx context.Context, inputPath, vttPath, jsonPath string) error
 	// GenerateHoverPreview creates a short muted teaser video in WebM/MP4.
-	GenerateHoverPreview(ctx context.Context, inputPath, outWebM, outMP4 string, duration time.Duration, width int, fps int) error
+	// The teaser concatenates clipCount clips of the given duration, evenly spaced (default 3 at 25/50/75%),
+	// or placed at explicit fractions (0-1) of the video when fractions is non-empty.
+	// Returns ErrTooShort, as do the WebP and GIF variants, for a still (see VideoInfo.IsStill).
+	GenerateHoverPreview(ctx context.Context, inputPath, outWebM, outMP4 string, duration time.Duration, width int, fps int, clipCount int, fractions []float64) error
+	// GenerateHoverPreviewWebP creates the same teaser as a looping animated WebP.
+	GenerateHoverPreviewWebP(ctx context.Context, inputPath, outPath string, duration time.Duration, width int, fps int, clipCount int, fractions []float64) error
+	// ExtractAudio writes the source's audio track to outPath as AAC (.m4a), MP3 or Opus.
+	// bitrateKbps <= 0 uses 192. Returns ErrNoAudio if the source has no audio.
+	ExtractAudio(ctx context.Context, inputPath, outPath string, format AudioFormat, bitrateKbps int) error
+	// GenerateHoverPreviewGIF creates the same teaser as a looping palette-optimized GIF.
+	GenerateHoverPreviewGIF(ctx context.Context, inputPath, outPath string, duration time.Duration, width int, fps int, clipCount int, fractions []float64) error
+	// Stitch joins the parts of a multi-part source, in order, into one video at outPath,
+	// normalizing them to a common resolution and frame rate.
+	Stitch(ctx context.Context, parts []string, outPath string) error
 }
@@ -0,0 +1,77 @@
+package transcoder
+
+import (
+	"errors"
+	"time"
+	ff "transcoder/pkg/ffmpeg"
+)
+
+// Trim limits an operation to part of its source. In and Out are offsets into the
+// source; a zero Out runs to the end.
+type Trim struct {
+	In  time.Duration
+	Out time.Duration
+}
+
+// IsZero reports whether tr leaves the source untouched.
+func (tr Trim) IsZero() bool {
+	return tr.In <= 0 && tr.Out <= 0
+}
+
+// length returns how much of a source lasting durationSec the trim keeps, or 0 when
+// it runs to the end of a source of unknown duration.
+func (tr Trim) length(durationSec float64) time.Duration {
+	end := secondsToDuration(durationSec)
+	if tr.Out > 0 && (end <= 0 || tr.Out < end) {
+		end = tr.Out
+	}
+	if end <= tr.In {
+		return 0
+	}
+	return end - tr.In
+}
+
+// span returns the length of the range to read from the source, or 0 when it runs to
+// the end.
+func (tr Trim) span() time.Duration {
+	if tr.Out <= tr.In {
+		return 0
+	}
+	return tr.Out - tr.In
+}
+
+// apply rewrites a probe of the whole source to describe only the trimmed range.
+func (tr Trim) apply(info ff.ProbeInfo) (ff.ProbeInfo, error) {
+	if tr.IsZero() {
+		return info, nil
+	}
+	length := tr.length(info.DurationSec)
+	if length <= 0 && (info.DurationSec > 0 || tr.Out > 0) {
+		return info, errors.New("trim leaves nothing of the source")
+	}
+	info.DurationSec = length.Seconds()
+
+	in, end := tr.In.Seconds(), length.Seconds()
+	var chapters []ff.Chapter
+	for _, ch := range info.Chapters {
+		start, stop := ch.StartSec-in, ch.EndSec-in
+		if stop <= 0 || (end > 0 && start >= end) {
+			continue
+		}
+		ch.StartSec = max(start, 0)
+		ch.EndSec = stop
+		if end > 0 {
+			ch.EndSec = min(stop, end)
+		}
+		chapters = append(chapters, ch)
+	}
+	info.Chapters = chapters
+	return info, nil
+}
+
+// trimmedInput adds inputPath to cmd as a source read from the job's trim in point to
+// its out point, the range every trimmed output covers.
+func (t *FFmpegTranscoder) trimmedInput(cmd *ff.Command, inputPath string) *ff.Command {
+	tr := t.job.Trim
+	return cmd.StartAt(tr.In).Duration(tr.span()).Input(inputPath)
+}
@@ -0,0 +1,49 @@
+package transcoder
+
+import (
+	"testing"
+	"time"
+	ff "transcoder/pkg/ffmpeg"
+)
+
+func TestTrim_Apply(t *testing.T) {
+	src := ff.ProbeInfo{
+		DurationSec: 120,
+		Chapters: []ff.Chapter{
+			{StartSec: 0, EndSec: 10, Title: "Intro"},
+			{StartSec: 10, EndSec: 60, Title: "Part 1"},
+			{StartSec: 60, EndSec: 115, Title: "Part 2"},
+			{StartSec: 115, EndSec: 120, Title: "Credits"},
+		},
+	}
+	got, err := Trim{In: 10 * time.Second, Out: 100 * time.Second}.apply(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.DurationSec != 90 {
+		t.Errorf("duration = %v, want 90", got.DurationSec)
+	}
+	want := []ff.Chapter{
+		{StartSec: 0, EndSec: 50, Title: "Part 1"},
+		{StartSec: 50, EndSec: 90, Title: "Part 2"},
+	}
+	if len(got.Chapters) != len(want) {
+		t.Fatalf("chapters = %+v, want %+v", got.Chapters, want)
+	}
+	for i := range want {
+		if got.Chapters[i] != want[i] {
+			t.Errorf("chapter %d = %+v, want %+v", i, got.Chapters[i], want[i])
+		}
+	}
+	if len(src.Chapters) != 4 || src.Chapters[1].StartSec != 10 {
+		t.Error("apply modified the source chapters")
+	}
+
+	// An out point past the end runs to the end
+	if got, _ := (Trim{In: 30 * time.Second, Out: time.Hour}).apply(src); got.DurationSec != 90 {
+		t.Errorf("duration = %v, want 90", got.DurationSec)
+	}
+	if _, err := (Trim{In: 130 * time.Second}).apply(src); err == nil {
+		t.Error("expected error for an in point past the end")
+	}
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+	"transcoder/pkg/hls"
+	"transcoder/pkg/storage"
+
+	"github.com/charmbracelet/log"
+)
+
+// hlsPublisher uploads HLS output while it is being encoded, so long videos become
+// playable before the encode finishes. It relies on the transcoder's progressive mode:
+// media playlists only list segments that are complete, and segments and playlists are
+// renamed into place, so nothing half-written is ever read.
+//
+// Each pass uploads, for every media playlist that changed, the segments it references
+// that aren't uploaded yet and then the playlist itself, so a published playlist never
+// points at a missing segment. The master playlist is published with only the variants
+// whose playlists are already up.
+//
+// With finishedOnly it serves fast start output (HLS_FAST_START without progressive
+// upload) instead: ffmpeg rewrites those media playlists in place while encoding, so only
+// the ones the local master already lists, which the transcoder adds once finished, are
+// published.
+type hlsPublisher struct {
+	s            storage.Backend
+	bucket       string
+	prefix       string
+	dir          string
+	scratchDir   string // where the master playlist is staged for upload
+	logger       *log.Logger
+	finishedOnly bool
+
+	uploaded  map[string]bool      // segments, by URI relative to dir
+	playlists map[string]time.Time // published media playlists and their mod time
+	master    string               // master playlist content last published
+
+	stop func()
+	done chan struct{}
+}
+
+func newHLSPublisher(s storage.Backend, bucket, prefix, dir, scratchDir string, logger *log.Logger) *hlsPublisher {
+	return &hlsPublisher{
+		s:          s,
+		bucket:     bucket,
+		prefix:     prefix,
+		dir:        dir,
+		scratchDir: scratchDir,
+		logger:     logger,
+		uploaded:   make(map[string]bool),
+		playlists:  make(map[string]time.Time),
+	}
+}
+
+// Start publishes every interval until ctx is cancelled or Stop is called.
+func (p *hlsPublisher) Start(ctx context.Context, interval time.Duration) {
+	ctx, cancel := context.WithCancel(ctx)
+	p.stop = cancel
+	p.done = make(chan struct{})
+	go func() {
+		defer close(p.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := p.Publish(ctx); err != nil && ctx.Err() == nil {
+					p.logger.Warn("progressive upload failed, retrying next pass", "error", err)
+				}
+			}
+		}
+	}()
+}
+
+// Stop ends the background passes and waits for the running one to finish.
+func (p *hlsPublisher) Stop() {
+	if p.stop != nil {
+		p.stop()
+		<-p.done
+	}
+}
+
+// Publish runs one pass over the output directory and its rendition directories.
+func (p *hlsPublisher) Publish(ctx context.Context) error {
+	paths, err := filepath.Glob(filepath.Join(p.dir, "*.m3u8"))
+	if err != nil {
+		return err
+	}
+	nested, err := filepath.Glob(filepath.Join(p.dir, "*", "*.m3u8"))
+	if err != nil {
+		return err
+	}
+	var listed map[string]bool
+	if p.finishedOnly {
+		if listed, err = p.listedPlaylists(); err != nil || listed == nil {
+			return err
+		}
+	}
+	for _, pl := range append(paths, nested...) {
+		if pl == filepath.Join(p.dir, "master.m3u8") {
+			continue
+		}
+		if rel, err := filepath.Rel(p.dir, pl); listed != nil && (err != nil || !listed[filepath.ToSlash(rel)]) {
+			continue
+		}
+		if err := p.publishMedia(ctx, pl); err != nil {
+			return err
+		}
+	}
+	return p.publishMaster(ctx)
+}
+
+// listedPlaylists returns the URIs of the media playlists the local master.m3u8 lists,
+// or nil before it is written.
+func (p *hlsPublisher) listedPlaylists() (map[string]bool, error) {
+	f, err := os.Open(filepath.Join(p.dir, "master.m3u8"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	mb, err := hls.ParseMaster(f)
+	f.Close()
+	if err != nil {
+		return nil, fmt.Errorf("master.m3u8: %w", err)
+	}
+	listed := make(map[string]bool)
+	for _, uri := range append(mb.VariantURIs(), mb.MediaURIs()...) {
+		listed[uri] = true
+	}
+	return listed, nil
+}
+
+// publishMedia uploads a media playlist, after its new segments, if it changed.
+func (p *hlsPublisher) publishMedia(ctx context.Context, playlistPath string) error {
+	// Keyed by URI relative to dir, as the master playlist refers to it
+	rel, err := filepath.Rel(p.dir, playlistPath)
+	if err != nil {
+		return err
+	}
+	name := filepath.ToSlash(rel)
+	// Stat before reading: a rewrite in between is picked up by the next pass
+	fi, err := os.Stat(playlistPath)
+	if err != nil {
+		return err
+	}
+	if last, ok := p.playlists[name]; ok && fi.ModTime().Equal(last) {
+		return nil
+	}
+	f, err := os.Open(playlistPath)
+	if err != nil {
+		return err
+	}
+	uris, err := hls.ReferencedURIs(f)
+	f.Close()
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	for _, uri := range uris {
+		// Segment URIs are relative to their playlist
+		seg := path.Join(path.Dir(name), uri)
+		if p.uploaded[seg] {
+			continue
+		}
+		if err := p.upload(ctx, filepath.Join(p.dir, filepath.FromSlash(seg)), seg); err != nil {
+			return err
+		}
+		p.uploaded[seg] = true
+	}
+	if err := p.upload(ctx, playlistPath, name); err != nil {
+		return err
+	}
+	p.playlists[name] = fi.ModTime()
+	return nil
+}
+
+// publishMaster uploads the master playlist restricted to the variants already
+// published, once all its alternate audio/subtitle playlists are up.
+func (p *hlsPublisher) publishMaster(ctx context.Context) error {
+	f, err := os.Open(filepath.Join(p.dir, "master.m3u8"))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	mb, err := hls.ParseMaster(f)
+	f.Close()
+	if err != nil {
+		return fmt.Errorf("master.m3u8: %w", err)
+	}
+	published := func(uri string) bool {
+		_, ok := p.playlists[uri]
+		return ok
+	}
+	for _, uri := range mb.MediaURIs() {
+		if !published(uri) {
+			return nil
+		}
+	}
+	if !slices.ContainsFunc(mb.VariantURIs(), published) {
+		return nil
+	}
+	content := mb.RetainVariants(published).String()
+	if content == p.master {
+		return nil
+	}
+
+	tmp, err := os.CreateTemp(p.scratchDir, "master-*.m3u8")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.WriteString(content)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if err := p.upload(ctx, tmp.Name(), "master.m3u8"); err != nil {
+		return err
+	}
+	p.master = content
+	p.logger.Info("published master playlist", "variants", len(mb.VariantURIs()))
+	return nil
+}
+
+// isHLSOutput reports whether rel, relative to the output directory, is written by
+// TranscodeHLS and so published by hlsPublisher: playlists, media and fMP4 init
+// segments, subtitle tracks and in-progress temp files, flat or one level down in a
+// rendition directory (RENDITION_DIRS).
+func isHLSOutput(rel string) bool {
+	dir, name := filepath.Split(rel)
+	if strings.Count(dir, string(filepath.Separator)) > 1 {
+		return false
+	}
+	switch filepath.Ext(name) {
+	case ".m3u8", ".ts", ".m4s", ".tmp":
+		return true
+	case ".mp4":
+		return strings.HasSuffix(name, "_init.mp4") || (dir != "" && name == "init.mp4")
+	case ".vtt":
+		return dir == "" && strings.HasPrefix(name, "subs_")
+	}
+	return false
+}
+
+func (p *hlsPublisher) upload(ctx context.Context, localPath, rel string) error {
+	if err := p.s.UploadFile(ctx, localPath, p.bucket, path.Join(p.prefix, rel)); err != nil {
+		return fmt.Errorf("upload %s: %w", rel, err)
+	}
+	return nil
+}
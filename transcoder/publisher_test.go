@@ -0,0 +1,49 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestIsHLSOutput(t *testing.T) {
+	tests := []struct {
+		rel  string
+		want bool
+	}{
+		// Flat layout
+		{"master.m3u8", true},
+		{"720p.m3u8", true},
+		{"720p_001.ts", true},
+		{"720p_001.m4s", true},
+		{"720p_init.mp4", true},
+		{"720p.m3u8.tmp", true},
+		{"subs_0.vtt", true},
+		{"subs_0.m3u8", true},
+		{"init.mp4", false},
+		{"source.mp4", false},
+		{"poster.jpg", false},
+		{"thumbnails.vtt", false},
+
+		// Rendition directories
+		{"720p/index.m3u8", true},
+		{"720p/seg_001.ts", true},
+		{"720p/seg_001.m4s", true},
+		{"720p/init.mp4", true},
+		{"720p/720p_init.mp4", true},
+		{"720p/index.m3u8.tmp", true},
+		{"720p/subs_0.vtt", false},
+		{"720p/video.mp4", false},
+
+		// Nested deeper than a rendition directory
+		{"a/b/index.m3u8", false},
+		{"a/b/seg_001.ts", false},
+		{"a/b/init.mp4", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.rel, func(t *testing.T) {
+			if got := isHLSOutput(filepath.FromSlash(tt.rel)); got != tt.want {
+				t.Errorf("isHLSOutput(%q) = %v, want %v", tt.rel, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+	"transcoder/pkg/queue"
+
+	"github.com/charmbracelet/log"
+)
+
+// queueStatsTTL is how long /queue serves the same stats. Every worker answers for the
+// whole queue, so autoscalers polling several of them every few seconds cost at most
+// one round of queries per worker per TTL.
+const queueStatsTTL = 2 * time.Second
+
+// queueDepth is the /queue response: the backlog an autoscaler sizes the worker pool by.
+type queueDepth struct {
+	Queued                 int     `json:"queued"`
+	Ready                  int     `json:"ready"` // queued and not waiting out a retry backoff
+	Running                int     `json:"running"`
+	OldestQueuedAgeSeconds float64 `json:"oldest_queued_age_seconds"`
+	RecentCompleted        int     `json:"recent_completed"` // last 5 minutes
+	RecentFailed           int     `json:"recent_failed"`    // last 5 minutes
+}
+
+// newQueueStatsHandler serves GET /queue with the queue's depth as JSON, caching it
+// for queueStatsTTL.
+func newQueueStatsHandler(sqlDB *sql.DB) http.HandlerFunc {
+	var (
+		mu        sync.Mutex
+		cached    queueDepth
+		fetchedAt time.Time
+	)
+	return func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		if time.Since(fetchedAt) >= queueStatsTTL {
+			ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+			stats, err := queue.GetQueueStats(ctx, sqlDB)
+			cancel()
+			if err != nil {
+				mu.Unlock()
+				log.Warn("queue stats failed", "error", err)
+				http.Error(w, "queue stats unavailable", http.StatusServiceUnavailable)
+				return
+			}
+			cached = queueDepth{
+				Queued:                 stats.Queued,
+				Ready:                  stats.Ready,
+				Running:                stats.Running,
+				OldestQueuedAgeSeconds: stats.OldestQueuedAge.Seconds(),
+				RecentCompleted:        stats.RecentCompleted,
+				RecentFailed:           stats.RecentFailed,
+			}
+			fetchedAt = time.Now()
+		}
+		depth := cached
+		mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		if err := json.NewEncoder(w).Encode(depth); err != nil {
+			log.Warn("write queue stats failed", "error", err)
+		}
+	}
+}
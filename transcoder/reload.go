@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"transcoder/pkg/config"
+	"transcoder/pkg/transcoder"
+
+	"github.com/charmbracelet/log"
+)
+
+// limiter is a counting semaphore whose limit can change while slots are held. Lowering
+// the limit never takes slots away: new acquisitions wait until enough are released.
+type limiter struct {
+	mu      sync.Mutex
+	limit   int
+	held    int
+	changed chan struct{} // closed (and replaced) when a slot frees or the limit changes
+}
+
+func newLimiter(limit int) *limiter {
+	return &limiter{limit: limit, changed: make(chan struct{})}
+}
+
+// Acquire takes a slot, waiting for one to free up. It reports false if ctx is done
+// first.
+func (l *limiter) Acquire(ctx context.Context) bool {
+	for {
+		l.mu.Lock()
+		if l.held < l.limit {
+			l.held++
+			l.mu.Unlock()
+			return true
+		}
+		changed := l.changed
+		l.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
+
+// Release hands back a slot taken by Acquire.
+func (l *limiter) Release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.held--
+	l.notify()
+}
+
+// SetLimit changes how many slots can be held at once.
+func (l *limiter) SetLimit(limit int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.limit = limit
+	l.notify()
+}
+
+// Held returns the number of slots currently taken, and a channel closed on the next
+// change, to wait for slots to be released.
+func (l *limiter) Held() (int, <-chan struct{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.held, l.changed
+}
+
+func (l *limiter) notify() {
+	close(l.changed)
+	l.changed = make(chan struct{})
+}
+
+// workerLimit returns the number of jobs to run at once: WORKER_CONCURRENCY, or the CPU
+// count (at least 2) when unset.
+func workerLimit(cfg *config.Config) int {
+	if cfg.WorkerConcurrency > 0 {
+		return cfg.WorkerConcurrency
+	}
+	return max(2, runtime.GOMAXPROCS(0))
+}
+
+// liveConfig holds the configuration and transcoder new jobs start with. A SIGHUP
+// replaces both; each job keeps the pair it was claimed with.
+type liveConfig struct {
+	cfg atomic.Pointer[config.Config]
+	ff  atomic.Pointer[transcoder.FFmpegTranscoder]
+}
+
+// reload re-reads the configuration and applies the settings that can change at
+// runtime: worker and per-job concurrency, rendition threads, the rendition ladder's
+// extra codec tiers and the encoding preset. Other changes need a restart and are logged, then ignored.
+func (lc *liveConfig) reload(sem *limiter) error {
+	loaded, err := config.Load()
+	if err != nil {
+		return err
+	}
+	cur := lc.cfg.Load()
+
+	next := *cur
+	next.WorkerConcurrency = loaded.WorkerConcurrency
+	next.MaxParallelTasksPerJob = loaded.MaxParallelTasksPerJob
+	next.MaxParallelRenditions = loaded.MaxParallelRenditions
+	next.RenditionThreads = loaded.RenditionThreads
+	next.X264Preset = loaded.X264Preset
+	next.ExtraVideoCodecs = loaded.ExtraVideoCodecs
+
+	for _, c := range next.ExtraVideoCodecs {
+		if !transcoder.VideoCodec(c).Valid() {
+			return fmt.Errorf("invalid EXTRA_VIDEO_CODECS entry %q", c)
+		}
+	}
+	ff := lc.ff.Load().Clone()
+	if err := ff.SetX264Preset(next.X264Preset); err != nil {
+		return fmt.Errorf("X264_PRESET: %w", err)
+	}
+	ff.SetMaxParallelRenditions(next.MaxParallelRenditions)
+	ff.SetRenditionThreads(next.RenditionThreads)
+
+	if ignored := changedSettings(&next, loaded); len(ignored) > 0 {
+		log.Warn("config reload: ignoring settings that need a restart", "settings", ignored)
+	}
+	lc.cfg.Store(&next)
+	lc.ff.Store(ff)
+	sem.SetLimit(workerLimit(&next))
+
+	log.Info("config reloaded",
+		"concurrency", workerLimit(&next),
+		"max_parallel_tasks_per_job", next.MaxParallelTasksPerJob,
+		"max_parallel_renditions", next.MaxParallelRenditions,
+		"rendition_threads", next.RenditionThreads,
+		"x264_preset", next.X264Preset,
+		"extra_video_codecs", next.ExtraVideoCodecs,
+	)
+	return nil
+}
+
+// changedSettings returns the environment variable names of the settings that differ
+// between a and b.
+func changedSettings(a, b *config.Config) []string {
+	va, vb := reflect.ValueOf(a).Elem(), reflect.ValueOf(b).Elem()
+	var names []string
+	for i := range va.NumField() {
+		f := va.Type().Field(i)
+		if !f.IsExported() || reflect.DeepEqual(va.Field(i).Interface(), vb.Field(i).Interface()) {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("env"), ",")
+		names = append(names, name)
+	}
+	return names
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+	"transcoder/pkg/config"
+)
+
+// acquireAsync starts an Acquire on l and returns a channel that receives its result.
+func acquireAsync(ctx context.Context, l *limiter) <-chan bool {
+	got := make(chan bool, 1)
+	go func() { got <- l.Acquire(ctx) }()
+	return got
+}
+
+// expectBlocked fails t if an acquisition completes within a short wait.
+func expectBlocked(t *testing.T, got <-chan bool) {
+	t.Helper()
+	select {
+	case ok := <-got:
+		t.Fatalf("Acquire returned %v, want it to block", ok)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+// expectAcquired fails t unless an acquisition completes with ok.
+func expectAcquired(t *testing.T, got <-chan bool, ok bool) {
+	t.Helper()
+	select {
+	case v := <-got:
+		if v != ok {
+			t.Fatalf("Acquire returned %v, want %v", v, ok)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Acquire still blocked")
+	}
+}
+
+func TestLimiter_ReleaseUnblocks(t *testing.T) {
+	ctx := context.Background()
+	l := newLimiter(1)
+	if !l.Acquire(ctx) {
+		t.Fatal("first Acquire failed")
+	}
+	got := acquireAsync(ctx, l)
+	expectBlocked(t, got)
+	l.Release()
+	expectAcquired(t, got, true)
+	if held, _ := l.Held(); held != 1 {
+		t.Errorf("held = %d, want 1", held)
+	}
+}
+
+func TestLimiter_SetLimitUnblocks(t *testing.T) {
+	ctx := context.Background()
+	l := newLimiter(1)
+	l.Acquire(ctx)
+	got := acquireAsync(ctx, l)
+	expectBlocked(t, got)
+	l.SetLimit(2)
+	expectAcquired(t, got, true)
+	if held, _ := l.Held(); held != 2 {
+		t.Errorf("held = %d, want 2", held)
+	}
+}
+
+func TestLimiter_ShrinkBelowHeld(t *testing.T) {
+	ctx := context.Background()
+	l := newLimiter(3)
+	for range 3 {
+		l.Acquire(ctx)
+	}
+	// Lowering the limit keeps the slots already held
+	l.SetLimit(1)
+	if held, _ := l.Held(); held != 3 {
+		t.Fatalf("held = %d after shrinking, want 3", held)
+	}
+	got := acquireAsync(ctx, l)
+	l.Release()
+	expectBlocked(t, got) // 2 held, limit 1
+	l.Release()
+	expectBlocked(t, got) // 1 held, limit 1
+	l.Release()
+	expectAcquired(t, got, true)
+}
+
+func TestLimiter_AcquireCancelled(t *testing.T) {
+	l := newLimiter(1)
+	l.Acquire(context.Background())
+	ctx, cancel := context.WithCancel(context.Background())
+	got := acquireAsync(ctx, l)
+	expectBlocked(t, got)
+	cancel()
+	expectAcquired(t, got, false)
+	if held, _ := l.Held(); held != 1 {
+		t.Errorf("held = %d after a cancelled Acquire, want 1", held)
+	}
+}
+
+func TestLimiter_HeldChangedChannel(t *testing.T) {
+	l := newLimiter(1)
+	l.Acquire(context.Background())
+	_, changed := l.Held()
+	l.Release()
+	select {
+	case <-changed:
+	default:
+		t.Fatal("Held channel not closed by Release")
+	}
+}
+
+func TestChangedSettings(t *testing.T) {
+	a := &config.Config{WorkerConcurrency: 2, X264Preset: "veryfast", ExtraVideoCodecs: []string{"libvpx-vp9"}}
+	b := *a
+	if got := changedSettings(a, &b); len(got) != 0 {
+		t.Errorf("identical configs: got %v, want none", got)
+	}
+
+	b.X264Preset = "slow"
+	b.ExtraVideoCodecs = []string{"libsvtav1"}
+	got := changedSettings(a, &b)
+	slices.Sort(got)
+	want := []string{"EXTRA_VIDEO_CODECS", "X264_PRESET"}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
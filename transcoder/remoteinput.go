@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"transcoder/pkg/storage"
+)
+
+// inputPresigner is implemented by storage backends that can hand out a URL ffmpeg
+// reads the source from directly (REMOTE_INPUT), instead of downloading it first.
+type inputPresigner interface {
+	PresignGet(ctx context.Context, bucket string, key string, ttl time.Duration) (storage.PresignedURL, error)
+}
+
+// remoteInputTTL is how long a presigned source URL stays valid when jobs have no time
+// limit. ffmpeg reads the source until the last task finishes, so it must outlast the
+// job.
+const remoteInputTTL = 12 * time.Hour
+
+// isURL reports whether a source path is a URL read over HTTP rather than a local file.
+func isURL(path string) bool {
+	return strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://")
+}
+
+// openSource gives random access to a job's source, a local file or a URL, along with
+// its size. The caller closes it.
+func openSource(ctx context.Context, path string) (io.ReaderAt, int64, io.Closer, error) {
+	if isURL(path) {
+		r := &httpRangeReader{ctx: ctx, url: path}
+		size, err := r.size()
+		if err != nil {
+			return nil, 0, nil, err
+		}
+		return r, size, r, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, 0, nil, err
+	}
+	return f, fi.Size(), f, nil
+}
+
+// httpRangeReader reads parts of an object over HTTP with Range requests, so looking
+// at a few bytes of a large remote source doesn't fetch all of it.
+type httpRangeReader struct {
+	ctx context.Context
+	url string
+}
+
+// ReadAt implements io.ReaderAt.
+func (r *httpRangeReader) ReadAt(p []byte, off int64) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	resp, err := r.get(off, off+int64(len(p))-1)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable:
+		return 0, io.EOF
+	case resp.StatusCode == http.StatusOK && off > 0:
+		return 0, errors.New("read source: server ignored the Range request")
+	}
+	n, err := io.ReadFull(resp.Body, p)
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		err = io.EOF
+	}
+	return n, err
+}
+
+// Close implements io.Closer; each read is a request of its own, so there's nothing to
+// release.
+func (r *httpRangeReader) Close() error { return nil }
+
+// size returns the object's size, from the Content-Range of a one-byte read.
+func (r *httpRangeReader) size() (int64, error) {
+	resp, err := r.get(0, 0)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		return resp.ContentLength, nil
+	}
+	_, total, ok := strings.Cut(resp.Header.Get("Content-Range"), "/")
+	size, err := strconv.ParseInt(total, 10, 64)
+	if !ok || err != nil {
+		return 0, fmt.Errorf("source size: unexpected Content-Range %q", resp.Header.Get("Content-Range"))
+	}
+	return size, nil
+}
+
+// get requests bytes first through last (inclusive) of the object.
+func (r *httpRangeReader) get(first, last int64) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(r.ctx, http.MethodGet, r.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", first, last))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		// The URL carries a signature; keep it out of logs and job errors
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return nil, fmt.Errorf("read source: %w", err)
+	}
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusPartialContent, http.StatusRequestedRangeNotSatisfiable:
+		return resp, nil
+	}
+	resp.Body.Close()
+	return nil, fmt.Errorf("read source: %s", resp.Status)
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"transcoder/pkg/config"
+	"transcoder/pkg/queue"
+	"transcoder/pkg/storage"
+
+	"github.com/charmbracelet/log"
+)
+
+// fingerprintChunk is how much of each end of the source sourceFingerprint hashes.
+const fingerprintChunk = 1 << 20
+
+// sourceFingerprint identifies a source's content cheaply: its size and a hash of its
+// first and last MiB. A re-upload under the same key changes it, without hashing a
+// multi-gigabyte file on every attempt. A remote source (REMOTE_INPUT) gets the same
+// fingerprint as its download would.
+func sourceFingerprint(ctx context.Context, path string) (string, error) {
+	r, size, closer, err := openSource(ctx, path)
+	if err != nil {
+		return "", err
+	}
+	defer closer.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, io.NewSectionReader(r, 0, min(size, fingerprintChunk))); err != nil {
+		return "", fmt.Errorf("hash source: %w", err)
+	}
+	if tail := size - fingerprintChunk; tail > fingerprintChunk {
+		if _, err := io.Copy(h, io.NewSectionReader(r, tail, fingerprintChunk)); err != nil {
+			return "", fmt.Errorf("hash source: %w", err)
+		}
+	}
+	return fmt.Sprintf("%d:%s", size, hex.EncodeToString(h.Sum(nil))), nil
+}
+
+// partsFingerprint is sourceFingerprint over every part of a source, in order. A
+// single-file source gets its own fingerprint.
+func partsFingerprint(ctx context.Context, paths []string) (string, error) {
+	fps := make([]string, len(paths))
+	for i, p := range paths {
+		fp, err := sourceFingerprint(ctx, p)
+		if err != nil {
+			return "", err
+		}
+		fps[i] = fp
+	}
+	return strings.Join(fps, ","), nil
+}
+
+// completedTasks returns which of tasks an earlier attempt of the job already finished,
+// by index, so a retry can skip them. Tasks of a source that changed since are never
+// reported done; a stitched source is compared by its parts, since stitching them again
+// needn't give the same bytes. With cfg.VerifySkippedTasks a task only counts as done
+// if its main artifact is still in storage.
+func completedTasks(ctx context.Context, sqlDB *sql.DB, s storage.Backend, cfg *config.Config, j *queue.TranscodeJob, inputPaths []string, tasks []Task, logger *log.Logger) ([]bool, error) {
+	done := make([]bool, len(tasks))
+	fingerprint, err := partsFingerprint(ctx, inputPaths)
+	if err != nil {
+		return done, fmt.Errorf("fingerprint source: %w", err)
+	}
+	statuses, err := queue.StartAttempt(ctx, sqlDB, j.ID, j.Attempts, fingerprint)
+	if err != nil {
+		return done, err
+	}
+	if !cfg.SkipCompletedTasks {
+		return done, nil
+	}
+
+	for i, task := range tasks {
+		done[i] = task.Completed(statuses)
+	}
+	if !cfg.VerifySkippedTasks {
+		return done, nil
+	}
+	for i, task := range tasks {
+		if !done[i] {
+			continue
+		}
+		key := path.Join(j.OutputPrefix, task.Artifact(cfg))
+		exists, err := s.FileExists(ctx, cfg.Bucket(), key)
+		if err != nil {
+			return done, fmt.Errorf("check %s: %w", key, err)
+		}
+		if !exists {
+			logger.Warn("output of a completed task is missing, running it again", "key", key)
+			done[i] = false
+		}
+	}
+	return done, nil
+}
@@ -0,0 +1,364 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+	"transcoder/pkg/config"
+	"transcoder/pkg/hls"
+	"transcoder/pkg/queue"
+	"transcoder/pkg/storage"
+	"transcoder/pkg/transcoder"
+
+	"github.com/charmbracelet/log"
+)
+
+// Task produces one artifact of a job (the HLS stream, the hover preview, ...).
+// processJob runs the tasks enabled by JOB_TASKS concurrently and tracks their status;
+// adding an artifact only takes a Task and an entry in availableTasks.
+type Task interface {
+	// ID names the task in JOB_TASKS and the status log
+	ID() string
+	// Name is the human-readable name used in logs, metrics and the job result
+	Name() string
+	// Weight is the task's share of the job's progress, relative to the other tasks
+	Weight() float64
+	// Artifact is the task's main output, relative to the output directory; a retry
+	// with VERIFY_SKIPPED_TASKS only skips the task while it is still in storage
+	Artifact(cfg *config.Config) string
+	// Run writes the task's output to env.outputPath and uploads it, reporting its
+	// progress (0-100)
+	Run(ctx context.Context, env *taskEnv, progress func(float64)) error
+	// UpdateStatus records the task's status on the job row, if the job is still
+	// running under the claim identified by attempt
+	UpdateStatus(ctx context.Context, db *sql.DB, jobID string, attempt int, status queue.ProcessingStatus) error
+	// Completed reports whether an earlier attempt of the job finished the task
+	Completed(statuses queue.TaskStatuses) bool
+}
+
+// availableTasks lists every task JOB_TASKS can enable.
+var availableTasks = []Task{hlsTask{}, hoverTask{}, thumbnailsTask{}, posterTask{}}
+
+// selectTasks returns the tasks named by ids, in that order.
+func selectTasks(ids []string) ([]Task, error) {
+	var tasks []Task
+	for _, id := range ids {
+		i := slices.IndexFunc(availableTasks, func(t Task) bool { return t.ID() == id })
+		if i < 0 {
+			known := make([]string, len(availableTasks))
+			for i, t := range availableTasks {
+				known[i] = t.ID()
+			}
+			return nil, fmt.Errorf("unknown task %q (want one of %s)", id, strings.Join(known, ", "))
+		}
+		if slices.Contains(tasks, availableTasks[i]) {
+			return nil, fmt.Errorf("task %q listed twice", id)
+		}
+		tasks = append(tasks, availableTasks[i])
+	}
+	if len(tasks) == 0 {
+		return nil, fmt.Errorf("no tasks enabled")
+	}
+	return tasks, nil
+}
+
+// taskIDs returns the IDs of tasks, for logging.
+func taskIDs(tasks []Task) []string {
+	ids := make([]string, len(tasks))
+	for i, t := range tasks {
+		ids[i] = t.ID()
+	}
+	return ids
+}
+
+// taskEnv is what a task gets to work with: the job, its downloaded source and the
+// directory its output goes to.
+type taskEnv struct {
+	job        *queue.TranscodeJob
+	cfg        *config.Config
+	t          transcoder.Transcoder
+	s          storage.Backend
+	inputPath  string
+	outputPath string
+	renditions []transcoder.Rendition
+	res        *JobResult
+	logger     *log.Logger
+}
+
+// sync uploads the output directory as soon as a task is done. HLS files are only
+// uploaded by the HLS task once validated (or, with progressive upload, by the
+// hlsPublisher, which uploads segments before the playlists listing them), never by the
+// other tasks mid-encode; the final sync catches anything missed.
+func (e *taskEnv) sync(ctx context.Context, task Task) {
+	e.logger.Info("syncing directory", "task", task.Name())
+	err := e.s.SyncDirectoryWithOptions(ctx, e.outputPath, e.cfg.Bucket(), e.job.OutputPrefix, storage.SyncOptions{Exclude: isHLSOutput})
+	if err != nil {
+		e.logger.Warn("sync failed, left to the final sync", "task", task.Name(), "error", err)
+		return
+	}
+	e.logger.Info("syncing directory complete", "task", task.Name())
+}
+
+// hlsTask transcodes the source to the HLS rendition ladder (usually the longest task).
+type hlsTask struct{}
+
+func (hlsTask) ID() string                     { return "hls" }
+func (hlsTask) Name() string                   { return "HLS transcode" }
+func (hlsTask) Weight() float64                { return 0.75 }
+func (hlsTask) Artifact(*config.Config) string { return "master.m3u8" }
+func (hlsTask) Completed(s queue.TaskStatuses) bool {
+	return s.HLS == queue.ProcessingStatusDone
+}
+
+func (hlsTask) UpdateStatus(ctx context.Context, db *sql.DB, jobID string, attempt int, status queue.ProcessingStatus) error {
+	return queue.UpdateHLSStatus(ctx, db, jobID, attempt, status)
+}
+
+func (hlsTask) Run(ctx context.Context, env *taskEnv, progress func(float64)) error {
+	taskStart := time.Now()
+	env.logger.Info("starting HLS transcode", "renditions", len(env.renditions))
+
+	// Start a heartbeat goroutine for long-running transcode
+	heartbeatDone := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(30 * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-heartbeatDone:
+				return
+			case <-ticker.C:
+				elapsed := time.Since(taskStart).Truncate(time.Second)
+				env.logger.Info("HLS transcode in progress", "elapsed", elapsed, "renditions", len(env.renditions))
+			}
+		}
+	}()
+
+	var publisher *hlsPublisher
+	if env.cfg.ProgressiveUpload || env.cfg.HLSFastStart {
+		publisher = newHLSPublisher(env.s, env.cfg.Bucket(), env.job.OutputPrefix, env.outputPath, env.cfg.ScratchDir(), env.logger)
+		publisher.finishedOnly = !env.cfg.ProgressiveUpload
+		publisher.Start(ctx, progressiveUploadInterval)
+	}
+	hlsResult, err := env.t.TranscodeHLS(ctx, env.inputPath, env.outputPath, env.renditions, progress)
+	close(heartbeatDone)
+	if err == nil {
+		// Checked on disk before the final upload, so a rendition that went missing
+		// or came out truncated fails the job instead of shipping a broken stream
+		if vErr := hls.ValidateOutput(env.outputPath); vErr != nil {
+			err = fmt.Errorf("validate HLS output: %w", vErr)
+		}
+	}
+	if publisher != nil {
+		publisher.Stop()
+		if err == nil {
+			// Final pass: the finished VOD playlists and the complete master
+			if pubErr := publisher.Publish(ctx); pubErr != nil {
+				env.logger.Warn("final progressive upload failed, left to the final sync", "error", pubErr)
+			}
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	if hlsResult.Loudness != nil {
+		env.logger.Info("source loudness",
+			"integrated_lufs", hlsResult.Loudness.IntegratedLUFS,
+			"true_peak_dbtp", hlsResult.Loudness.TruePeakDBTP,
+			"lra", hlsResult.Loudness.LRA,
+		)
+	}
+	env.res.setHLS(hlsResult)
+	for _, sub := range hlsResult.Subtitles {
+		env.logger.Info("subtitle track", "language", sub.Language, "name", sub.Name, "playlist", sub.Playlist)
+	}
+
+	if publisher == nil {
+		env.logger.Info("HLS syncing directory")
+		env.s.SyncDirectory(ctx, env.outputPath, env.cfg.Bucket(), env.job.OutputPrefix)
+		env.logger.Info("HLS syncing directory complete")
+	}
+	return nil
+}
+
+// hoverTask renders the hover preview clip as WebM/MP4, animated WebP and GIF.
+type hoverTask struct{}
+
+func (hoverTask) ID() string                     { return "hover" }
+func (hoverTask) Name() string                   { return "hover preview" }
+func (hoverTask) Weight() float64                { return 0.10 }
+func (hoverTask) Artifact(*config.Config) string { return "hover.webm" }
+func (hoverTask) Completed(s queue.TaskStatuses) bool {
+	return s.HoverPreview == queue.ProcessingStatusDone
+}
+
+func (hoverTask) UpdateStatus(ctx context.Context, db *sql.DB, jobID string, attempt int, status queue.ProcessingStatus) error {
+	return queue.UpdateHoverPreviewStatus(ctx, db, jobID, attempt, status)
+}
+
+func (task hoverTask) Run(ctx context.Context, env *taskEnv, progress func(float64)) error {
+	cfg := env.cfg
+	hoverDuration := time.Duration(cfg.HoverDurationSec) * time.Second
+	env.logger.Info("starting hover preview generation", "duration", hoverDuration, "width", cfg.HoverWidth, "fps", cfg.HoverFPS)
+	err := env.t.GenerateHoverPreview(
+		ctx, env.inputPath,
+		filepath.Join(env.outputPath, "hover.webm"), filepath.Join(env.outputPath, "hover.mp4"),
+		hoverDuration,
+		cfg.HoverWidth, cfg.HoverFPS,
+		0, nil, // Default clip placement
+	)
+	if errors.Is(err, transcoder.ErrTooShort) {
+		// Not an error: a still image has no clips to preview, the poster stands in
+		env.logger.Info("source is a still image or too short, skipping hover preview", "error", err)
+		return nil
+	}
+	if err == nil {
+		progress(100.0 / 3)
+		// Animated WebP for browsers that won't autoplay video previews
+		err = env.t.GenerateHoverPreviewWebP(
+			ctx, env.inputPath,
+			filepath.Join(env.outputPath, "hover.webp"),
+			hoverDuration,
+			480, 12,
+			0, nil, // Default clip placement
+		)
+	}
+	if err == nil {
+		progress(200.0 / 3)
+		// GIF for legacy surfaces that only accept images
+		err = env.t.GenerateHoverPreviewGIF(
+			ctx, env.inputPath,
+			filepath.Join(env.outputPath, "hover.gif"),
+			hoverDuration,
+			320, 10,
+			0, nil, // Default clip placement
+		)
+	}
+	if err != nil {
+		return err
+	}
+	env.sync(ctx, task)
+	return nil
+}
+
+// thumbnailsTask generates the scrubber thumbnails and the WebVTT file indexing them.
+type thumbnailsTask struct{}
+
+func (thumbnailsTask) ID() string                     { return "thumbnails" }
+func (thumbnailsTask) Name() string                   { return "thumbnails and VTT" }
+func (thumbnailsTask) Weight() float64                { return 0.10 }
+func (thumbnailsTask) Artifact(*config.Config) string { return "thumbnails.vtt" }
+func (thumbnailsTask) Completed(s queue.TaskStatuses) bool {
+	return s.ScrubberPreview == queue.ProcessingStatusDone
+}
+
+func (thumbnailsTask) UpdateStatus(ctx context.Context, db *sql.DB, jobID string, attempt int, status queue.ProcessingStatus) error {
+	return queue.UpdateScrubberPreviewStatus(ctx, db, jobID, attempt, status)
+}
+
+func (task thumbnailsTask) Run(ctx context.Context, env *taskEnv, progress func(float64)) error {
+	env.logger.Info("starting thumbnail generation")
+	err := env.t.GenerateThumbnailsAndVTT(
+		ctx, env.inputPath,
+		filepath.Join(env.outputPath, "thumbnails"),
+		filepath.Join(env.outputPath, "thumbnails.vtt"),
+		env.cfg.ThumbnailHeight, // Thumbnail height in pixels
+		env.cfg.MaxThumbnails,   // Maximum number of thumbnails (will be less for shorter videos)
+		0,                       // Window start (0 = from the beginning)
+		0,                       // Window end (0 = until the end)
+		progress,
+	)
+	if err != nil {
+		return err
+	}
+	env.sync(ctx, task)
+	return nil
+}
+
+// posterTask extracts the poster image, at 25% of the video or picked by SMART_POSTER,
+// plus a candidate poster at each fraction in POSTER_CANDIDATES for editorial selection.
+type posterTask struct{}
+
+func (posterTask) ID() string      { return "poster" }
+func (posterTask) Name() string    { return "25pct thumbnail" }
+func (posterTask) Weight() float64 { return 0.05 }
+func (posterTask) Completed(s queue.TaskStatuses) bool {
+	return s.Poster == queue.ProcessingStatusDone
+}
+
+func (posterTask) Artifact(cfg *config.Config) string {
+	return "thumb_25pct" + transcoder.ImageFormat(cfg.PosterFormat).Ext()
+}
+
+func (posterTask) UpdateStatus(ctx context.Context, db *sql.DB, jobID string, attempt int, status queue.ProcessingStatus) error {
+	return queue.UpdatePosterStatus(ctx, db, jobID, attempt, status)
+}
+
+func (task posterTask) Run(ctx context.Context, env *taskEnv, progress func(float64)) error {
+	env.logger.Info("starting 25pct thumbnail generation")
+	// Probe video info to get duration
+	info, err := env.t.ProbeVideo(ctx, env.inputPath)
+	if err != nil {
+		return fmt.Errorf("probe video: %w", err)
+	}
+	thumbPath := filepath.Join(env.outputPath, task.Artifact(env.cfg))
+	if env.cfg.SmartPoster {
+		err = env.t.GenerateSmartPoster(ctx, env.inputPath, thumbPath, 480)
+	} else {
+		err = env.t.GeneratePoster(ctx, env.inputPath, thumbPath, posterTime(info, 0.25), 480, transcoder.SeekAccurate)
+	}
+	if err != nil {
+		return err
+	}
+	// Candidate posters reuse the probe above rather than probing once per frame
+	ext := transcoder.ImageFormat(env.cfg.PosterFormat).Ext()
+	for i, f := range env.cfg.PosterCandidates {
+		progress(float64(i+1) / float64(len(env.cfg.PosterCandidates)+1) * 100)
+		path := filepath.Join(env.outputPath, posterCandidateName(f, ext))
+		if err := env.t.GeneratePoster(ctx, env.inputPath, path, posterTime(info, f), 480, transcoder.SeekAccurate); err != nil {
+			return fmt.Errorf("candidate poster at %g: %w", f, err)
+		}
+	}
+	env.sync(ctx, task)
+	return nil
+}
+
+// posterTime returns the time fraction of the way into the video, or the start of a
+// still (see VideoInfo.IsStill), where a seek could miss the only frame.
+func posterTime(info transcoder.VideoInfo, fraction float64) time.Duration {
+	if info.IsStill() {
+		return 0
+	}
+	return time.Duration(info.DurationSec * fraction * float64(time.Second))
+}
+
+// posterCandidateName names the candidate poster taken at fraction of the video after
+// its whole percentage (poster_10.jpg for 0.1), so a name always means the same point
+// whatever else is configured.
+func posterCandidateName(fraction float64, ext string) string {
+	return fmt.Sprintf("poster_%02d%s", int(math.Round(fraction*100)), ext)
+}
+
+// validatePosterCandidates checks that every POSTER_CANDIDATES fraction lies in [0, 1)
+// and that no two of them round to the same file name.
+func validatePosterCandidates(fractions []float64) error {
+	seen := make(map[string]float64, len(fractions))
+	for _, f := range fractions {
+		if f < 0 || f >= 1 {
+			return fmt.Errorf("%g is not a fraction of the duration in [0, 1)", f)
+		}
+		name := posterCandidateName(f, "")
+		if prev, ok := seen[name]; ok {
+			return fmt.Errorf("%g and %g both round to %s", prev, f, name)
+		}
+		seen[name] = f
+	}
+	return nil
+}